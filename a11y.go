@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Values of the accessibility config setting.
+const (
+	accessibilityAuto = "auto" // follow the OS screen-reader flag (default)
+	accessibilityOn   = "on"
+	accessibilityOff  = "off"
+)
+
+// accessibleText is whether menu and tooltip text is currently rendered in
+// the screen-reader friendly form. Refreshed on every update.
+var accessibleText atomic.Bool
+
+// accessible resolves the accessibility setting. A nil config yields auto.
+func (c *Config) accessible() bool {
+	var mode string
+	if c != nil {
+		mode = strings.ToLower(strings.TrimSpace(c.Accessibility))
+	}
+	switch mode {
+	case accessibilityOn:
+		return true
+	case accessibilityOff:
+		return false
+	default:
+		return screenReaderActive()
+	}
+}
+
+// refreshAccessibility re-reads the setting from config.json.
+func refreshAccessibility() {
+	cfg, _ := readConfigFile(configPath)
+	accessibleText.Store(cfg.accessible())
+}
+
+// statusMark is a short status indicator that is a glyph normally and a
+// word in accessible mode.
+type statusMark int
+
+const (
+	markOK statusMark = iota
+	markFailed
+	markWarning
+	markError
+)
+
+// mark returns the indicator for k, e.g. "✓" or "(done)".
+func mark(k statusMark, accessible bool) string {
+	glyphs := [...]string{"✓", "✗", "⚠", "!"}
+	words := [...]string{"(done)", "(failed)", "Warning:", "Error:"}
+	if accessible {
+		return words[k]
+	}
+	return glyphs[k]
+}
+
+// levelWord describes how close a bucket is to its limit, matching the icon
+// colors (amber below 50% remaining, red below 20%), so that state isn't
+// carried by color alone. Empty when there is plenty left.
+func levelWord(utilization float64) string {
+	remaining := 100 - int(utilization)
+	switch {
+	case remaining < 20:
+		return "nearly exhausted"
+	case remaining < 50:
+		return "running low"
+	default:
+		return ""
+	}
+}
+
+// spellReset is formatReset with the units written out for screen readers:
+// "in 2 hours 13 minutes" instead of "in 2h 13m".
+func spellReset(at time.Time) string {
+	if at.IsZero() {
+		return "?"
+	}
+	d := at.Sub(timeNow())
+	if d <= 0 {
+		return "soon"
+	}
+
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h >= 24 {
+		return "in " + plural(h/24, "day") + " " + plural(h%24, "hour")
+	}
+	if h > 0 {
+		return "in " + plural(h, "hour") + " " + plural(m, "minute")
+	}
+	return "in " + plural(m, "minute")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// renderTooltip formats the tray tooltip: "S:42% W:63%" normally, or
+// "Session 42 percent, resets in 2 hours 13 minutes. Weekly ..." in
+// accessible mode. A non-empty stale label is appended.
+func renderTooltip(usage *UsageResponse, stale string, accessible bool) string {
+	if !accessible {
+		tooltip := fmt.Sprintf("S:%d%% W:%d%%", int(usage.FiveHour.Utilization), int(usage.SevenDay.Utilization))
+		if stale != "" {
+			tooltip += " (" + stale + ")"
+		}
+		return tooltip
+	}
+
+	spoken := func(name string, b UsageBucket) string {
+		s := fmt.Sprintf("%s %d percent", name, int(b.Utilization))
+		if r := spellReset(resetTime(b.ResetsAt)); r != "?" {
+			s += ", resets " + r
+		}
+		return s + "."
+	}
+	tooltip := spoken("Session", usage.FiveHour) + " " + spoken("Weekly", usage.SevenDay)
+	if stale != "" {
+		tooltip += " As of " + stale + "."
+	}
+	return tooltip
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// screenReaderActive has no portable equivalent outside Windows; set
+// "accessibility": "on" in config.json instead.
+func screenReaderActive() bool {
+	return false
+}
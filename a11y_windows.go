@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import "unsafe"
+
+var procSystemParametersInfoW = user32.NewProc("SystemParametersInfoW")
+
+const spiGetScreenReader = 0x0046
+
+// screenReaderActive reports the SPI_GETSCREENREADER flag, which screen
+// readers such as Narrator and NVDA set while running.
+func screenReaderActive() bool {
+	var on int32
+	r, _, _ := procSystemParametersInfoW.Call(spiGetScreenReader, 0, uintptr(unsafe.Pointer(&on)), 0)
+	return r != 0 && on != 0
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/getlantern/systray"
+)
+
+// Account is one entry of the accounts array in config.json.
+type Account struct {
+	Name        string `json:"name"`
+	SessionKey  string `json:"session_key"`
+	OrgID       string `json:"org_id"`
+	CfClearance string `json:"cf_clearance,omitempty"`
+}
+
+// iconAccountWorst makes the tray icon show the highest usage across all
+// accounts instead of a single one.
+const iconAccountWorst = "worst"
+
+// maxAccounts is how many accounts get their own submenu; the slots are
+// created up front because systray items can't be removed.
+const maxAccounts = 6
+
+// validateAccounts trims and checks the accounts array in place.
+func validateAccounts(accounts []Account, iconAccount string) error {
+	if len(accounts) > maxAccounts {
+		return fmt.Errorf("at most %d accounts are supported, got %d", maxAccounts, len(accounts))
+	}
+	names := make(map[string]bool)
+	for i := range accounts {
+		a := &accounts[i]
+		a.Name = strings.TrimSpace(a.Name)
+		a.SessionKey = strings.TrimSpace(a.SessionKey)
+		a.OrgID = strings.TrimSpace(a.OrgID)
+		a.CfClearance = strings.TrimSpace(a.CfClearance)
+		if a.Name == "" {
+			a.Name = fmt.Sprintf("Account %d", i+1)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("accounts: duplicate name %q", a.Name)
+		}
+		names[a.Name] = true
+		if a.SessionKey == "" || strings.HasPrefix(a.SessionKey, "PASTE") {
+			return fmt.Errorf("accounts[%d] (%s): session_key not configured", i, a.Name)
+		}
+		if a.OrgID == "" || strings.HasPrefix(a.OrgID, "PASTE") {
+			return fmt.Errorf("accounts[%d] (%s): org_id not configured", i, a.Name)
+		}
+	}
+	if iconAccount != "" && iconAccount != iconAccountWorst && !names[iconAccount] {
+		return fmt.Errorf("icon_account %q is neither %q nor an account name", iconAccount, iconAccountWorst)
+	}
+	return nil
+}
+
+// accountConfigs returns one Config per monitored account: c itself for the
+// flat form, otherwise copies of c carrying each account's credentials.
+func (c *Config) accountConfigs() []*Config {
+	if len(c.Accounts) == 0 {
+		return []*Config{c}
+	}
+	out := make([]*Config, len(c.Accounts))
+	for i, a := range c.Accounts {
+		ac := *c
+		ac.Accounts = nil
+		ac.SessionKey = a.SessionKey
+		ac.OrgID = a.OrgID
+		ac.CfClearance = a.CfClearance
+		ac.accountIndex = i
+		ac.accountName = a.Name
+		out[i] = &ac
+	}
+	return out
+}
+
+// primaryAccount returns the index of the account whose data fills the
+// main menu rows, state.json and hooks: icon_account if it names one,
+// otherwise the first.
+func (c *Config) primaryAccount() int {
+	for i, a := range c.Accounts {
+		if a.Name == c.IconAccount {
+			return i
+		}
+	}
+	return 0
+}
+
+// accountResult is the outcome of one account's fetch.
+type accountResult struct {
+	usage      *UsageResponse
+	err        error
+	orgWarning string // set when the org_id looks wrong for the session
+	// orgNotes notifies about the mismatch behind orgWarning, once.
+	orgNotes []notification
+}
+
+// worstUsage combines the successful results into one response holding the
+// highest utilization of each bucket, for the "worst" icon mode.
+func worstUsage(results []accountResult) *UsageResponse {
+	var w *UsageResponse
+	worse := func(dst **UsageBucket, b *UsageBucket) {
+		if b != nil && (*dst == nil || b.Utilization > (*dst).Utilization) {
+			cp := *b
+			*dst = &cp
+		}
+	}
+	for _, r := range results {
+		if r.usage == nil {
+			continue
+		}
+		if w == nil {
+			w = &UsageResponse{FiveHour: r.usage.FiveHour, SevenDay: r.usage.SevenDay}
+		}
+		if r.usage.FiveHour.Utilization > w.FiveHour.Utilization {
+			w.FiveHour = r.usage.FiveHour
+		}
+		if r.usage.SevenDay.Utilization > w.SevenDay.Utilization {
+			w.SevenDay = r.usage.SevenDay
+		}
+		worse(&w.SevenDayOpus, r.usage.SevenDayOpus)
+		worse(&w.SevenDaySonnet, r.usage.SevenDaySonnet)
+	}
+	return w
+}
+
+// accountMenu is one account's submenu, e.g. "Work: S 42% · W 10%" with the
+// bucket rows underneath.
+type accountMenu struct {
+	parent                        *systray.MenuItem
+	session, weekly, opus, sonnet *systray.MenuItem
+
+	opusPresence, sonnetPresence *bucketPresence
+}
+
+// renderAccountTitle formats an account's submenu title, e.g.
+// "Work: S 42% · W 10%" or "Work: session 42 percent, weekly 10 percent".
+func renderAccountTitle(name string, u *UsageResponse, accessible bool) string {
+	if accessible {
+		return fmt.Sprintf("%s: session %d percent, weekly %d percent", name,
+			int(u.FiveHour.Utilization), int(u.SevenDay.Utilization))
+	}
+	return fmt.Sprintf("%s: S %d%% · W %d%%", name,
+		int(u.FiveHour.Utilization), int(u.SevenDay.Utilization))
+}
+
+// accountMenus holds the pre-created account submenus. They are only shown
+// when more than one account is configured.
+type accountMenus struct {
+	mu    sync.Mutex
+	slots []*accountMenu
+	names []string // account shown in each slot, to reset presence on change
+}
+
+func newAccountMenus() *accountMenus {
+	am := &accountMenus{}
+	for i := 0; i < maxAccounts; i++ {
+		parent := systray.AddMenuItem("", "")
+		a := &accountMenu{
+			parent:  parent,
+			session: parent.AddSubMenuItem("Session (5h): ...", ""),
+			weekly:  parent.AddSubMenuItem("Weekly: ...", ""),
+			opus:    parent.AddSubMenuItem("Opus: ...", ""),
+			sonnet:  parent.AddSubMenuItem("Sonnet: ...", ""),
+		}
+		for _, item := range []*systray.MenuItem{a.session, a.weekly, a.opus, a.sonnet} {
+			item.Disable()
+		}
+		parent.Hide()
+		am.slots = append(am.slots, a)
+		am.names = append(am.names, "")
+	}
+	return am
+}
+
+// accountView is what one account's submenu shows. Empty rows keep their
+// previous text, so a failed fetch only changes the title.
+type accountView struct {
+	title                         string
+	session, weekly, opus, sonnet string
+}
+
+// views renders one submenu per account, or none for a single account.
+// It tracks per-account bucket presence, so it must be called once per
+// update.
+func (am *accountMenus) views(accounts []*Config, results []accountResult) []accountView {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if len(accounts) < 2 {
+		for i := range am.names {
+			am.names[i] = ""
+		}
+		return nil
+	}
+	accessible := accessibleText.Load()
+	opts := bucketLineOpts{Accessible: accessible, ResetFormat: accounts[0].resetFormat()}
+	var views []accountView
+	for i, acc := range accounts {
+		if i >= len(am.slots) {
+			break
+		}
+		a := am.slots[i]
+		name := acc.accountName
+		if am.names[i] != name {
+			am.names[i] = name
+			a.opusPresence = &bucketPresence{name: name + " Opus"}
+			a.sonnetPresence = &bucketPresence{name: name + " Sonnet"}
+		}
+
+		r := results[i]
+		if r.err != nil {
+			views = append(views, accountView{
+				title: truncate(mark(markError, accessible)+" "+name+": "+r.err.Error(), maxMenuLine),
+			})
+			continue
+		}
+		u := r.usage
+		opus, _ := a.opusPresence.observe(u.SevenDayOpus)
+		sonnet, _ := a.sonnetPresence.observe(u.SevenDaySonnet)
+		views = append(views, accountView{
+			title:   renderAccountTitle(name, u, accessible),
+			session: renderBucketLine("Session (5h)", &u.FiveHour, opts),
+			weekly:  renderBucketLine("Weekly", &u.SevenDay, opts),
+			opus:    renderBucketLine("Opus", opus, opts),
+			sonnet:  renderBucketLine("Sonnet", sonnet, opts),
+		})
+	}
+	return views
+}
+
+// apply shows views in the submenu slots; called on the UI goroutine only.
+func (am *accountMenus) apply(views []accountView) {
+	for i, a := range am.slots {
+		if len(views) < 2 || i >= len(views) {
+			setShown(a.parent, false)
+			continue
+		}
+		v := views[i]
+		setTitle(a.parent, v.title)
+		for _, row := range []struct {
+			item *systray.MenuItem
+			text string
+		}{{a.session, v.session}, {a.weekly, v.weekly}, {a.opus, v.opus}, {a.sonnet, v.sonnet}} {
+			if row.text != "" {
+				setTitle(row.item, row.text)
+			}
+		}
+		setShown(a.parent, true)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+// Icon activation (middle-click / double-click on the tray icon) runs one
+// configurable action. getlantern/systray has no portable click API, so
+// platform files provide hookIconActivation where the gesture can be caught.
+
+const (
+	iconActionRefresh    = "refresh"
+	iconActionOpenClaude = "open_claude"
+	iconActionCopyStatus = "copy_status"
+	iconActionStatus     = "status_window"
+)
+
+// iconActions lists the selectable actions in menu order.
+var iconActions = []struct {
+	key   string
+	title string
+}{
+	{iconActionRefresh, "Refresh now"},
+	{iconActionOpenClaude, "Open claude.ai"},
+	{iconActionCopyStatus, "Copy status"},
+	{iconActionStatus, "Open status window"},
+}
+
+// normalizeIconAction maps an empty or unknown icon_action to the default.
+func normalizeIconAction(action string) string {
+	for _, a := range iconActions {
+		if a.key == action {
+			return action
+		}
+	}
+	if action != "" {
+		uiLog.Warnf("Unknown icon_action %q, using %q", action, iconActionRefresh)
+	}
+	return iconActionRefresh
+}
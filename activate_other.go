@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// hookIconActivation is not available here: the AppIndicator backend used by
+// systray on Linux doesn't forward Activate, and macOS always opens the menu.
+func hookIconActivation(activated chan<- struct{}) bool {
+	return false
+}
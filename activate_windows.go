@@ -0,0 +1,112 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	user32                       = syscall.NewLazyDLL("user32.dll")
+	procEnumWindows              = user32.NewProc("EnumWindows")
+	procGetWindowThreadProcessID = user32.NewProc("GetWindowThreadProcessId")
+	procGetClassNameW            = user32.NewProc("GetClassNameW")
+	procSetWindowLongPtrW        = user32.NewProc("SetWindowLongPtrW")
+	procCallWindowProcW          = user32.NewProc("CallWindowProcW")
+)
+
+const (
+	// systray registers its hidden window under this class and receives
+	// notification-icon events as WM_USER+1 with the mouse message in lParam.
+	systrayClassName = "SystrayClass"
+	wmSystrayMessage = 0x0400 + 1
+
+	wmLButtonUp     = 0x0202
+	wmLButtonDblClk = 0x0203
+	wmRButtonUp     = 0x0205
+	wmMButtonUp     = 0x0208
+)
+
+// gwlpWndProc is GWLP_WNDPROC (-4) as an unsigned pointer-sized value.
+var gwlpWndProc = ^uintptr(3)
+
+var (
+	origWndProc   uintptr
+	activationCh  chan<- struct{}
+	subclassProcC = syscall.NewCallback(subclassProc)
+)
+
+// hookIconActivation subclasses systray's hidden window so that middle-clicks
+// and double-clicks on the notification icon are delivered on activated.
+// Left/right single clicks keep opening the menu as before, and are
+// passed on to menuOpened.
+func hookIconActivation(activated chan<- struct{}) bool {
+	// The window is created on the systray thread just before onReady runs;
+	// allow a moment in case it isn't enumerable yet.
+	var hwnd uintptr
+	for i := 0; i < 10 && hwnd == 0; i++ {
+		if hwnd = findSystrayWindow(); hwnd == 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	if hwnd == 0 {
+		uiLog.Warnf("Icon activation: systray window not found")
+		return false
+	}
+
+	activationCh = activated
+	prev, _, err := procSetWindowLongPtrW.Call(hwnd, gwlpWndProc, subclassProcC)
+	if prev == 0 {
+		uiLog.Warnf("Icon activation: subclassing failed: %v", err)
+		return false
+	}
+	origWndProc = prev
+	return true
+}
+
+func subclassProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	if msg == wmSystrayMessage && (lParam == wmMButtonUp || lParam == wmLButtonDblClk) {
+		select {
+		case activationCh <- struct{}{}:
+		default: // an activation is already pending
+		}
+		return 0
+	}
+	if msg == wmSystrayMessage && (lParam == wmLButtonUp || lParam == wmRButtonUp) {
+		menuOpened()
+	}
+	r, _, _ := procCallWindowProcW.Call(origWndProc, hwnd, msg, wParam, lParam)
+	return r
+}
+
+// findSystrayWindow returns this process's systray window handle, or 0.
+func findSystrayWindow() uintptr {
+	enumFound = 0
+	procEnumWindows.Call(enumWindowsProcC, uintptr(os.Getpid()))
+	return enumFound
+}
+
+// enumFound receives the match from enumWindowsProc; the callback is created
+// once because Windows callbacks are a limited resource in Go.
+var (
+	enumFound        uintptr
+	enumWindowsProcC = syscall.NewCallback(enumWindowsProc)
+)
+
+func enumWindowsProc(hwnd, pid uintptr) uintptr {
+	var wpid uint32
+	procGetWindowThreadProcessID.Call(hwnd, uintptr(unsafe.Pointer(&wpid)))
+	if uintptr(wpid) != pid {
+		return 1 // continue
+	}
+	buf := make([]uint16, 64)
+	n, _, _ := procGetClassNameW.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if syscall.UTF16ToString(buf[:n]) == systrayClassName {
+		enumFound = hwnd
+		return 0 // stop
+	}
+	return 1
+}
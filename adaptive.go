@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Utilization marks, in percent, of any bucket that speed up adaptive
+	// polling, and below which all buckets must be for it to slow down.
+	adaptiveCritical  = 90
+	adaptiveHighWater = 80
+	adaptiveLowWater  = 20
+	adaptiveIdle      = 5
+	// adaptiveResetSoon is how close a reset has to be to poll fast, to
+	// show the freed-up limit soon after; adaptiveResetFar how far every
+	// reset has to be to poll slowly.
+	adaptiveResetSoon = 10 * time.Minute
+	adaptiveResetFar  = time.Hour
+)
+
+// adaptiveInterval picks the polling interval for adaptive_polling from
+// the latest reading: 1 minute with a bucket at adaptiveCritical or
+// above, 2 with one above adaptiveHighWater or a used bucket resetting
+// within adaptiveResetSoon, 30 or 15 when every bucket is below
+// adaptiveIdle or adaptiveLowWater and far from a reset, and 5 otherwise.
+// It also returns why, for the log.
+func adaptiveInterval(usage *UsageResponse, now time.Time) (time.Duration, string) {
+	if usage == nil {
+		return defaultUpdateInterval, "no reading"
+	}
+	buckets := []struct {
+		name string
+		b    *UsageBucket
+	}{
+		{"session", &usage.FiveHour},
+		{"weekly", &usage.SevenDay},
+		{"Opus", usage.SevenDayOpus},
+		{"Sonnet", usage.SevenDaySonnet},
+	}
+
+	var highest float64
+	var highestName string
+	soonest := time.Duration(-1)
+	var soonestName string
+	for _, bk := range buckets {
+		if bk.b == nil {
+			continue
+		}
+		if bk.b.Utilization > highest {
+			highest, highestName = bk.b.Utilization, bk.name
+		}
+		if t, ok := parseResetTime(bk.b.ResetsAt); ok && bk.b.Utilization > 0 {
+			if left := t.Sub(now); left > 0 && (soonest < 0 || left < soonest) {
+				soonest, soonestName = left, bk.name
+			}
+		}
+	}
+	resetSoon := soonest >= 0 && soonest <= adaptiveResetSoon
+	resetFar := soonest < 0 || soonest > adaptiveResetFar
+
+	switch {
+	case highest >= adaptiveCritical:
+		return time.Minute, fmt.Sprintf("%s at %d%%", highestName, int(highest))
+	case highest >= adaptiveHighWater:
+		return 2 * time.Minute, fmt.Sprintf("%s at %d%%", highestName, int(highest))
+	case resetSoon:
+		return 2 * time.Minute, fmt.Sprintf("%s resets in %s", soonestName, shortDuration(soonest))
+	case highest < adaptiveIdle && resetFar:
+		return 30 * time.Minute, fmt.Sprintf("all below %d%%", adaptiveIdle)
+	case highest < adaptiveLowWater && resetFar:
+		return 15 * time.Minute, fmt.Sprintf("all below %d%%", adaptiveLowWater)
+	}
+	return defaultUpdateInterval, "normal usage"
+}
+
+// nextReset is the earliest reset after now of a bucket that is in use,
+// zero if none is due.
+func nextReset(usage *UsageResponse, now time.Time) time.Time {
+	if usage == nil {
+		return time.Time{}
+	}
+	var soonest time.Time
+	for _, b := range []*UsageBucket{&usage.FiveHour, &usage.SevenDay, usage.SevenDayOpus, usage.SevenDaySonnet} {
+		if b == nil || b.Utilization <= 0 {
+			continue
+		}
+		if t := resetTime(b.ResetsAt); t.After(now) && (soonest.IsZero() || t.Before(soonest)) {
+			soonest = t
+		}
+	}
+	return soonest
+}
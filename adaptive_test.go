@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	resets := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	usage := func(session, weekly float64, sessionResets time.Duration) *UsageResponse {
+		return &UsageResponse{
+			FiveHour: UsageBucket{Utilization: session, ResetsAt: resets(sessionResets)},
+			SevenDay: UsageBucket{Utilization: weekly, ResetsAt: resets(72 * time.Hour)},
+		}
+	}
+	sonnet := usage(10, 10, 3*time.Hour)
+	sonnet.SevenDaySonnet = &UsageBucket{Utilization: 92, ResetsAt: resets(48 * time.Hour)}
+	unused := usage(0, 2, 0)
+	unused.FiveHour.ResetsAt = ""
+
+	for _, tc := range []struct {
+		name  string
+		usage *UsageResponse
+		want  time.Duration
+		why   string
+	}{
+		{"critical", usage(95, 30, 3*time.Hour), time.Minute, "session at 95%"},
+		{"critical per-model bucket", sonnet, time.Minute, "Sonnet at 92%"},
+		{"high water", usage(50, 85, 3*time.Hour), 2 * time.Minute, "weekly at 85%"},
+		{"reset soon", usage(40, 30, 8*time.Minute), 2 * time.Minute, "session resets in 8m"},
+		{"unused bucket resetting", usage(0, 30, 5*time.Minute), defaultUpdateInterval, "normal usage"},
+		{"normal", usage(40, 30, 3*time.Hour), defaultUpdateInterval, "normal usage"},
+		{"low but reset within the hour", usage(10, 10, 40*time.Minute), defaultUpdateInterval, "normal usage"},
+		{"low", usage(15, 10, 3*time.Hour), 15 * time.Minute, "all below 20%"},
+		{"idle", unused, 30 * time.Minute, "all below 5%"},
+		{"no reading", nil, defaultUpdateInterval, "no reading"},
+	} {
+		got, why := adaptiveInterval(tc.usage, now)
+		if got != tc.want || why != tc.why {
+			t.Errorf("%s: got %v (%s), want %v (%s)", tc.name, got, why, tc.want, tc.why)
+		}
+	}
+}
+
+func TestNextReset(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	resets := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	usage := &UsageResponse{
+		FiveHour:       UsageBucket{Utilization: 40, ResetsAt: resets(3 * time.Hour)},
+		SevenDay:       UsageBucket{Utilization: 10, ResetsAt: resets(-time.Hour)}, // already passed
+		SevenDaySonnet: &UsageBucket{Utilization: 0, ResetsAt: resets(time.Hour)},  // unused
+	}
+	if got, want := nextReset(usage, now), now.Add(3*time.Hour); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	usage.FiveHour.Utilization = 0
+	if got := nextReset(usage, now); !got.IsZero() {
+		t.Errorf("nothing in use: got %v", got)
+	}
+}
@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,21 +21,91 @@ type UsageResponse struct {
 	SevenDay       UsageBucket  `json:"seven_day"`
 	SevenDayOpus   *UsageBucket `json:"seven_day_opus"`
 	SevenDaySonnet *UsageBucket `json:"seven_day_sonnet"`
-	ExtraUsage     *struct {
-		IsEnabled    bool     `json:"is_enabled"`
-		MonthlyLimit *float64 `json:"monthly_limit"`
-		UsedCredits  *float64 `json:"used_credits"`
-		Utilization  *float64 `json:"utilization"`
-	} `json:"extra_usage"`
+	ExtraUsage     *ExtraUsage  `json:"extra_usage"`
+
+	// redirectedTo is set when the usage request was redirected, which
+	// suggests the org ID no longer maps to the same organization.
+	redirectedTo string
+}
+
+// ExtraUsage is the pay-as-you-go credits bucket. Every numeric field is
+// optional; MonthlyLimit is nil when spending is uncapped.
+type ExtraUsage struct {
+	IsEnabled    bool     `json:"is_enabled"`
+	MonthlyLimit *float64 `json:"monthly_limit"`
+	UsedCredits  *float64 `json:"used_credits"`
+	Utilization  *float64 `json:"utilization"`
+}
+
+// utilization returns the reported utilization, or derives it from used
+// credits and the monthly limit. ok is false when neither is available.
+func (e *ExtraUsage) utilization() (pct float64, ok bool) {
+	if e.Utilization != nil {
+		return *e.Utilization, true
+	}
+	if e.UsedCredits != nil && e.MonthlyLimit != nil && *e.MonthlyLimit > 0 {
+		return *e.UsedCredits / *e.MonthlyLimit * 100, true
+	}
+	return 0, false
+}
+
+// usageCache remembers the last usage response of each account together
+// with its ETag / Last-Modified validators so unchanged data can be
+// revalidated cheaply. Accounts are fetched concurrently, so each has its
+// own entry.
+type usageCache struct {
+	mu      sync.Mutex
+	entries map[string]usageCacheEntry // by org + session key
 }
 
-var httpClient = &http.Client{
-	Timeout: 15 * time.Second,
-	Transport: &http.Transport{
-		MaxIdleConns:        1,
-		MaxIdleConnsPerHost: 1,
-		IdleConnTimeout:     90 * time.Second,
-	},
+type usageCacheEntry struct {
+	etag         string
+	lastModified string
+	usage        *UsageResponse
+}
+
+// maxCachedUsage bounds the entries; keys left behind by changed session
+// keys or orgs go when it is reached.
+const maxCachedUsage = 16
+
+var cachedUsage usageCache
+
+// validators returns the stored validators for key, if any.
+func (c *usageCache) validators(key string) (etag, lastModified string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[key]
+	return e.etag, e.lastModified
+}
+
+func (c *usageCache) store(key, etag, lastModified string, usage *UsageResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedUsage {
+		c.entries = nil
+	}
+	if c.entries == nil {
+		c.entries = map[string]usageCacheEntry{}
+	}
+	c.entries[key] = usageCacheEntry{etag: etag, lastModified: lastModified, usage: usage}
+}
+
+func (c *usageCache) get(key string) *UsageResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.usage == nil {
+		return nil
+	}
+	u := *e.usage
+	return &u
+}
+
+// reset forgets every entry.
+func (c *usageCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
 }
 
 var retryDelays = []time.Duration{10 * time.Second, 30 * time.Second, 60 * time.Second}
@@ -47,6 +117,15 @@ type ErrCloudflare struct {
 
 func (e *ErrCloudflare) Error() string { return e.Msg }
 
+// ErrHTTP is a non-success API response other than a Cloudflare challenge.
+type ErrHTTP struct {
+	StatusCode int
+	Msg        string
+}
+
+// Error masks credentials the response body may echo.
+func (e *ErrHTTP) Error() string { return scrubSecrets(e.Msg) }
+
 func isRetryable(err error) bool {
 	msg := err.Error()
 	return strings.Contains(msg, "HTTP 403") ||
@@ -62,19 +141,37 @@ func isCloudflare(err error) bool {
 	return false
 }
 
-func fetchUsage(ctx context.Context, cfg *Config) (*UsageResponse, error) {
+// retryProgress describes fetchUsage waiting to make another attempt.
+type retryProgress struct {
+	Attempt int // the attempt about to be made, from 2
+	Total   int // attempts in all
+	RetryIn time.Duration
+}
+
+// fetchUsage fetches cfg's usage, retrying transient failures after
+// retryDelays. progress, if not nil, is called before each wait.
+func fetchUsage(ctx context.Context, cfg *Config, progress func(retryProgress)) (*UsageResponse, error) {
+	return fetchWithRetries(ctx, func(ctx context.Context) (*UsageResponse, error) {
+		return doFetch(ctx, cfg)
+	}, progress)
+}
+
+func fetchWithRetries(ctx context.Context, fetch func(context.Context) (*UsageResponse, error), progress func(retryProgress)) (*UsageResponse, error) {
 	var lastErr error
 	for attempt := 0; attempt <= len(retryDelays); attempt++ {
 		if attempt > 0 {
 			delay := retryDelays[attempt-1]
-			log.Printf("Retry %d/%d after %v (error: %v)", attempt, len(retryDelays), delay, lastErr)
+			apiLog.Warnf("Retry %d/%d after %v (error: %v)", attempt, len(retryDelays), delay, lastErr)
+			if progress != nil {
+				progress(retryProgress{Attempt: attempt + 1, Total: len(retryDelays) + 1, RetryIn: delay})
+			}
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			case <-time.After(delay):
 			}
 		}
-		usage, err := doFetch(ctx, cfg)
+		usage, err := fetch(context.WithValue(ctx, attemptKey{}, attempt+1))
 		if err == nil {
 			return usage, nil
 		}
@@ -90,9 +187,63 @@ func fetchUsage(ctx context.Context, cfg *Config) (*UsageResponse, error) {
 	return nil, fmt.Errorf("all %d attempts failed: %w", len(retryDelays)+1, lastErr)
 }
 
+// attemptKey carries the number of fetchWithRetries' attempt in a
+// request's context, for the debug log.
+type attemptKey struct{}
+
 func doFetch(ctx context.Context, cfg *Config) (*UsageResponse, error) {
-	url := fmt.Sprintf("https://claude.ai/api/organizations/%s/usage", cfg.OrgID)
+	url := cfg.apiURL(fmt.Sprintf("/organizations/%s/usage", cfg.OrgID))
+
+	req, err := newAPIRequest(ctx, cfg, url)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := cfg.OrgID + "\x00" + cfg.SessionKey
+	if etag, lastModified := cachedUsage.validators(cacheKey); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	} else if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, body, err := doAPIRequest(cfg, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		if usage := cachedUsage.get(cacheKey); usage != nil {
+			return usage, nil
+		}
+		// The entry is gone (evicted, or never stored); ask once more
+		// without validators
+		apiLog.Debugf("HTTP 304 without a cached response, fetching again unconditionally")
+		req = req.Clone(ctx)
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+		if resp, body, err = doAPIRequest(cfg, req); err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			return nil, fmt.Errorf("HTTP 304 to a request without validators")
+		}
+	}
 
+	var usage UsageResponse
+	if err := json.Unmarshal(body, &usage); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	if final := resp.Request.URL.String(); final != url {
+		usage.redirectedTo = final
+	}
+	cachedUsage.store(cacheKey, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), &usage)
+
+	return &usage, nil
+}
+
+// newAPIRequest builds a GET request carrying the session cookies and the
+// browser-like headers claude.ai expects.
+func newAPIRequest(ctx context.Context, cfg *Config, url string) (*http.Request, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
@@ -116,35 +267,54 @@ func doFetch(ctx context.Context, cfg *Config) (*UsageResponse, error) {
 	req.Header.Set("Sec-GPC", "1")
 	req.Header.Set("DNT", "1")
 	req.Header.Set("TE", "trailers")
+	return req, nil
+}
+
+// requestLog is apiLog with the duration of req, sent at start, and its
+// attempt if fetchWithRetries made it.
+func requestLog(req *http.Request, start time.Time) logger {
+	l := apiLog.with("duration_ms", time.Since(start).Milliseconds())
+	if attempt, ok := req.Context().Value(attemptKey{}).(int); ok {
+		l = l.with("attempt", attempt)
+	}
+	return l
+}
+
+// doAPIRequest sends req and reads the body. Responses other than 200 and
+// 304 are recorded for diagnostics and returned as *ErrCloudflare or *ErrHTTP.
+func doAPIRequest(cfg *Config, req *http.Request) (*http.Response, []byte, error) {
+	client, err := httpClientFor(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	resp, err := httpClient.Do(req)
+	start := time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		requestLog(req, start).Debugf("%s %s failed: %v", req.Method, req.URL.Path, err)
+		return nil, nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		return nil, nil, fmt.Errorf("reading response: %w", err)
 	}
+	requestLog(req, start).with("status_code", resp.StatusCode).Debugf("%s %s", req.Method, req.URL.Path)
 
-	if resp.StatusCode != 200 {
-		bodyStr := string(body)
-		if len(bodyStr) > 200 {
-			bodyStr = bodyStr[:200] + "..."
-		}
-		msg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, bodyStr)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		failedResponses.add(failedResponse{
+			Time:   time.Now(),
+			URL:    req.URL.String(),
+			Status: resp.StatusCode,
+			Body:   string(body),
+		})
+		msg := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, errorSnippet(string(body)))
 		// Detect Cloudflare challenge page
 		if resp.StatusCode == 403 && strings.Contains(string(body), "Just a moment") {
-			return nil, &ErrCloudflare{Msg: msg}
+			return nil, nil, &ErrCloudflare{Msg: msg}
 		}
-		return nil, fmt.Errorf("%s", msg)
-	}
-
-	var usage UsageResponse
-	if err := json.Unmarshal(body, &usage); err != nil {
-		return nil, fmt.Errorf("parsing JSON: %w", err)
+		return nil, nil, &ErrHTTP{StatusCode: resp.StatusCode, Msg: msg}
 	}
-
-	return &usage, nil
+	return resp, body, nil
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conditionalServer serves usage with validator and answers 304 to a
+// request carrying it; requests records each request's conditional header.
+func conditionalServer(t *testing.T, header, value string) (srv *httptest.Server, requests *[]string) {
+	t.Helper()
+	requests = new([]string)
+	cond := map[string]string{"ETag": "If-None-Match", "Last-Modified": "If-Modified-Since"}[header]
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(cond)
+		*requests = append(*requests, got)
+		if got == value {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(header, value)
+		w.Write([]byte(`{"five_hour": {"utilization": 42}, "seven_day": {"utilization": 7}}`))
+	}))
+	t.Cleanup(srv.Close)
+	cachedUsage.reset()
+	t.Cleanup(func() { cachedUsage.reset() })
+	return srv, requests
+}
+
+func TestFetchRevalidates(t *testing.T) {
+	for header, value := range map[string]string{
+		"ETag":          `"v1"`,
+		"Last-Modified": "Sat, 17 Oct 2026 10:00:00 GMT",
+	} {
+		srv, requests := conditionalServer(t, header, value)
+		cfg := &Config{SessionKey: "sk-ant-sid01-etag", OrgID: "org-etag", APIBaseURL: srv.URL}
+		for i := 0; i < 2; i++ {
+			usage, err := doFetch(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("%s: fetch %d: %v", header, i+1, err)
+			}
+			if usage.FiveHour.Utilization != 42 || usage.SevenDay.Utilization != 7 {
+				t.Errorf("%s: fetch %d: session %v, weekly %v", header, i+1, usage.FiveHour.Utilization, usage.SevenDay.Utilization)
+			}
+		}
+		if len(*requests) != 2 || (*requests)[0] != "" || (*requests)[1] != value {
+			t.Errorf("%s: conditional headers sent %q, want none then %q", header, *requests, value)
+		}
+	}
+}
+
+func TestFetchCacheBelongsToAccount(t *testing.T) {
+	srv, requests := conditionalServer(t, "ETag", `"v1"`)
+	cfg := &Config{SessionKey: "sk-ant-sid01-etag", OrgID: "org-etag", APIBaseURL: srv.URL}
+	if _, err := doFetch(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range []func(*Config){
+		func(c *Config) { c.SessionKey = "sk-ant-sid01-other" },
+		func(c *Config) { c.OrgID = "org-other" },
+	} {
+		change(cfg)
+		if _, err := doFetch(context.Background(), cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, got := range (*requests)[1:] {
+		if got != "" {
+			t.Errorf("request %d after an account change sent If-None-Match %q", i+2, got)
+		}
+	}
+}
+
+func TestFetch304WithoutCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+	cachedUsage.reset()
+	cfg := &Config{SessionKey: "sk-ant-sid01-etag", OrgID: "org-etag", APIBaseURL: srv.URL}
+	if _, err := doFetch(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "304") {
+		t.Errorf("doFetch error = %v, want one about the 304", err)
+	}
+}
+
+func TestFetch304RefetchesWithoutCache(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("If-None-Match"))
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"five_hour": {"utilization": 42}, "seven_day": {"utilization": 7}}`))
+	}))
+	defer srv.Close()
+	cachedUsage.reset()
+	t.Cleanup(cachedUsage.reset)
+	cfg := &Config{SessionKey: "sk-ant-sid01-etag", OrgID: "org-etag", APIBaseURL: srv.URL}
+	usage, err := doFetch(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.FiveHour.Utilization != 42 || len(requests) != 2 {
+		t.Errorf("session %v after %d requests, want 42 after 2", usage.FiveHour.Utilization, len(requests))
+	}
+}
+
+func TestFetchAccountsConcurrently(t *testing.T) {
+	// Each org has its own ETag and numbers
+	var mu sync.Mutex
+	conditional := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		org := strings.Split(r.URL.Path, "/")[2]
+		etag := `"` + org + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			mu.Lock()
+			conditional[org]++
+			mu.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"five_hour": {"utilization": %d}, "seven_day": {"utilization": 7}}`, len(org))
+	}))
+	defer srv.Close()
+	cachedUsage.reset()
+	t.Cleanup(cachedUsage.reset)
+
+	accounts := []*Config{
+		{SessionKey: "sk-ant-sid01-personal", OrgID: "org-a", APIBaseURL: srv.URL},
+		{SessionKey: "sk-ant-sid01-work", OrgID: "org-work", APIBaseURL: srv.URL},
+	}
+	const rounds = 5
+	for round := 0; round < rounds; round++ {
+		var wg sync.WaitGroup
+		errs := make([]error, len(accounts))
+		for i, cfg := range accounts {
+			wg.Add(1)
+			go func(i int, cfg *Config) {
+				defer wg.Done()
+				usage, err := doFetch(context.Background(), cfg)
+				if err == nil && usage.FiveHour.Utilization != float64(len(cfg.OrgID)) {
+					err = fmt.Errorf("session %v, want %d", usage.FiveHour.Utilization, len(cfg.OrgID))
+				}
+				errs[i] = err
+			}(i, cfg)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("round %d, %s: %v", round+1, accounts[i].OrgID, err)
+			}
+		}
+	}
+	for _, cfg := range accounts {
+		if conditional[cfg.OrgID] != rounds-1 {
+			t.Errorf("%s: %d of %d later requests revalidated", cfg.OrgID, conditional[cfg.OrgID], rounds-1)
+		}
+	}
+}
+
+func TestRetryProgressShownThenCleared(t *testing.T) {
+	saved := retryDelays
+	retryDelays = []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 60 * time.Millisecond}
+	t.Cleanup(func() { retryDelays = saved })
+
+	u := newUIUpdater()
+	applied := make(chan uiState, 8)
+	go u.run(func(prev, st uiState) { applied <- st })
+	defer u.stop()
+
+	gen := u.nextGeneration()
+	u.publish(gen, uiState{progress: refreshingText})
+
+	calls := 0
+	fetch := func(ctx context.Context) (*UsageResponse, error) {
+		calls++
+		if calls <= 2 {
+			return nil, errors.New("HTTP 503: unavailable")
+		}
+		return &UsageResponse{FiveHour: UsageBucket{Utilization: 42}}, nil
+	}
+	usage, err := fetchWithRetries(context.Background(), fetch, publishProgress(u, gen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final uiState
+	presentUpdate(&final, nil, usage, nil, 0, time.Now())
+	u.publish(gen, final)
+
+	var tooltips, progress []string
+	for len(progress) < 4 {
+		select {
+		case st := <-applied:
+			tooltips = append(tooltips, st.tooltip)
+			progress = append(progress, st.progress)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d snapshots shown: %q", len(progress), progress)
+		}
+	}
+	wantProgress := []string{
+		"Refreshing…",
+		"Refreshing… (attempt 2/4, retrying in 0s)",
+		"Refreshing… (attempt 3/4, retrying in 0s)",
+		"",
+	}
+	if fmt.Sprint(progress) != fmt.Sprint(wantProgress) {
+		t.Errorf("progress went %q, want %q", progress, wantProgress)
+	}
+	if tooltips[1] != appName+": "+wantProgress[1] || strings.Contains(tooltips[3], "Refreshing") {
+		t.Errorf("tooltips went %q", tooltips)
+	}
+}
+
+func TestShowRetryProgress(t *testing.T) {
+	p := retryProgress{Attempt: 2, Total: 4, RetryIn: 30 * time.Second}
+	var st uiState
+	showRetryProgress(&st, p, false)
+	if want := appName + ": Refreshing… (attempt 2/4, retrying in 30s)"; st.tooltip != want {
+		t.Errorf("tooltip = %q, want %q", st.tooltip, want)
+	}
+	showRetryProgress(&st, p, true)
+	if want := "Refreshing… (attempt 2/4, retrying in 30 seconds)"; st.progress != want {
+		t.Errorf("accessible progress = %q, want %q", st.progress, want)
+	}
+}
+
+func TestFetchDebugLog(t *testing.T) {
+	var buf bytes.Buffer
+	saved := log.Writer()
+	t.Cleanup(func() {
+		log.SetOutput(saved)
+		configureLogging(&Config{})
+	})
+	log.SetOutput(&buf)
+	configureLogging(&Config{LogLevel: "debug"})
+
+	srv, _ := conditionalServer(t, "ETag", `"v1"`)
+	cfg := &Config{SessionKey: "sk-ant-sid01-etag", OrgID: "org-etag", APIBaseURL: srv.URL}
+	if _, err := fetchUsage(context.Background(), cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{"GET /organizations/org-etag/usage", "status_code=200", "attempt=1", "duration_ms="} {
+		if !strings.Contains(got, want) {
+			t.Errorf("debug log lacks %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultLogMaxSizeMB is the size claude-monitor.log is rotated at
+	// unless log_max_size_mb is set.
+	defaultLogMaxSizeMB = 1
+	// defaultLogKeep is how many rotated logs (.1, .2, ...) are kept
+	// unless log_keep is set.
+	defaultLogKeep = 3
+)
+
+// rotatingLog is the log file: when a write would take it past maxSize it
+// is renamed to .1 (shifting older ones up to .keep) and a fresh file is
+// started. Writes are serialized, so lines from concurrent goroutines are
+// never split or lost across a rotation.
+type rotatingLog struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	size    int64
+	maxSize int64
+	keep    int
+}
+
+// openRotatingLog opens path for appending with the default limits.
+func openRotatingLog(path string) (*rotatingLog, error) {
+	l := &rotatingLog{path: path, maxSize: defaultLogMaxSizeMB << 20, keep: defaultLogKeep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	l.f, l.size = f, 0
+	if fi, err := f.Stat(); err == nil {
+		l.size = fi.Size()
+	}
+	return nil
+}
+
+// configure applies log_max_size_mb and log_keep; called on every update
+// so that config edits take effect. A nil config leaves it as is.
+func (l *rotatingLog) configure(cfg *Config) {
+	if l == nil || cfg == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxSize = defaultLogMaxSizeMB << 20
+	if cfg.LogMaxSizeMB > 0 {
+		l.maxSize = int64(cfg.LogMaxSizeMB * (1 << 20))
+	}
+	l.keep = defaultLogKeep
+	if cfg.LogKeep > 0 {
+		l.keep = cfg.LogKeep
+	}
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return len(p), nil // closed
+	}
+	if l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		l.rotateLocked()
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotateLocked shifts the old logs up and starts a new file. If renaming
+// fails (another process holds the file open on Windows), writing carries
+// on in the same file and rotation is tried again after another maxSize.
+func (l *rotatingLog) rotateLocked() {
+	l.f.Close()
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	renamed := os.Rename(l.path, l.path+".1") == nil
+	if err := l.open(); err != nil {
+		l.f = nil
+		return
+	}
+	if !renamed {
+		l.size = 0
+	}
+}
+
+// Close syncs and closes the file; later writes are dropped.
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	l.f.Sync()
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// logLevel is how severe a log entry is. Entries below their module's
+// level are left out.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string { return logLevelNames[l] }
+
+// parseLogLevel parses a log_level value; "" is info.
+func parseLogLevel(s string) (logLevel, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return levelInfo, true
+	}
+	for i, name := range logLevelNames {
+		if s == name {
+			return logLevel(i), true
+		}
+	}
+	return levelInfo, false
+}
+
+// Log modules, the parts of the app log_module_levels can set apart.
+var (
+	apiLog     = logger{module: "api"}     // claude.ai requests and organizations
+	firefoxLog = logger{module: "firefox"} // cookie imports from the browsers
+	uiLog      = logger{module: "ui"}      // the tray, alerts and integrations
+	configLog  = logger{module: "config"}  // config.json and the policy
+)
+
+var logModules = []string{"api", "firefox", "ui", "config"}
+
+// logSettings is what log_format, log_level and log_module_levels set.
+type logSettings struct {
+	json    bool
+	level   logLevel
+	modules map[string]logLevel
+}
+
+var logSetup atomic.Pointer[logSettings]
+
+func init() { logSetup.Store(&logSettings{level: levelInfo}) }
+
+// validateLogging checks log_format, log_level and log_module_levels.
+func validateLogging(cfg *Config) error {
+	if f := strings.ToLower(cfg.LogFormat); f != "" && f != "text" && f != "json" {
+		return fmt.Errorf("log_format must be \"text\" or \"json\", got %q", cfg.LogFormat)
+	}
+	if _, ok := parseLogLevel(cfg.LogLevel); !ok {
+		return fmt.Errorf("log_level must be one of %s, got %q", strings.Join(logLevelNames, ", "), cfg.LogLevel)
+	}
+	for module, level := range cfg.LogModuleLevels {
+		if !slices.Contains(logModules, module) {
+			return fmt.Errorf("log_module_levels: unknown module %q (want %s)", module, strings.Join(logModules, ", "))
+		}
+		if _, ok := parseLogLevel(level); !ok {
+			return fmt.Errorf("log_module_levels: %s must be one of %s, got %q", module, strings.Join(logLevelNames, ", "), level)
+		}
+	}
+	return nil
+}
+
+// configureLogging applies log_format, log_level and log_module_levels;
+// values that don't parse are left at their defaults. A nil config leaves
+// logging as is.
+func configureLogging(cfg *Config) {
+	if cfg == nil {
+		return
+	}
+	s := &logSettings{json: strings.EqualFold(strings.TrimSpace(cfg.LogFormat), "json")}
+	s.level, _ = parseLogLevel(cfg.LogLevel)
+	for module, name := range cfg.LogModuleLevels {
+		if l, ok := parseLogLevel(name); ok {
+			if s.modules == nil {
+				s.modules = make(map[string]logLevel)
+			}
+			s.modules[module] = l
+		}
+	}
+	logSetup.Store(s)
+}
+
+// logger writes a module's entries to the standard logger's output, as
+// the familiar text lines or, with log_format "json", one JSON object a
+// line.
+type logger struct {
+	module string
+	fields []any // key, value, ...
+}
+
+// with returns l with the key/value pairs kv added to its entries, e.g.
+// apiLog.with("status_code", 200).
+func (l logger) with(kv ...any) logger {
+	l.fields = append(slices.Clip(l.fields), kv...)
+	return l
+}
+
+func (l logger) Debugf(format string, args ...any) { l.logf(levelDebug, format, args...) }
+func (l logger) Infof(format string, args ...any)  { l.logf(levelInfo, format, args...) }
+func (l logger) Warnf(format string, args ...any)  { l.logf(levelWarn, format, args...) }
+func (l logger) Errorf(format string, args ...any) { l.logf(levelError, format, args...) }
+
+func (l logger) enabled(level logLevel) bool {
+	s := logSetup.Load()
+	min, ok := s.modules[l.module]
+	if !ok {
+		min = s.level
+	}
+	return level >= min
+}
+
+func (l logger) logf(level logLevel, format string, args ...any) {
+	if !l.enabled(level) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if !logSetup.Load().json {
+		for i := 0; i+1 < len(l.fields); i += 2 {
+			msg += fmt.Sprintf(" %v=%v", l.fields[i], l.fields[i+1])
+		}
+		log.Output(3, msg)
+		return
+	}
+	entry := map[string]any{"ts": time.Now().Format(time.RFC3339Nano), "level": level.String(), "module": l.module, "msg": msg}
+	for i := 0; i+1 < len(l.fields); i += 2 {
+		entry[fmt.Sprint(l.fields[i])] = l.fields[i+1]
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]any{"ts": entry["ts"], "level": entry["level"], "module": l.module, "msg": msg})
+	}
+	log.Writer().Write(append(line, '\n'))
+}
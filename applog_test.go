@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotatingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "claude-monitor.log")
+	l, err := openRotatingLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.maxSize, l.keep = 1000, 2
+
+	// 20 goroutines × 50 lines of 20 bytes: 20 KB through a 1 KB log
+	var wg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				fmt.Fprintf(l, "line %02d-%02d ........\n", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == 0 || len(data) > 1000 {
+			t.Errorf("%s: %d bytes", filepath.Base(name), len(data))
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			if len(line) != 19 {
+				t.Errorf("%s: torn line %q", filepath.Base(name), line)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("kept more than log_keep files")
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	saved := log.Writer()
+	t.Cleanup(func() {
+		log.SetOutput(saved)
+		configureLogging(&Config{})
+	})
+	log.SetOutput(&buf)
+
+	configureLogging(&Config{LogLevel: "warn"})
+	uiLog.Infof("OK: session=%d%%", 42)
+	apiLog.Errorf("API error")
+	configureLogging(&Config{})
+	uiLog.Infof("OK: session=%d%%", 43)
+	apiLog.Debugf("GET /usage")
+
+	if got := buf.String(); strings.Contains(got, "session=42") || !strings.Contains(got, "API error") || !strings.Contains(got, "session=43") || strings.Contains(got, "GET") {
+		t.Errorf("log:\n%s", got)
+	}
+}
+
+func TestLogModuleLevels(t *testing.T) {
+	var buf bytes.Buffer
+	saved := log.Writer()
+	t.Cleanup(func() {
+		log.SetOutput(saved)
+		configureLogging(&Config{})
+	})
+	log.SetOutput(&buf)
+
+	configureLogging(&Config{LogLevel: "warn", LogModuleLevels: map[string]string{"api": "debug"}})
+	apiLog.with("status_code", 200).Debugf("GET /usage")
+	firefoxLog.Infof("Firefox profile: x")
+
+	if got := buf.String(); !strings.Contains(got, "GET /usage status_code=200") || strings.Contains(got, "Firefox") {
+		t.Errorf("log:\n%s", got)
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	var buf bytes.Buffer
+	saved := log.Writer()
+	t.Cleanup(func() {
+		log.SetOutput(saved)
+		configureLogging(&Config{})
+	})
+	log.SetOutput(&buf)
+
+	configureLogging(&Config{LogFormat: "json", LogLevel: "debug"})
+	apiLog.with("status_code", 429, "attempt", 2, "duration_ms", int64(85)).Warnf("GET %s", "/usage")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("not one JSON line: %v\n%s", err, buf.Bytes())
+	}
+	for k, want := range map[string]any{"level": "warn", "module": "api", "msg": "GET /usage", "status_code": 429.0, "attempt": 2.0, "duration_ms": 85.0} {
+		if entry[k] != want {
+			t.Errorf("%s = %v, want %v", k, entry[k], want)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(entry["ts"])); err != nil {
+		t.Errorf("ts: %v", err)
+	}
+}
+
+func TestValidateLogging(t *testing.T) {
+	for _, tc := range []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{}, true},
+		{Config{LogFormat: "json", LogLevel: "debug", LogModuleLevels: map[string]string{"firefox": "error"}}, true},
+		{Config{LogFormat: "xml"}, false},
+		{Config{LogLevel: "verbose"}, false},
+		{Config{LogModuleLevels: map[string]string{"network": "debug"}}, false},
+		{Config{LogModuleLevels: map[string]string{"api": "loud"}}, false},
+	} {
+		if err := validateLogging(&tc.cfg); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.cfg, err)
+		}
+	}
+}
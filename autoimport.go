@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAutoImportInterval is how often browser cookies are re-read
+	// unless auto_import_interval is set. cf_clearance rotates every few
+	// hours while claude.ai stays open in the browser.
+	defaultAutoImportInterval = 6 * time.Hour
+	// minAutoImportInterval keeps a typo like "6s" from reading the cookie
+	// database all the time.
+	minAutoImportInterval = 10 * time.Minute
+)
+
+// parseAutoImportInterval parses auto_import_interval; empty means the
+// default.
+func parseAutoImportInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultAutoImportInterval, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("auto_import_interval must be a duration such as \"6h\", got %q", s)
+	}
+	if d < minAutoImportInterval {
+		return 0, fmt.Errorf("auto_import_interval must be at least %s, got %q", shortDuration(minAutoImportInterval), s)
+	}
+	return d, nil
+}
+
+// autoImport reports whether cookies may be imported from the browser
+// without the user asking. A nil config yields the default.
+func (c *Config) autoImport() bool {
+	return c == nil || c.AutoImport == nil || *c.AutoImport
+}
+
+// autoImportInterval returns the configured interval or the default. A nil
+// config, or one with an invalid value, yields the default.
+func (c *Config) autoImportInterval() time.Duration {
+	if c == nil {
+		return defaultAutoImportInterval
+	}
+	d, err := parseAutoImportInterval(c.AutoImportInterval)
+	if err != nil {
+		return defaultAutoImportInterval
+	}
+	return d
+}
+
+// cookieUpdate returns the change that stores cookies imported from a
+// browser, or nil if cfg already has them. With several accounts only the
+// cf_clearance of the account the browser is logged in to is updated;
+// accounts are never switched behind the user's back.
+func cookieUpdate(cfg *Config, sessionKey, orgID, cfClearance string) func(*Config) {
+	if len(cfg.Accounts) == 0 {
+		if sessionKey == cfg.SessionKey &&
+			(orgID == "" || orgID == cfg.OrgID) &&
+			(cfClearance == "" || cfClearance == cfg.CfClearance) {
+			return nil
+		}
+		return firefoxCookies(sessionKey, orgID, cfClearance)
+	}
+	if cfClearance == "" {
+		return nil
+	}
+	for _, a := range cfg.Accounts {
+		if a.SessionKey != sessionKey || a.CfClearance == cfClearance {
+			continue
+		}
+		return func(c *Config) {
+			for i := range c.Accounts {
+				if c.Accounts[i].SessionKey == sessionKey {
+					c.Accounts[i].CfClearance = cfClearance
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// watchBrowserCookies re-imports cookies from the browser every
+// auto_import_interval, so that a rotated cf_clearance is picked up before
+// a Cloudflare block fails an update. onChange is called after the new
+// cookies were saved.
+func watchBrowserCookies(onChange func()) {
+	for {
+		cfg, _ := readConfigFile(configPath)
+		time.Sleep(cfg.autoImportInterval())
+		if refreshBrowserCookies("proactively", findBrowserCookies) {
+			onChange()
+		}
+	}
+}
+
+// refreshBrowserCookies imports cookies with find if auto_import allows it
+// and saves them if they differ from config.json; why completes the log
+// line. It reports whether config.json was changed.
+func refreshBrowserCookies(why string, find func() (browser, sessionKey, orgID, cfClearance string, err error)) bool {
+	cfg, err := readConfigFile(configPath)
+	if err != nil || !cfg.autoImport() {
+		return false
+	}
+	browser, sk, org, cfc, err := find()
+	if err != nil {
+		firefoxLog.Warnf("Refreshing cookies %s failed: %v", why, err)
+		return false
+	}
+	save := cookieUpdate(cfg, sk, org, cfc)
+	if save == nil {
+		return false
+	}
+	if err := updateConfigAuto(configPath, save); err != nil && !errors.Is(err, errConfigWriteDeferred) {
+		firefoxLog.Warnf("Failed to save refreshed cookies: %v", err)
+		return false
+	}
+	if len(cfg.Accounts) > 0 || sk == cfg.SessionKey {
+		firefoxLog.Infof("cf_clearance refreshed %s from %s", why, browser)
+	} else {
+		firefoxLog.Infof("Cookies refreshed %s from %s", why, browser)
+	}
+	return true
+}
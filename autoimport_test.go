@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCookieUpdate(t *testing.T) {
+	flat := &Config{SessionKey: "sk-1", OrgID: "org-1", CfClearance: "cf-1"}
+	if cookieUpdate(flat, "sk-1", "org-1", "cf-1") != nil {
+		t.Error("unchanged cookies produced an update")
+	}
+	if cookieUpdate(flat, "sk-1", "", "") != nil {
+		t.Error("missing lastActiveOrg and cf_clearance produced an update")
+	}
+	save := cookieUpdate(flat, "sk-1", "", "cf-2")
+	if save == nil {
+		t.Fatal("new cf_clearance produced no update")
+	}
+	got := *flat
+	save(&got)
+	if got.SessionKey != "sk-1" || got.OrgID != "org-1" || got.CfClearance != "cf-2" {
+		t.Errorf("after update: %q %q %q", got.SessionKey, got.OrgID, got.CfClearance)
+	}
+
+	multi := &Config{Accounts: []Account{
+		{Name: "Work", SessionKey: "sk-work", CfClearance: "cf-1"},
+		{Name: "Home", SessionKey: "sk-home", CfClearance: "cf-1"},
+	}}
+	if cookieUpdate(multi, "sk-other", "", "cf-2") != nil {
+		t.Error("cookies of an unlisted account produced an update")
+	}
+	save = cookieUpdate(multi, "sk-home", "org-home", "cf-2")
+	if save == nil {
+		t.Fatal("new cf_clearance for a listed account produced no update")
+	}
+	got = Config{Accounts: append([]Account(nil), multi.Accounts...)}
+	save(&got)
+	if got.Accounts[0].CfClearance != "cf-1" || got.Accounts[1].CfClearance != "cf-2" || got.Accounts[1].OrgID != "" {
+		t.Errorf("after update: %+v", got.Accounts)
+	}
+}
+
+func TestAutoImportSettings(t *testing.T) {
+	var nilCfg *Config
+	if !nilCfg.autoImport() || nilCfg.autoImportInterval() != defaultAutoImportInterval {
+		t.Error("nil config doesn't yield the defaults")
+	}
+	off := false
+	if (&Config{AutoImport: &off}).autoImport() {
+		t.Error(`"auto_import": false left auto-import on`)
+	}
+
+	path := writeTestConfig(t, `{"session_key": "sk", "org_id": "org", "auto_import_interval": "90m"}`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.autoImportInterval(); got != 90*time.Minute {
+		t.Errorf("autoImportInterval() = %s, want 1h30m", got)
+	}
+	for _, bad := range []string{"6", "5m"} {
+		path := writeTestConfig(t, `{"session_key": "sk", "org_id": "org", "auto_import_interval": "`+bad+`"}`)
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("auto_import_interval %q accepted", bad)
+		}
+	}
+}
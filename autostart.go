@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const (
+	// autostartName names the registration: the Run key value on Windows,
+	// the .desktop file in ~/.config/autostart on Linux.
+	autostartName = "claude-monitor"
+	// launchAgentLabel is the LaunchAgent's label and file name on macOS.
+	launchAgentLabel = "com.github.nocturnal-ru.claude-monitor"
+)
+
+// syncAutostart reports whether the app is registered to start at login,
+// for the "Start at login" checkmark. A registration of another path, left
+// behind when the executable was moved, is pointed at exe.
+func syncAutostart(exe string) bool {
+	registered, ok, err := autostartTarget()
+	if err != nil {
+		configLog.Warnf("Cannot read the autostart registration: %v", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if !samePath(registered, exe) {
+		configLog.Infof("Autostart registered for %s, updating to %s", registered, exe)
+		if err := registerAutostart(exe); err != nil {
+			configLog.Warnf("Failed to update autostart: %v", err)
+		}
+	}
+	return true
+}
+
+// setAutostart registers exe to start at login, or removes the
+// registration.
+func setAutostart(enable bool, exe string) error {
+	if enable {
+		if err := registerAutostart(exe); err != nil {
+			return err
+		}
+		configLog.Infof("Autostart registered for %s", exe)
+		return nil
+	}
+	if err := unregisterAutostart(); err != nil {
+		return err
+	}
+	configLog.Infof("Autostart removed")
+	return nil
+}
+
+// samePath compares executable paths the way the platform's file system
+// does: Windows ignores case.
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// runCommand is the Run key value starting exe, quoted for spaces in the
+// path.
+func runCommand(exe string) string {
+	return `"` + exe + `"`
+}
+
+// parseRunCommand returns the executable a Run key value starts, without
+// quotes and arguments.
+func parseRunCommand(s string) string {
+	s = strings.TrimSpace(s)
+	if rest, ok := strings.CutPrefix(s, `"`); ok {
+		exe, _, _ := strings.Cut(rest, `"`)
+		return exe
+	}
+	exe, _, _ := strings.Cut(s, " ")
+	return exe
+}
+
+// desktopEntry is the XDG autostart file starting exe.
+func desktopEntry(exe string) string {
+	return "[Desktop Entry]\n" +
+		"Type=Application\n" +
+		"Name=" + appName + "\n" +
+		"Exec=" + desktopQuote(exe) + "\n" +
+		"Hidden=false\n" +
+		"NoDisplay=false\n" +
+		"X-GNOME-Autostart-enabled=true\n"
+}
+
+// desktopQuote quotes an Exec argument as the Desktop Entry
+// Specification asks: in double quotes, with ", `, $ and \ escaped.
+func desktopQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"`$\\'<>~|&;*?#()") {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if strings.ContainsRune("\"`$\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseDesktopEntry returns the executable an autostart file starts.
+// enabled is false when the file turns itself off with Hidden=true or
+// X-GNOME-Autostart-enabled=false.
+func parseDesktopEntry(data []byte) (exe string, enabled bool) {
+	enabled = true
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Exec":
+			exe = desktopUnquote(value)
+		case "Hidden":
+			enabled = enabled && value != "true"
+		case "X-GNOME-Autostart-enabled":
+			enabled = enabled && value != "false"
+		}
+	}
+	return exe, enabled
+}
+
+// desktopUnquote returns the first argument of an Exec value.
+func desktopUnquote(s string) string {
+	rest, ok := strings.CutPrefix(s, `"`)
+	if !ok {
+		exe, _, _ := strings.Cut(s, " ")
+		return exe
+	}
+	var b strings.Builder
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case c == '"':
+			return b.String()
+		case c == '\\' && i+1 < len(rest):
+			i++
+			b.WriteByte(rest[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// launchAgent is the macOS LaunchAgent property list starting exe at
+// login.
+func launchAgent(exe string) string {
+	var esc bytes.Buffer
+	xml.EscapeText(&esc, []byte(exe))
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchAgentLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>` + esc.String() + `</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>ProcessType</key>
+	<string>Interactive</string>
+</dict>
+</plist>
+`
+}
+
+var programArgument = regexp.MustCompile(`<key>ProgramArguments</key>\s*<array>\s*<string>([^<]*)</string>`)
+
+// parseLaunchAgent returns the executable a LaunchAgent starts.
+func parseLaunchAgent(data []byte) (string, error) {
+	m := programArgument.FindSubmatch(data)
+	if m == nil {
+		return "", fmt.Errorf("no ProgramArguments in the LaunchAgent")
+	}
+	var exe string
+	if err := xml.Unmarshal(append(append([]byte("<s>"), m[1]...), "</s>"...), &exe); err != nil {
+		return "", fmt.Errorf("LaunchAgent: %w", err)
+	}
+	return exe, nil
+}
@@ -0,0 +1,79 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// autostartFile is the LaunchAgent on macOS, the XDG autostart entry
+// elsewhere.
+func autostartFile() (string, error) {
+	if runtime.GOOS == "darwin" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, "Library", "LaunchAgents", launchAgentLabel+".plist"), nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "autostart", autostartName+".desktop"), nil
+}
+
+// autostartTarget returns the executable registered to start at login;
+// ok is false when there is none.
+func autostartTarget() (exe string, ok bool, err error) {
+	path, err := autostartFile()
+	if err != nil {
+		return "", false, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if runtime.GOOS == "darwin" {
+		exe, err = parseLaunchAgent(data)
+		return exe, err == nil, err
+	}
+	exe, enabled := parseDesktopEntry(data)
+	return exe, enabled && exe != "", nil
+}
+
+// registerAutostart writes the autostart file for exe. A LaunchAgent
+// isn't loaded now: launchd reads it at the next login.
+func registerAutostart(exe string) error {
+	path, err := autostartFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	content := desktopEntry(exe)
+	if runtime.GOOS == "darwin" {
+		content = launchAgent(exe)
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// unregisterAutostart removes the autostart file. Unloading the
+// LaunchAgent would quit the running app, so it stays loaded until
+// logout.
+func unregisterAutostart() error {
+	path, err := autostartFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
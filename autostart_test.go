@@ -0,0 +1,82 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	exe := `C:\Program Files\claude-monitor\claude-monitor.exe`
+	if got := parseRunCommand(runCommand(exe)); got != exe {
+		t.Errorf("round trip: %q", got)
+	}
+	if got := parseRunCommand(`C:\tools\monitor.exe --flag`); got != `C:\tools\monitor.exe` {
+		t.Errorf("unquoted: %q", got)
+	}
+}
+
+func TestDesktopEntry(t *testing.T) {
+	for _, exe := range []string{
+		"/usr/local/bin/claude-monitor",
+		"/home/me/My Apps/claude-monitor",
+		`/opt/odd "$name"\bin/claude-monitor`,
+	} {
+		got, enabled := parseDesktopEntry([]byte(desktopEntry(exe)))
+		if got != exe || !enabled {
+			t.Errorf("%q: got %q, enabled %v", exe, got, enabled)
+		}
+	}
+
+	for _, tc := range []struct {
+		entry   string
+		enabled bool
+	}{
+		{"[Desktop Entry]\nExec=/bin/cm\nHidden=true\n", false},
+		{"[Desktop Entry]\nExec=/bin/cm\nX-GNOME-Autostart-enabled=false\n", false},
+		{"[Desktop Entry]\nExec=/bin/cm --quiet\n", true},
+	} {
+		exe, enabled := parseDesktopEntry([]byte(tc.entry))
+		if exe != "/bin/cm" || enabled != tc.enabled {
+			t.Errorf("%q: got %q, enabled %v", tc.entry, exe, enabled)
+		}
+	}
+}
+
+func TestLaunchAgent(t *testing.T) {
+	exe := "/Applications/Claude Monitor & Co/claude-monitor"
+	got, err := parseLaunchAgent([]byte(launchAgent(exe)))
+	if err != nil || got != exe {
+		t.Errorf("round trip: %q, %v", got, err)
+	}
+	if _, err := parseLaunchAgent([]byte("<plist><dict></dict></plist>")); err == nil {
+		t.Error("plist without ProgramArguments accepted")
+	}
+}
+
+func TestSyncAutostartStalePath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the registration is in the registry")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if syncAutostart("/new/claude-monitor") {
+		t.Fatal("registered before anything was written")
+	}
+	if err := setAutostart(true, "/old/claude-monitor"); err != nil {
+		t.Fatal(err)
+	}
+	// The executable moved: the registration follows it
+	if !syncAutostart("/new/claude-monitor") {
+		t.Fatal("registration not found")
+	}
+	if exe, ok, err := autostartTarget(); exe != "/new/claude-monitor" || !ok || err != nil {
+		t.Errorf("after the move: %q, %v, %v", exe, ok, err)
+	}
+	if err := setAutostart(false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := autostartTarget(); ok {
+		t.Error("still registered after removal")
+	}
+}
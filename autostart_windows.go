@@ -0,0 +1,73 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procRegSetKeyValueW    = advapi32.NewProc("RegSetKeyValueW")
+	procRegDeleteKeyValueW = advapi32.NewProc("RegDeleteKeyValueW")
+)
+
+const (
+	runKey             = `Software\Microsoft\Windows\CurrentVersion\Run`
+	rrfRtRegSz         = 0x00000002
+	regSz              = 1
+	errorFileNotFound  = 2
+	errorMoreData      = 234
+	maxRunValueRetries = 3
+)
+
+// autostartTarget returns the executable the Run key starts at login; ok
+// is false when there is no value for the app.
+func autostartTarget() (exe string, ok bool, err error) {
+	key, _ := syscall.UTF16PtrFromString(runKey)
+	name, _ := syscall.UTF16PtrFromString(autostartName)
+	size := uint32(512)
+	for i := 0; i < maxRunValueRetries; i++ {
+		buf := make([]uint16, size/2+1)
+		r, _, _ := procRegGetValueW.Call(hkeyCurrentUser, uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(name)),
+			rrfRtRegSz, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+		switch r {
+		case 0:
+			return parseRunCommand(syscall.UTF16ToString(buf)), true, nil
+		case errorFileNotFound:
+			return "", false, nil
+		case errorMoreData:
+			continue // size now holds what the value needs
+		default:
+			return "", false, syscall.Errno(r)
+		}
+	}
+	return "", false, syscall.Errno(errorMoreData)
+}
+
+// registerAutostart sets the app's Run key value to start exe.
+func registerAutostart(exe string) error {
+	key, _ := syscall.UTF16PtrFromString(runKey)
+	name, _ := syscall.UTF16PtrFromString(autostartName)
+	data, err := syscall.UTF16FromString(runCommand(exe))
+	if err != nil {
+		return err
+	}
+	r, _, _ := procRegSetKeyValueW.Call(hkeyCurrentUser, uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(name)),
+		regSz, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)*2))
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+// unregisterAutostart deletes the app's Run key value.
+func unregisterAutostart() error {
+	key, _ := syscall.UTF16PtrFromString(runKey)
+	name, _ := syscall.UTF16PtrFromString(autostartName)
+	r, _, _ := procRegDeleteKeyValueW.Call(hkeyCurrentUser, uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(name)))
+	if r != 0 && r != errorFileNotFound {
+		return syscall.Errno(r)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultUpdateInterval is how often usage is polled unless
+	// update_interval is set.
+	defaultUpdateInterval = 5 * time.Minute
+	// minUpdateInterval keeps a typo like "5s" from hammering the API.
+	minUpdateInterval = time.Minute
+	// backoffAfterFailures is how many consecutive failed update cycles keep
+	// the normal interval before polling slows down.
+	backoffAfterFailures = 2
+	// maxBackoffInterval caps the stretched interval.
+	maxBackoffInterval = time.Hour
+	// resetGrace is how long after a limit resets the loop fetches the
+	// new window's numbers, giving the API a moment to roll over.
+	resetGrace = 30 * time.Second
+)
+
+// updateIntervalChoices are the intervals offered in the "Update every"
+// submenu.
+var updateIntervalChoices = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute}
+
+// parseUpdateInterval parses update_interval; empty means the default.
+func parseUpdateInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultUpdateInterval, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("update_interval must be a duration such as \"5m\", got %q", s)
+	}
+	if d < minUpdateInterval {
+		return 0, fmt.Errorf("update_interval must be at least %s, got %q", shortDuration(minUpdateInterval), s)
+	}
+	return d, nil
+}
+
+// updateInterval returns the configured polling interval or the default. A
+// nil config, or one with an invalid value, yields the default.
+func (c *Config) updateInterval() time.Duration {
+	if c == nil {
+		return defaultUpdateInterval
+	}
+	d, err := parseUpdateInterval(c.UpdateInterval)
+	if err != nil {
+		return defaultUpdateInterval
+	}
+	return d
+}
+
+// backoffInterval returns the polling interval after the given number of
+// consecutive failed cycles: base at first, then doubling (10m, 20m,
+// 40m, ... for the default 5m) up to maxBackoffInterval.
+func backoffInterval(base time.Duration, failures int) time.Duration {
+	if failures < backoffAfterFailures || base >= maxBackoffInterval {
+		return base
+	}
+	d := base
+	for i := backoffAfterFailures; i <= failures; i++ {
+		d *= 2
+		if d >= maxBackoffInterval {
+			return maxBackoffInterval
+		}
+	}
+	return d
+}
+
+// updateScheduler tracks the polling interval and consecutive failures
+// for the auto-update loop.
+type updateScheduler struct {
+	mu        sync.Mutex
+	base      time.Duration // update_interval; 0 until set is the default
+	failures  int
+	next      time.Time // when the loop's timer fires; zero until armed
+	nextReset time.Time // the next reset of a used limit; zero if none
+
+	// changed is signaled whenever the interval may have changed, so the
+	// loop can re-arm its timer.
+	changed chan struct{}
+}
+
+var scheduler = updateScheduler{changed: make(chan struct{}, 1)}
+
+// record notes the outcome of an update cycle.
+func (s *updateScheduler) record(ok bool) {
+	s.mu.Lock()
+	if ok {
+		s.failures = 0
+	} else {
+		s.failures++
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// reset returns to the normal interval, e.g. on a manual refresh.
+func (s *updateScheduler) reset() {
+	s.mu.Lock()
+	s.failures = 0
+	s.mu.Unlock()
+	s.notify()
+}
+
+// setBase changes the normal interval, re-arming the loop's timer if it
+// differs.
+func (s *updateScheduler) setBase(d time.Duration) {
+	s.mu.Lock()
+	changed := d != s.baseLocked()
+	s.base = d
+	s.mu.Unlock()
+	if changed {
+		s.notify()
+	}
+}
+
+// baseInterval is the normal interval, without backoff.
+func (s *updateScheduler) baseInterval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.baseLocked()
+}
+
+func (s *updateScheduler) baseLocked() time.Duration {
+	if s.base == 0 {
+		return defaultUpdateInterval
+	}
+	return s.base
+}
+
+// interval is the wait before the next automatic update.
+func (s *updateScheduler) interval() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return backoffInterval(s.baseLocked(), s.failures)
+}
+
+// jittered returns the next wait with jitter of ±10% of the normal
+// interval (±30s at 5m) to avoid predictable request patterns.
+func (s *updateScheduler) jittered() time.Duration {
+	spread := int64(s.baseInterval() / 10)
+	return s.interval() + time.Duration(rand.Int63n(2*spread+1)-spread)
+}
+
+// setNextReset notes when the next used limit resets, zero for none.
+func (s *updateScheduler) setNextReset(t time.Time) {
+	s.mu.Lock()
+	s.nextReset = t
+	s.mu.Unlock()
+}
+
+// wait is how long the loop waits for the next update: the jittered
+// interval, cut short to fetch the new window's numbers shortly after a
+// limit resets.
+func (s *updateScheduler) wait(now time.Time) time.Duration {
+	d := s.jittered()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nextReset.IsZero() {
+		return d
+	}
+	if early := s.nextReset.Add(resetGrace).Sub(now); early > 0 && early < d {
+		return early
+	}
+	return d
+}
+
+// armed notes that the loop's timer fires after wait, for the Next check
+// row.
+func (s *updateScheduler) armed(wait time.Duration, now time.Time) {
+	s.mu.Lock()
+	s.next = now.Add(wait)
+	s.mu.Unlock()
+}
+
+// nextCheck is when the next automatic update starts, zero before the
+// loop has armed its timer.
+func (s *updateScheduler) nextCheck() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// failing reports whether the last update cycle failed.
+func (s *updateScheduler) failing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failures > 0
+}
+
+// backingOff returns the stretched interval, or 0 at the normal rate.
+func (s *updateScheduler) backingOff() time.Duration {
+	if d := s.interval(); d > s.baseInterval() {
+		return d
+	}
+	return 0
+}
+
+func (s *updateScheduler) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
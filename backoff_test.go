@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffInterval(t *testing.T) {
+	for _, tc := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{4, 40 * time.Minute},
+		{5, time.Hour},
+		{1000, time.Hour}, // no overflow
+	} {
+		if got := backoffInterval(defaultUpdateInterval, tc.failures); got != tc.want {
+			t.Errorf("backoffInterval(%d) = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestSchedulerBackoff(t *testing.T) {
+	s := updateScheduler{changed: make(chan struct{}, 1)}
+	want := func(interval, backingOff time.Duration) {
+		t.Helper()
+		if got := s.interval(); got != interval {
+			t.Errorf("interval = %v, want %v", got, interval)
+		}
+		if got := s.backingOff(); got != backingOff {
+			t.Errorf("backingOff = %v, want %v", got, backingOff)
+		}
+	}
+
+	want(defaultUpdateInterval, 0)
+	s.record(false)
+	want(defaultUpdateInterval, 0)
+	s.record(false)
+	want(10*time.Minute, 10*time.Minute)
+	s.record(false)
+	want(20*time.Minute, 20*time.Minute)
+
+	s.reset() // Refresh now
+	want(defaultUpdateInterval, 0)
+
+	s.record(false)
+	s.record(false)
+	if !s.failing() {
+		t.Error("not failing after two failures")
+	}
+	s.record(true) // first success
+	want(defaultUpdateInterval, 0)
+	if s.failing() {
+		t.Error("still failing after a success")
+	}
+}
+
+func TestSchedulerBase(t *testing.T) {
+	s := updateScheduler{changed: make(chan struct{}, 1)}
+	s.setBase(defaultUpdateInterval)
+	select {
+	case <-s.changed:
+		t.Error("setting the default re-armed the timer")
+	default:
+	}
+
+	s.setBase(15 * time.Minute)
+	select {
+	case <-s.changed:
+	default:
+		t.Error("a new interval didn't re-arm the timer")
+	}
+	s.record(false)
+	s.record(false)
+	if got := s.interval(); got != 30*time.Minute {
+		t.Errorf("backoff from 15m = %v, want 30m", got)
+	}
+	s.reset()
+	for i := 0; i < 100; i++ {
+		if d := s.jittered(); d < 13*time.Minute+30*time.Second || d > 16*time.Minute+30*time.Second {
+			t.Fatalf("jittered 15m = %v, want within ±10%%", d)
+		}
+	}
+}
+
+func TestUpdateIntervalSetting(t *testing.T) {
+	var nilCfg *Config
+	if nilCfg.updateInterval() != defaultUpdateInterval {
+		t.Error("nil config doesn't yield the default")
+	}
+	path := writeTestConfig(t, `{"session_key": "sk", "org_id": "org", "update_interval": "15m"}`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.updateInterval(); got != 15*time.Minute {
+		t.Errorf("updateInterval() = %s, want 15m", got)
+	}
+	for _, bad := range []string{"5", "30s"} {
+		path := writeTestConfig(t, `{"session_key": "sk", "org_id": "org", "update_interval": "`+bad+`"}`)
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("update_interval %q accepted", bad)
+		}
+	}
+}
+
+func TestShowUpdateErrorRetry(t *testing.T) {
+	saved := accessibleText.Load()
+	defer accessibleText.Store(saved)
+	for _, tc := range []struct {
+		accessible bool
+		retryIn    time.Duration
+		want       string
+	}{
+		{false, 0, appName + ": API error"},
+		{false, 20 * time.Minute, appName + ": API error — retrying in 20m"},
+		{true, 20 * time.Minute, appName + ": API error, retrying in 20 minutes"},
+	} {
+		accessibleText.Store(tc.accessible)
+		var st uiState
+		showUpdateError(&st, errors.New("HTTP 503"), tc.retryIn)
+		if st.tooltip != tc.want {
+			t.Errorf("tooltip = %q, want %q", st.tooltip, tc.want)
+		}
+	}
+}
+
+func TestSchedulerWaitForReset(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	s := updateScheduler{changed: make(chan struct{}, 1)}
+	s.setNextReset(now.Add(90 * time.Second))
+	if got, want := s.wait(now), 2*time.Minute; got != want {
+		t.Errorf("reset in 90s: wait %v, want %v", got, want)
+	}
+	// Once the reset has passed, the interval applies again
+	if got := s.wait(now.Add(3 * time.Minute)); got < 4*time.Minute {
+		t.Errorf("after the reset: wait %v, want about 5m", got)
+	}
+	s.setNextReset(now.Add(time.Hour))
+	if got := s.wait(now); got > 6*time.Minute {
+		t.Errorf("reset in an hour: wait %v, want about 5m", got)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// presenceDebouncePolls is how many consecutive polls must agree on a
+// per-model bucket appearing or disappearing before the UI follows.
+const presenceDebouncePolls = 3
+
+// bucketPresence stabilizes an optional bucket (e.g. seven_day_sonnet) that
+// may flip between present and absent across polls when the account's
+// per-model limits are being changed server-side.
+type bucketPresence struct {
+	mu      sync.Mutex
+	name    string
+	known   bool         // false until the first observation
+	present bool         // presence state currently shown
+	pending int          // consecutive polls disagreeing with present
+	last    *UsageBucket // last non-nil value, shown while a removal is pending
+}
+
+// presenceEvent describes a confirmed change of a bucket's presence.
+type presenceEvent int
+
+const (
+	presenceUnchanged presenceEvent = iota
+	presenceAdded
+	presenceRemoved
+)
+
+// observe records one poll's value and returns the bucket to display
+// (nil means "n/a") plus whether the shown presence state just changed.
+func (p *bucketPresence) observe(b *UsageBucket) (*UsageBucket, presenceEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if b != nil {
+		cp := *b
+		p.last = &cp
+	}
+
+	if !p.known {
+		p.known = true
+		p.present = b != nil
+		return b, presenceUnchanged
+	}
+
+	if (b != nil) == p.present {
+		p.pending = 0
+		return p.shown(b), presenceUnchanged
+	}
+
+	p.pending++
+	if p.pending < presenceDebouncePolls {
+		apiLog.Infof("%s bucket %s (%d/%d polls), keeping previous state",
+			p.name, presenceWord(b != nil), p.pending, presenceDebouncePolls)
+		return p.shown(b), presenceUnchanged
+	}
+
+	p.pending = 0
+	p.present = b != nil
+	ev := presenceRemoved
+	if p.present {
+		ev = presenceAdded
+	}
+	apiLog.Infof("%s bucket %s", p.name, presenceWord(p.present))
+	return p.shown(b), ev
+}
+
+// shown returns what the row should display for the current presence state.
+func (p *bucketPresence) shown(b *UsageBucket) *UsageBucket {
+	if !p.present {
+		return nil
+	}
+	if b != nil {
+		return b
+	}
+	return p.last
+}
+
+// bucketChange is a confirmed change of a per-model bucket's presence,
+// recorded in the history so that its series ends or starts over there.
+type bucketChange struct {
+	bucket string // its key, e.g. "seven_day_sonnet"
+	event  presenceEvent
+}
+
+// appendBucketChange appends ev for bucket to changes unless it is
+// presenceUnchanged.
+func appendBucketChange(changes []bucketChange, bucket string, ev presenceEvent) []bucketChange {
+	if ev == presenceUnchanged {
+		return changes
+	}
+	return append(changes, bucketChange{bucket: bucket, event: ev})
+}
+
+// sample is c as a history line at t.
+func (c bucketChange) sample(t time.Time) historySample {
+	ev := historyBucketRemoved
+	if c.event == presenceAdded {
+		ev = historyBucketAdded
+	}
+	return historySample{Time: t, Event: ev, Bucket: c.bucket}
+}
+
+func presenceWord(present bool) string {
+	if present {
+		return "added"
+	}
+	return "removed"
+}
+
+// maxMenuLine caps rendered menu rows so long reset strings can't blow up
+// the menu width.
+const maxMenuLine = 64
+
+// bucketLineOpts controls how renderBucketLine formats a row.
+type bucketLineOpts struct {
+	// Stale, when set, marks restored data with its age (e.g. "3m ago").
+	Stale string
+	// Accessible renders words instead of glyphs and abbreviations.
+	Accessible bool
+	// ResetFormat is reset_format: relative (the default when empty),
+	// absolute or both.
+	ResetFormat string
+}
+
+// renderBucketLine formats one usage row, e.g.
+// "Session (5h): 42% (3m ago) — resets in 2h 13m". A nil bucket renders as
+// "n/a"; a bucket without a reset time omits the reset part. All bucket rows
+// go through here so wording and ordering stay uniform.
+//
+// In accessible mode the row reads "Session (5h): 42 percent used, running
+// low, resets in 2 hours 13 minutes", spelling out the level that the icon
+// otherwise shows only by color.
+func renderBucketLine(name string, b *UsageBucket, opts bucketLineOpts) string {
+	return newBucketLine(name, b, opts).render()
+}
+
+// bucketLine is what a usage row is rendered from. uiState keeps it so the
+// reset countdown can be rendered again between updates.
+type bucketLine struct {
+	name        string
+	present     bool // false renders as n/a
+	utilization float64
+	resetsAt    time.Time   // zero without a reset time
+	trend       bucketTrend // marked after the percentage when known
+	opts        bucketLineOpts
+}
+
+func newBucketLine(name string, b *UsageBucket, opts bucketLineOpts) *bucketLine {
+	l := &bucketLine{name: name, opts: opts}
+	if b != nil {
+		l.present = true
+		l.utilization = b.Utilization
+		l.resetsAt = resetTime(b.ResetsAt)
+	}
+	return l
+}
+
+// render formats the row at timeNow; past its reset time it says
+// "resetting…" until the next update brings the new window.
+func (l *bucketLine) render() string {
+	name, opts := l.name, l.opts
+	if !l.present {
+		if opts.Accessible {
+			return name + ": not available"
+		}
+		return name + ": n/a"
+	}
+	now := timeNow()
+	resetting := !l.resetsAt.IsZero() && !l.resetsAt.After(now)
+	if opts.Accessible {
+		line := fmt.Sprintf("%s: %d percent used", name, int(l.utilization))
+		if t := l.trend.glyph(true); t != "" {
+			line += ", " + t
+		}
+		if w := levelWord(l.utilization); w != "" {
+			line += ", " + w
+		}
+		if opts.Stale != "" {
+			line += ", as of " + opts.Stale
+		}
+		if resetting {
+			line += ", resetting"
+		} else if !l.resetsAt.IsZero() {
+			line += ", resets " + resetText(l.resetsAt, now, opts.ResetFormat, true)
+		}
+		return truncate(line, maxMenuLine)
+	}
+
+	line := fmt.Sprintf("%s: %d%%", name, int(l.utilization))
+	if t := l.trend.glyph(false); t != "" {
+		line += " " + t
+	}
+	if opts.Stale != "" {
+		line += " (" + opts.Stale + ")"
+	}
+	if resetting {
+		line += " — resetting…"
+	} else if !l.resetsAt.IsZero() {
+		line += " — resets " + resetText(l.resetsAt, now, opts.ResetFormat, false)
+	}
+	return truncate(line, maxMenuLine)
+}
+
+// renderExtraUsageLine formats the pay-as-you-go row, e.g.
+// "Extra usage: $12.34 / $50.00 (25%)", prefixed with a warning mark once
+// utilization reaches warnPct.
+func renderExtraUsageLine(e *ExtraUsage, warnPct float64, opts bucketLineOpts) string {
+	money := func(v *float64) string {
+		if v == nil {
+			return "?"
+		}
+		return formatMoney(*v)
+	}
+
+	of, percent := " / ", "%"
+	if opts.Accessible {
+		of, percent = " of ", " percent"
+	}
+	line := "Extra usage: " + money(e.UsedCredits)
+	if e.MonthlyLimit != nil {
+		line += of + money(e.MonthlyLimit)
+	} else {
+		line += " (no cap)"
+	}
+	pct, ok := e.utilization()
+	if ok {
+		line += fmt.Sprintf(" (%d%s)", int(pct), percent)
+	}
+	if opts.Stale != "" {
+		line += " (" + opts.Stale + ")"
+	}
+	if ok && pct >= warnPct {
+		line = mark(markWarning, opts.Accessible) + " " + line
+	}
+	return truncate(line, maxMenuLine)
+}
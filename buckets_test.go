@@ -0,0 +1,174 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// flipPolls is a Sonnet bucket going away for two polls (a glitch), then
+// for good, then coming back.
+var flipPolls = []*UsageBucket{
+	{Utilization: 40}, {Utilization: 41}, nil, nil, {Utilization: 42},
+	nil, nil, nil, nil, {Utilization: 5}, {Utilization: 6}, {Utilization: 7},
+}
+
+func TestBucketPresenceDebounce(t *testing.T) {
+	p := &bucketPresence{name: "Sonnet"}
+	want := []struct {
+		shown float64 // -1 for n/a
+		event presenceEvent
+	}{
+		{40, presenceUnchanged},
+		{41, presenceUnchanged},
+		{41, presenceUnchanged}, // missing once or twice: last value kept
+		{41, presenceUnchanged},
+		{42, presenceUnchanged},
+		{42, presenceUnchanged},
+		{42, presenceUnchanged},
+		{-1, presenceRemoved}, // third poll in a row without it
+		{-1, presenceUnchanged},
+		{-1, presenceUnchanged},
+		{-1, presenceUnchanged},
+		{7, presenceAdded},
+	}
+	for i, b := range flipPolls {
+		shown, ev := p.observe(b)
+		got := -1.0
+		if shown != nil {
+			got = shown.Utilization
+		}
+		if got != want[i].shown || ev != want[i].event {
+			t.Errorf("poll %d: shown %v, event %v; want %v, %v", i, got, ev, want[i].shown, want[i].event)
+		}
+	}
+}
+
+func TestRenderBucketLineStates(t *testing.T) {
+	// Half a minute of slack, so the minutes don't tick over during the test
+	at := func(d time.Duration) string { return time.Now().Add(d + 30*time.Second).Format(time.RFC3339) }
+	for _, tc := range []struct {
+		state string
+		name  string
+		b     *UsageBucket
+		opts  bucketLineOpts
+		want  string
+	}{
+		{"normal", "Session (5h)", &UsageBucket{Utilization: 42, ResetsAt: at(2*time.Hour + 13*time.Minute)}, bucketLineOpts{},
+			"Session (5h): 42% — resets in 2h 13m"},
+		{"days away", "Weekly", &UsageBucket{Utilization: 85, ResetsAt: at(74 * time.Hour)}, bucketLineOpts{},
+			"Weekly: 85% — resets in 3d 2h"},
+		{"no reset", "Sonnet", &UsageBucket{Utilization: 42}, bucketLineOpts{},
+			"Sonnet: 42%"},
+		{"reset passed", "Session (5h)", &UsageBucket{Utilization: 99, ResetsAt: at(-time.Hour)}, bucketLineOpts{},
+			"Session (5h): 99% — resetting…"},
+		{"unparsable reset", "Session (5h)", &UsageBucket{Utilization: 99, ResetsAt: "tomorrow"}, bucketLineOpts{},
+			"Session (5h): 99%"},
+		{"stale", "Session (5h)", &UsageBucket{Utilization: 42, ResetsAt: at(0)}, bucketLineOpts{Stale: "3m ago"},
+			"Session (5h): 42% (3m ago) — resets in 0m"},
+		{"missing", "Sonnet", nil, bucketLineOpts{Stale: "3m ago"},
+			"Sonnet: n/a"},
+		{"truncated", "Weekly · Sonnet only (7d)", &UsageBucket{Utilization: 42, ResetsAt: at(74 * time.Hour)}, bucketLineOpts{Stale: "12 minutes ago"},
+			"Weekly · Sonnet only (7d): 42% (12 minutes ago) — resets in 3d …"},
+		{"accessible", "Session (5h)", &UsageBucket{Utilization: 42, ResetsAt: at(2*time.Hour + 13*time.Minute)}, bucketLineOpts{Accessible: true},
+			"Session (5h): 42 percent used, resets in 2 hours 13 minutes"},
+		{"accessible low", "Weekly", &UsageBucket{Utilization: 85}, bucketLineOpts{Accessible: true, Stale: "3m ago"},
+			"Weekly: 85 percent used, nearly exhausted, as of 3m ago"},
+		{"accessible missing", "Sonnet", nil, bucketLineOpts{Accessible: true},
+			"Sonnet: not available"},
+		{"accessible reset passed", "Session (5h)", &UsageBucket{Utilization: 99, ResetsAt: at(-time.Hour)}, bucketLineOpts{Accessible: true},
+			"Session (5h): 99 percent used, nearly exhausted, resetting"},
+	} {
+		got := renderBucketLine(tc.name, tc.b, tc.opts)
+		if got != tc.want {
+			t.Errorf("%s:\n got %q\nwant %q", tc.state, got, tc.want)
+		}
+		if utf8.RuneCountInString(got) > maxMenuLine {
+			t.Errorf("%s: %d runes, more than %d", tc.state, utf8.RuneCountInString(got), maxMenuLine)
+		}
+	}
+}
+
+func TestFormatReset(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	for _, tc := range []struct {
+		left          time.Duration
+		want, spelled string
+	}{
+		{30 * time.Second, "in 0m", "in 0 minutes"},
+		{59*time.Minute + 59*time.Second, "in 59m", "in 59 minutes"},
+		{time.Hour, "in 1h 0m", "in 1 hour 0 minutes"},
+		{2*time.Hour + 13*time.Minute, "in 2h 13m", "in 2 hours 13 minutes"},
+		{23*time.Hour + 59*time.Minute, "in 23h 59m", "in 23 hours 59 minutes"},
+		{24 * time.Hour, "in 1d 0h", "in 1 day 0 hours"},
+		{74 * time.Hour, "in 3d 2h", "in 3 days 2 hours"},
+		{0, "soon", "soon"},
+		{-time.Minute, "soon", "soon"},
+	} {
+		at := now.Add(tc.left)
+		if got := formatReset(at); got != tc.want {
+			t.Errorf("formatReset(%v) = %q, want %q", tc.left, got, tc.want)
+		}
+		if got := spellReset(at); got != tc.spelled {
+			t.Errorf("spellReset(%v) = %q, want %q", tc.left, got, tc.spelled)
+		}
+	}
+	if got := formatReset(time.Time{}); got != "?" {
+		t.Errorf("formatReset(zero) = %q, want ?", got)
+	}
+}
+
+func TestBucketLinesCountDown(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	b := &UsageBucket{Utilization: 42, ResetsAt: now.Add(2 * time.Minute).Format(time.RFC3339)}
+	st := uiState{session: "Session (5h): 42% — resets in 2m", weekly: "✗ kept as is"}
+	st.lines[0] = newBucketLine("Session (5h)", b, bucketLineOpts{})
+
+	now = now.Add(time.Minute)
+	st.renderLines()
+	if want := "Session (5h): 42% — resets in 1m"; st.session != want {
+		t.Errorf("after a minute: %q, want %q", st.session, want)
+	}
+	now = now.Add(time.Minute)
+	st.renderLines()
+	if want := "Session (5h): 42% — resetting…"; st.session != want {
+		t.Errorf("at the reset: %q, want %q", st.session, want)
+	}
+	if st.weekly != "✗ kept as is" {
+		t.Errorf("row without a line changed: %q", st.weekly)
+	}
+}
+
+func TestRenderExtraUsageLine(t *testing.T) {
+	t.Setenv("LC_ALL", "C")
+	used, limit := 12.34, 50.0
+	for _, tc := range []struct {
+		state string
+		e     *ExtraUsage
+		opts  bucketLineOpts
+		want  string
+	}{
+		{"capped", &ExtraUsage{UsedCredits: &used, MonthlyLimit: &limit}, bucketLineOpts{}, "Extra usage: $12.34 / $50.00 (24%)"},
+		{"unlimited", &ExtraUsage{UsedCredits: &used}, bucketLineOpts{}, "Extra usage: $12.34 (no cap)"},
+		{"stale", &ExtraUsage{UsedCredits: &used, MonthlyLimit: &limit}, bucketLineOpts{Stale: "3m ago"}, "Extra usage: $12.34 / $50.00 (24%) (3m ago)"},
+		{"nothing used", &ExtraUsage{MonthlyLimit: &limit}, bucketLineOpts{}, "Extra usage: ? / $50.00"},
+		{"accessible", &ExtraUsage{UsedCredits: &used, MonthlyLimit: &limit}, bucketLineOpts{Accessible: true}, "Extra usage: $12.34 of $50.00 (24 percent)"},
+	} {
+		if got := renderExtraUsageLine(tc.e, 80, tc.opts); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.state, got, tc.want)
+		}
+	}
+	over := 45.0
+	got := renderExtraUsageLine(&ExtraUsage{UsedCredits: &over, MonthlyLimit: &limit}, 80, bucketLineOpts{})
+	if want := "⚠ Extra usage: $45.00 / $50.00 (90%)"; got != want {
+		t.Errorf("over the warning: got %q, want %q", got, want)
+	}
+}
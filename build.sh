@@ -15,8 +15,13 @@ echo "-> Downloading dependencies..."
 go mod tidy
 echo ""
 
-echo "-> Building for Windows (amd64)..."
-CGO_ENABLED=0 GOOS=windows GOARCH=amd64 go build -ldflags="-s -w -H windowsgui" -o claude-monitor.exe .
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse HEAD 2>/dev/null || true)
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+LDFLAGS="-X main.version=$VERSION -X main.commit=$COMMIT -X main.buildDate=$BUILD_DATE"
+
+echo "-> Building $VERSION for Windows (amd64)..."
+CGO_ENABLED=0 GOOS=windows GOARCH=amd64 go build -ldflags="-s -w -H windowsgui $LDFLAGS" -o claude-monitor.exe .
 
 if [ -f "claude-monitor.exe" ]; then
     SIZE=$(du -h claude-monitor.exe | cut -f1)
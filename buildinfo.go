@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.4.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    string
+	buildDate string
+)
+
+// buildInfo identifies the running build, for --version, About, the
+// startup log line and bug reports.
+type buildInfo struct {
+	Version, Commit, Date string
+	GoVersion, Platform   string
+}
+
+// currentBuild returns the ldflags values. What they leave unset is taken
+// from what the Go toolchain stamps into the binary: the module version
+// of a "go install …@v1.4.0", the commit and its time of a build in a git
+// checkout.
+func currentBuild() buildInfo {
+	b := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	if b.Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		b.Version = info.Main.Version
+	}
+	dirty := false
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && b.Commit == "":
+			b.Commit = s.Value
+		case s.Key == "vcs.time" && b.Date == "":
+			b.Date = s.Value
+		case s.Key == "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty && commit == "" && b.Commit != "" {
+		b.Commit += "-dirty"
+	}
+	return b
+}
+
+// shortCommit is the commit as git abbreviates it, keeping a "-dirty"
+// suffix.
+func (b buildInfo) shortCommit() string {
+	c, suffix := b.Commit, ""
+	if strings.HasSuffix(c, "-dirty") {
+		c, suffix = strings.TrimSuffix(c, "-dirty"), "-dirty"
+	}
+	if len(c) > 7 {
+		c = c[:7]
+	}
+	return c + suffix
+}
+
+// String is the build on one line: "v1.4.0 (abc1234, built 2026-10-17T12:00:00Z)".
+func (b buildInfo) String() string {
+	var details []string
+	if c := b.shortCommit(); c != "" {
+		details = append(details, c)
+	}
+	if b.Date != "" {
+		details = append(details, "built "+b.Date)
+	}
+	if len(details) == 0 {
+		return b.Version
+	}
+	return b.Version + " (" + strings.Join(details, ", ") + ")"
+}
+
+// text is the build on several lines, for --version and About.
+func (b buildInfo) text() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%s %s\n", appName, b.Version)
+	if b.Commit != "" {
+		fmt.Fprintf(&s, "Commit:   %s\n", b.Commit)
+	}
+	if b.Date != "" {
+		fmt.Fprintf(&s, "Built:    %s\n", b.Date)
+	}
+	fmt.Fprintf(&s, "Go:       %s %s\n", b.GoVersion, b.Platform)
+	return s.String()
+}
+
+// aboutText is what About shows: the build, and where its files are.
+func aboutText() string {
+	return currentBuild().text() +
+		"\n" +
+		"Config:   " + configPath + "\n" +
+		"Log:      " + paths.logFile() + "\n" +
+		"\n" +
+		"https://github.com/Nocturnal-ru/claude-monitor\n" +
+		"Please include the lines above in a bug report.\n"
+}
+
+// showAbout writes aboutText to about.txt in the state directory and
+// opens it, which works the same on every platform the tray runs on.
+func showAbout() error {
+	path := filepath.Join(paths.stateDir, "about.txt")
+	if err := os.WriteFile(path, []byte(aboutText()), 0644); err != nil {
+		return err
+	}
+	openPath(path)
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildInfoString(t *testing.T) {
+	for _, tc := range []struct {
+		b    buildInfo
+		want string
+	}{
+		{buildInfo{Version: "v1.4.0", Commit: "0123456789abcdef", Date: "2026-10-17T12:00:00Z"}, "v1.4.0 (0123456, built 2026-10-17T12:00:00Z)"},
+		{buildInfo{Version: "dev", Commit: "0123456789abcdef-dirty"}, "dev (0123456-dirty)"},
+		{buildInfo{Version: "dev"}, "dev"},
+	} {
+		if got := tc.b.String(); got != tc.want {
+			t.Errorf("%+v: %q, want %q", tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCurrentBuildFromLdflags(t *testing.T) {
+	savedVersion, savedCommit, savedDate := version, commit, buildDate
+	t.Cleanup(func() { version, commit, buildDate = savedVersion, savedCommit, savedDate })
+	version, commit, buildDate = "v1.4.0", "0123456789abcdef", "2026-10-17T12:00:00Z"
+
+	b := currentBuild()
+	if b.Version != "v1.4.0" || b.Commit != commit || b.Date != buildDate {
+		t.Errorf("build %+v", b)
+	}
+	text := b.text()
+	for _, want := range []string{appName + " v1.4.0\n", "Commit:   0123456789abcdef\n", "Built:    2026-10-17T12:00:00Z\n", "Go:       go"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// chromeBrowser is a Chromium-based browser whose cookie store can be read.
+type chromeBrowser struct {
+	name string
+	// userData is the "User Data" directory relative to the platform base
+	// (%LOCALAPPDATA% on Windows, ~/.config on Linux, ~/Library/Application
+	// Support on macOS).
+	userData map[string]string
+	// keyring names the macOS Keychain item "<keyring> Safe Storage".
+	keyring string
+	// keyringApp is the Secret Service "application" attribute on Linux.
+	keyringApp string
+	// roaming puts userData under %APPDATA% on Windows, as Electron apps do.
+	roaming bool
+	// singleProfile means the cookie store is in userData itself rather
+	// than in a profile subdirectory.
+	singleProfile bool
+}
+
+var chromeBrowsers = []chromeBrowser{
+	{
+		name: "Chrome",
+		userData: map[string]string{
+			"windows": `Google\Chrome\User Data`,
+			"linux":   "google-chrome",
+			"darwin":  "Google/Chrome",
+		},
+		keyring:    "Chrome",
+		keyringApp: "chrome",
+	},
+	{
+		name: "Chromium",
+		userData: map[string]string{
+			"windows": `Chromium\User Data`,
+			"linux":   "chromium",
+			"darwin":  "Chromium",
+		},
+		keyring:    "Chromium",
+		keyringApp: "chromium",
+	},
+	{
+		name: "Edge",
+		userData: map[string]string{
+			"windows": `Microsoft\Edge\User Data`,
+			"linux":   "microsoft-edge",
+			"darwin":  "Microsoft Edge",
+		},
+		keyring:    "Microsoft Edge",
+		keyringApp: "microsoft-edge",
+	},
+	{
+		name: "Brave",
+		userData: map[string]string{
+			"windows": `BraveSoftware\Brave-Browser\User Data`,
+			"linux":   "BraveSoftware/Brave-Browser",
+			"darwin":  "BraveSoftware/Brave-Browser",
+		},
+		keyring:    "Brave",
+		keyringApp: "brave",
+	},
+	{
+		name: "Vivaldi",
+		userData: map[string]string{
+			"windows": `Vivaldi\User Data`,
+			"linux":   "vivaldi",
+			"darwin":  "Vivaldi",
+		},
+		keyring:    "Vivaldi",
+		keyringApp: "vivaldi",
+	},
+	{
+		// Opera keeps "Opera Stable" under %APPDATA% and, before it had
+		// profiles, the cookie store directly in it
+		name: "Opera",
+		userData: map[string]string{
+			"windows": `Opera Software\Opera Stable`,
+			"linux":   "opera",
+			"darwin":  "com.operasoftware.Opera",
+		},
+		keyring:    "Opera",
+		keyringApp: "opera",
+		roaming:    true,
+	},
+}
+
+// chromeBrowserNamed returns the entry of chromeBrowsers called name, as
+// set in chrome_browser (case-insensitive).
+func chromeBrowserNamed(name string) (chromeBrowser, error) {
+	name = strings.TrimSpace(name)
+	var names []string
+	for _, b := range chromeBrowsers {
+		if strings.EqualFold(name, b.name) {
+			return b, nil
+		}
+		names = append(names, strings.ToLower(b.name))
+	}
+	return chromeBrowser{}, fmt.Errorf("chrome_browser %q: must be one of %s", name, strings.Join(names, ", "))
+}
+
+// claudeDesktop is the Claude desktop app. Being an Electron app, it keeps
+// a Chromium cookie store, encrypted the same way, in its own directory.
+var claudeDesktop = chromeBrowser{
+	name: "Claude Desktop",
+	userData: map[string]string{
+		"windows": "Claude",
+		"linux":   "Claude",
+		"darwin":  "Claude",
+	},
+	keyring:       "Claude",
+	keyringApp:    "Claude",
+	roaming:       true,
+	singleProfile: true,
+}
+
+// userDataDir returns the browser's "User Data" directory on this OS.
+func (b chromeBrowser) userDataDir() (string, error) {
+	rel, ok := b.userData[runtime.GOOS]
+	if !ok {
+		return "", fmt.Errorf("%s import is not supported on %s", b.name, runtime.GOOS)
+	}
+	var base string
+	switch runtime.GOOS {
+	case "windows":
+		env := "LOCALAPPDATA"
+		if b.roaming {
+			env = "APPDATA"
+		}
+		base = os.Getenv(env)
+		if base == "" {
+			return "", fmt.Errorf("%s environment variable not set", env)
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("getting home directory: %w", err)
+		}
+		base = filepath.Join(home, "Library", "Application Support")
+	default:
+		if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+			base = dir
+		} else {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("getting home directory: %w", err)
+			}
+			base = filepath.Join(home, ".config")
+		}
+	}
+	dir := filepath.Join(base, filepath.FromSlash(rel))
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("%s directory not found: %s", b.name, dir)
+	}
+	return dir, nil
+}
+
+// chromeLocalState is the part of "Local State" the import needs.
+type chromeLocalState struct {
+	Profile struct {
+		LastUsed string `json:"last_used"`
+	} `json:"profile"`
+	OSCrypt struct {
+		EncryptedKey string `json:"encrypted_key"`
+	} `json:"os_crypt"`
+}
+
+func readChromeLocalState(userDataDir string) (*chromeLocalState, error) {
+	data, err := os.ReadFile(filepath.Join(userDataDir, "Local State"))
+	if err != nil {
+		return nil, fmt.Errorf("reading Local State: %w", err)
+	}
+	var ls chromeLocalState
+	if err := json.Unmarshal(data, &ls); err != nil {
+		return nil, fmt.Errorf("parsing Local State: %w", err)
+	}
+	return &ls, nil
+}
+
+// chromeDefaultProfile returns the last used profile directory, or
+// "Default" when Local State doesn't say.
+func chromeDefaultProfile(userDataDir string, ls *chromeLocalState) string {
+	name := "Default"
+	if ls != nil && ls.Profile.LastUsed != "" {
+		name = ls.Profile.LastUsed
+	}
+	return filepath.Join(userDataDir, name)
+}
+
+// profileDir returns the profile imports read from: the last used one, or
+// userDataDir itself for single-profile stores and for older Opera, whose
+// profile directory has no cookie database.
+func (b chromeBrowser) profileDir(userDataDir string, ls *chromeLocalState) string {
+	if b.singleProfile {
+		return userDataDir
+	}
+	dir := chromeDefaultProfile(userDataDir, ls)
+	if _, err := chromeCookiesFile(dir); err != nil {
+		if _, uerr := chromeCookiesFile(userDataDir); uerr == nil {
+			return userDataDir
+		}
+	}
+	return dir
+}
+
+// installed reports whether b has a cookie database to import from.
+func (b chromeBrowser) installed() bool {
+	userDataDir, err := b.userDataDir()
+	if err != nil {
+		return false
+	}
+	ls, _ := readChromeLocalState(userDataDir)
+	_, err = chromeCookiesFile(b.profileDir(userDataDir, ls))
+	return err == nil
+}
+
+// chromeCookiesFile returns the profile's cookie database. Chrome 96 moved
+// it into the Network subdirectory.
+func chromeCookiesFile(profileDir string) (string, error) {
+	for _, p := range []string{
+		filepath.Join(profileDir, "Network", "Cookies"),
+		filepath.Join(profileDir, "Cookies"),
+	} {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no Cookies database in %s", profileDir)
+}
+
+// findChromeCookies searches the default profile of each supported
+// Chromium-based browser for claude.ai cookies and returns the first one
+// that has a sessionKey, with the browser's name.
+func findChromeCookies() (browser, sessionKey, orgID, cfClearance string, err error) {
+	var errs []error
+	for _, b := range chromeBrowsers {
+		sk, org, cfc, err := chromeCookiesFrom(b)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return b.name, sk, org, cfc, nil
+	}
+	return "", "", "", "", errors.Join(errs...)
+}
+
+// findClaudeDesktopCookies reads claude.ai cookies from the Claude desktop
+// app, for people who don't use claude.ai in a browser.
+func findClaudeDesktopCookies() (sessionKey, orgID, cfClearance string, err error) {
+	return chromeCookiesFrom(claudeDesktop)
+}
+
+// chromeCookiesFrom reads the credentials from b's default profile.
+func chromeCookiesFrom(b chromeBrowser) (sessionKey, orgID, cfClearance string, err error) {
+	report, err := importChromeDefault(b)
+	if err != nil {
+		return "", "", "", err
+	}
+	if sessionKey, orgID, cfClearance, err = report.credentials(); err != nil {
+		return "", "", "", fmt.Errorf("%s: %w", b.name, err)
+	}
+	recordSessionKey(report.Selected["sessionKey"])
+	if row, ok := report.Selected["cf_clearance"]; ok {
+		recordClearance(row)
+	}
+	return sessionKey, orgID, cfClearance, nil
+}
+
+// importChromeDefault reads the default profile of b.
+func importChromeDefault(b chromeBrowser) (*importReport, error) {
+	userDataDir, err := b.userDataDir()
+	if err != nil {
+		return nil, err
+	}
+	ls, err := readChromeLocalState(userDataDir)
+	if err != nil {
+		firefoxLog.Infof("%s: %v", b.name, err)
+	}
+	profileDir := b.profileDir(userDataDir, ls)
+	firefoxLog.Infof("%s profile: %s", b.name, profileDir)
+	return importChromeProfile(b, profileDir, ls)
+}
+
+// importChromeProfile reads and decrypts claude.ai cookies from profileDir.
+func importChromeProfile(b chromeBrowser, profileDir string, ls *chromeLocalState) (*importReport, error) {
+	dbPath, err := chromeCookiesFile(profileDir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.name, err)
+	}
+	data, err := readLockedFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s cookies (close %s and retry): %w", b.name, b.name, err)
+	}
+	cipher, err := newChromeCipher(b, ls)
+	if err != nil {
+		return nil, fmt.Errorf("%s cookie key: %w", b.name, err)
+	}
+	rows, err := parseChromeCookies(data, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s cookies: %w", b.name, err)
+	}
+	firefoxLog.Infof("Found %d claude.ai cookies in %s profile", len(rows), b.name)
+
+	selected := make(map[string]cookieRow)
+	for _, r := range rows {
+		selected[r.Name] = r
+	}
+	return &importReport{
+		Browser:    b.name,
+		ProfileDir: profileDir,
+		Rows:       rows,
+		Selected:   selected,
+		Decision:   "last row per cookie name",
+	}, nil
+}
+
+// chromeCipher decrypts encrypted_value blobs for one browser.
+type chromeCipher interface {
+	decrypt(enc []byte) ([]byte, error)
+}
+
+// chromeEpochOffset is the number of microseconds between 1601-01-01, the
+// epoch of Chromium timestamps, and the Unix epoch.
+const chromeEpochOffset = 11644473600 * 1000000
+
+func chromeTime(us int64) time.Time {
+	if us <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(us - chromeEpochOffset)
+}
+
+// parseChromeCookies reads claude.ai rows from a Chromium "Cookies"
+// database, decrypting encrypted_value where value is empty.
+func parseChromeCookies(data []byte, cipher chromeCipher) ([]cookieRow, error) {
+	db, err := newSQLiteDB(data)
+	if err != nil {
+		return nil, err
+	}
+	rootPage, createSQL := db.findTable("cookies")
+	if rootPage == 0 {
+		return nil, fmt.Errorf("cookies table not found (not a Chromium cookies database?)")
+	}
+	colIdx := tableColumns(createSQL)
+	for _, required := range []string{"host_key", "name", "value", "encrypted_value"} {
+		if _, ok := colIdx[required]; !ok {
+			return nil, fmt.Errorf("cookies table has no %s column", required)
+		}
+	}
+	col := func(cols []sqliteVal, name string) sqliteVal {
+		if i, ok := colIdx[name]; ok && i < len(cols) {
+			return cols[i]
+		}
+		return sqliteVal{isNull: true}
+	}
+
+	var rows []cookieRow
+	var decryptErr error
+	db.walkTableBTree(rootPage, func(cols []sqliteVal) {
+		host := col(cols, "host_key").text
+		if !isClaudeHost(host) {
+			return
+		}
+		name := col(cols, "name").text
+		value := col(cols, "value").text
+		if enc := col(cols, "encrypted_value").blob; value == "" && len(enc) > 0 {
+			plain, err := cipher.decrypt(enc)
+			if err != nil {
+				decryptErr = fmt.Errorf("decrypting %s: %w", name, err)
+				return
+			}
+			value = string(stripHostDigest(plain, host))
+		}
+		if name == "" || value == "" {
+			return
+		}
+		rows = append(rows, cookieRow{
+			Name:         name,
+			Value:        value,
+			Host:         host,
+			Expiry:       chromeTime(col(cols, "expires_utc").intV),
+			Created:      chromeTime(col(cols, "creation_utc").intV),
+			LastAccessed: chromeTime(col(cols, "last_access_utc").intV),
+		})
+	})
+	if len(rows) == 0 && decryptErr != nil {
+		return nil, decryptErr
+	}
+	return dropExpired(rows, timeNow()), nil
+}
+
+// stripHostDigest removes the SHA-256 of the cookie's host that databases
+// from Chrome 130 on prepend to the plaintext.
+func stripHostDigest(plain []byte, host string) []byte {
+	sum := sha256.Sum256([]byte(host))
+	if bytes.HasPrefix(plain, sum[:]) {
+		return plain[len(sum):]
+	}
+	return plain
+}
+
+// findBrowserCookies tries the browser picked in "Import from browser"
+// (chrome_browser) if any, then Firefox, the Claude desktop app and the
+// Chromium-based browsers, and names the source the cookies came from.
+func findBrowserCookies() (browser, sessionKey, orgID, cfClearance string, err error) {
+	if cfg, err := readConfigFile(configPath); err == nil && strings.TrimSpace(cfg.ChromeBrowser) != "" {
+		b, err := chromeBrowserNamed(cfg.ChromeBrowser)
+		if err == nil {
+			var sk, org, cfc string
+			if sk, org, cfc, err = chromeCookiesFrom(b); err == nil {
+				return b.name, sk, org, cfc, nil
+			}
+		}
+		firefoxLog.Warnf("Import from chrome_browser failed, trying the others: %v", err)
+	}
+	browser, sk, org, cfc, ferr := findFirefoxCookies()
+	if ferr == nil {
+		return browser, sk, org, cfc, nil
+	}
+	sk, org, cfc, derr := findClaudeDesktopCookies()
+	if derr == nil {
+		return claudeDesktop.name, sk, org, cfc, nil
+	}
+	browser, sk, org, cfc, cerr := findChromeCookies()
+	if cerr == nil {
+		return browser, sk, org, cfc, nil
+	}
+	return "", "", "", "", fmt.Errorf("Firefox: %w; %w; %w", ferr, derr, cerr)
+}
@@ -0,0 +1,103 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// posixChromeCipher decrypts the AES-128-CBC scheme Chromium uses on Linux
+// and macOS. The key is derived with PBKDF2 from a password: on Linux
+// "peanuts" for v10 values and the Secret Service entry for v11; on macOS
+// the Keychain entry.
+type posixChromeCipher struct {
+	b chromeBrowser
+
+	once sync.Once
+	key  []byte // keyring-derived key, fetched on first v11 (or macOS) value
+	err  error
+}
+
+func newChromeCipher(b chromeBrowser, _ *chromeLocalState) (chromeCipher, error) {
+	return &posixChromeCipher{b: b}, nil
+}
+
+func (c *posixChromeCipher) decrypt(enc []byte) ([]byte, error) {
+	var key []byte
+	switch {
+	case runtime.GOOS == "linux" && bytes.HasPrefix(enc, []byte("v10")):
+		key = chromeKey("peanuts", 1)
+	case bytes.HasPrefix(enc, []byte("v10")), bytes.HasPrefix(enc, []byte("v11")):
+		c.once.Do(func() { c.key, c.err = c.keyringKey() })
+		if c.err != nil {
+			return nil, c.err
+		}
+		key = c.key
+	default:
+		return nil, fmt.Errorf("unknown encryption version %q", enc[:min(3, len(enc))])
+	}
+	return chromeCBCDecrypt(key, enc[3:])
+}
+
+// keyringKey reads the browser's password from the OS keyring.
+func (c *posixChromeCipher) keyringKey() ([]byte, error) {
+	var cmd *exec.Cmd
+	iterations := 1
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-w", "-s", c.b.keyring+" Safe Storage")
+		iterations = 1003
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "application", c.b.keyringApp)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s password from the keyring (%s): %w", c.b.name, cmd.Path, err)
+	}
+	return chromeKey(strings.TrimRight(string(out), "\r\n"), iterations), nil
+}
+
+// chromeKey derives the 16-byte AES key: PBKDF2-HMAC-SHA1 with the salt
+// "saltysalt". One block of output is enough for a 16-byte key.
+func chromeKey(password string, iterations int) []byte {
+	mac := hmac.New(sha1.New, []byte(password))
+	mac.Write([]byte("saltysalt\x00\x00\x00\x01"))
+	u := mac.Sum(nil)
+	t := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range t {
+			t[j] ^= u[j]
+		}
+	}
+	return t[:16]
+}
+
+// chromeCBCDecrypt decrypts with AES-128-CBC, an IV of 16 spaces and
+// PKCS#7 padding.
+func chromeCBCDecrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted value has a bad length")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(plain, data)
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return nil, fmt.Errorf("wrong key (bad padding)")
+	}
+	return plain[:len(plain)-pad], nil
+}
@@ -0,0 +1,130 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// encryptV10 encrypts plain the way Chromium on Linux writes v10 values.
+func encryptV10(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(chromeKey("peanuts", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(data, data)
+	return append([]byte("v10"), data...)
+}
+
+func TestChromeKey(t *testing.T) {
+	// The key every Chromium on Linux uses without a keyring
+	if got := hex.EncodeToString(chromeKey("peanuts", 1)); got != "fd621fe5a2b402539dfa147ca9272778" {
+		t.Errorf("chromeKey(peanuts) = %s", got)
+	}
+}
+
+func TestChromeDecryptV10(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("v10 values use the built-in key only on Linux")
+	}
+	c, _ := newChromeCipher(chromeBrowsers[0], nil)
+
+	plain, err := c.decrypt(encryptV10(t, []byte("sk-ant-sid01-test")))
+	if err != nil || string(plain) != "sk-ant-sid01-test" {
+		t.Errorf("decrypt = %q, %v", plain, err)
+	}
+
+	// Chrome 130+ prepends the SHA-256 of the host
+	sum := sha256.Sum256([]byte(".claude.ai"))
+	plain, err = c.decrypt(encryptV10(t, append(sum[:], "cf-value"...)))
+	if err != nil || string(stripHostDigest(plain, ".claude.ai")) != "cf-value" {
+		t.Errorf("decrypt with host digest = %q, %v", plain, err)
+	}
+
+	if _, err := c.decrypt([]byte("v20 app-bound")); err == nil {
+		t.Error("decrypted a v20 value")
+	}
+}
+
+func TestFindClaudeDesktopCookies(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the fixture uses the Linux config directory and built-in key")
+	}
+	dir, err := filepath.Abs(filepath.Join("testdata", "claude-desktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	sk, org, cfc, err := findClaudeDesktopCookies()
+	if err != nil || sk != "sk-ant-sid01-desktop" || org != "org-desktop" || cfc != "cf-desktop" {
+		t.Errorf("got %q, %q, %q, %v", sk, org, cfc, err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, _, _, err := findClaudeDesktopCookies(); err == nil || !strings.Contains(err.Error(), "Claude Desktop directory not found") {
+		t.Errorf("without the app: error = %v", err)
+	}
+}
+
+func TestChromiumForks(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the fixture uses the Linux config directory and built-in key")
+	}
+	fixture, err := os.ReadFile(filepath.Join("testdata", "claude-desktop", "Claude", "Network", "Cookies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	// Brave with profiles; Opera with the cookie store in its own directory
+	for _, rel := range []string{"BraveSoftware/Brave-Browser/Default/Network", "opera/Network"} {
+		dir := filepath.Join(config, filepath.FromSlash(rel))
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "Cookies"), fixture, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"brave", "Opera"} {
+		b, err := chromeBrowserNamed(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !b.installed() {
+			t.Errorf("%s not detected", b.name)
+		}
+		if sk, _, cfc, err := chromeCookiesFrom(b); err != nil || sk != "sk-ant-sid01-desktop" || cfc != "cf-desktop" {
+			t.Errorf("%s: got %q, %q, %v", b.name, sk, cfc, err)
+		}
+	}
+	for _, name := range []string{"chrome", "vivaldi"} {
+		if b, _ := chromeBrowserNamed(name); b.installed() {
+			t.Errorf("%s detected without a cookie database", b.name)
+		}
+	}
+	if _, err := chromeBrowserNamed("netscape"); err == nil || !strings.Contains(err.Error(), "brave") {
+		t.Errorf("unknown browser: error = %v", err)
+	}
+}
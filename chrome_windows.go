@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = syscall.NewLazyDLL("kernel32.dll").NewProc("LocalFree")
+)
+
+type dataBlob struct {
+	cbData uint32
+	pbData *byte
+}
+
+// dpapiDecrypt unprotects data encrypted for the current Windows user.
+func dpapiDecrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty DPAPI blob")
+	}
+	in := dataBlob{cbData: uint32(len(data)), pbData: &data[0]}
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(&in)), 0, 0, 0, 0, 0, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData: %w", err)
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.pbData)))
+	return append([]byte(nil), unsafe.Slice(out.pbData, out.cbData)...), nil
+}
+
+// windowsChromeCipher decrypts v10 values with the AES-256-GCM key from
+// Local State (itself protected by DPAPI), and pre-v10 values with DPAPI
+// directly.
+type windowsChromeCipher struct {
+	gcm cipher.AEAD // nil if Local State had no key
+}
+
+func newChromeCipher(b chromeBrowser, ls *chromeLocalState) (chromeCipher, error) {
+	if ls == nil || ls.OSCrypt.EncryptedKey == "" {
+		return windowsChromeCipher{}, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(ls.OSCrypt.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding os_crypt key: %w", err)
+	}
+	if !bytes.HasPrefix(raw, []byte("DPAPI")) {
+		return nil, fmt.Errorf("unexpected os_crypt key format")
+	}
+	key, err := dpapiDecrypt(raw[len("DPAPI"):])
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return windowsChromeCipher{gcm: gcm}, nil
+}
+
+func (c windowsChromeCipher) decrypt(enc []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(enc, []byte("v20")):
+		return nil, fmt.Errorf("value uses app-bound encryption (Chrome 127+), which only the browser itself can decrypt")
+	case bytes.HasPrefix(enc, []byte("v10")), bytes.HasPrefix(enc, []byte("v11")):
+		if c.gcm == nil {
+			return nil, fmt.Errorf("no os_crypt key in Local State")
+		}
+		enc = enc[3:]
+		if len(enc) < c.gcm.NonceSize()+c.gcm.Overhead() {
+			return nil, fmt.Errorf("encrypted value too short")
+		}
+		nonce, ciphertext := enc[:c.gcm.NonceSize()], enc[c.gcm.NonceSize():]
+		return c.gcm.Open(nil, nonce, ciphertext, nil)
+	default:
+		return dpapiDecrypt(enc)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/getlantern/systray"
+)
+
+const chromeMenuTitle = "Import from browser"
+
+// chromeMenu is the "Import from browser" submenu: one item per entry of
+// chromeBrowsers, shown when that browser has a cookie database, with the
+// one picked last (chrome_browser) checked.
+type chromeMenu struct {
+	parent *systray.MenuItem
+	items  []*systray.MenuItem
+	none   *systray.MenuItem
+
+	// selected receives the browser picked in the submenu.
+	selected chan chromeBrowser
+}
+
+func newChromeMenu() *chromeMenu {
+	m := &chromeMenu{
+		parent:   systray.AddMenuItem(chromeMenuTitle, "Pick the Chromium-based browser to read cookies from"),
+		selected: make(chan chromeBrowser, 1),
+	}
+	for _, b := range chromeBrowsers {
+		item := m.parent.AddSubMenuItemCheckbox(b.name, "Read cookies from "+b.name, false)
+		item.Hide()
+		m.items = append(m.items, item)
+		go func(b chromeBrowser) {
+			for range item.ClickedCh {
+				m.selected <- b
+			}
+		}(b)
+	}
+	m.none = m.parent.AddSubMenuItem("No Chromium-based browser found", "")
+	m.none.Disable()
+	m.none.Hide()
+	return m
+}
+
+// load shows the browsers that have a cookie database, checking the one in
+// chrome_browser.
+func (m *chromeMenu) load() {
+	current := ""
+	if cfg, err := readConfigFile(configPath); err == nil {
+		current = strings.TrimSpace(cfg.ChromeBrowser)
+	}
+	installed := make([]bool, len(chromeBrowsers))
+	for i, b := range chromeBrowsers {
+		installed[i] = b.installed()
+	}
+	ui.do(func() {
+		found := false
+		for i, b := range chromeBrowsers {
+			item := m.items[i]
+			if !installed[i] {
+				item.Hide()
+				continue
+			}
+			found = true
+			if strings.EqualFold(b.name, current) {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+			item.Show()
+		}
+		if found {
+			m.none.Hide()
+		} else {
+			m.none.Show()
+		}
+	})
+}
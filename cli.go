@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parseConfigFlag takes a leading --config PATH (or --config=PATH) off
+// args, which picks the config.json for the tray app and every subcommand.
+// It returns the remaining args and the path made absolute, or "" if the
+// flag isn't given.
+func parseConfigFlag(args []string) ([]string, string, error) {
+	if len(args) == 0 {
+		return args, "", nil
+	}
+	var path string
+	switch {
+	case args[0] == "--config" || args[0] == "-config":
+		if len(args) < 2 || args[1] == "" {
+			return nil, "", fmt.Errorf("--config needs a path")
+		}
+		path, args = args[1], args[2:]
+	case strings.HasPrefix(args[0], "--config="):
+		path, args = strings.TrimPrefix(args[0], "--config="), args[1:]
+		if path == "" {
+			return nil, "", fmt.Errorf("--config needs a path")
+		}
+	default:
+		return args, "", nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("--config: %w", err)
+	}
+	return args, abs, nil
+}
+
+// runCLI handles command-line subcommands. It returns ok=false when args
+// don't name a subcommand, in which case the tray app starts as usual.
+func runCLI(args []string) (code int, ok bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	if isNativeHostLaunch(args) {
+		// stdout carries the protocol; diagnostics go to the browser console
+		setLogOutput(os.Stderr)
+		return runNativeHost(os.Stdin, os.Stdout), true
+	}
+	switch args[0] {
+	case "--version":
+		attachConsole()
+		fmt.Print(currentBuild().text())
+		return 0, true
+	case "import-firefox":
+		attachConsole()
+		setLogOutput(os.Stderr)
+		return cmdImportFirefox(args[1:], os.Stdout), true
+	case "--install-native-host":
+		attachConsole()
+		setLogOutput(os.Stderr)
+		return cmdInstallNativeHost(args[1:], os.Stdout), true
+	case "doctor":
+		attachConsole()
+		return cmdDoctor(args[1:], os.Stdout), true
+	case "--once":
+		attachConsole()
+		return cmdOnce(args[1:], os.Stdout, os.Stderr), true
+	case "--headless":
+		attachConsole()
+		setLogOutput(os.Stdout)
+		return runHeadless(), true
+	case "replay":
+		attachConsole()
+		setLogOutput(os.Stderr)
+		return cmdReplay(args[1:], os.Stdout), true
+	}
+	return 0, false
+}
+
+// cmdImportFirefox runs the Firefox import the way the menu does, prints
+// the import report and writes the cookies to config.json: from the
+// default profile or firefox_profile, or a profile named in profiles.ini.
+// --profile-dir reads any profile directory (e.g. an unpacked copy from a
+// bug report) and only writes with --save. A missing sessionKey or
+// lastActiveOrg fails the import, with nothing written.
+func cmdImportFirefox(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("import-firefox", flag.ContinueOnError)
+	fs.SetOutput(out)
+	profileDir := fs.String("profile-dir", "", "only report on the cookies in this profile `directory`, unless --save is given")
+	profileName := fs.String("profile", "", "read cookies from the profile with this `name` in profiles.ini")
+	cfgPath := fs.String("config", "", "use this config.json `file` instead of the usual one")
+	save := fs.Bool("save", false, "with --profile-dir, write the imported cookies to config.json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *profileDir != "" && *profileName != "" {
+		fmt.Fprintln(out, "Use either --profile or --profile-dir")
+		return 2
+	}
+	if *cfgPath != "" {
+		configPath = *cfgPath
+	}
+
+	// The profile to remember as firefox_profile, as when it is picked in
+	// the menu
+	dir := *profileDir
+	if *profileName != "" {
+		var err error
+		if dir, err = firefoxProfileNamed(*profileName); err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 1
+		}
+	}
+	pinned := dir
+	if dir == "" {
+		var err error
+		if dir, err = firefoxProfileDir(); err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 1
+		}
+	}
+
+	report, err := importFirefoxProfile(dir)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	report.print(out)
+
+	sk, org, cfc, err := report.credentials()
+	if err != nil {
+		fmt.Fprintln(out, "Result:  ", err)
+		return 1
+	}
+	if org == "" {
+		fmt.Fprintln(out, "Result:   no lastActiveOrg cookie, so org_id is unknown; open claude.ai in this profile and import again")
+		return 1
+	}
+	if *profileDir != "" && !*save {
+		fmt.Fprintln(out, "Result:   OK (not saved; pass --save to write config.json)")
+		return 0
+	}
+	if err := saveFirefoxImport(configPath, pinned, sk, org, cfc); err != nil {
+		fmt.Fprintln(out, "Error saving config:", err)
+		return 1
+	}
+	report.recordImported()
+	fmt.Fprintln(out, "Result:   saved to", configPath)
+	return 0
+}
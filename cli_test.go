@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runImportFirefox runs import-firefox against a fixture profile with
+// config.json and state.json in a temporary directory.
+func runImportFirefox(t *testing.T, args ...string) (cfgPath, out string, code int) {
+	t.Helper()
+	savedPaths, savedConfig := paths, configPath
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	configPath = paths.configFile()
+	t.Cleanup(func() { paths, configPath = savedPaths, savedConfig })
+
+	var buf bytes.Buffer
+	code = cmdImportFirefox(args, &buf)
+	return configPath, buf.String(), code
+}
+
+func TestImportFirefoxProfileDirReport(t *testing.T) {
+	cfgPath, out, code := runImportFirefox(t, "--profile-dir", filepath.Join("testdata", "firefox", "basic"))
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	for _, want := range []string{
+		"Rows:     3 claude.ai cookie(s)",
+		"container (default): cf_clearance, lastActiveOrg, sessionKey",
+		"lastActiveOrg  org-default",
+		"not saved",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "sk-ant-sid01-default") {
+		t.Errorf("report shows the sessionKey unmasked:\n%s", out)
+	}
+	if _, err := os.Stat(cfgPath); !os.IsNotExist(err) {
+		t.Errorf("config.json written without --save: %v", err)
+	}
+}
+
+func TestImportFirefoxSave(t *testing.T) {
+	profile := filepath.Join("testdata", "firefox", "basic")
+	cfgPath, out, code := runImportFirefox(t, "--profile-dir", profile, "--save")
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	cfg, err := readConfigFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SessionKey != "sk-ant-sid01-default" || cfg.OrgID != "org-default" || cfg.CfClearance != "cf-default" {
+		t.Errorf("saved session_key %q, org_id %q, cf_clearance %q", cfg.SessionKey, cfg.OrgID, cfg.CfClearance)
+	}
+}
+
+func TestImportFirefoxWithoutOrg(t *testing.T) {
+	// The default context of this profile has a sessionKey but no
+	// lastActiveOrg
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(cfgPath, []byte(`{"firefox_container": 0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profile := filepath.Join("testdata", "firefox", "containers")
+	_, out, code := runImportFirefox(t, "--profile-dir", profile, "--config", cfgPath, "--save")
+	if code != 1 || !strings.Contains(out, "no lastActiveOrg cookie, so org_id is unknown") {
+		t.Errorf("exit code %d, output:\n%s", code, out)
+	}
+	cfg, err := readConfigFile(cfgPath)
+	if err != nil || cfg.SessionKey != "" {
+		t.Errorf("config.json after a failed import: %+v, %v", cfg, err)
+	}
+	if st, _ := readStateFile(statePath()); st != nil && st.SessionKey != nil {
+		t.Error("recorded the sessionKey of an import that wasn't saved")
+	}
+}
+
+func TestImportFirefoxArgs(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"--no-such-flag"}, 2, "flag provided but not defined"},
+		{[]string{"--profile-dir", filepath.Join("testdata", "firefox", "missing")}, 1, "Error:"},
+		{[]string{"--profile", "Work", "--profile-dir", "x"}, 2, "either --profile or --profile-dir"},
+	} {
+		_, out, code := runImportFirefox(t, tc.args...)
+		if code != tc.code || !strings.Contains(out, tc.want) {
+			t.Errorf("%q: exit code %d, output:\n%s\nwant %d and %q", tc.args, code, out, tc.code, tc.want)
+		}
+	}
+}
+
+func TestImportFirefoxProfileByName(t *testing.T) {
+	basic, err := filepath.Abs(filepath.Join("testdata", "firefox", "basic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	firefoxDir := t.TempDir()
+	ini := "[Profile0]\nName=default-release\nIsRelative=1\nPath=Profiles/missing\n\n[Profile1]\nName=Work\nIsRelative=0\nPath=" + filepath.ToSlash(basic) + "\n"
+	if err := os.WriteFile(filepath.Join(firefoxDir, "profiles.ini"), []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// --config points at a config.json outside the usual directory
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(cfgPath, []byte(`{"firefox_dir": `+strconv.Quote(firefoxDir)+`, "chrome_browser": "brave"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, out, code := runImportFirefox(t, "--profile", "work", "--config", cfgPath)
+	if code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out)
+	}
+	cfg, err := readConfigFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SessionKey != "sk-ant-sid01-default" || cfg.FirefoxProfile != basic || cfg.ChromeBrowser != "" {
+		t.Errorf("saved session_key %q, firefox_profile %q, chrome_browser %q", cfg.SessionKey, cfg.FirefoxProfile, cfg.ChromeBrowser)
+	}
+
+	_, out, code = runImportFirefox(t, "--profile", "Personal", "--config", cfgPath)
+	if code != 1 || !strings.Contains(out, `no Firefox profile named "Personal" (there are: default-release, Work)`) {
+		t.Errorf("unknown profile: exit code %d, output:\n%s", code, out)
+	}
+}
+
+func TestParseConfigFlag(t *testing.T) {
+	abs, _ := filepath.Abs("my.json")
+	for _, tc := range []struct {
+		args []string
+		rest []string
+		path string
+		bad  bool
+	}{
+		{nil, nil, "", false},
+		{[]string{"--headless"}, []string{"--headless"}, "", false},
+		{[]string{"--config", "my.json"}, []string{}, abs, false},
+		{[]string{"--config=my.json", "doctor"}, []string{"doctor"}, abs, false},
+		{[]string{"--config", "my.json", "import-firefox", "--config", "other.json"}, []string{"import-firefox", "--config", "other.json"}, abs, false},
+		{[]string{"--config"}, nil, "", true},
+		{[]string{"--config="}, nil, "", true},
+	} {
+		rest, path, err := parseConfigFlag(tc.args)
+		if (err != nil) != tc.bad || path != tc.path || strings.Join(rest, " ") != strings.Join(tc.rest, " ") {
+			t.Errorf("%q: %q, %q, %v", tc.args, rest, path, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// copyToClipboard places text on the system clipboard using whatever tool
+// the platform provides (PowerShell, pbcopy, wl-copy/xclip/xsel).
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "windows":
+		candidates = [][]string{
+			{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command",
+				"[Console]::InputEncoding=[Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"},
+		}
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	default:
+		candidates = [][]string{
+			{"wl-copy"},
+			{"xclip", "-selection", "clipboard"},
+			{"xsel", "--clipboard", "--input"},
+		}
+	}
+
+	var tried []string
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			tried = append(tried, c[0])
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		hideWindow(cmd)
+		cmd.Stdin = strings.NewReader(text)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %v %s", c[0], err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard tool found (tried %s)", strings.Join(tried, ", "))
+}
+
+// readClipboard returns the text on the system clipboard, read with
+// whatever tool the platform provides (PowerShell, pbpaste,
+// wl-paste/xclip/xsel).
+func readClipboard() (string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "windows":
+		candidates = [][]string{
+			{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command",
+				"[Console]::OutputEncoding=[Text.Encoding]::UTF8; Get-Clipboard -Raw"},
+		}
+	case "darwin":
+		candidates = [][]string{{"pbpaste"}}
+	default:
+		candidates = [][]string{
+			{"wl-paste", "--no-newline"},
+			{"xclip", "-selection", "clipboard", "-o"},
+			{"xsel", "--clipboard", "--output"},
+		}
+	}
+
+	var tried []string
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			tried = append(tried, c[0])
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		hideWindow(cmd)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s: %v %s", c[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return string(out), nil
+	}
+	return "", fmt.Errorf("no clipboard tool found (tried %s)", strings.Join(tried, ", "))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Values of the icon_mode config setting.
+const (
+	iconModeSplit   = "split"   // session left, weekly right (default)
+	iconModeSession = "session" // one number: the 5-hour bucket
+	iconModeWeekly  = "weekly"  // one number: the weekly bucket
+	iconModeWorst   = "worst"   // one number: the most constrained bucket
+)
+
+// iconModes are the icon_mode values in the order of the "Icon shows"
+// submenu, with their titles there.
+var iconModes = []struct{ key, title string }{
+	{iconModeSplit, "Session and weekly"},
+	{iconModeSession, "Session"},
+	{iconModeWeekly, "Weekly"},
+	{iconModeWorst, "Most constrained limit"},
+}
+
+// Values of the icon_value config setting.
+const (
+	iconValueRemaining = "remaining" // headroom left (default)
+	iconValueUsed      = "used"      // utilization, as in the menu
+)
+
+// validIconValue reports whether s is a value icon_value accepts.
+func validIconValue(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", iconValueRemaining, iconValueUsed:
+		return true
+	}
+	return false
+}
+
+// iconShowsUsed reports whether icon_value asks for used percentages. A
+// nil config yields the default, remaining.
+func (c *Config) iconShowsUsed() bool {
+	return c != nil && strings.EqualFold(strings.TrimSpace(c.IconValue), iconValueUsed)
+}
+
+// Values of the icon_style config setting.
+const (
+	iconStyleColor = "color" // digits on a background colored by level (default)
+	iconStyleMono  = "mono"  // digits alone, color only in a level bar
+	// iconStyleSplit and iconStyleCompact are the setting's older values,
+	// from before icon_mode: the split icon, or icon_mode "worst", both in
+	// color.
+	iconStyleSplit   = "split"
+	iconStyleCompact = "compact"
+)
+
+// validIconStyle reports whether s is a value icon_style accepts.
+func validIconStyle(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", iconStyleColor, iconStyleMono, iconStyleSplit, iconStyleCompact:
+		return true
+	}
+	return false
+}
+
+// iconPalette returns how icons are drawn: in color, or with icon_style
+// "mono" in white or black to suit the menu bar. A nil config yields the
+// default.
+func (c *Config) iconPalette() iconPalette {
+	if c == nil || !strings.EqualFold(strings.TrimSpace(c.IconStyle), iconStyleMono) {
+		return paletteColor
+	}
+	if darkMenuBar() {
+		return paletteWhite
+	}
+	return paletteBlack
+}
+
+// iconTrend reports whether icon_trend allows trend arrows on the icon. A
+// nil config yields the default, on.
+func (c *Config) iconTrend() bool {
+	return c == nil || c.IconTrend == nil || *c.IconTrend
+}
+
+// templatePalette turns a mono palette into a macOS template image unless
+// the icon has a level bar, lowest being its least remaining: the menu
+// bar then tints the digits to match its appearance itself. Templates are
+// monochrome, so an icon with a bar stays a regular one.
+func templatePalette(p iconPalette, lowest int) (iconPalette, bool) {
+	if p == paletteColor || runtime.GOOS != "darwin" || monoNeedsBar(lowest) {
+		return p, false
+	}
+	return paletteBlack, true
+}
+
+// validIconMode reports whether s is a value icon_mode accepts.
+func validIconMode(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, m := range iconModes {
+		if s == m.key {
+			return true
+		}
+	}
+	return s == ""
+}
+
+// iconMode returns what the tray icon shows: icon_mode, or "worst" for
+// the older icon_style "compact". A nil config yields the default.
+func (c *Config) iconMode() string {
+	if c == nil {
+		return iconModeSplit
+	}
+	if m := strings.ToLower(strings.TrimSpace(c.IconMode)); m != "" && validIconMode(m) {
+		return m
+	}
+	if strings.EqualFold(strings.TrimSpace(c.IconStyle), iconStyleCompact) {
+		return iconModeWorst
+	}
+	return iconModeSplit
+}
+
+// worstBucketHysteresis is how many points less remaining another bucket
+// needs before the compact icon switches to it, so two buckets at about the
+// same level don't make the icon flicker between them.
+const worstBucketHysteresis = 3
+
+// bucketReading is one bucket's remaining percentage and its icon letter.
+type bucketReading struct {
+	letter    string
+	remaining int
+}
+
+// compactReadings lists the buckets that limit usage right now. The
+// per-model buckets count only when present; absent means no separate limit.
+func compactReadings(usage *UsageResponse, opus, sonnet *UsageBucket) []bucketReading {
+	readings := []bucketReading{
+		{"S", 100 - int(usage.FiveHour.Utilization)},
+		{"W", 100 - int(usage.SevenDay.Utilization)},
+	}
+	if opus != nil {
+		readings = append(readings, bucketReading{"O", 100 - int(opus.Utilization)})
+	}
+	if sonnet != nil {
+		readings = append(readings, bucketReading{"N", 100 - int(sonnet.Utilization)})
+	}
+	return readings
+}
+
+// worstBucketPicker chooses the bucket the compact icon shows and keeps
+// showing it until another one is clearly worse.
+type worstBucketPicker struct {
+	mu     sync.Mutex
+	letter string // bucket shown, "" before the first pick
+}
+
+var worstBucket worstBucketPicker
+
+// pick returns the reading to show: the one with the least remaining,
+// unless the bucket already shown is within worstBucketHysteresis points
+// of it. A shown bucket that is no longer present is replaced right away.
+func (p *worstBucketPicker) pick(readings []bucketReading) bucketReading {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	worst := readings[0]
+	for _, r := range readings[1:] {
+		if r.remaining < worst.remaining {
+			worst = r
+		}
+	}
+	for _, r := range readings {
+		if r.letter == p.letter && r.remaining-worst.remaining < worstBucketHysteresis {
+			return r
+		}
+	}
+	if p.letter != "" && p.letter != worst.letter {
+		uiLog.Infof("Compact icon: %s is now the most constrained bucket (%d%% left)", worst.letter, worst.remaining)
+	}
+	p.letter = worst.letter
+	return worst
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestCompactReadings(t *testing.T) {
+	usage := &UsageResponse{
+		FiveHour: UsageBucket{Utilization: 30},
+		SevenDay: UsageBucket{Utilization: 55},
+	}
+	var p worstBucketPicker
+	if r := p.pick(compactReadings(usage, nil, nil)); r != (bucketReading{"W", 45}) {
+		t.Errorf("without per-model buckets: %+v, want W 45", r)
+	}
+
+	var q worstBucketPicker
+	sonnet := &UsageBucket{Utilization: 80}
+	if r := q.pick(compactReadings(usage, &UsageBucket{Utilization: 10}, sonnet)); r != (bucketReading{"N", 20}) {
+		t.Errorf("with Opus and Sonnet: %+v, want N 20", r)
+	}
+}
+
+func TestWorstBucketHysteresis(t *testing.T) {
+	var p worstBucketPicker
+	for i, step := range []struct {
+		readings []bucketReading
+		want     bucketReading
+	}{
+		{[]bucketReading{{"S", 40}, {"W", 50}}, bucketReading{"S", 40}},
+		// W is worse, but by less than 3 points: keep S
+		{[]bucketReading{{"S", 40}, {"W", 38}}, bucketReading{"S", 40}},
+		{[]bucketReading{{"S", 40}, {"W", 39}}, bucketReading{"S", 40}},
+		// 3 points worse: switch
+		{[]bucketReading{{"S", 40}, {"W", 37}}, bucketReading{"W", 37}},
+		// and the same margin applies on the way back
+		{[]bucketReading{{"S", 36}, {"W", 37}}, bucketReading{"W", 37}},
+		{[]bucketReading{{"S", 34}, {"W", 37}}, bucketReading{"S", 34}},
+		// A bucket that disappears is replaced at once
+		{[]bucketReading{{"S", 34}, {"W", 37}, {"O", 33}}, bucketReading{"S", 34}},
+		{[]bucketReading{{"S", 34}, {"W", 37}, {"O", 20}}, bucketReading{"O", 20}},
+		{[]bucketReading{{"S", 34}, {"W", 37}}, bucketReading{"S", 34}},
+	} {
+		if got := p.pick(step.readings); got != step.want {
+			t.Errorf("step %d: picked %+v, want %+v", i, got, step.want)
+		}
+	}
+}
+
+func TestIconMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode, style, want string
+	}{
+		{"", "", iconModeSplit},
+		{"", "split", iconModeSplit},
+		{"", "compact", iconModeWorst},
+		{"", " Compact ", iconModeWorst},
+		{"Session", "", iconModeSession},
+		{"weekly", "compact", iconModeWeekly}, // icon_mode wins
+		{"split", "compact", iconModeSplit},
+	} {
+		if got := (&Config{IconMode: tc.mode, IconStyle: tc.style}).iconMode(); got != tc.want {
+			t.Errorf("icon_mode %q, icon_style %q: %q, want %q", tc.mode, tc.style, got, tc.want)
+		}
+	}
+	if got := (*Config)(nil).iconMode(); got != iconModeSplit {
+		t.Errorf("nil config: %q", got)
+	}
+	if validIconMode("both") {
+		t.Error("accepted an unknown icon_mode")
+	}
+
+	usage := &UsageResponse{FiveHour: UsageBucket{Utilization: 42}, SevenDay: UsageBucket{Utilization: 90}}
+	for mode, want := range map[string][]byte{
+		iconModeSession: makeCompactIcon("S", 58, false, paletteColor),
+		iconModeWeekly:  makeCompactIcon("W", 10, false, paletteColor),
+		iconModeSplit:   makeIcon(58, 10, 10, false, paletteColor),
+	} {
+		if got, _ := usageIcon(&Config{IconMode: mode}, usage, nil, nil); !bytes.Equal(got.icon(), want) {
+			t.Errorf("icon_mode %q: unexpected icon", mode)
+		}
+	}
+
+	for _, remaining := range []int{100, 42, 5, 0} {
+		img, err := png.Decode(bytes.NewReader(iconPNG(makeCompactIcon("W", remaining, false, paletteColor))))
+		if err != nil {
+			t.Fatalf("remaining %d: %v", remaining, err)
+		}
+		if b := img.Bounds(); b.Dx() != iconSize || b.Dy() != iconSize {
+			t.Errorf("remaining %d: icon is %v", remaining, b)
+		}
+	}
+}
+
+func TestIconValue(t *testing.T) {
+	for _, tc := range []struct {
+		utilization     int
+		remaining, used string
+	}{
+		{0, "100", "0%"},
+		{1, "99%", "1%"},
+		{99, "1%", "99%"},
+		{100, "0%", "100"},
+		{120, "0%", "100"},
+		{-5, "100", "0%"},
+	} {
+		remaining := 100 - tc.utilization
+		if got := formatPct(iconNumber(remaining, false)); got != tc.remaining {
+			t.Errorf("%d%% used, remaining: %q, want %q", tc.utilization, got, tc.remaining)
+		}
+		if got := formatPct(iconNumber(remaining, true)); got != tc.used {
+			t.Errorf("%d%% used, used: %q, want %q", tc.utilization, got, tc.used)
+		}
+	}
+
+	for value, want := range map[string]bool{"": false, "remaining": false, " Used ": true} {
+		if got := (&Config{IconValue: value}).iconShowsUsed(); got != want {
+			t.Errorf("icon_value %q: used %v, want %v", value, got, want)
+		}
+	}
+	if validIconValue("headroom") {
+		t.Error("accepted an unknown icon_value")
+	}
+
+	// The color follows the headroom, so 90% used is red either way
+	usage := &UsageResponse{FiveHour: UsageBucket{Utilization: 90}, SevenDay: UsageBucket{Utilization: 10}}
+	key, _ := usageIcon(&Config{IconValue: "used"}, usage, nil, nil)
+	img, err := png.Decode(bytes.NewReader(iconPNG(key.icon())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := color.RGBAModel.Convert(img.At(2, 2)), color.Color(levelColor(10)); got != want {
+		t.Errorf("session half is %v, want %v", got, want)
+	}
+}
+
+func TestMonoIcon(t *testing.T) {
+	for style, want := range map[string]bool{"": true, "color": true, " Mono ": true, "compact": true, "split": true, "dark": false} {
+		if got := validIconStyle(style); got != want {
+			t.Errorf("icon_style %q: valid %v, want %v", style, got, want)
+		}
+	}
+	if got := (&Config{IconStyle: "color"}).iconPalette(); got != paletteColor {
+		t.Errorf("color: palette %v", got)
+	}
+	if got := (&Config{IconStyle: "mono"}).iconPalette(); got == paletteColor {
+		t.Error("mono: color palette")
+	}
+
+	pixel := func(icon []byte, x, y int) color.RGBA {
+		t.Helper()
+		img, err := png.Decode(bytes.NewReader(iconPNG(icon)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+	bottom := iconSize - monoBarHeight/2
+
+	// 80% and 10% left: no bar under the session half, a red one under
+	// the weekly half, and transparent everywhere around the digits
+	icon := makeIcon(80, 10, 10, false, paletteWhite)
+	if c := pixel(icon, 2, 2); c.A != 0 {
+		t.Errorf("background is %v, want transparent", c)
+	}
+	if c := pixel(icon, 8, bottom); c.A != 0 {
+		t.Errorf("session bar drawn at 80%% left: %v", c)
+	}
+	if c, want := pixel(icon, iconSize-8, bottom), levelColor(10); c != want {
+		t.Errorf("weekly bar is %v, want %v", c, want)
+	}
+
+	icon = makeCompactIcon("S", 30, false, paletteBlack)
+	if c, want := pixel(icon, iconSize/2, bottom), levelColor(30); c != want {
+		t.Errorf("compact bar is %v, want %v", c, want)
+	}
+	if c := pixel(icon, iconSize/2, 2); c.A != 0 {
+		t.Errorf("compact background is %v, want transparent", c)
+	}
+
+	// A template image is monochrome, so only a bar-less icon becomes one
+	p, template := templatePalette(paletteWhite, 10)
+	if p != paletteWhite || template {
+		t.Errorf("with a bar: %v, template %v", p, template)
+	}
+	if _, template := templatePalette(paletteColor, 80); template {
+		t.Error("color icon made a template")
+	}
+}
@@ -1,71 +1,616 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
+	"sync"
 )
 
+// defaultAPIBaseURL is used when api_base_url is not set.
+const defaultAPIBaseURL = "https://claude.ai/api"
+
 type Config struct {
 	SessionKey  string `json:"session_key"`
 	OrgID       string `json:"org_id"`
 	CfClearance string `json:"cf_clearance"`
+
+	// ProxyURL routes API requests through an http://, https:// or socks5://
+	// proxy (optionally with user:pass@). When empty, the standard
+	// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables are honored.
+	ProxyURL string `json:"proxy_url,omitempty"`
+
+	// CACertFile is a PEM file whose certificates are trusted in addition to
+	// the system pool (for corporate proxies that re-sign TLS).
+	CACertFile string `json:"ca_cert_file,omitempty"`
+	// TLSInsecureSkipVerify disables certificate verification entirely.
+	// Explicit opt-in only; prefer CACertFile.
+	TLSInsecureSkipVerify bool `json:"tls_insecure_skip_verify,omitempty"`
+
+	// ForceIPv4 resolves and dials A records only, for networks where IPv6
+	// is advertised but broken.
+	ForceIPv4 bool `json:"force_ipv4,omitempty"`
+
+	// APIBaseURL overrides the API root (e.g. a local mock server).
+	// Trailing slashes are stripped; empty means defaultAPIBaseURL.
+	APIBaseURL string `json:"api_base_url,omitempty"`
+
+	// ExtraUsageWarnPct is the extra-usage utilization (percent of the monthly
+	// limit) at which the menu row gets a warning prefix. Default 80.
+	ExtraUsageWarnPct float64 `json:"extra_usage_warn_pct,omitempty"`
+
+	// OnUpdateCommand is run through the shell after every update with the
+	// status JSON on stdin and CLAUDE_SESSION_PCT etc. in the environment.
+	OnUpdateCommand string `json:"on_update_command,omitempty"`
+
+	// IconAction is what middle-/double-clicking the tray icon does:
+	// "refresh" (default), "open_claude", "copy_status" or "status_window".
+	IconAction string `json:"icon_action,omitempty"`
+
+	// IconMode is what the tray icon shows: "split" (default: session and
+	// weekly side by side), or one large number with its bucket's letter
+	// for "session", "weekly" or "worst", the most constrained bucket.
+	IconMode string `json:"icon_mode,omitempty"`
+	// IconValue is what the icon's numbers are: "remaining" (default) or
+	// "used", the utilization the menu shows. Colors follow the remaining
+	// headroom either way.
+	IconValue string `json:"icon_value,omitempty"`
+	// IconStyle is "color" (default: digits on a background colored by
+	// level) or "mono": white or black digits on a transparent background,
+	// with color only in a bar under a bucket that is running low. The
+	// older value "compact" is icon_mode "worst" in color.
+	IconStyle string `json:"icon_style,omitempty"`
+	// IconTrend puts a small arrow in the icon's corner when a bucket has
+	// risen or fallen steeply over the last half hour. Default true.
+	IconTrend *bool `json:"icon_trend,omitempty"`
+	// CriticalBlink turns on the critical alert: once a bucket has less
+	// than 20% left the icon blinks for this long, a Go duration such as
+	// "1m", or until the menu is opened, then keeps a red badge until the
+	// bucket recovers. "0s" skips the blinking. Empty (default) is off.
+	CriticalBlink string `json:"critical_blink,omitempty"`
+
+	// TrayTitle puts the numbers as text next to the icon in the macOS menu
+	// bar: "compact" ("42·12"), "labeled" ("S42 W12") or a format of its
+	// own with {s}, {w}, {o} and {n} for session, weekly, Opus and Sonnet.
+	// Empty (default) shows the icon alone; other platforms ignore it.
+	TrayTitle string `json:"tray_title,omitempty"`
+
+	// StatusFormat is the Go template "Copy status" renders, e.g.
+	// "**Session** {{.Session.Used}}% · **Weekly** {{.Weekly.Used}}%" for
+	// Markdown. Empty (default) is a plain sentence with every bucket, its
+	// reset time and the fetch time.
+	StatusFormat string `json:"status_format,omitempty"`
+
+	// ResetFormat is how the menu shows reset times: "relative" (default,
+	// "in 6h 40m"), "absolute" ("Thu 09:00") or "both".
+	ResetFormat string `json:"reset_format,omitempty"`
+
+	// Paused is set by "Pause monitoring" and stops automatic updates
+	// until it is unchecked, across restarts too.
+	Paused bool `json:"paused,omitempty"`
+
+	// Accounts lists several accounts to monitor at once. When empty, the
+	// flat session_key/org_id/cf_clearance fields are the only account.
+	Accounts []Account `json:"accounts,omitempty"`
+	// IconAccount picks what the tray icon shows with several accounts:
+	// an account name, or "worst" for the highest usage across all of them.
+	// Default: the first account.
+	IconAccount string `json:"icon_account,omitempty"`
+
+	// FirefoxDir is the Firefox directory holding profiles.ini, for
+	// installs the automatic search doesn't find.
+	FirefoxDir string `json:"firefox_dir,omitempty"`
+	// FirefoxContainer pins the Firefox container (userContextId; 0 is the
+	// default context) cookies are imported from. Unset: the container
+	// that holds a sessionKey, the most recently used one if several do.
+	FirefoxContainer *int `json:"firefox_container,omitempty"`
+	// Browser limits the Firefox directory search to one browser of the
+	// Firefox family: firefox, librewolf, waterfox or floorp. Empty tries
+	// them all, Firefox first.
+	Browser string `json:"browser,omitempty"`
+	// FirefoxProfile is the Firefox profile directory to import cookies
+	// from, set by picking one in the "Import from Firefox" submenu. Empty
+	// means the default profile.
+	FirefoxProfile string `json:"firefox_profile,omitempty"`
+	// ChromeBrowser is the Chromium-based browser picked in the "Import
+	// from browser" submenu (chrome, chromium, edge, brave, vivaldi or
+	// opera). Automatic imports read it before trying the other browsers;
+	// importing from Firefox clears it.
+	ChromeBrowser string `json:"chrome_browser,omitempty"`
+	// AutoImport re-reads the browser's cookies every AutoImportInterval
+	// and after a Cloudflare block, saving them when they differ from
+	// config.json. Default true; false keeps hand-entered values as they are.
+	AutoImport *bool `json:"auto_import,omitempty"`
+	// AutoImportInterval is a Go duration such as "6h" (the default) or
+	// "90m", at least 10 minutes.
+	AutoImportInterval string `json:"auto_import_interval,omitempty"`
+
+	// UpdateInterval is how often usage is polled, a Go duration such as
+	// "5m" (the default) or "15m", at least 1 minute. The "Update every"
+	// submenu sets it.
+	UpdateInterval string `json:"update_interval,omitempty"`
+	// AdaptivePolling picks the interval from the latest reading instead:
+	// every 1-2 minutes near a limit or a reset, 15-30 when usage is low.
+	// It takes precedence over UpdateInterval.
+	AdaptivePolling bool `json:"adaptive_polling,omitempty"`
+
+	// StrictNetwork limits traffic to the usage endpoint itself: optional
+	// calls such as the daily organization check are skipped.
+	StrictNetwork bool `json:"strict_network,omitempty"`
+
+	// Accessibility switches menu and tooltip text to a screen-reader
+	// friendly form: "auto" (default, follows the OS flag), "on" or "off".
+	Accessibility string `json:"accessibility,omitempty"`
+
+	// MaxConfigWritesPerHour caps how often the app rewrites config.json on
+	// its own (default 12); further changes are batched into one later write.
+	MaxConfigWritesPerHour int `json:"max_config_writes_per_hour,omitempty"`
+
+	// SpendAlertUSD and SpendAlertPct are extra-usage spend thresholds, in
+	// dollars and in percent of monthly_limit. Each fires once per month.
+	// Percentages are ignored when spending is uncapped. Default: 80% and 100%.
+	SpendAlertUSD []float64 `json:"spend_alert_usd,omitempty"`
+	SpendAlertPct []float64 `json:"spend_alert_pct,omitempty"`
+
+	// NotifySessionAt and NotifyWeeklyAt are session and weekly utilization
+	// percentages that raise a desktop notification when usage goes up past
+	// them. Empty (the default) means no notifications.
+	NotifySessionAt []float64 `json:"notify_session_at,omitempty"`
+	NotifyWeeklyAt  []float64 `json:"notify_weekly_at,omitempty"`
+	// NotifySessionReset and NotifyWeeklyReset notify when a window resets
+	// after at least half of it was used. Default true.
+	NotifySessionReset *bool `json:"notify_session_reset,omitempty"`
+	NotifyWeeklyReset  *bool `json:"notify_weekly_reset,omitempty"`
+	// NotifyPace notifies when the session or weekly limit is projected to
+	// run out before it resets, once per window.
+	NotifyPace bool `json:"notify_pace,omitempty"`
+
+	// Webhooks are URLs threshold crossings, limit resets, auth errors
+	// and recoveries are POSTed to as JSON, for automation of your own.
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+	// TelegramBotToken and TelegramChatID send threshold crossings,
+	// session resets and auth errors as messages from a Telegram bot, at
+	// most one per bucket an hour. Both are needed.
+	TelegramBotToken string `json:"telegram_bot_token,omitempty"`
+	TelegramChatID   string `json:"telegram_chat_id,omitempty"`
+	// Push publishes threshold crossings and auth errors to a push
+	// notification service: an ntfy topic.
+	Push *PushConfig `json:"push,omitempty"`
+	// Hooks maps events (threshold_crossed, limit_reset, update_failed,
+	// update_recovered, ...) to a shell command run when one happens,
+	// with the event as JSON on stdin and in CLAUDE_* variables.
+	Hooks map[string]string `json:"hooks,omitempty"`
+	// QuietHours silences notifications and the integrations above
+	// during a daily window, optionally sending what it held as a digest
+	// when the window ends.
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+
+	// MetricsListen is a host:port to serve Prometheus metrics on at
+	// /metrics, e.g. "127.0.0.1:9877". Empty (the default) serves none.
+	MetricsListen string `json:"metrics_listen,omitempty"`
+	// MetricsAllowRemote allows a metrics_listen address that isn't
+	// loopback.
+	MetricsAllowRemote bool `json:"metrics_allow_remote,omitempty"`
+
+	// CheckUpdates looks for a newer release on GitHub once every
+	// CheckUpdatesInterval (default a day) and offers it in the menu. Off
+	// by default, so that nothing is sent anywhere but claude.ai.
+	CheckUpdates         bool   `json:"check_updates,omitempty"`
+	CheckUpdatesInterval string `json:"check_updates_interval,omitempty"`
+
+	// IdlePauseAfter stops automatic updates once there has been no input
+	// or the session has been locked this long, e.g. "30m" (the default),
+	// and refreshes on return. "0" keeps updating.
+	IdlePauseAfter string `json:"idle_pause_after,omitempty"`
+
+	// LogFormat is "text" (default), the familiar lines, or "json", one
+	// object a line with ts, level, module, msg and fields such as
+	// status_code.
+	LogFormat string `json:"log_format,omitempty"`
+	// LogLevel is "debug", "info" (default), "warn" or "error": the least
+	// severe entries written. "warn" leaves out routine lines such as
+	// "OK: session=…"; "debug" adds every request with its status and
+	// duration.
+	LogLevel string `json:"log_level,omitempty"`
+	// LogModuleLevels sets the level of single modules (api, firefox,
+	// ui, config) apart from log_level, e.g. {"api": "debug"}.
+	LogModuleLevels map[string]string `json:"log_module_levels,omitempty"`
+	// LogMaxSizeMB is the size claude-monitor.log is rotated at, in MB
+	// (default 1); LogKeep how many rotated files are kept (default 3).
+	LogMaxSizeMB float64 `json:"log_max_size_mb,omitempty"`
+	LogKeep      int     `json:"log_keep,omitempty"`
+
+	// CredentialStore is where sessionKey and cf_clearance are kept: ""
+	// or "file" for config.json, "keyring" for the OS keyring, with
+	// "@keyring" in their place in config.json.
+	CredentialStore string `json:"credential_store,omitempty"`
+
+	// StatusFile is a path the status JSON is written to after every
+	// update, for status bars and scripts. Empty (the default) writes none.
+	StatusFile string `json:"status_file,omitempty"`
+
+	// accountIndex is the Accounts entry this config was derived from by
+	// accountConfigs, or -1 for the flat form.
+	accountIndex int
+	accountName  string
 }
 
-func loadConfig(path string) (*Config, error) {
+// readConfigFile parses config.json without validating credentials, so that
+// settings can be read even before the user has finished setup.
+func readConfigFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && envValue(envSessionKey) != "" {
+		// The environment provides the credentials; the file is optional
+		data, err = []byte("{}"), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 
-	var cfg Config
+	cfg := Config{accountIndex: -1}
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config: %w", err)
 	}
 
+	// The system-wide policy overrides the user's settings for the keys it
+	// lists. updateConfig reads the file directly, so managed values are
+	// never copied into config.json.
+	pol, err := readPolicy(policyPath())
+	if err != nil {
+		return nil, err
+	}
+	if err := pol.apply(&cfg); err != nil {
+		return nil, err
+	}
+	if err := resolveKeyringSecrets(&cfg); err != nil {
+		return nil, err
+	}
+	applyEnvCredentials(&cfg)
+	return &cfg, nil
+}
+
+// errNoOrgID means the config is otherwise usable but org_id is missing,
+// so the organization can be looked up from the API.
+var errNoOrgID = errors.New("org_id not configured")
+
+func loadConfig(path string) (*Config, error) {
+	cfg, err := loadConfigNoOrg(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Accounts) == 0 && cfg.OrgID == "" {
+		return nil, errNoOrgID
+	}
+	return cfg, nil
+}
+
+// loadConfigNoOrg validates everything except org_id, which is returned
+// empty when unset (or still the template placeholder).
+func loadConfigNoOrg(path string) (*Config, error) {
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg.SessionKey = strings.TrimSpace(cfg.SessionKey)
 	cfg.OrgID = strings.TrimSpace(cfg.OrgID)
 	cfg.CfClearance = strings.TrimSpace(cfg.CfClearance)
+	cfg.ProxyURL = strings.TrimSpace(cfg.ProxyURL)
+	cfg.CACertFile = strings.TrimSpace(cfg.CACertFile)
+	cfg.APIBaseURL = strings.TrimRight(strings.TrimSpace(cfg.APIBaseURL), "/")
+
+	cfg.IconAccount = strings.TrimSpace(cfg.IconAccount)
+
+	if len(cfg.Accounts) > 0 {
+		if err := validateAccounts(cfg.Accounts, cfg.IconAccount); err != nil {
+			return nil, err
+		}
+	} else {
+		if cfg.SessionKey == "" || strings.HasPrefix(cfg.SessionKey, "PASTE") {
+			return nil, fmt.Errorf("session_key not configured")
+		}
+		if strings.HasPrefix(cfg.OrgID, "PASTE") {
+			cfg.OrgID = ""
+		}
+	}
+	if cfg.ProxyURL != "" {
+		if _, err := parseProxyURL(cfg.ProxyURL); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.APIBaseURL == "" {
+		cfg.APIBaseURL = defaultAPIBaseURL
+	} else if err := validateAPIBaseURL(cfg.APIBaseURL); err != nil {
+		return nil, err
+	}
+	if _, err := parseAutoImportInterval(cfg.AutoImportInterval); err != nil {
+		return nil, err
+	}
+	if _, err := parseUpdateInterval(cfg.UpdateInterval); err != nil {
+		return nil, err
+	}
+	if err := validateLogging(cfg); err != nil {
+		return nil, err
+	}
+	if _, err := parseReleaseCheckInterval(cfg.CheckUpdatesInterval); err != nil {
+		return nil, err
+	}
+	if _, err := parseIdlePauseAfter(cfg.IdlePauseAfter); err != nil {
+		return nil, err
+	}
+	if cfg.CredentialStore != "" && cfg.CredentialStore != "file" && cfg.CredentialStore != credentialStoreKeyring {
+		return nil, fmt.Errorf("credential_store must be \"file\" or \"keyring\", got %q", cfg.CredentialStore)
+	}
+	if !validIconMode(cfg.IconMode) {
+		return nil, fmt.Errorf("icon_mode must be \"split\", \"session\", \"weekly\" or \"worst\", got %q", cfg.IconMode)
+	}
+	if !validIconStyle(cfg.IconStyle) {
+		return nil, fmt.Errorf("icon_style must be \"color\" or \"mono\", got %q", cfg.IconStyle)
+	}
+	if !validIconValue(cfg.IconValue) {
+		return nil, fmt.Errorf("icon_value must be \"remaining\" or \"used\", got %q", cfg.IconValue)
+	}
+	if _, _, err := parseCriticalBlink(cfg.CriticalBlink); err != nil {
+		return nil, err
+	}
+	if err := validateWebhooks(cfg.Webhooks); err != nil {
+		return nil, err
+	}
+	if err := validatePush(cfg.Push); err != nil {
+		return nil, err
+	}
+	if err := validateHooks(cfg.Hooks); err != nil {
+		return nil, err
+	}
+	if err := validateQuietHours(cfg.QuietHours); err != nil {
+		return nil, err
+	}
+	if _, err := parseStatusFormat(cfg.StatusFormat); err != nil {
+		return nil, err
+	}
+	if !validResetFormat(cfg.ResetFormat) {
+		return nil, fmt.Errorf("reset_format must be \"relative\", \"absolute\" or \"both\", got %q", cfg.ResetFormat)
+	}
 
-	if cfg.SessionKey == "" || strings.HasPrefix(cfg.SessionKey, "PASTE") {
-		return nil, fmt.Errorf("session_key not configured")
+	return cfg, nil
+}
+
+// validateAPIBaseURL checks that api_base_url is an absolute http(s) URL.
+func validateAPIBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("api_base_url must be an absolute http:// or https:// URL, got %q", raw)
 	}
-	if cfg.OrgID == "" || strings.HasPrefix(cfg.OrgID, "PASTE") {
-		return nil, fmt.Errorf("org_id not configured")
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("api_base_url must not contain a query or fragment")
 	}
+	return nil
+}
 
-	return &cfg, nil
+// apiURL joins the configured API base with path (which starts with "/").
+func (c *Config) apiURL(path string) string {
+	base := c.APIBaseURL
+	if base == "" {
+		base = defaultAPIBaseURL
+	}
+	return base + path
+}
+
+// extraUsageWarnPct returns the configured warning threshold or the default.
+// A nil config yields defaults.
+func (c *Config) extraUsageWarnPct() float64 {
+	if c == nil || c.ExtraUsageWarnPct <= 0 {
+		return 80
+	}
+	return c.ExtraUsageWarnPct
 }
 
 // saveFirefoxConfig writes (or updates) config.json with cookies from Firefox.
 // If cfClearance is empty, preserves the existing cf_clearance value.
 func saveFirefoxConfig(path, sessionKey, orgID, cfClearance string) error {
-	// Preserve existing cf_clearance if the new one is empty
-	if cfClearance == "" {
-		var existing Config
-		if data, err := os.ReadFile(path); err == nil {
-			json.Unmarshal(data, &existing) //nolint — best-effort
+	return updateConfig(path, firefoxCookies(sessionKey, orgID, cfClearance))
+}
+
+// firefoxCookies returns the config change that stores imported cookies.
+func firefoxCookies(sessionKey, orgID, cfClearance string) func(*Config) {
+	return func(cfg *Config) {
+		cfg.SessionKey = sessionKey
+		// Without lastActiveOrg keep the saved org; a wrong one is detected
+		// and replaced on the next update
+		if orgID != "" {
+			cfg.OrgID = orgID
+		}
+		// Preserve existing cf_clearance if the new one is empty
+		if cfClearance != "" {
+			cfg.CfClearance = cfClearance
 		}
-		cfClearance = existing.CfClearance
 	}
+}
 
-	cfg := Config{
-		SessionKey:  sessionKey,
-		OrgID:       orgID,
-		CfClearance: cfClearance,
+// configMu serializes read-modify-write cycles on config.json.
+var configMu sync.Mutex
+
+// updateConfig applies fn to the current contents of config.json and writes
+// the result back. Settings that fn doesn't touch are kept as they are, and
+// so are keys this version doesn't know.
+func updateConfig(path string, fn func(*Config)) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	var cfg Config
+	orig, err := os.ReadFile(path)
+	if err == nil {
+		json.Unmarshal(orig, &cfg) //nolint — best-effort
+	}
+	before := cfg
+	fn(&cfg)
+	keepEnvCredentials(&before, &cfg)
+	if err := storeKeyringSecrets(&cfg); err != nil {
+		return err
 	}
 
 	// Ensure the directory exists
-	if err := os.MkdirAll(strings.TrimSuffix(path, "config.json"), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	data, err := mergeConfigJSON(orig, &cfg)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	noteConfigWrite(data)
+	return writeFileAtomic(path, data, configFileMode)
+}
+
+// mergeConfigJSON renders cfg over the config.json it was read from: keys
+// the Config struct doesn't know, such as notes or settings of a newer
+// version, are kept as they were, and the keys keep their order, with new
+// settings appended in struct order. A settings key missing from cfg's
+// JSON (cleared, with omitempty) is removed. If orig isn't a JSON object,
+// such as a file cut short, it fails with errConfigNotObject rather than
+// write over what the user had.
+func mergeConfigJSON(orig []byte, cfg *Config) ([]byte, error) {
+	fresh, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(fresh, &values); err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	var order []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[name] = true
+			order = append(order, name)
+		}
+	}
+
+	keys, origValues, ok := objectKeys(orig)
+	if !ok {
+		return nil, errConfigNotObject
+	}
+	var out bytes.Buffer
+	done := map[string]bool{}
+	field := func(k string, v json.RawMessage) {
+		if out.Len() > 0 {
+			out.WriteByte(',')
+		}
+		kj, _ := json.Marshal(k)
+		out.Write(kj)
+		out.WriteByte(':')
+		out.Write(v)
+		done[k] = true
+	}
+	for _, k := range keys {
+		switch {
+		case done[k]:
+		case !known[k]:
+			field(k, origValues[k])
+		case values[k] != nil:
+			field(k, values[k])
+		}
+	}
+	for _, k := range order {
+		if !done[k] && values[k] != nil {
+			field(k, values[k])
+		}
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte("{"+out.String()+"}"), "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// errConfigNotObject refuses to save over a config.json that doesn't parse.
+var errConfigNotObject = errors.New("config.json is not a valid JSON object; fix it by hand, it was left as it is")
+
+// objectKeys returns the keys of the JSON object data in the order they
+// appear, with their values. An empty or missing file is an empty object.
+func objectKeys(data []byte) ([]string, map[string]json.RawMessage, bool) {
+	values := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, values, true
+	}
+	if json.Unmarshal(data, &values) != nil {
+		return nil, nil, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil { // {
+		return nil, nil, false
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, false
+		}
+		keys = append(keys, tok.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, nil, false
+		}
+	}
+	return keys, values, true
+}
+
+// configFileMode keeps config.json, which holds the sessionKey, readable
+// by its owner only.
+const configFileMode = 0600
+
+// writeFileAtomic replaces path with data through a synced temporary file
+// in the same directory, so that a crash leaves either the old or the new
+// contents. A symlinked path is followed, replacing the file it points to.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, werr := tmp.Write(data)
+	if werr == nil {
+		werr = tmp.Sync()
+	}
+	if cerr := tmp.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Chmod(tmp.Name(), perm)
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), path)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+	}
+	return werr
+}
+
+// restrictConfigMode takes group and other access away from a config.json
+// written by an older version with 0644.
+func restrictConfigMode(path string) {
+	fi, err := os.Stat(path)
+	if err != nil || runtime.GOOS == "windows" || fi.Mode().Perm()&0077 == 0 {
+		return
+	}
+	if err := os.Chmod(path, configFileMode); err != nil {
+		configLog.Warnf("Failed to restrict config.json permissions: %v", err)
+		return
+	}
+	configLog.Infof("Restricted %s to mode %o", path, configFileMode)
 }
 
 func createTemplateConfig(path string) error {
@@ -80,7 +625,7 @@ func createTemplateConfig(path string) error {
 		return err
 	}
 
-	dir := strings.TrimSuffix(path, "config.json")
+	dir := filepath.Dir(path)
 	readme := `=== Claude Monitor - Setup ===
 
 To get the values for config.json:
@@ -91,7 +636,7 @@ To get the values for config.json:
 
 3. Find and copy these 3 cookies:
    - sessionKey      (starts with sk-ant-sid01-...)
-   - lastActiveOrg   (UUID format)
+   - lastActiveOrg   (UUID format; optional, looked up automatically if missing)
    - cf_clearance     (Cloudflare token)
 
 4. Paste all three values into config.json
@@ -99,8 +644,29 @@ To get the values for config.json:
 Note: cf_clearance refreshes frequently (hours/days).
 sessionKey refreshes roughly once a month.
 If the app stops showing data - update the values.
+
+Shortcut: in DevTools -> tab "Network", right-click a request to
+claude.ai/api -> Copy -> Copy as cURL, then use "Import from clipboard"
+in the tray menu.
+
+Status file: set "status_file" to a path and the app writes its status
+there as JSON after every update, for status bars and scripts:
+
+  updated_at    when the file was written (RFC 3339)
+  ok            whether the last update succeeded
+  error         why it failed, if it did
+  last_update   when the numbers below were read; older than a few
+                polls means they are stale
+  session, weekly, opus, sonnet
+                {"utilization": 42, "remaining": 58,
+                 "resets_at": "<ISO time>"}; absent buckets are left out
+  extra_usage   pay-as-you-go credits, when enabled
+
+Fields are only ever added, never renamed. With "metrics_listen" set the
+same document is served at http://<metrics_listen>/status.
 `
-	os.WriteFile(dir+"README-config.txt", []byte(readme), 0644)
+	os.WriteFile(filepath.Join(dir, "README-config.txt"), []byte(readme), 0644)
 
-	return os.WriteFile(path, data, 0644)
+	noteConfigWrite(data)
+	return writeFileAtomic(path, data, configFileMode)
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeTestConfig writes config.json with content to a temporary directory,
+// with no policy in effect, and returns its path.
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saved := policyPath
+	policyPath = func() string { return filepath.Join(dir, policyFileName) }
+	t.Cleanup(func() { policyPath = saved })
+	return path
+}
+
+func TestLoadConfigAccounts(t *testing.T) {
+	path := writeTestConfig(t, `{
+		"accounts": [
+			{"name": " Work ", "session_key": "sk-work", "org_id": "org-work"},
+			{"session_key": "sk-2", "org_id": "org-2", "cf_clearance": "cf-2"}
+		],
+		"icon_account": "Account 2"
+	}`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := cfg.accountConfigs()
+	if len(accounts) != 2 {
+		t.Fatalf("accountConfigs() returned %d configs, want 2", len(accounts))
+	}
+	if a := accounts[0]; a.accountName != "Work" || a.SessionKey != "sk-work" || a.OrgID != "org-work" || a.accountIndex != 0 {
+		t.Errorf("accounts[0] = %q %q %q %d", a.accountName, a.SessionKey, a.OrgID, a.accountIndex)
+	}
+	if a := accounts[1]; a.accountName != "Account 2" || a.CfClearance != "cf-2" || a.Accounts != nil {
+		t.Errorf("accounts[1] = %q cf=%q accounts=%v", a.accountName, a.CfClearance, a.Accounts)
+	}
+	if got := cfg.primaryAccount(); got != 1 {
+		t.Errorf("primaryAccount() = %d, want 1", got)
+	}
+}
+
+func TestLoadConfigRejectsBadAccounts(t *testing.T) {
+	for name, content := range map[string]string{
+		"duplicate name": `{"accounts": [{"name": "A", "session_key": "x", "org_id": "y"}, {"name": "A", "session_key": "z", "org_id": "w"}]}`,
+		"missing org_id": `{"accounts": [{"name": "A", "session_key": "x"}]}`,
+		"unknown icon":   `{"accounts": [{"name": "A", "session_key": "x", "org_id": "y"}], "icon_account": "B"}`,
+	} {
+		if _, err := loadConfig(writeTestConfig(t, content)); err == nil {
+			t.Errorf("%s: loadConfig succeeded", name)
+		}
+	}
+}
+
+func TestUpdateConfigWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "settings.json")
+	saved := policyPath
+	policyPath = func() string { return filepath.Join(dir, policyFileName) }
+	t.Cleanup(func() { policyPath = saved })
+
+	if err := saveFirefoxConfig(path, "sk-ant-sid01-x", "org", "cf"); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %o, want 600", fi.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("left behind: %v", entries)
+	}
+
+	// A symlinked config.json stays a symlink
+	link := filepath.Join(dir, "config.json")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	if err := saveFirefoxConfig(link, "sk-ant-sid01-y", "org", ""); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink replaced: %v, %v", fi, err)
+	}
+	if cfg, err := loadConfig(path); err != nil || cfg.SessionKey != "sk-ant-sid01-y" {
+		t.Errorf("target not updated: %+v, %v", cfg, err)
+	}
+}
+
+func TestRestrictConfigMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Unix permissions")
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restrictConfigMode(path)
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("mode = %o, want 600", fi.Mode().Perm())
+	}
+}
+
+func TestUpdateConfigKeepsUnknownKeys(t *testing.T) {
+	path := writeTestConfig(t, `{
+  "_notes": "work laptop",
+  "session_key": "sk-old",
+  "org_id": "org",
+  "cf_clearance": "cf-old",
+  "icon_style": "compact",
+  "my_script": {"enabled": true, "targets": [1, 2]},
+  "proxy_url": "http://proxy:3128"
+}`)
+	if err := updateConfig(path, func(c *Config) {
+		c.SessionKey = "sk-new"
+		c.ProxyURL = ""
+		c.IconAction = "open_claude"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "_notes": "work laptop",
+  "session_key": "sk-new",
+  "org_id": "org",
+  "cf_clearance": "cf-old",
+  "icon_style": "compact",
+  "my_script": {
+    "enabled": true,
+    "targets": [
+      1,
+      2
+    ]
+  },
+  "icon_action": "open_claude"
+}`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestUpdateConfigKeepsBrokenFile(t *testing.T) {
+	broken := `{
+  "_notes": "work laptop",
+  "session_key": "sk-old",
+  "org_id": "or`
+	path := writeTestConfig(t, broken)
+	err := updateConfig(path, func(c *Config) { c.SessionKey = "sk-new" })
+	if !errors.Is(err, errConfigNotObject) {
+		t.Errorf("updateConfig on a truncated file: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != broken {
+		t.Errorf("file rewritten:\n%s", data)
+	}
+}
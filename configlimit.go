@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultConfigWritesPerHour caps automatic config.json rewrites (cookie
+// refreshes, org corrections) unless max_config_writes_per_hour is set.
+const defaultConfigWritesPerHour = 12
+
+// errConfigWriteDeferred is returned by updateConfigAuto when the change was
+// queued instead of written.
+var errConfigWriteDeferred = errors.New("config.json write deferred: automatic write limit reached")
+
+// writeLimiter allows at most a given number of writes per sliding window.
+// Writes over the limit are queued and applied together in one write once
+// the window has room again.
+type writeLimiter struct {
+	mu      sync.Mutex
+	window  time.Duration
+	times   []time.Time // writes within the window, oldest first
+	pending []func(*Config)
+}
+
+var configWrites = writeLimiter{window: time.Hour}
+
+// allow records a write at now if fewer than limit writes happened within
+// the window. Otherwise it returns how long until the oldest one expires.
+// l.mu must be held.
+func (l *writeLimiter) allow(now time.Time, limit int) (bool, time.Duration) {
+	keep := l.times[:0]
+	for _, t := range l.times {
+		if now.Sub(t) < l.window {
+			keep = append(keep, t)
+		}
+	}
+	l.times = keep
+	if len(l.times) < limit {
+		l.times = append(l.times, now)
+		return true, 0
+	}
+	return false, l.times[0].Add(l.window).Sub(now)
+}
+
+// maxConfigWritesPerHour returns the configured limit or the default.
+// A nil config yields the default.
+func (c *Config) maxConfigWritesPerHour() int {
+	if c == nil || c.MaxConfigWritesPerHour <= 0 {
+		return defaultConfigWritesPerHour
+	}
+	return c.MaxConfigWritesPerHour
+}
+
+// updateConfigAuto is updateConfig for changes the app makes on its own. It
+// is rate limited; user actions call updateConfig directly.
+func updateConfigAuto(path string, fn func(*Config)) error {
+	cfg, _ := readConfigFile(path)
+	limit := cfg.maxConfigWritesPerHour()
+
+	l := &configWrites
+	l.mu.Lock()
+	if len(l.pending) > 0 {
+		// A flush is already scheduled; coalesce into it
+		l.pending = append(l.pending, fn)
+		n := len(l.pending)
+		l.mu.Unlock()
+		health.report("Config writes", false, fmt.Sprintf("limit %d/h reached, %d changes pending", limit, n))
+		return errConfigWriteDeferred
+	}
+	ok, wait := l.allow(time.Now(), limit)
+	if !ok {
+		l.pending = append(l.pending, fn)
+		time.AfterFunc(wait, func() { l.flush(path) })
+		l.mu.Unlock()
+		configLog.Warnf("Automatic config.json writes exceeded %d/hour; next write in %s", limit, shortDuration(wait))
+		health.report("Config writes", false, fmt.Sprintf("limit %d/h reached, next write in %s", limit, shortDuration(wait)))
+		return errConfigWriteDeferred
+	}
+	l.mu.Unlock()
+	return updateConfig(path, fn)
+}
+
+// flush applies all queued changes in a single write.
+func (l *writeLimiter) flush(path string) {
+	l.mu.Lock()
+	fns := l.pending
+	l.pending = nil
+	l.times = append(l.times, time.Now())
+	l.mu.Unlock()
+
+	err := updateConfig(path, func(c *Config) {
+		for _, fn := range fns {
+			fn(c)
+		}
+	})
+	if err != nil {
+		configLog.Warnf("Deferred config.json write failed: %v", err)
+		health.report("Config writes", false, "deferred write failed: "+err.Error())
+		return
+	}
+	configLog.Infof("Deferred config.json write applied (%d changes)", len(fns))
+	health.report("Config writes", true, fmt.Sprintf("%d deferred changes applied", len(fns)))
+}
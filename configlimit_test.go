@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLimiterWindow(t *testing.T) {
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	l := writeLimiter{window: time.Hour}
+	for _, tc := range []struct {
+		minute int
+		ok     bool
+		wait   time.Duration
+	}{
+		{0, true, 0},
+		{10, true, 0},
+		{20, true, 0},
+		{30, false, 30 * time.Minute}, // until the write at 0 leaves the window
+		{59, false, time.Minute},
+		{60, true, 0}, // the write at 0 is an hour old
+		{61, false, 9 * time.Minute},
+		{200, true, 0}, // all old writes gone
+		{201, true, 0},
+		{202, true, 0},
+		{203, false, 57 * time.Minute},
+	} {
+		l.mu.Lock()
+		ok, wait := l.allow(at(tc.minute), 3)
+		l.mu.Unlock()
+		if ok != tc.ok || wait != tc.wait {
+			t.Errorf("minute %d: allow = %v, %v; want %v, %v", tc.minute, ok, wait, tc.ok, tc.wait)
+		}
+	}
+}
+
+// useConfigWriteWindow gives the config write limiter a short window and
+// no history for the test.
+func useConfigWriteWindow(t *testing.T, window time.Duration) {
+	t.Helper()
+	l := &configWrites
+	l.mu.Lock()
+	saved := l.window
+	l.window, l.times, l.pending = window, nil, nil
+	l.mu.Unlock()
+	t.Cleanup(func() {
+		l.mu.Lock()
+		l.window, l.times, l.pending = saved, nil, nil
+		l.mu.Unlock()
+	})
+}
+
+func TestUpdateConfigAutoCoalesces(t *testing.T) {
+	path := writeTestConfig(t, `{"session_key": "sk-ant-sid01-abc", "org_id": "org-1", "max_config_writes_per_hour": 2}`)
+	useConfigWriteWindow(t, 300*time.Millisecond)
+
+	setOrg := func(org string) func(*Config) { return func(c *Config) { c.OrgID = org } }
+	for i, org := range []string{"org-2", "org-3"} {
+		if err := updateConfigAuto(path, setOrg(org)); err != nil {
+			t.Fatalf("write %d: %v", i+1, err)
+		}
+	}
+	if err := updateConfigAuto(path, setOrg("org-4")); !errors.Is(err, errConfigWriteDeferred) {
+		t.Fatalf("third write: %v, want it deferred", err)
+	}
+	if err := updateConfigAuto(path, func(c *Config) { c.CfClearance = "cf-5" }); !errors.Is(err, errConfigWriteDeferred) {
+		t.Fatalf("fourth write: %v, want it deferred", err)
+	}
+	if st, _ := health.get("Config writes"); st.OK || !strings.Contains(st.Detail, "2 changes pending") {
+		t.Errorf("health while limited: %+v", st)
+	}
+	if cfg, _ := readConfigFile(path); cfg.OrgID != "org-3" || cfg.CfClearance != "" {
+		t.Errorf("config.json written over the limit: org_id %q, cf_clearance %q", cfg.OrgID, cfg.CfClearance)
+	}
+
+	// A user action isn't limited
+	if err := updateConfig(path, func(c *Config) { c.IconAction = iconActionStatus }); err != nil {
+		t.Fatalf("manual write: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if st, _ := health.get("Config writes"); st.OK {
+			if st.Detail != "2 deferred changes applied" {
+				t.Errorf("health after the flush: %+v", st)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("deferred changes not applied")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cfg, _ := readConfigFile(path)
+	if cfg.OrgID != "org-4" || cfg.CfClearance != "cf-5" {
+		t.Errorf("after the flush org_id %q, cf_clearance %q; want both deferred changes", cfg.OrgID, cfg.CfClearance)
+	}
+	if cfg.IconAction != iconActionStatus {
+		t.Errorf("deferred write lost the manual change: icon_action %q", cfg.IconAction)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// configPollInterval is how often config.json's modification time is
+	// checked for edits made outside the app.
+	configPollInterval = 2 * time.Second
+	// configFeedbackFor is how long the verdict stays in the header item.
+	configFeedbackFor = 10 * time.Second
+)
+
+// ownConfigWrite is the content the app last wrote to config.json, so the
+// watcher doesn't report the app's own changes as edits.
+var ownConfigWrite struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func noteConfigWrite(data []byte) {
+	ownConfigWrite.mu.Lock()
+	ownConfigWrite.data = data
+	ownConfigWrite.mu.Unlock()
+}
+
+func isOwnConfigWrite(data []byte) bool {
+	ownConfigWrite.mu.Lock()
+	defer ownConfigWrite.mu.Unlock()
+	return ownConfigWrite.data != nil && bytes.Equal(data, ownConfigWrite.data)
+}
+
+// watchConfig polls config.json and, after it was edited, calls feedback
+// with a one-line verdict for the header item. refresh is called when the
+// edited config is usable, so new credentials take effect right away.
+func watchConfig(feedback func(string), refresh func()) {
+	var lastMod time.Time
+	var lastSize int64
+	if fi, err := os.Stat(configPath); err == nil {
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+	}
+	for {
+		time.Sleep(configPollInterval)
+		fi, err := os.Stat(configPath)
+		if err != nil || (fi.ModTime().Equal(lastMod) && fi.Size() == lastSize) {
+			continue
+		}
+		lastMod, lastSize = fi.ModTime(), fi.Size()
+
+		data, err := os.ReadFile(configPath)
+		if err != nil || isOwnConfigWrite(data) {
+			continue
+		}
+		msg, ok := configVerdict(data, checkConfig(configPath, data), accessibleText.Load())
+		configLog.Infof("config.json changed: %s", msg)
+		feedback(msg)
+		if ok {
+			refresh()
+		}
+	}
+}
+
+// checkConfig validates config.json, whose contents are data, the way
+// updates load it. JSON errors in data are returned unwrapped. A missing
+// org_id is not an error: it is looked up from the API.
+func checkConfig(path string, data []byte) error {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return err
+	}
+	_, err := loadConfigNoOrg(path)
+	return err
+}
+
+// configVerdict returns the header line for a config.json with contents
+// data that failed validation with err (nil if it passed).
+func configVerdict(data []byte, err error, accessible bool) (msg string, ok bool) {
+	if err == nil {
+		return "Config OK " + mark(markOK, accessible), true
+	}
+	return truncate("Config error: "+describeConfigError(data, err), maxMenuLine), false
+}
+
+// describeConfigError formats a config loading error for the menu. JSON
+// errors from parsing data are located by line and column instead of byte
+// offset; wrapped ones (e.g. from policy.json) refer to another file.
+func describeConfigError(data []byte, err error) string {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		line, col := jsonLineCol(data, e.Offset)
+		return fmt.Sprintf("line %d, column %d: %s", line, col, e.Error())
+	case *json.UnmarshalTypeError:
+		line, col := jsonLineCol(data, e.Offset)
+		return fmt.Sprintf("line %d, column %d: %s: expected %s, got %s", line, col, e.Field, e.Type, e.Value)
+	}
+	return err.Error()
+}
+
+// jsonLineCol converts the Offset of a JSON error (the number of bytes read
+// before the error was detected) to the 1-based line and column of the
+// offending byte. Columns count runes, as editors do.
+func jsonLineCol(data []byte, offset int64) (line, col int) {
+	pos := int(offset) - 1
+	if pos >= len(data) {
+		pos = len(data) - 1
+	}
+	if pos < 0 {
+		return 1, 1
+	}
+	line, col = 1, 1
+	for _, r := range string(data[:pos]) {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestJSONLineCol(t *testing.T) {
+	for _, tc := range []struct {
+		data      string
+		line, col int
+	}{
+		{"{\n  \"session_key\": \"sk\",\n  \"org_id\": \"org\"\n  \"cf_clearance\": \"\"\n}", 4, 3},
+		{"{\"session_key\": \"sk\",}", 1, 22},
+		{"{\r\n  \"session_key\": \"sk\"\r\n  }}", 3, 4},
+		{"{\n  \"name\": \"Ünïcödé\" x\n}", 2, 21},
+		{"{\n  \"session_key\": \"sk\",\n", 2, 23}, // end of input: the last byte
+		{"", 1, 1},
+	} {
+		var cfg Config
+		err := json.Unmarshal([]byte(tc.data), &cfg)
+		var serr *json.SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%q: error %v is not a syntax error", tc.data, err)
+			continue
+		}
+		if line, col := jsonLineCol([]byte(tc.data), serr.Offset); line != tc.line || col != tc.col {
+			t.Errorf("%q (offset %d): line %d col %d, want line %d col %d", tc.data, serr.Offset, line, col, tc.line, tc.col)
+		}
+	}
+}
+
+func TestDescribeConfigError(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{"{\n  \"session_key\": \"sk\",\n}", "line 3, column 1: invalid character '}' looking for beginning of object key string"},
+		{"{\n  \"session_key\": \"sk\"", "line 2, column 21: unexpected end of JSON input"},
+		{"{\n  \"strict_network\": \"yes\"\n}", "line 2, column 25: strict_network: expected bool, got string"},
+		{"{\n  \"accounts\": {}\n}", "line 2, column 15: accounts: expected []main.Account, got object"},
+	} {
+		if got := describeConfigError([]byte(tc.data), checkConfig("", []byte(tc.data))); got != tc.want {
+			t.Errorf("%q: %q, want %q", tc.data, got, tc.want)
+		}
+	}
+
+	// Wrapped JSON errors come from another file; their offset means nothing here
+	wrapped := fmt.Errorf("parsing policy x: %w", &json.SyntaxError{Offset: 3})
+	if got := describeConfigError([]byte("{}"), wrapped); got != wrapped.Error() {
+		t.Errorf("wrapped error described as %q", got)
+	}
+}
+
+func TestConfigVerdict(t *testing.T) {
+	if msg, ok := configVerdict(nil, nil, false); msg != "Config OK ✓" || !ok {
+		t.Errorf("valid config: verdict %q %v", msg, ok)
+	}
+	if msg, _ := configVerdict(nil, nil, true); msg != "Config OK (done)" {
+		t.Errorf("valid config, accessible: verdict %q", msg)
+	}
+
+	data := []byte("{\n  \"session_key\": \"sk\",\n}")
+	msg, ok := configVerdict(data, checkConfig("", data), false)
+	if ok || !strings.HasPrefix(msg, "Config error: line 3, column 1: invalid character") ||
+		!strings.HasSuffix(msg, "…") || len([]rune(msg)) != maxMenuLine {
+		t.Errorf("syntax error: verdict %q %v, want a truncated error", msg, ok)
+	}
+
+	if msg, _ := configVerdict(nil, errors.New("session_key not configured"), false); msg != "Config error: session_key not configured" {
+		t.Errorf("validation error: verdict %q", msg)
+	}
+}
+
+func TestCheckConfigValidatesFile(t *testing.T) {
+	data := `{"session_key": "", "org_id": "org"}`
+	path := writeTestConfig(t, data)
+	if err := checkConfig(path, []byte(data)); err == nil || !strings.Contains(err.Error(), "session_key") {
+		t.Errorf("checkConfig error = %v, want one about session_key", err)
+	}
+}
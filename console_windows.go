@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// attachConsole connects stdout/stderr to the parent console. The release
+// binary is built as a GUI app (-H windowsgui), so CLI subcommands would
+// otherwise print nowhere.
+func attachConsole() {
+	const attachParentProcess = ^uintptr(0) // (DWORD)-1
+	proc := syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+	if r, _, _ := proc.Call(attachParentProcess); r == 0 {
+		return
+	}
+	if f, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = f
+		os.Stderr = f
+	}
+}
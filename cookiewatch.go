@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cookieWatchInterval is how often the Firefox cookie files are
+	// stat'ed. Polling, like watchConfig does, follows a file that Firefox
+	// deleted and recreated, which a watch on the old file would lose.
+	cookieWatchInterval = 2 * time.Second
+	// cookieWatchDebounce is how long the files must stay unchanged before
+	// they are read: Firefox writes in bursts.
+	cookieWatchDebounce = 4 * time.Second
+	// cookieWatchMinGap spaces out reads while browsing keeps touching the
+	// database, which holds every site's cookies, not just claude.ai's.
+	cookieWatchMinGap = 30 * time.Second
+)
+
+// cookieFileNames are the files of a profile whose changes are watched:
+// new cookies land in the WAL first and in cookies.sqlite at checkpoints.
+var cookieFileNames = []string{"cookies.sqlite", "cookies.sqlite-wal"}
+
+// cookieWatcher notices when Firefox writes the cookie database of the
+// profile imports read from, so a re-login in the browser is picked up
+// right away instead of after the next Cloudflare block.
+type cookieWatcher struct {
+	interval, debounce, minGap time.Duration
+
+	mu  sync.Mutex
+	dir string // profile directory, "" while there is none
+
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+var cookieFiles = newCookieWatcher()
+
+func newCookieWatcher() *cookieWatcher {
+	return &cookieWatcher{
+		interval: cookieWatchInterval,
+		debounce: cookieWatchDebounce,
+		minGap:   cookieWatchMinGap,
+		quit:     make(chan struct{}),
+	}
+}
+
+// locate switches to the profile imports currently read from
+// (firefox_profile or the default one). Nothing is watched while
+// chrome_browser names the browser to import from instead.
+func (w *cookieWatcher) locate() {
+	if cfg, err := readConfigFile(configPath); err == nil && strings.TrimSpace(cfg.ChromeBrowser) != "" {
+		w.setDir("")
+		return
+	}
+	dir, err := firefoxProfileDir()
+	if err != nil {
+		firefoxLog.Warnf("Not watching Firefox cookies: %v", err)
+		dir = ""
+	}
+	w.setDir(dir)
+}
+
+func (w *cookieWatcher) setDir(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if dir != w.dir && dir != "" {
+		firefoxLog.Infof("Watching Firefox cookies in %s", dir)
+	}
+	w.dir = dir
+}
+
+// profile returns the directory being watched.
+func (w *cookieWatcher) profile() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dir
+}
+
+// cookieFileStamp is what a poll sees of the cookie files; a missing file
+// has the zero stamp.
+type cookieFileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+func statCookieFiles(dir string) [2]cookieFileStamp {
+	var st [2]cookieFileStamp
+	if dir == "" {
+		return st
+	}
+	for i, name := range cookieFileNames {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			st[i] = cookieFileStamp{fi.ModTime(), fi.Size()}
+		}
+	}
+	return st
+}
+
+// run polls the cookie files until stop and calls onChange with the
+// profile directory once they have changed and then stayed unchanged for
+// the debounce time.
+func (w *cookieWatcher) run(onChange func(profileDir string)) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	dir := w.profile()
+	last := statCookieFiles(dir)
+	var changed, lastRead time.Time
+	for {
+		select {
+		case <-w.quit:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if d := w.profile(); d != dir {
+			// Another profile was picked: start over from its files
+			dir, last, changed = d, statCookieFiles(d), time.Time{}
+			continue
+		}
+		if st := statCookieFiles(dir); st != last {
+			last, changed = st, now
+			continue
+		}
+		if changed.IsZero() || now.Sub(changed) < w.debounce || now.Sub(lastRead) < w.minGap {
+			continue
+		}
+		changed, lastRead = time.Time{}, now
+		onChange(dir)
+	}
+}
+
+// stop makes run return at its next poll. A read already in progress
+// finishes; updates it starts are refused once shutdown has begun.
+func (w *cookieWatcher) stop() {
+	w.stopOnce.Do(func() { close(w.quit) })
+}
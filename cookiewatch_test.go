@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCookieWatcher(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "cookies.sqlite")
+	if err := os.WriteFile(db, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newCookieWatcher()
+	w.interval, w.debounce, w.minGap = 10*time.Millisecond, 80*time.Millisecond, 0
+	w.setDir(dir)
+	calls := make(chan string, 10)
+	go w.run(func(profileDir string) { calls <- profileDir })
+	defer w.stop()
+
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case got := <-calls:
+			if got != dir {
+				t.Errorf("%s: onChange(%q), want %q", what, got, dir)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: onChange not called", what)
+		}
+	}
+
+	select {
+	case <-calls:
+		t.Fatal("onChange called before anything changed")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(db+"-wal", []byte("wal"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expect("WAL created")
+
+	// Firefox replacing the database: delete, then recreate
+	if err := os.Remove(db); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(db, []byte("recreated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expect("database recreated")
+
+	select {
+	case <-calls:
+		t.Error("onChange called again without a change")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
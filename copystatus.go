@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultStatusFormat is the "Copy status" text when status_format is
+// unset, e.g. "Claude usage: session 42% (resets in 2h 13m), weekly 12%
+// (resets in 3d 4h), Opus 30% — as of 14:02".
+const defaultStatusFormat = "Claude usage: session {{.Session.Used}}% (resets {{.Session.Resets}}), " +
+	"weekly {{.Weekly.Used}}% (resets {{.Weekly.Resets}})" +
+	"{{with .Opus}}, Opus {{.Used}}%{{end}}{{with .Sonnet}}, Sonnet {{.Used}}%{{end}}" +
+	" — as of {{.Fetched}}"
+
+// parseStatusFormat parses status_format, a Go template; empty is the
+// default format.
+func parseStatusFormat(s string) (*template.Template, error) {
+	if strings.TrimSpace(s) == "" {
+		s = defaultStatusFormat
+	}
+	t, err := template.New("status_format").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("status_format is not a valid template: %w", err)
+	}
+	return t, nil
+}
+
+// statusFormatBucket is one bucket as status_format sees it.
+type statusFormatBucket struct {
+	Used      int    // utilization, percent
+	Remaining int    // 100 - Used
+	Resets    string // "in 2h 13m", "?" without a reset time
+	ResetsAt  string // "Thu 09:00", "" without a reset time
+}
+
+// statusData is what status_format is executed with. Opus and Sonnet are
+// nil when the account has no such bucket, for {{with .Opus}}…{{end}}.
+type statusData struct {
+	Session, Weekly statusFormatBucket
+	Opus, Sonnet    *statusFormatBucket
+	Fetched         string // clock time of the fetch, with the day if not today
+	Age             string // "3m ago"
+}
+
+// statusSnapshot is the last usage shown, kept for "Copy status" so reset
+// countdowns are current when it is copied.
+type statusSnapshot struct {
+	mu           sync.Mutex
+	usage        *UsageResponse
+	opus, sonnet *UsageBucket
+	fetched      time.Time
+}
+
+var lastStatus statusSnapshot
+
+// set records the usage shown and when it was fetched.
+func (s *statusSnapshot) set(usage *UsageResponse, opus, sonnet *UsageBucket, fetched time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage, s.opus, s.sonnet, s.fetched = usage, opus, sonnet, fetched
+}
+
+// text renders the snapshot with status_format at now; without usage yet
+// it says so.
+func (s *statusSnapshot) text(cfg *Config, now time.Time) (string, error) {
+	s.mu.Lock()
+	usage, opus, sonnet, fetched := s.usage, s.opus, s.sonnet, s.fetched
+	s.mu.Unlock()
+	if usage == nil {
+		return appName + ": no data yet", nil
+	}
+	var format string
+	if cfg != nil {
+		format = cfg.StatusFormat
+	}
+	return renderStatus(format, usage, opus, sonnet, fetched, now)
+}
+
+// renderStatus fills status_format with usage as fetched at fetched.
+func renderStatus(format string, usage *UsageResponse, opus, sonnet *UsageBucket, fetched, now time.Time) (string, error) {
+	t, err := parseStatusFormat(format)
+	if err != nil {
+		return "", err
+	}
+	bucket := func(b *UsageBucket) statusFormatBucket {
+		at := resetTime(b.ResetsAt)
+		sb := statusFormatBucket{Used: int(b.Utilization), Remaining: 100 - int(b.Utilization), Resets: formatReset(at)}
+		if !at.IsZero() {
+			sb.ResetsAt = formatResetAt(at, now, false)
+		}
+		return sb
+	}
+	optional := func(b *UsageBucket) *statusFormatBucket {
+		if b == nil {
+			return nil
+		}
+		sb := bucket(b)
+		return &sb
+	}
+	data := statusData{
+		Session: bucket(&usage.FiveHour),
+		Weekly:  bucket(&usage.SevenDay),
+		Opus:    optional(opus),
+		Sonnet:  optional(sonnet),
+		Fetched: "?",
+	}
+	if !fetched.IsZero() {
+		data.Fetched = strings.TrimPrefix(formatResetAt(fetched, now, false), "at ")
+		data.Age = formatAge(max(now.Sub(fetched), 0))
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("status_format: %w", err)
+	}
+	return b.String(), nil
+}
+
+// copyStatus puts the status_format summary of the last usage shown on the
+// clipboard, for the "Copy status" menu item and icon action.
+func copyStatus() error {
+	cfg, _ := readConfigFile(configPath) // nil, the default format, if unreadable
+	text, err := lastStatus.text(cfg, timeNow())
+	if err != nil {
+		return err
+	}
+	return copyToClipboard(text)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderStatus(t *testing.T) {
+	now := time.Date(2026, 5, 4, 14, 5, 0, 0, time.Local)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	usage := &UsageResponse{
+		FiveHour: UsageBucket{Utilization: 42, ResetsAt: now.Add(2*time.Hour + 13*time.Minute).Format(time.RFC3339)},
+		SevenDay: UsageBucket{Utilization: 12, ResetsAt: now.Add(76 * time.Hour).Format(time.RFC3339)},
+	}
+	opus := &UsageBucket{Utilization: 30}
+	fetched := now.Add(-3 * time.Minute)
+
+	for _, tc := range []struct {
+		name, format string
+		opus         *UsageBucket
+		want         string
+	}{
+		{"default", "", opus, "Claude usage: session 42% (resets in 2h 13m), weekly 12% (resets in 3d 4h), Opus 30% — as of 14:02"},
+		{"no Opus", "", nil, "Claude usage: session 42% (resets in 2h 13m), weekly 12% (resets in 3d 4h) — as of 14:02"},
+		{"markdown", "**Session** {{.Session.Remaining}}% left · **Weekly** resets {{.Weekly.ResetsAt}} _({{.Age}})_", opus,
+			"**Session** 58% left · **Weekly** resets Thu 18:05 _(3m ago)_"},
+	} {
+		got, err := renderStatus(tc.format, usage, tc.opus, nil, fetched, now)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v\nwant %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	if _, err := renderStatus("{{.Session.Used", usage, nil, nil, fetched, now); err == nil {
+		t.Error("unterminated action accepted")
+	}
+	if _, err := renderStatus("{{.Session.Percent}}", usage, nil, nil, fetched, now); err == nil {
+		t.Error("unknown field accepted")
+	}
+
+	var s statusSnapshot
+	if got, _ := s.text(nil, now); got != appName+": no data yet" {
+		t.Errorf("without usage: %q", got)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+// crashRestartDelay is how long a goroutine restarted after a panic waits
+// first, so one that panics right away doesn't spin.
+var crashRestartDelay = 5 * time.Second
+
+// crashTest is set by the hidden --crash-test flag: the menu handler
+// panics once the tray is up, to check the crash report end to end.
+var crashTest bool
+
+// exitAfterCrash ends the process after a panic nothing recovers from;
+// swapped out in tests.
+var exitAfterCrash = func() {
+	closeLog()
+	os.Exit(2)
+}
+
+// recoverCrash, deferred at the top of a long-lived goroutine, turns a
+// panic in it into a crash report next to the log. Then it calls restart,
+// or with a nil restart ends the process with a final log line, since a
+// menu that no longer answers is worse than no tray icon.
+func recoverCrash(where string, restart func()) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	path, err := writeCrashReport(filepath.Dir(paths.logFile()), where, v, debug.Stack(), time.Now())
+	if err != nil {
+		path = "not written: " + err.Error()
+	}
+	if restart != nil {
+		uiLog.Errorf("Panic in %s: %v (crash report %s), carrying on", where, v, path)
+		restart()
+		return
+	}
+	uiLog.Errorf("Panic in %s: %v (crash report %s), exiting", where, v, path)
+	exitAfterCrash()
+}
+
+// restartAfter returns a restart for recoverCrash that runs fn again in a
+// new goroutine after crashRestartDelay.
+func restartAfter(fn func()) func() {
+	return func() {
+		go func() {
+			time.Sleep(crashRestartDelay)
+			fn()
+		}()
+	}
+}
+
+// writeCrashReport writes crash-<timestamp>.log in dir with the panic
+// value v and stack, scrubbed of secrets like the log, and syncs it to
+// disk. It returns the file's path.
+func writeCrashReport(dir, where string, v any, stack []byte, now time.Time) (string, error) {
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".log")
+	report := fmt.Sprintf("%s crashed in %s at %s\n%s\npanic: %v\n\n%s",
+		appName, where, now.Format(time.RFC3339), currentBuild().text(), v, stack)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(scrubSecrets(report)); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteCrashReport(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 2, 30, 5, 0, time.UTC)
+	path, err := writeCrashReport(dir, "menu handler", "bad key "+testSessionKey, []byte("goroutine 7 [running]:\nmain.onReady()"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "crash-20261017-023005.log" {
+		t.Errorf("report at %s", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(b)
+	for _, want := range []string{"crashed in menu handler", "panic: bad key sk-ant…", "goroutine 7 [running]:\nmain.onReady()"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, testSessionKey) {
+		t.Error("session key in the crash report")
+	}
+}
+
+func TestRecoverCrash(t *testing.T) {
+	savedPaths, savedExit := paths, exitAfterCrash
+	t.Cleanup(func() { paths, exitAfterCrash = savedPaths, savedExit })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	exited := false
+	exitAfterCrash = func() { exited = true }
+
+	restarted := false
+	func() {
+		defer recoverCrash("update loop", func() { restarted = true })
+		panic("update loop test")
+	}()
+	if !restarted || exited {
+		t.Errorf("restarted %v, exited %v", restarted, exited)
+	}
+
+	func() {
+		defer recoverCrash("menu handler", nil)
+		var m map[string]int
+		m["x"]++
+	}()
+	if !exited {
+		t.Error("didn't exit after a panic without restart")
+	}
+
+	// Both panics may land in one report, within the same second
+	reports, _ := filepath.Glob(filepath.Join(paths.stateDir, "crash-*.log"))
+	var all string
+	for _, r := range reports {
+		b, _ := os.ReadFile(r)
+		all += string(b)
+	}
+	if !strings.Contains(all, "update loop test") || !strings.Contains(all, "assignment to entry in nil map") || !strings.Contains(all, "TestRecoverCrash") {
+		t.Errorf("reports %v:\n%s", reports, all)
+	}
+}
+
+func TestUIPanicWritesCrashReport(t *testing.T) {
+	savedPaths, savedExit := paths, exitAfterCrash
+	t.Cleanup(func() { paths, exitAfterCrash = savedPaths, savedExit })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	exited := make(chan struct{})
+	exitAfterCrash = func() { close(exited) }
+
+	u := newUIUpdater()
+	go u.run(func(prev, st uiState) {})
+	u.do(func() { panic("systray call test") })
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no exit after a panic on the UI goroutine")
+	}
+	u.stop()
+
+	reports, _ := filepath.Glob(filepath.Join(paths.stateDir, "crash-*.log"))
+	if len(reports) != 1 {
+		t.Fatalf("crash reports: %v", reports)
+	}
+	if b, _ := os.ReadFile(reports[0]); !strings.Contains(string(b), "crashed in UI") || !strings.Contains(string(b), "systray call test") {
+		t.Errorf("report:\n%s", b)
+	}
+}
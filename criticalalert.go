@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// criticalRemaining is the remaining percentage below which a bucket
+	// is in the critical band, the icon's red.
+	criticalRemaining = 20
+	// blinkInterval is how long each of the two blinking icons shows.
+	blinkInterval = time.Second
+)
+
+// parseCriticalBlink parses critical_blink: how long the icon blinks once
+// a bucket turns critical. ok is false when it is empty, which turns the
+// critical alert off; "0s" keeps only the badge.
+func parseCriticalBlink(s string) (d time.Duration, ok bool, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false, nil
+	}
+	d, err = time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, false, fmt.Errorf("critical_blink must be a duration such as \"1m\" or \"0s\", got %q", s)
+	}
+	return d, true, nil
+}
+
+// criticalBlink returns critical_blink, and false if the critical alert is
+// off. A nil config, or one with an invalid value, has it off.
+func (c *Config) criticalBlink() (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	d, ok, err := parseCriticalBlink(c.CriticalBlink)
+	return d, ok && err == nil
+}
+
+// anyCritical reports whether a bucket of usage, or one of the per-model
+// buckets shown, is in the critical band.
+func anyCritical(usage *UsageResponse, opus, sonnet *UsageBucket) bool {
+	for _, r := range compactReadings(usage, opus, sonnet) {
+		if r.remaining < criticalRemaining {
+			return true
+		}
+	}
+	return false
+}
+
+// criticalAlertState follows the primary account's buckets in and out of
+// the critical band: entering it starts the blinking, which lasts until a
+// deadline or until the menu is opened.
+type criticalAlertState struct {
+	mu       sync.Mutex
+	critical bool
+	until    time.Time // blinking ends
+	acked    bool      // the menu was opened since it started
+}
+
+var criticalAlert criticalAlertState
+
+// observe records whether a bucket is critical as of now. Entering the
+// band starts blinking for blinkFor.
+func (a *criticalAlertState) observe(critical bool, blinkFor time.Duration, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case critical && !a.critical:
+		uiLog.Infof("Critical alert: a limit is below %d%%, blinking for %s", criticalRemaining, shortDuration(blinkFor))
+		a.until, a.acked = now.Add(blinkFor), false
+	case !critical && a.critical:
+		uiLog.Infof("Critical alert: all limits recovered")
+	}
+	a.critical = critical
+}
+
+// blinking reports whether the icon blinks at now.
+func (a *criticalAlertState) blinking(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.critical && !a.acked && now.Before(a.until)
+}
+
+// acknowledge stops the blinking, reporting whether it was; the badge
+// stays until the bucket recovers.
+func (a *criticalAlertState) acknowledge(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.critical || a.acked || !now.Before(a.until) {
+		return false
+	}
+	a.acked = true
+	return true
+}
+
+// menuOpened acknowledges a blinking critical alert; the platform hooks
+// call it when the tray menu opens. It must not block.
+func menuOpened() {
+	if criticalAlert.acknowledge(time.Now()) {
+		uiLog.Infof("Critical alert acknowledged")
+		ui.refresh()
+	}
+}
+
+// withAlert returns st as shown while a critical alert is on: the usage
+// icon's highlighted variant in the blinking's on phase, or its badged
+// variant once the blinking is over. Other states are left as they are.
+func (st uiState) withAlert(blinking, on bool) uiState {
+	if !st.critical || st.lastUsage == nil {
+		return st
+	}
+	key := *st.lastUsage
+	switch {
+	case !blinking:
+		key.alert = alertBadge
+	case on:
+		key.alert = alertHighlight
+	default:
+		return st
+	}
+	// Both add red, which a template image would lose
+	st.icon, st.iconTemplate = key.icon(), false
+	return st
+}
+
+// blinkTicker asks run to flip the icon every blinkInterval while the
+// critical alert blinks, and once more when it stops so the badge takes
+// over. It returns on quit.
+func (u *uiUpdater) blinkTicker(done chan<- struct{}) {
+	defer close(done)
+	t := time.NewTicker(blinkInterval)
+	defer t.Stop()
+	was := false
+	for {
+		select {
+		case <-u.quit:
+			return
+		case now := <-t.C:
+			blinking := criticalAlert.blinking(now)
+			if blinking || was {
+				select {
+				case u.blinks <- struct{}{}:
+				default:
+				}
+			}
+			was = blinking
+		}
+	}
+}
+
+// iconAlert is how an icon marks a critical alert.
+type iconAlert int8
+
+const (
+	alertNone      iconAlert = iota
+	alertHighlight           // colors inverted, on red where transparent
+	alertBadge               // a red dot in the bottom-right corner
+)
+
+// drawAlert marks img, a usage icon, for a critical alert.
+func drawAlert(img *image.RGBA, a iconAlert) {
+	red := levelColor(0)
+	switch a {
+	case alertHighlight:
+		// RGBA is alpha-premultiplied: A-c inverts a channel
+		p := img.Pix
+		for i := 0; i < len(p); i += 4 {
+			if p[i+3] == 0 {
+				p[i], p[i+1], p[i+2], p[i+3] = red.R, red.G, red.B, red.A
+				continue
+			}
+			p[i], p[i+1], p[i+2] = p[i+3]-p[i], p[i+3]-p[i+1], p[i+3]-p[i+2]
+		}
+	case alertBadge:
+		size := img.Rect.Dx()
+		r := max(3, size/7)
+		cx, cy := size-r-1, size-r-1
+		white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		for y := cy - r; y <= cy+r; y++ {
+			for x := cx - r; x <= cx+r; x++ {
+				d := (x-cx)*(x-cx) + (y-cy)*(y-cy)
+				switch {
+				case d <= (r-1)*(r-1):
+					img.SetRGBA(x, y, red)
+				case d <= r*r:
+					img.SetRGBA(x, y, white)
+				}
+			}
+		}
+	}
+}
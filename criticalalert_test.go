@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseCriticalBlink(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		want   time.Duration
+		ok     bool
+		hasErr bool
+	}{
+		{"", 0, false, false},
+		{"1m", time.Minute, true, false},
+		{"0s", 0, true, false},
+		{"-5s", 0, false, true},
+		{"soon", 0, false, true},
+	} {
+		d, ok, err := parseCriticalBlink(tc.in)
+		if d != tc.want || ok != tc.ok || (err != nil) != tc.hasErr {
+			t.Errorf("%q: got %v, %v, %v", tc.in, d, ok, err)
+		}
+	}
+	if _, ok := (*Config)(nil).criticalBlink(); ok {
+		t.Error("critical alert on without a config")
+	}
+}
+
+func TestCriticalAlertState(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	var a criticalAlertState
+	a.observe(false, time.Minute, now)
+	if a.blinking(now) {
+		t.Fatal("blinking before anything is critical")
+	}
+
+	// Entering the band blinks until the deadline, staying in it doesn't
+	// restart the blinking
+	a.observe(true, time.Minute, now)
+	if !a.blinking(now.Add(30 * time.Second)) {
+		t.Error("not blinking after entering the critical band")
+	}
+	a.observe(true, time.Minute, now.Add(50*time.Second))
+	if a.blinking(now.Add(70 * time.Second)) {
+		t.Error("still blinking after the deadline")
+	}
+
+	// Opening the menu stops it early; a recovery and a new crossing
+	// start it again
+	a.observe(false, time.Minute, now.Add(2*time.Minute))
+	a.observe(true, time.Minute, now.Add(3*time.Minute))
+	if !a.acknowledge(now.Add(3 * time.Minute)) {
+		t.Error("acknowledge missed the blinking")
+	}
+	if a.blinking(now.Add(3 * time.Minute)) {
+		t.Error("still blinking after the menu was opened")
+	}
+	if a.acknowledge(now.Add(3 * time.Minute)) {
+		t.Error("acknowledged twice")
+	}
+}
+
+func TestWithAlert(t *testing.T) {
+	key := iconKey{a: 8, b: 60, c: 60}
+	var st uiState
+	st.setUsageIcon(key, false)
+	if got := st.withAlert(true, true); !bytes.Equal(got.icon, st.icon) {
+		t.Error("icon changed without a critical bucket")
+	}
+
+	st.critical = true
+	plain := st.icon
+	if got := st.withAlert(true, false); !bytes.Equal(got.icon, plain) {
+		t.Error("off phase isn't the plain icon")
+	}
+	on := st.withAlert(true, true)
+	badged := st.withAlert(false, false)
+	if bytes.Equal(on.icon, plain) || bytes.Equal(badged.icon, plain) || bytes.Equal(on.icon, badged.icon) {
+		t.Error("blinking and badge icons aren't all different")
+	}
+	if !bytes.Equal(st.icon, plain) {
+		t.Error("withAlert changed the state it was called on")
+	}
+
+	// An error icon replaces the usage icon, alert and all
+	st.setStatusIcon(statusAPIError)
+	if got := st.withAlert(false, false); !bytes.Equal(got.icon, st.icon) {
+		t.Error("error icon got the badge")
+	}
+}
+
+func TestDrawAlert(t *testing.T) {
+	// Highlighting inverts the colors and fills transparency with red
+	img := iconKey{a: 8, b: 60, c: 60, alert: alertHighlight}.render(iconSize)
+	bg := levelColor(8)
+	if got := img.RGBAAt(4, iconSize-4); got.R != 0xff-bg.R || got.G != 0xff-bg.G || got.B != 0xff-bg.B {
+		t.Errorf("highlighted background is %v, want %v inverted", got, bg)
+	}
+	mono := iconKey{letter: "S", a: 60, palette: paletteWhite, alert: alertHighlight}.render(iconSize)
+	if got := mono.RGBAAt(iconSize/2, 1); got != levelColor(0) {
+		t.Errorf("highlighted mono icon is %v where it was transparent", got)
+	}
+
+	// The badge sits in the bottom-right corner
+	badge := iconKey{a: 8, b: 60, c: 60, alert: alertBadge}.render(iconSize)
+	r := iconSize / 7
+	if got := badge.RGBAAt(iconSize-r-1, iconSize-r-1); got != levelColor(0) {
+		t.Errorf("badge center is %v", got)
+	}
+	if got := badge.RGBAAt(iconSize/4, iconSize/4); got != levelColor(8) {
+		t.Errorf("badge spilled over the icon: %v", got)
+	}
+}
+
+func TestRunBlinks(t *testing.T) {
+	criticalAlert.observe(true, time.Hour, time.Now())
+	t.Cleanup(func() { criticalAlert.observe(false, 0, time.Now()) })
+
+	u := newUIUpdater()
+	applied := make(chan uiState, 8)
+	go u.run(func(prev, st uiState) { applied <- st })
+	defer u.stop()
+
+	var st uiState
+	st.setUsageIcon(iconKey{a: 8, b: 60, c: 60}, false)
+	st.critical = true
+	plain := st.icon
+	u.publish(u.nextGeneration(), st)
+
+	next := func() []byte {
+		t.Helper()
+		select {
+		case st := <-applied:
+			return st.icon
+		case <-time.After(5 * time.Second):
+			t.Fatal("nothing shown")
+		}
+		return nil
+	}
+	if !bytes.Equal(next(), plain) {
+		t.Error("the snapshot isn't shown as it is")
+	}
+	highlighted := next()
+	if bytes.Equal(highlighted, plain) {
+		t.Error("the first blink didn't highlight the icon")
+	}
+	if !bytes.Equal(next(), plain) {
+		t.Error("the second blink didn't bring the icon back")
+	}
+
+	// Opening the menu ends the blinking with the badge
+	criticalAlert.acknowledge(time.Now())
+	u.refresh()
+	if icon := next(); bytes.Equal(icon, plain) || bytes.Equal(icon, highlighted) {
+		t.Error("no badge after the blinking")
+	}
+	if got := u.state().icon; !bytes.Equal(got, plain) {
+		t.Error("the blinking leaked into the state updates start from")
+	}
+}
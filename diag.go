@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// recordClearance remembers when an imported cf_clearance cookie was created
+// and when it expires, for the Diagnostics submenu.
+func recordClearance(row cookieRow) {
+	err := updateState(statePath(), func(st *appState) {
+		st.Clearance = &clearanceInfo{
+			Fingerprint: secretFingerprint(row.Value),
+			Created:     row.Created,
+			Expires:     row.Expiry,
+		}
+	})
+	if err != nil {
+		firefoxLog.Warnf("Failed to save clearance info: %v", err)
+	}
+}
+
+// recordSessionKey remembers when an imported sessionKey cookie expires, so
+// the menu can warn before it does.
+func recordSessionKey(row cookieRow) {
+	if !row.Expiry.IsZero() {
+		firefoxLog.Infof("sessionKey valid for %s (until %s)", shortDuration(row.Expiry.Sub(timeNow())), row.Expiry.Format(time.DateTime))
+	}
+	err := updateState(statePath(), func(st *appState) {
+		st.SessionKey = &clearanceInfo{
+			Fingerprint: secretFingerprint(row.Value),
+			Created:     row.Created,
+			Expires:     row.Expiry,
+		}
+	})
+	if err != nil {
+		firefoxLog.Warnf("Failed to save sessionKey info: %v", err)
+	}
+}
+
+// sessionExpiryWarn is how long before the imported sessionKey expires the
+// menu starts warning about it.
+const sessionExpiryWarn = 3 * 24 * time.Hour
+
+// sessionExpiryWarning renders e.g. "sessionKey expires in 2 days" once the
+// imported sessionKey is within sessionExpiryWarn of expiring, or "" when
+// it isn't, or its expiry is unknown or belongs to another key.
+func sessionExpiryWarning(sessionKey string, st *appState, now time.Time, accessible bool) string {
+	if sessionKey == "" || st == nil || st.SessionKey == nil || st.SessionKey.Expires.IsZero() ||
+		st.SessionKey.Fingerprint != secretFingerprint(sessionKey) {
+		return ""
+	}
+	w := mark(markWarning, accessible)
+	d := st.SessionKey.Expires.Sub(now)
+	switch {
+	case d <= 0:
+		return w + " sessionKey expired, log in and re-import"
+	case d > sessionExpiryWarn:
+		return ""
+	case d >= 24*time.Hour:
+		return w + " sessionKey expires in " + plural(int(d.Hours()/24), "day")
+	case d >= time.Hour:
+		return w + " sessionKey expires in " + plural(int(d.Hours()), "hour")
+	}
+	return w + " sessionKey expires in less than an hour"
+}
+
+// recordCloudflareBlock persists the time of a Cloudflare 403.
+func recordCloudflareBlock(at time.Time) {
+	if err := updateState(statePath(), func(st *appState) { st.LastCloudflareBlock = at }); err != nil {
+		firefoxLog.Warnf("Failed to save Cloudflare block time: %v", err)
+	}
+}
+
+// cloudflareInfo is what the Cloudflare diagnostics go by, for the
+// metrics and the status document. Zero times are unknown.
+type cloudflareInfo struct {
+	clearanceCreated time.Time // of the cf_clearance config.json holds
+	clearanceExpires time.Time
+	lastBlock        time.Time
+}
+
+// cloudflareInfoOf picks the Cloudflare diagnostics out of st; the
+// clearance times only if they belong to cfClearance.
+func cloudflareInfoOf(cfClearance string, st *appState) cloudflareInfo {
+	var ci cloudflareInfo
+	if st == nil {
+		return ci
+	}
+	ci.lastBlock = st.LastCloudflareBlock
+	if cfClearance != "" && st.Clearance != nil && st.Clearance.Fingerprint == secretFingerprint(cfClearance) {
+		ci.clearanceCreated, ci.clearanceExpires = st.Clearance.Created, st.Clearance.Expires
+	}
+	return ci
+}
+
+// status returns ci for the status document as of now, or nil when
+// nothing is known.
+func (ci cloudflareInfo) status(now time.Time) *statusCloudflare {
+	if ci == (cloudflareInfo{}) {
+		return nil
+	}
+	sc := &statusCloudflare{}
+	if !ci.clearanceCreated.IsZero() {
+		age := int64(now.Sub(ci.clearanceCreated).Seconds())
+		sc.ClearanceAgeSeconds = &age
+	}
+	if !ci.clearanceExpires.IsZero() {
+		expires := ci.clearanceExpires.UTC()
+		sc.ClearanceExpiresAt = &expires
+	}
+	if !ci.lastBlock.IsZero() {
+		block := ci.lastBlock.UTC()
+		sc.LastBlockAt = &block
+	}
+	return sc
+}
+
+// cloudflareDiagnostics renders e.g.
+// "cf_clearance age 9h, expires in 15h; last block 2d ago".
+// Clearance metadata is only used while config still holds the same token.
+func cloudflareDiagnostics(cfClearance string, st *appState, now time.Time) string {
+	var clearance string
+	switch {
+	case cfClearance == "":
+		clearance = "no cf_clearance"
+	case st == nil || st.Clearance == nil || st.Clearance.Fingerprint != secretFingerprint(cfClearance):
+		clearance = "cf_clearance age unknown"
+	default:
+		ci := st.Clearance
+		clearance = "cf_clearance"
+		if !ci.Created.IsZero() {
+			clearance += " age " + shortDuration(now.Sub(ci.Created))
+		}
+		if !ci.Expires.IsZero() {
+			if !ci.Created.IsZero() {
+				clearance += ","
+			}
+			if d := ci.Expires.Sub(now); d > 0 {
+				clearance += " expires in " + shortDuration(d)
+			} else {
+				clearance += " expired"
+			}
+		}
+	}
+
+	block := "no block recorded"
+	if st != nil && !st.LastCloudflareBlock.IsZero() {
+		block = "last block " + shortDuration(now.Sub(st.LastCloudflareBlock)) + " ago"
+	}
+	return clearance + "; " + block
+}
+
+// shortDuration renders d with its largest unit only: "45s", "12m", "9h", "2d".
+func shortDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
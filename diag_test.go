@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionExpiryWarning(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	st := func(expires time.Time) *appState {
+		return &appState{SessionKey: &clearanceInfo{Fingerprint: secretFingerprint("sk-ant-sid01-key"), Expires: expires}}
+	}
+	for _, tc := range []struct {
+		key  string
+		st   *appState
+		want string
+	}{
+		{"sk-ant-sid01-key", st(now.Add(30 * 24 * time.Hour)), ""},
+		{"sk-ant-sid01-key", st(now.Add(50 * time.Hour)), "⚠ sessionKey expires in 2 days"},
+		{"sk-ant-sid01-key", st(now.Add(5*time.Hour + 30*time.Minute)), "⚠ sessionKey expires in 5 hours"},
+		{"sk-ant-sid01-key", st(now.Add(10 * time.Minute)), "⚠ sessionKey expires in less than an hour"},
+		{"sk-ant-sid01-key", st(now.Add(-time.Minute)), "⚠ sessionKey expired, log in and re-import"},
+		// Metadata of a key config.json no longer holds says nothing
+		{"sk-ant-sid01-other", st(now.Add(time.Hour)), ""},
+		{"sk-ant-sid01-key", st(time.Time{}), ""},
+		{"sk-ant-sid01-key", nil, ""},
+	} {
+		if got := sessionExpiryWarning(tc.key, tc.st, now, false); got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestCloudflareInfoOf(t *testing.T) {
+	created := time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+	block := created.Add(-time.Hour)
+	st := &appState{
+		LastCloudflareBlock: block,
+		Clearance:           &clearanceInfo{Fingerprint: secretFingerprint("cf-token"), Created: created},
+	}
+	if got := cloudflareInfoOf("cf-token", st); got.clearanceCreated != created || got.lastBlock != block {
+		t.Errorf("same token: %+v", got)
+	}
+	// Times of a token config.json no longer holds say nothing about it
+	if got := cloudflareInfoOf("cf-other", st); !got.clearanceCreated.IsZero() || got.lastBlock != block {
+		t.Errorf("other token: %+v", got)
+	}
+	if got := cloudflareInfoOf("cf-token", nil); got != (cloudflareInfo{}) {
+		t.Errorf("no state: %+v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// diagLogTail is how much of the end of the log a diagnostics bundle
+// carries.
+const diagLogTail = 256 << 10
+
+// diagBundlePath names a bundle saved at now.
+func diagBundlePath(now time.Time) string {
+	return filepath.Join(paths.stateDir, "claude-monitor-diagnostics-"+now.Format("20060102-150405")+".zip")
+}
+
+// writeDiagBundle writes a zip for a bug report to path: the config, state
+// and the end of the log with credentials masked, the complete bodies of
+// the last failed API responses, and component health. Files that can't be
+// read are noted in the bundle instead.
+func writeDiagBundle(path string, now time.Time) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+	zw := zip.NewWriter(f)
+	add := func(name, content string) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, content)
+		return err
+	}
+	readRedacted := func(path string, tail int64) string {
+		data, err := readTail(path, tail)
+		if err != nil {
+			return fmt.Sprintf("(%v)\n", err)
+		}
+		return scrubSecrets(string(data))
+	}
+
+	about := currentBuild().text() +
+		"Saved:    " + now.Format(time.RFC3339) + "\n" +
+		"Config:   " + configPath + "\n" +
+		"Log:      " + paths.logFile() + "\n"
+	var responses strings.Builder
+	for i, fr := range failedResponses.all() {
+		if i > 0 {
+			responses.WriteString("\n" + strings.Repeat("-", 72) + "\n\n")
+		}
+		responses.WriteString(fr.String())
+	}
+	if responses.Len() == 0 {
+		responses.WriteString("No failed API response recorded since startup\n")
+	}
+	var components strings.Builder
+	for _, name := range health.components() {
+		st, _ := health.get(name)
+		fmt.Fprintf(&components, "%s: ok=%v %s (%s)\n", name, st.OK, st.Detail, st.At.Format(time.RFC3339))
+	}
+	components.WriteString("Cloudflare: " + ui.state().cloudflare + "\n")
+
+	for _, file := range []struct{ name, content string }{
+		{"about.txt", about},
+		{"config.json", readRedacted(configPath, 0)},
+		{"state.json", readRedacted(statePath(), 0)},
+		{"claude-monitor.log", readRedacted(paths.logFile(), diagLogTail)},
+		{"failed-responses.txt", responses.String()},
+		{"health.txt", components.String()},
+	} {
+		if err := add(file.name, file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// readTail reads path, or only its last n bytes when n > 0, starting at a
+// line boundary.
+func readTail(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 || fi.Size() <= n {
+		return io.ReadAll(f)
+	}
+	if _, err := f.Seek(fi.Size()-n, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	return data, err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiagBundle(t *testing.T) {
+	savedPaths, savedConfig := paths, configPath
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	configPath = paths.configFile()
+	defer func() { paths, configPath = savedPaths, savedConfig }()
+
+	key := "sk-ant-sid01-" + strings.Repeat("k", 40)
+	os.WriteFile(configPath, []byte(`{"session_key": "`+key+`", "org_id": "org-1"}`), 0600)
+	os.WriteFile(paths.logFile(), []byte("first line\nAuth with "+key+"\n"), 0644)
+	failedResponses.add(failedResponse{Time: time.Now(), URL: "https://claude.ai/api/x", Status: 403, Body: "<html>Just a moment...</html>"})
+
+	path := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := writeDiagBundle(path, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	for _, name := range []string{"about.txt", "config.json", "state.json", "claude-monitor.log", "failed-responses.txt", "health.txt"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle has no %s", name)
+		}
+	}
+	for name, content := range files {
+		if strings.Contains(content, strings.Repeat("k", 40)) {
+			t.Errorf("%s leaks the session key", name)
+		}
+	}
+	if !strings.Contains(files["config.json"], "org-1") || !strings.Contains(files["claude-monitor.log"], "first line") {
+		t.Error("config or log missing from the bundle")
+	}
+	if !strings.Contains(files["failed-responses.txt"], "Just a moment...") {
+		t.Errorf("failed-responses.txt = %q", files["failed-responses.txt"])
+	}
+	if !strings.Contains(files["health.txt"], "Cloudflare: ") {
+		t.Errorf("health.txt = %q", files["health.txt"])
+	}
+}
+
+func TestReadTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644)
+	if b, _ := readTail(path, 7); string(b) != "three\n" {
+		t.Errorf("tail = %q, want the last whole line", b)
+	}
+	if b, _ := readTail(path, 0); string(b) != "one\ntwo\nthree\n" {
+		t.Errorf("whole file = %q", b)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// doctorTimeout bounds each network step of doctor.
+const doctorTimeout = 20 * time.Second
+
+// orgIDPattern is the shape of an organization ID: a UUID.
+var orgIDPattern = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// doctor prints one line per step of cmdDoctor and remembers the first
+// that failed.
+type doctor struct {
+	out         io.Writer
+	step        int
+	firstFailed int // 0 while every step passed
+}
+
+// pass reports a step that succeeded.
+func (d *doctor) pass(name, detail string) {
+	d.step++
+	fmt.Fprintf(d.out, "%s %-18s %s\n", mark(markOK, false), name, detail)
+}
+
+// fail reports a step that failed, with what to do about it.
+func (d *doctor) fail(name, detail, hint string) {
+	d.step++
+	if d.firstFailed == 0 {
+		d.firstFailed = d.step
+	}
+	fmt.Fprintf(d.out, "%s %-18s %s\n", mark(markFailed, false), name, detail)
+	if hint != "" {
+		fmt.Fprintf(d.out, "  %-18s → %s\n", "", hint)
+	}
+}
+
+// skip reports a step that couldn't run or doesn't apply.
+func (d *doctor) skip(name, why string) {
+	d.step++
+	fmt.Fprintf(d.out, "- %-18s skipped: %s\n", name, why)
+}
+
+// cmdDoctor checks the setup step by step, from config.json to an
+// authenticated usage request, and prints a verdict with a hint for each.
+// The exit code is the number of the first step that failed, 0 if none.
+func cmdDoctor(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(out)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	log.SetOutput(io.Discard) // the steps report for themselves
+	d := &doctor{out: out}
+
+	// 1. config.json
+	var acc *Config
+	if cfg, err := loadConfigNoOrg(configPath); err != nil {
+		hint := "fix the error, or delete the file and start the app to create a template"
+		if errors.Is(err, os.ErrNotExist) {
+			hint = "start the app once to create it, or run: claude-monitor import-firefox"
+		}
+		d.fail("Config file", err.Error(), hint)
+	} else {
+		acc = cfg.accountConfigs()[cfg.primaryAccount()]
+		d.pass("Config file", configPath)
+	}
+
+	// 2. sessionKey
+	switch {
+	case acc == nil:
+		d.skip("sessionKey", "no usable config")
+	case !strings.HasPrefix(acc.SessionKey, sessionKeyPrefix):
+		d.fail("sessionKey", fmt.Sprintf("%s doesn't start with %s", maskSecret(acc.SessionKey), sessionKeyPrefix),
+			"copy the sessionKey cookie of claude.ai again, or use Import from Firefox")
+	default:
+		d.pass("sessionKey", maskSecret(acc.SessionKey))
+	}
+
+	// 3. org_id
+	switch {
+	case acc == nil:
+		d.skip("org_id", "no usable config")
+	case acc.OrgID == "":
+		d.pass("org_id", "not set; looked up from the API")
+	case !orgIDPattern.MatchString(acc.OrgID):
+		d.fail("org_id", fmt.Sprintf("%q is not a UUID", acc.OrgID),
+			"copy the lastActiveOrg cookie, or remove org_id to have it looked up")
+	default:
+		d.pass("org_id", shortID(acc.OrgID))
+	}
+
+	// 4, 5. The browser the cookies are imported from
+	d.checkBrowser(acc)
+
+	// 6, 7, 8. The API
+	if acc == nil {
+		d.skip("DNS", "no usable config")
+		d.skip("Connection", "no usable config")
+		d.skip("Usage request", "no usable config")
+		return d.firstFailed
+	}
+	u, err := url.Parse(acc.apiURL(""))
+	if err != nil {
+		d.fail("DNS", err.Error(), "check api_base_url")
+		return d.firstFailed
+	}
+	d.checkConnection(acc, u)
+	d.checkFetch(acc)
+	return d.firstFailed
+}
+
+// checkBrowser finds the browser profile and reads the claude.ai cookies
+// from it, comparing the sessionKey with config.json's.
+func (d *doctor) checkBrowser(acc *Config) {
+	var read func() (sk string, err error)
+	if acc != nil && acc.ChromeBrowser != "" {
+		b, err := chromeBrowserNamed(acc.ChromeBrowser)
+		switch {
+		case err != nil:
+			d.fail("Browser profile", err.Error(), "set chrome_browser to chrome, chromium, edge, brave, vivaldi or opera")
+		case !b.installed():
+			d.fail("Browser profile", b.name+" not found", "install it, log in to claude.ai, or clear chrome_browser")
+		default:
+			d.pass("Browser profile", b.name)
+			read = func() (string, error) {
+				sk, _, _, err := chromeCookiesFrom(b)
+				return sk, err
+			}
+		}
+	} else {
+		dir, err := firefoxProfileDir()
+		if err != nil {
+			d.fail("Firefox profile", err.Error(), "set firefox_dir (or firefox_profile) if Firefox keeps its profiles elsewhere")
+		} else {
+			d.pass("Firefox profile", dir)
+			read = func() (string, error) {
+				report, err := importFirefoxProfile(dir)
+				if err != nil {
+					return "", err
+				}
+				sk, _, _, err := report.credentials()
+				return sk, err
+			}
+		}
+	}
+	if read == nil {
+		d.skip("Browser cookies", "no browser profile")
+		return
+	}
+	sk, err := read()
+	switch {
+	case err != nil:
+		d.fail("Browser cookies", err.Error(), "close the browser if it locks its cookies, and log in to claude.ai")
+	case acc != nil && sk != acc.SessionKey:
+		d.pass("Browser cookies", "sessionKey differs from config.json; importing again updates it")
+	default:
+		d.pass("Browser cookies", "sessionKey found")
+	}
+}
+
+// checkConnection resolves the API host and connects to it, with TLS for
+// https. Behind a proxy the request itself is the test.
+func (d *doctor) checkConnection(acc *Config, u *url.URL) {
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	if acc.ProxyURL != "" {
+		d.skip("DNS", "proxy_url is set; the proxy resolves "+host)
+		d.skip("Connection", "proxy_url is set")
+		return
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		d.fail("DNS", err.Error(), "check the network connection and DNS settings")
+		d.skip("Connection", "no address for "+host)
+		return
+	}
+	d.pass("DNS", host+" → "+strings.Join(addrs, ", "))
+
+	dial := dialContextFor(acc.ForceIPv4, net.DefaultResolver)
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		hint := "a firewall may block it; set proxy_url if you need a proxy"
+		if !acc.ForceIPv4 {
+			hint += ", or force_ipv4 if IPv6 is broken"
+		}
+		d.fail("Connection", err.Error(), hint)
+		return
+	}
+	defer conn.Close()
+	if u.Scheme != "https" {
+		d.pass("Connection", "TCP to "+conn.RemoteAddr().String())
+		return
+	}
+	tlsConfig, err := newTLSConfig(netSettingsOf(acc))
+	if err != nil {
+		d.fail("Connection", err.Error(), "check ca_cert_file")
+		return
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ServerName = host
+	tc := tls.Client(conn, tlsConfig)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		d.fail("Connection", "TLS: "+err.Error(), "if your network re-signs TLS, set ca_cert_file to its root CA")
+		return
+	}
+	d.pass("Connection", "TLS to "+conn.RemoteAddr().String())
+}
+
+// checkFetch makes one authenticated usage request, without retries, and
+// tells the kinds of failure apart.
+func (d *doctor) checkFetch(acc *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	cfg := *acc
+	if cfg.OrgID == "" {
+		orgs, err := fetchOrganizations(ctx, &cfg)
+		if err != nil {
+			d.failFetch("listing organizations", err)
+			return
+		}
+		org, ok := pickOrganization(orgs)
+		if !ok {
+			d.fail("Usage request", "the session has no organizations", "log in to claude.ai with the account you use")
+			return
+		}
+		cfg.OrgID = org.UUID
+	}
+	usage, err := doFetch(ctx, &cfg)
+	if err != nil {
+		d.failFetch("", err)
+		return
+	}
+	d.pass("Usage request", fmt.Sprintf("session %d%%, weekly %d%%", int(usage.FiveHour.Utilization), int(usage.SevenDay.Utilization)))
+}
+
+func (d *doctor) failFetch(what string, err error) {
+	detail := err.Error()
+	if what != "" {
+		detail = what + ": " + detail
+	}
+	var herr *ErrHTTP
+	var nerr *ErrNetConfig
+	switch {
+	case isCloudflare(err):
+		d.fail("Usage request", "blocked by Cloudflare (403)",
+			"open claude.ai in the browser, then import the cookies again to refresh cf_clearance")
+	case errors.As(err, &nerr):
+		d.fail("Usage request", detail, "fix proxy_url or ca_cert_file")
+	case errors.As(err, &herr) && herr.StatusCode == http.StatusUnauthorized:
+		d.fail("Usage request", "sessionKey rejected (401)", "log in to claude.ai again, then import the cookies")
+	case errors.As(err, &herr) && (herr.StatusCode == http.StatusForbidden || herr.StatusCode == http.StatusNotFound):
+		d.fail("Usage request", fmt.Sprintf("organization not accessible (%d)", herr.StatusCode),
+			"remove org_id from config.json to have it looked up again")
+	case errors.As(err, &herr) && herr.StatusCode == http.StatusTooManyRequests:
+		d.fail("Usage request", "rate limited (429)", "wait a few minutes before trying again")
+	case errors.As(err, &herr) && herr.StatusCode >= 500:
+		d.fail("Usage request", fmt.Sprintf("claude.ai failed (%d)", herr.StatusCode), "a problem on claude.ai's side; try again later")
+	case isNetworkError(err):
+		d.fail("Usage request", detail, "check the network connection")
+	default:
+		d.fail("Usage request", detail, "")
+	}
+}
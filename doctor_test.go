@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdDoctor(t *testing.T) {
+	savedConfig, savedPaths := configPath, paths
+	t.Cleanup(func() { configPath, paths = savedConfig, savedPaths })
+	savedLog := log.Writer()
+	t.Cleanup(func() { log.SetOutput(savedLog) })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, `{"five_hour": {"utilization": 38}, "seven_day": {"utilization": 12}}`)
+	}))
+	t.Cleanup(srv.Close)
+	profile, err := filepath.Abs(filepath.Join("testdata", "firefox", "basic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath = writeTestConfig(t, fmt.Sprintf(`{"session_key": "sk-ant-sid01-x", "org_id": "0b4c2f1e-7d3a-4e5b-9c8d-1a2b3c4d5e6f",
+		"api_base_url": %q, "firefox_profile": %q}`, srv.URL, profile))
+
+	var out bytes.Buffer
+	if code := cmdDoctor(nil, &out); code != 0 {
+		t.Fatalf("exit %d:\n%s", code, out.String())
+	}
+	for _, want := range []string{"Config file", "sessionKey", "org_id", "Firefox profile", "Browser cookies", "DNS", "Connection", "Usage request"} {
+		if !strings.Contains(out.String(), "✓ "+want) {
+			t.Errorf("no passing %s step in:\n%s", want, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "session 38%, weekly 12%") {
+		t.Errorf("no usage in:\n%s", out.String())
+	}
+
+	status = http.StatusUnauthorized
+	out.Reset()
+	if code := cmdDoctor(nil, &out); code != 8 {
+		t.Errorf("401: exit %d, want 8:\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "sessionKey rejected (401)") {
+		t.Errorf("401 not explained:\n%s", out.String())
+	}
+
+	configPath = writeTestConfig(t, fmt.Sprintf(`{"session_key": "x", "org_id": "not-a-uuid", "api_base_url": %q}`, srv.URL))
+	out.Reset()
+	if code := cmdDoctor(nil, &out); code != 2 {
+		t.Errorf("bad sessionKey: exit %d, want 2:\n%s", code, out.String())
+	}
+}
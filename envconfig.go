@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables that override config.json, for containers and
+// other deployments where secrets are injected rather than saved.
+const (
+	envSessionKey  = "CLAUDE_SESSION_KEY"
+	envOrgID       = "CLAUDE_ORG_ID"
+	envCfClearance = "CLAUDE_CF_CLEARANCE"
+	// envConfigPath points at a config.json other than the default one.
+	envConfigPath = "CLAUDE_MONITOR_CONFIG"
+)
+
+// envCredential is a credential field of config.json with the variable
+// that overrides it.
+type envCredential struct {
+	env   string
+	field func(*Config) *string
+}
+
+var envCredentials = []envCredential{
+	{envSessionKey, func(c *Config) *string { return &c.SessionKey }},
+	{envOrgID, func(c *Config) *string { return &c.OrgID }},
+	{envCfClearance, func(c *Config) *string { return &c.CfClearance }},
+}
+
+// envValue returns the trimmed value of a variable; empty counts as unset.
+func envValue(name string) string {
+	return strings.TrimSpace(os.Getenv(name))
+}
+
+// applyEnvCredentials puts the credentials set in the environment over
+// the flat fields of cfg. Entries of accounts have no overrides.
+func applyEnvCredentials(cfg *Config) {
+	for _, e := range envCredentials {
+		if v := envValue(e.env); v != "" {
+			*e.field(cfg) = v
+		}
+	}
+}
+
+// keepEnvCredentials undoes, in cfg about to be saved, credentials that
+// came from the environment rather than from before, the file as read, so
+// that they are never written to disk.
+func keepEnvCredentials(before, cfg *Config) {
+	for _, e := range envCredentials {
+		v := envValue(e.env)
+		if v == "" {
+			continue
+		}
+		if p := e.field(cfg); strings.TrimSpace(*p) == v && strings.TrimSpace(*e.field(before)) != v {
+			*p = *e.field(before)
+		}
+	}
+}
+
+// envOverrides lists the variables in effect, for the log.
+func envOverrides() []string {
+	var set []string
+	for _, e := range envCredentials {
+		if envValue(e.env) != "" {
+			set = append(set, e.env)
+		}
+	}
+	return set
+}
+
+// configPathFromEnv returns CLAUDE_MONITOR_CONFIG, or def if it is unset.
+func configPathFromEnv(def string) string {
+	if p := envValue(envConfigPath); p != "" {
+		return p
+	}
+	return def
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	path := writeTestConfig(t, `{"session_key": "sk-file", "org_id": "org-file", "cf_clearance": "cf-file"}`)
+
+	// Unset or empty variables leave the file's values
+	t.Setenv(envSessionKey, "")
+	if cfg, err := loadConfig(path); err != nil || cfg.SessionKey != "sk-file" {
+		t.Fatalf("without env: %+v, %v", cfg, err)
+	}
+
+	// Set ones win over the file
+	t.Setenv(envSessionKey, " sk-env ")
+	t.Setenv(envOrgID, "org-env")
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SessionKey != "sk-env" || cfg.OrgID != "org-env" || cfg.CfClearance != "cf-file" {
+		t.Errorf("env over file: %q %q %q", cfg.SessionKey, cfg.OrgID, cfg.CfClearance)
+	}
+
+	// Saving never writes them: an import keeps its own values, a change
+	// that passes on the environment's keeps the file's
+	if err := saveFirefoxConfig(path, "sk-browser", "", "cf-browser"); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateConfig(path, func(c *Config) { c.OrgID = cfg.OrgID }); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "-env") || !strings.Contains(string(data), "sk-browser") || !strings.Contains(string(data), "org-file") {
+		t.Errorf("saved:\n%s", data)
+	}
+
+	// With the session key in the environment the file is optional
+	missing := filepath.Join(t.TempDir(), "config.json")
+	if cfg, err := loadConfig(missing); err != nil || cfg.SessionKey != "sk-env" || cfg.OrgID != "org-env" {
+		t.Errorf("no file: %+v, %v", cfg, err)
+	}
+	t.Setenv(envSessionKey, "")
+	if _, err := loadConfig(missing); err == nil {
+		t.Error("no file and no session key accepted")
+	}
+}
+
+func TestConfigPathFromEnv(t *testing.T) {
+	t.Setenv(envConfigPath, "")
+	if got := configPathFromEnv("/default/config.json"); got != "/default/config.json" {
+		t.Errorf("unset: %q", got)
+	}
+	t.Setenv(envConfigPath, "/etc/claude/config.json")
+	if got := configPathFromEnv("/default/config.json"); got != "/etc/claude/config.json" {
+		t.Errorf("set: %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// hookEventAliases are names the hooks section takes besides the event
+// names themselves.
+var hookEventAliases = map[string]string{"update_recovered": eventRecovered}
+
+// validateHooks checks the hooks section of config.json.
+func validateHooks(hooks map[string]string) error {
+	for name, command := range hooks {
+		if _, ok := hookEventAliases[name]; !ok && !validAlertEvent(name) {
+			return fmt.Errorf("hooks: unknown event %q", name)
+		}
+		if strings.TrimSpace(command) == "" {
+			return fmt.Errorf("hooks: %s has no command", name)
+		}
+	}
+	return nil
+}
+
+// commandHooks runs the command the hooks section maps an event to.
+type commandHooks map[string]string
+
+func (commandHooks) name() string { return "Hooks" }
+
+func (h commandHooks) wants(ev alertEvent) bool { return h.command(ev.Event) != "" }
+
+// command is the command for event, under its name or an alias.
+func (h commandHooks) command(event string) string {
+	if c := h[event]; c != "" {
+		return c
+	}
+	for alias, e := range hookEventAliases {
+		if e == event {
+			return h[alias]
+		}
+	}
+	return ""
+}
+
+// deliver runs ev's command through the shell with ev as JSON on stdin
+// and in CLAUDE_* variables, for at most hookTimeout, and logs its exit
+// code and stderr.
+func (h commandHooks) deliver(ev alertEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	stderr, err := runShell(h.command(ev.Event), payload, eventEnv(ev))
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		return fmt.Errorf("%s: %w", ev.Event, err)
+	}
+	if stderr != "" {
+		uiLog.Infof("Hook %s: exit %d, stderr: %s", ev.Event, code, truncate(stderr, 200))
+	} else {
+		uiLog.Infof("Hook %s: exit %d", ev.Event, code)
+	}
+	if code != 0 {
+		return fmt.Errorf("%s exited with %d", ev.Event, code)
+	}
+	return nil
+}
+
+// eventEnv exposes ev to a hook's command. Variables ev has no value for
+// are left unset.
+func eventEnv(ev alertEvent) []string {
+	env := []string{"CLAUDE_EVENT=" + ev.Event}
+	if ev.Bucket != "" {
+		env = append(env, "CLAUDE_BUCKET="+ev.Bucket)
+	}
+	if ev.Utilization != nil {
+		env = append(env, fmt.Sprintf("CLAUDE_UTILIZATION=%g", *ev.Utilization))
+	}
+	if ev.ResetsAt != "" {
+		env = append(env, "CLAUDE_RESETS_AT="+ev.ResetsAt)
+	}
+	if ev.Error != "" {
+		env = append(env, "CLAUDE_ERROR="+ev.Error)
+	}
+	return env
+}
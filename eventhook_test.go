@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandHookDeliver(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	ev := bucketEvent(eventThresholdCrossed, "session", &UsageBucket{Utilization: 82.5, ResetsAt: "2026-10-17T15:00:00Z"}, now)
+	ev.Threshold = 80
+	h := commandHooks{eventThresholdCrossed: fakeHook(dir, shellLine("exit 0", "exit 0"))}
+	if err := h.deliver(ev); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "stdin.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got alertEvent
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("stdin is not the event JSON: %v\n%s", err, b)
+	}
+	if got.Event != eventThresholdCrossed || got.Bucket != "session" || got.Threshold != 80 {
+		t.Errorf("stdin event = %s", b)
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := strings.ReplaceAll(string(b), "\r\n", "\n")
+	for _, want := range []string{
+		"CLAUDE_EVENT=threshold_crossed\n",
+		"CLAUDE_BUCKET=session\n",
+		"CLAUDE_UTILIZATION=82.5\n",
+		"CLAUDE_RESETS_AT=2026-10-17T15:00:00Z\n",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("environment lacks %q:\n%s", want, env)
+		}
+	}
+	if strings.Contains(env, "CLAUDE_ERROR") {
+		t.Errorf("empty error set in the environment:\n%s", env)
+	}
+}
+
+func TestCommandHookFailure(t *testing.T) {
+	h := commandHooks{eventAuthError: shellLine("echo bad key 1>&2 & exit 2", "echo bad key >&2; exit 2")}
+	err := h.deliver(alertEvent{Event: eventAuthError, Error: "HTTP 401"})
+	if err == nil || !strings.Contains(err.Error(), "exited with 2") {
+		t.Errorf("deliver error = %v, want exit 2", err)
+	}
+}
+
+func TestCommandHookAliases(t *testing.T) {
+	h := commandHooks{"update_recovered": "notify-send ok", eventLimitReset: "true"}
+	for _, tc := range []struct {
+		event string
+		want  bool
+	}{
+		{eventRecovered, true},
+		{eventLimitReset, true},
+		{eventAuthError, false},
+	} {
+		if got := h.wants(alertEvent{Event: tc.event}); got != tc.want {
+			t.Errorf("wants(%s) = %v", tc.event, got)
+		}
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	for _, tc := range []struct {
+		hooks map[string]string
+		ok    bool
+	}{
+		{nil, true},
+		{map[string]string{"threshold_crossed": "notify-send hi", "update_recovered": "true", "update_failed": "true"}, true},
+		{map[string]string{"threshold": "true"}, false},
+		{map[string]string{"auth_error": "  "}, false},
+	} {
+		if err := validateHooks(tc.hooks); (err == nil) != tc.ok {
+			t.Errorf("%v: %v", tc.hooks, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// Events the integrations hear about, named as in their config.
+const (
+	eventThresholdCrossed = "threshold_crossed"
+	eventLimitReset       = "limit_reset"
+	eventAuthError        = "auth_error"
+	eventUpdateFailed     = "update_failed"
+	eventRecovered        = "recovered"
+)
+
+// alertEventNames lists the events in the order the README gives them.
+var alertEventNames = []string{eventThresholdCrossed, eventLimitReset, eventAuthError, eventUpdateFailed, eventRecovered}
+
+func validAlertEvent(name string) bool {
+	for _, e := range alertEventNames {
+		if name == e {
+			return true
+		}
+	}
+	return false
+}
+
+// alertEvent is something an update found, as the integrations get it:
+// webhooks post it as JSON.
+type alertEvent struct {
+	Event string `json:"event"`
+	// Bucket is "session" or "weekly"; empty for the update's own events.
+	Bucket      string   `json:"bucket,omitempty"`
+	Utilization *float64 `json:"utilization,omitempty"`
+	// Threshold is the notify_*_at percentage a threshold_crossed passed.
+	Threshold float64 `json:"threshold,omitempty"`
+	ResetsAt  string  `json:"resets_at,omitempty"`
+	// Error is the kind of the failure an auth_error or update_failed is
+	// about, or that a recovered ended, e.g. "HTTP 401".
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// bucketEvent is an event about one of usage's buckets.
+func bucketEvent(event, bucket string, b *UsageBucket, now time.Time) alertEvent {
+	u := b.Utilization
+	return alertEvent{Event: event, Bucket: bucket, Utilization: &u, ResetsAt: b.ResetsAt, Timestamp: now}
+}
+
+// outcomeEvents returns the events an update's outcome raises, given the
+// kind of the previous update's failure ("" if it succeeded):
+// update_failed when updates start failing, auth_error when the session
+// starts being rejected, recovered when an update succeeds after a
+// failure.
+func outcomeEvents(prevKind string, err error, now time.Time) []alertEvent {
+	if err == nil {
+		if prevKind == "" {
+			return nil
+		}
+		return []alertEvent{{Event: eventRecovered, Error: prevKind, Timestamp: now}}
+	}
+	var events []alertEvent
+	kind := errorKind(err)
+	if prevKind == "" {
+		events = append(events, alertEvent{Event: eventUpdateFailed, Error: kind, Timestamp: now})
+	}
+	if isAuthError(err) && kind != prevKind {
+		events = append(events, alertEvent{Event: eventAuthError, Error: kind, Timestamp: now})
+	}
+	return events
+}
+
+// isAuthError reports whether the API rejected the session: a 401, or a
+// 403 that isn't a Cloudflare challenge.
+func isAuthError(err error) bool {
+	var herr *ErrHTTP
+	return errors.As(err, &herr) && (herr.StatusCode == 401 || herr.StatusCode == 403)
+}
+
+// eventSink is a channel events are delivered to.
+type eventSink interface {
+	// name identifies the sink in the log, without credentials.
+	name() string
+	// wants reports whether the sink takes ev.
+	wants(ev alertEvent) bool
+	// deliver sends ev, retries included.
+	deliver(ev alertEvent) error
+}
+
+// eventSinks returns the sinks cfg configures. A nil config has none.
+func eventSinks(cfg *Config) []eventSink {
+	if cfg == nil {
+		return nil
+	}
+	var sinks []eventSink
+	for _, w := range cfg.Webhooks {
+		sinks = append(sinks, w)
+	}
+	if t, ok := cfg.telegram(); ok {
+		sinks = append(sinks, t)
+	}
+	if p, ok := cfg.push(); ok {
+		sinks = append(sinks, p)
+	}
+	if len(cfg.Hooks) > 0 {
+		sinks = append(sinks, commandHooks(cfg.Hooks))
+	}
+	return sinks
+}
+
+// dispatchEvents delivers events to cfg's sinks in the background, each
+// sink in a goroutine of its own so a slow or broken one can't hold up the
+// others, nor the update that raised them.
+func dispatchEvents(cfg *Config, events []alertEvent) {
+	if len(events) == 0 {
+		return
+	}
+	for _, s := range eventSinks(cfg) {
+		go func(s eventSink) {
+			for _, ev := range events {
+				if !s.wants(ev) {
+					continue
+				}
+				if err := s.deliver(ev); err != nil {
+					uiLog.Warnf("%s: %s not delivered: %v", s.name(), ev.Event, err)
+					health.report(s.name(), false, err.Error())
+					continue
+				}
+				health.report(s.name(), true, ev.Event+" delivered")
+			}
+		}(s)
+	}
+}
+
+// redactURL drops what may carry credentials from a URL for the log: the
+// user info, the query and the fragment.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return u.String()
+}
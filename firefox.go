@@ -3,138 +3,702 @@ package main
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// findFirefoxCookies searches the default Firefox profile for claude.ai cookies.
-// Returns sessionKey, lastActiveOrg, and cf_clearance if found.
-func findFirefoxCookies() (sessionKey, orgID, cfClearance string, err error) {
-	profilesDir, err := findFirefoxProfilesDir()
+// findFirefoxCookies searches the Firefox profile to import from for
+// claude.ai cookies: firefox_profile from config.json if set, else the
+// default profile. Returns the browser's name (Firefox or a fork such as
+// LibreWolf) and sessionKey, lastActiveOrg, and cf_clearance if found.
+func findFirefoxCookies() (browser, sessionKey, orgID, cfClearance string, err error) {
+	profileDir, err := firefoxProfileDir()
 	if err != nil {
-		return "", "", "", fmt.Errorf("finding Firefox profiles: %w", err)
+		return "", "", "", "", err
+	}
+	return firefoxCookiesFrom(profileDir)
+}
+
+// firefoxProfileDir returns the profile directory findFirefoxCookies reads.
+// A firefox_profile that no longer exists is an error rather than a reason
+// to fall back: the default profile may well be logged into another account.
+func firefoxProfileDir() (string, error) {
+	if cfg, err := readConfigFile(configPath); err == nil && strings.TrimSpace(cfg.FirefoxProfile) != "" {
+		dir := strings.TrimSpace(cfg.FirefoxProfile)
+		if _, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("firefox_profile: %w", err)
+		}
+		firefoxLog.Infof("Firefox profile (firefox_profile): %s", dir)
+		return dir, nil
 	}
 
+	profilesDir, err := findFirefoxProfilesDir()
+	if err != nil {
+		return "", fmt.Errorf("finding Firefox profiles: %w", err)
+	}
 	profileDir, err := findDefaultProfile(profilesDir)
 	if err != nil {
-		return "", "", "", fmt.Errorf("finding default Firefox profile: %w", err)
+		return "", fmt.Errorf("finding default Firefox profile: %w", err)
+	}
+	firefoxLog.Infof("Firefox profile: %s", profileDir)
+	return profileDir, nil
+}
+
+// firefoxCookiesFrom reads the credentials from one profile directory.
+func firefoxCookiesFrom(profileDir string) (browser, sessionKey, orgID, cfClearance string, err error) {
+	report, err := importFirefoxProfile(profileDir)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	if sessionKey, orgID, cfClearance, err = report.credentials(); err != nil {
+		return "", "", "", "", err
+	}
+	report.recordImported()
+	return report.Browser, sessionKey, orgID, cfClearance, nil
+}
+
+// firefoxProfileNamed returns the directory of the profile called name in
+// profiles.ini, ignoring case.
+func firefoxProfileNamed(name string) (string, error) {
+	firefoxDir, err := findFirefoxProfilesDir()
+	if err != nil {
+		return "", fmt.Errorf("finding Firefox profiles: %w", err)
 	}
+	profiles, err := readProfilesIni(firefoxDir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, p := range profiles {
+		if p.Dir == "" {
+			continue
+		}
+		if strings.EqualFold(p.Name, name) {
+			return p.Dir, nil
+		}
+		names = append(names, p.Name)
+	}
+	return "", fmt.Errorf("no Firefox profile named %q (there are: %s)", name, strings.Join(names, ", "))
+}
 
-	log.Println("Firefox profile:", profileDir)
+// saveFirefoxImport writes cookies imported from Firefox to the config at
+// path. A profileDir picked explicitly is remembered as firefox_profile,
+// and the import takes over from chrome_browser either way.
+func saveFirefoxImport(path, profileDir, sessionKey, orgID, cfClearance string) error {
+	save := firefoxCookies(sessionKey, orgID, cfClearance)
+	return updateConfig(path, func(c *Config) {
+		save(c)
+		if profileDir != "" {
+			c.FirefoxProfile = profileDir
+		}
+		c.ChromeBrowser = ""
+	})
+}
+
+// importReport describes what was read from a Firefox profile and which
+// cookie values were selected.
+type importReport struct {
+	Browser    string
+	ProfileDir string
+	Rows       []cookieRow          // every claude.ai cookie row found
+	Selected   map[string]cookieRow // chosen row per cookie name
+	Decision   string               // human-readable explanation of the selection
+}
 
+// importFirefoxProfile reads claude.ai cookies from profileDir/cookies.sqlite
+// and selects the values to use. The firefox_container setting in
+// config.json, if any, pins the container to read.
+func importFirefoxProfile(profileDir string) (*importReport, error) {
 	dbPath := filepath.Join(profileDir, "cookies.sqlite")
-	cookies, err := readClaudeAICookies(dbPath)
+	rows, err := readClaudeAICookies(dbPath)
 	if err != nil {
-		return "", "", "", fmt.Errorf("reading Firefox cookies: %w", err)
+		return nil, fmt.Errorf("reading Firefox cookies: %w", err)
+	}
+
+	cfg, _ := readConfigFile(configPath)
+	var pin *int
+	if cfg != nil {
+		pin = cfg.FirefoxContainer
+	}
+	selected, decision := selectContainerCookies(rows, pin)
+	firefoxLog.Infof("Firefox cookies: %s", decision)
+	return &importReport{
+		Browser:    firefoxBrowserOf(profileDir),
+		ProfileDir: profileDir,
+		Rows:       rows,
+		Selected:   selected,
+		Decision:   decision,
+	}, nil
+}
+
+// userContextID returns the container a cookie's originAttributes belong
+// to, e.g. 3 for "^userContextId=3"; 0 is the default (no container).
+func userContextID(originAttributes string) int {
+	for _, attr := range strings.Split(strings.TrimPrefix(originAttributes, "^"), "&") {
+		if v, ok := strings.CutPrefix(attr, "userContextId="); ok {
+			if id, err := strconv.Atoi(v); err == nil {
+				return id
+			}
+		}
+	}
+	return 0
+}
+
+// containerLabel names the cookies of originAttributes for logs and reports.
+func containerLabel(originAttributes string) string {
+	if originAttributes == "" {
+		return "default container"
+	}
+	return "container " + originAttributes
+}
+
+// newerCookie reports whether a was used more recently than b, by
+// lastAccessed and then expiry.
+func newerCookie(a, b cookieRow) bool {
+	if !a.LastAccessed.Equal(b.LastAccessed) {
+		return a.LastAccessed.After(b.LastAccessed)
+	}
+	return a.Expiry.After(b.Expiry)
+}
+
+// selectContainerCookies picks the cookies of one container (one
+// originAttributes value). With pin set, that userContextId is used.
+// Otherwise the container holding a sessionKey wins; if several do, the
+// one whose sessionKey was used last. Within the container the most
+// recently used row per name is selected.
+func selectContainerCookies(rows []cookieRow, pin *int) (map[string]cookieRow, string) {
+	groups := make(map[string]map[string]cookieRow)
+	var order []string
+	for _, r := range rows {
+		g, ok := groups[r.OriginAttributes]
+		if !ok {
+			g = make(map[string]cookieRow)
+			groups[r.OriginAttributes] = g
+			order = append(order, r.OriginAttributes)
+		}
+		if prev, ok := g[r.Name]; !ok || !newerCookie(prev, r) {
+			g[r.Name] = r
+		}
+	}
+	sort.Strings(order)
+
+	if pin != nil {
+		for _, oa := range order {
+			if userContextID(oa) == *pin {
+				return groups[oa], containerLabel(oa) + " (firefox_container)"
+			}
+		}
+		return map[string]cookieRow{}, fmt.Sprintf("no cookies in userContextId %d (firefox_container)", *pin)
 	}
 
-	sessionKey = cookies["sessionKey"]
-	orgID = cookies["lastActiveOrg"]
-	cfClearance = cookies["cf_clearance"]
+	var best string
+	withSession := 0
+	for _, oa := range order {
+		sk, ok := groups[oa]["sessionKey"]
+		if !ok {
+			continue
+		}
+		if withSession == 0 || newerCookie(sk, groups[best]["sessionKey"]) {
+			best = oa
+		}
+		withSession++
+	}
+	switch withSession {
+	case 0:
+		if g, ok := groups[""]; ok {
+			return g, containerLabel("") + " (no container has a sessionKey)"
+		}
+		if len(order) > 0 {
+			return groups[order[0]], containerLabel(order[0]) + " (no container has a sessionKey)"
+		}
+		return map[string]cookieRow{}, "no claude.ai cookies"
+	case 1:
+		return groups[best], containerLabel(best) + " (the only one with a sessionKey)"
+	default:
+		return groups[best], fmt.Sprintf("%s (most recently used sessionKey of %d containers)", containerLabel(best), withSession)
+	}
+}
+
+// credentials extracts sessionKey, lastActiveOrg and cf_clearance from the
+// selected cookies, failing if there is no sessionKey. A missing
+// lastActiveOrg is returned as "" and looked up from the API later.
+func (r *importReport) credentials() (sessionKey, orgID, cfClearance string, err error) {
+	sessionKey = r.Selected["sessionKey"].Value
+	orgID = r.Selected["lastActiveOrg"].Value
+	cfClearance = r.Selected["cf_clearance"].Value
 
 	if sessionKey == "" {
-		return "", "", "", fmt.Errorf("sessionKey not found — are you logged in to claude.ai in Firefox?")
+		return "", "", "", fmt.Errorf("sessionKey not found — are you logged in to claude.ai in %s?", r.Browser)
 	}
 	if orgID == "" {
-		return "", "", "", fmt.Errorf("lastActiveOrg not found in Firefox cookies")
+		firefoxLog.Infof("%s cookies found: no lastActiveOrg (will look up organizations), cf_clearance=%v", r.Browser, cfClearance != "")
+		return sessionKey, "", cfClearance, nil
 	}
 
-	log.Printf("Firefox cookies found: org_id=%s... cf_clearance=%v", orgID[:min(8, len(orgID))], cfClearance != "")
+	firefoxLog.Infof("%s cookies found: org_id=%s cf_clearance=%v", r.Browser, shortID(orgID), cfClearance != "")
 	return sessionKey, orgID, cfClearance, nil
 }
 
-// findFirefoxProfilesDir returns the Firefox base directory for the current OS.
+// recordImported notes the expiry of the selected sessionKey and
+// cf_clearance in state.json, for the expiry warning and Diagnostics.
+func (r *importReport) recordImported() {
+	recordSessionKey(r.Selected["sessionKey"])
+	if row, ok := r.Selected["cf_clearance"]; ok {
+		recordClearance(row)
+	}
+}
+
+// print writes a human-readable report to w. Secret values are masked.
+func (r *importReport) print(w io.Writer) {
+	fmt.Fprintf(w, "Profile:  %s\n", r.ProfileDir)
+	fmt.Fprintf(w, "Rows:     %d claude.ai cookie(s)\n", len(r.Rows))
+
+	byContainer := make(map[string][]string)
+	var containers []string
+	for _, row := range r.Rows {
+		oa := row.OriginAttributes
+		if _, ok := byContainer[oa]; !ok {
+			containers = append(containers, oa)
+		}
+		byContainer[oa] = append(byContainer[oa], row.Name)
+	}
+	sort.Strings(containers)
+	for _, oa := range containers {
+		label := oa
+		if label == "" {
+			label = "(default)"
+		}
+		names := byContainer[oa]
+		sort.Strings(names)
+		fmt.Fprintf(w, "  container %s: %s\n", label, strings.Join(names, ", "))
+	}
+
+	fmt.Fprintf(w, "Decision: %s\n", r.Decision)
+	for _, name := range claudeCookieNames {
+		row, ok := r.Selected[name]
+		if !ok {
+			fmt.Fprintf(w, "  %-14s missing\n", name)
+			continue
+		}
+		v := row.Value
+		if isSecretField(name) {
+			v = maskSecret(v)
+		}
+		fmt.Fprintf(w, "  %-14s %s\n", name, v)
+	}
+}
+
+// findFirefoxProfilesDir returns the Firefox base directory (the one with
+// profiles.ini): firefox_dir from config.json if set, else the first usable
+// of firefoxDirCandidates, limited to the configured browser if any.
 func findFirefoxProfilesDir() (string, error) {
+	var browser string
+	if cfg, err := readConfigFile(configPath); err == nil {
+		if dir := strings.TrimSpace(cfg.FirefoxDir); dir != "" {
+			if _, err := os.Stat(dir); err != nil {
+				return "", fmt.Errorf("firefox_dir: %w", err)
+			}
+			return dir, nil
+		}
+		browser = cfg.Browser
+	}
+	candidates, err := firefoxDirCandidates(browser)
+	if err != nil {
+		return "", err
+	}
+	return probeFirefoxDirs(candidates)
+}
+
+// firefoxBrowser is a browser that keeps its profiles the way Firefox does
+// (profiles.ini, cookies.sqlite): Firefox itself and its forks.
+// Developer Edition and ESR share Firefox's directory; their own
+// [Install...] sections tell their profiles apart.
+type firefoxBrowser struct {
+	name string
+	// dirs are the directories with profiles.ini relative to the platform
+	// base (%APPDATA% on Windows, ~/Library/Application Support on macOS,
+	// the home directory elsewhere), in the order tried. On Linux they
+	// include the Snap and Flatpak sandbox homes.
+	dirs map[string][]string
+}
+
+var firefoxBrowsers = []firefoxBrowser{
+	{
+		name: "Firefox",
+		dirs: map[string][]string{
+			"windows": {`Mozilla\Firefox`},
+			"darwin":  {"Firefox"},
+			"linux": {".mozilla/firefox", "snap/firefox/common/.mozilla/firefox",
+				".var/app/org.mozilla.firefox/.mozilla/firefox"},
+		},
+	},
+	{
+		name: "LibreWolf",
+		dirs: map[string][]string{
+			"windows": {"librewolf"},
+			"darwin":  {"librewolf"},
+			"linux":   {".librewolf", ".var/app/io.gitlab.librewolf-community/.librewolf"},
+		},
+	},
+	{
+		name: "Waterfox",
+		dirs: map[string][]string{
+			"windows": {"Waterfox"},
+			"darwin":  {"Waterfox"},
+			"linux":   {".waterfox"},
+		},
+	},
+	{
+		name: "Floorp",
+		dirs: map[string][]string{
+			"windows": {"Floorp"},
+			"darwin":  {"Floorp"},
+			"linux":   {".floorp", ".var/app/one.ablaze.floorp/.floorp"},
+		},
+	},
+}
+
+// firefoxBrowserNames lists the values the browser setting accepts.
+func firefoxBrowserNames() string {
+	var names []string
+	for _, b := range firefoxBrowsers {
+		names = append(names, strings.ToLower(b.name))
+	}
+	return strings.Join(names, ", ")
+}
+
+// firefoxDirs returns b's directories on this OS as absolute paths.
+func (b firefoxBrowser) firefoxDirs() ([]string, error) {
 	var base string
-	switch runtime.GOOS {
+	goos := runtime.GOOS
+	switch goos {
 	case "windows":
-		appData := os.Getenv("APPDATA")
-		if appData == "" {
-			return "", fmt.Errorf("APPDATA environment variable not set")
+		base = os.Getenv("APPDATA")
+		if base == "" {
+			return nil, fmt.Errorf("APPDATA environment variable not set")
 		}
-		base = filepath.Join(appData, "Mozilla", "Firefox")
-	default: // linux, darwin
+	case "darwin":
 		home, err := os.UserHomeDir()
 		if err != nil {
-			return "", fmt.Errorf("getting home directory: %w", err)
+			return nil, fmt.Errorf("getting home directory: %w", err)
 		}
-		base = filepath.Join(home, ".mozilla", "firefox")
+		base = filepath.Join(home, "Library", "Application Support")
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("getting home directory: %w", err)
+		}
+		base, goos = home, "linux"
 	}
-	if _, err := os.Stat(base); os.IsNotExist(err) {
-		return "", fmt.Errorf("Firefox directory not found: %s", base)
+	var dirs []string
+	for _, rel := range b.dirs[goos] {
+		dirs = append(dirs, filepath.Join(base, filepath.FromSlash(rel)))
 	}
-	return base, nil
+	return dirs, nil
 }
 
-// findDefaultProfile parses profiles.ini and returns the default profile directory.
-// Falls back to the first profile if no Default=1 is set.
-func findDefaultProfile(firefoxDir string) (string, error) {
+// firefoxDirCandidates lists where Firefox and its forks keep profiles.ini
+// on this OS, Firefox first. A non-empty browser (the browser setting)
+// limits the list to that one.
+func firefoxDirCandidates(browser string) ([]string, error) {
+	browser = strings.TrimSpace(browser)
+	var dirs []string
+	found := false
+	for _, b := range firefoxBrowsers {
+		if browser != "" && !strings.EqualFold(browser, b.name) {
+			continue
+		}
+		found = true
+		d, err := b.firefoxDirs()
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d...)
+	}
+	if !found {
+		return nil, fmt.Errorf("browser %q: must be one of %s", browser, firefoxBrowserNames())
+	}
+	return dirs, nil
+}
+
+// firefoxBrowserOf names the browser profileDir belongs to, by the
+// directory it is in; "Firefox" for profiles elsewhere.
+func firefoxBrowserOf(profileDir string) string {
+	for _, b := range firefoxBrowsers {
+		dirs, err := b.firefoxDirs()
+		if err != nil {
+			break
+		}
+		for _, dir := range dirs {
+			if rel, err := filepath.Rel(dir, profileDir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return b.name
+			}
+		}
+	}
+	return "Firefox"
+}
+
+// probeFirefoxDirs picks the directory whose default profile has a
+// cookies.sqlite, the most recently written one if several do (e.g. after
+// moving from the deb package to the Snap). Failing that, any directory
+// with a profiles.ini will do, so the error names the actual problem.
+func probeFirefoxDirs(candidates []string) (string, error) {
+	var best, fallback string
+	var bestMod time.Time
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, "profiles.ini")); err != nil {
+			firefoxLog.Infof("Firefox directory checked, no profiles.ini: %s", dir)
+			continue
+		}
+		if fallback == "" {
+			fallback = dir
+		}
+		profile, err := findDefaultProfile(dir)
+		if err != nil {
+			firefoxLog.Infof("Firefox directory checked: %s (%v)", dir, err)
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(profile, "cookies.sqlite"))
+		if err != nil {
+			firefoxLog.Infof("Firefox directory checked, no cookies.sqlite in the default profile: %s", dir)
+			continue
+		}
+		firefoxLog.Infof("Firefox directory checked, usable: %s", dir)
+		if best == "" || fi.ModTime().After(bestMod) {
+			best, bestMod = dir, fi.ModTime()
+		}
+	}
+	switch {
+	case best != "":
+		return best, nil
+	case fallback != "":
+		return fallback, nil
+	}
+	return "", fmt.Errorf("Firefox directory not found (checked %s)", strings.Join(candidates, ", "))
+}
+
+// firefoxProfile is one [Profile...] section of profiles.ini.
+type firefoxProfile struct {
+	Name      string
+	Dir       string // "" if the section has no Path=
+	path      string // Path= as written
+	isDefault bool   // Default=1
+	installed bool   // named by an [Install...] section's Default=
+}
+
+// readProfilesIni lists the profiles in firefoxDir/profiles.ini.
+func readProfilesIni(firefoxDir string) ([]firefoxProfile, error) {
 	iniPath := filepath.Join(firefoxDir, "profiles.ini")
 	f, err := os.Open(iniPath)
 	if err != nil {
-		return "", fmt.Errorf("opening profiles.ini: %w", err)
+		return nil, fmt.Errorf("opening profiles.ini: %w", err)
 	}
 	defer f.Close()
 
-	type entry struct {
-		path       string
-		isRelative bool
-		isDefault  bool
-	}
-
-	var profiles []entry
-	var cur *entry
+	var profiles []firefoxProfile
+	var installDefaults []string
+	var cur *firefoxProfile
+	isRelative := make(map[int]bool)
+	inInstall := false
 
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "[Profile") {
-			profiles = append(profiles, entry{})
-			cur = &profiles[len(profiles)-1]
+		if strings.HasPrefix(line, "[") {
+			cur, inInstall = nil, strings.HasPrefix(line, "[Install")
+			if strings.HasPrefix(line, "[Profile") {
+				profiles = append(profiles, firefoxProfile{})
+				cur = &profiles[len(profiles)-1]
+			}
+			continue
+		}
+		if inInstall {
+			if p, ok := strings.CutPrefix(line, "Default="); ok && p != "" {
+				installDefaults = append(installDefaults, p)
+			}
 			continue
 		}
 		if cur == nil {
 			continue
 		}
 		switch {
+		case strings.HasPrefix(line, "Name="):
+			cur.Name = strings.TrimPrefix(line, "Name=")
 		case strings.HasPrefix(line, "Path="):
 			cur.path = strings.TrimPrefix(line, "Path=")
 		case line == "Default=1":
 			cur.isDefault = true
 		case line == "IsRelative=1":
-			cur.isRelative = true
+			isRelative[len(profiles)-1] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading profiles.ini: %w", err)
+	}
+
+	for i := range profiles {
+		p := &profiles[i]
+		switch {
+		case p.path == "":
+		case isRelative[i]:
+			p.Dir = filepath.Join(firefoxDir, filepath.FromSlash(p.path))
+		default:
+			p.Dir = filepath.FromSlash(p.path)
+		}
+		for _, d := range installDefaults {
+			if p.path != "" && p.path == d {
+				p.installed = true
+			}
 		}
 	}
+	return profiles, nil
+}
 
+// findDefaultProfile parses profiles.ini and returns the default profile directory.
+// The profile named by an [Install...] section's Default= wins: that is the
+// one the installed Firefox actually opens, while Default=1 flags in the
+// [Profile...] sections are often stale. Without one, the first Default=1
+// profile is used, and without that the profile with the most recently
+// written cookies.sqlite (or simply the first one).
+func findDefaultProfile(firefoxDir string) (string, error) {
+	profiles, err := readProfilesIni(firefoxDir)
+	if err != nil {
+		return "", err
+	}
 	if len(profiles) == 0 {
 		return "", fmt.Errorf("no profiles found in profiles.ini")
 	}
 
-	sel := &profiles[0]
-	for i := range profiles {
-		if profiles[i].isDefault {
-			sel = &profiles[i]
-			break
+	// newest returns the candidate whose cookies.sqlite was written last,
+	// or "" if none has one.
+	newest := func(candidates []firefoxProfile) string {
+		var best string
+		var bestMod time.Time
+		for _, p := range candidates {
+			if p.Dir == "" {
+				continue
+			}
+			fi, err := os.Stat(filepath.Join(p.Dir, "cookies.sqlite"))
+			if err == nil && (best == "" || fi.ModTime().After(bestMod)) {
+				best, bestMod = p.Dir, fi.ModTime()
+			}
 		}
+		return best
 	}
 
-	if sel.path == "" {
-		return "", fmt.Errorf("empty profile path in profiles.ini")
+	// Several installs (release and ESR, say) each name their own profile;
+	// the one with the freshest cookies is the one in use.
+	var installed []firefoxProfile
+	for _, p := range profiles {
+		if p.installed {
+			installed = append(installed, p)
+		}
 	}
-	if sel.isRelative {
-		return filepath.Join(firefoxDir, filepath.FromSlash(sel.path)), nil
+	switch len(installed) {
+	case 0:
+	case 1:
+		return installed[0].Dir, nil
+	default:
+		if best := newest(installed); best != "" {
+			return best, nil
+		}
+		return installed[0].Dir, nil
+	}
+
+	sel := profiles[0]
+	for _, p := range profiles {
+		if p.isDefault {
+			sel = p
+			break
+		}
+	}
+	if !sel.isDefault {
+		if best := newest(profiles); best != "" {
+			return best, nil
+		}
+	}
+	if sel.Dir == "" {
+		return "", fmt.Errorf("empty profile path in profiles.ini")
 	}
-	return filepath.FromSlash(sel.path), nil
+	return sel.Dir, nil
 }
 
 // readClaudeAICookies copies cookies.sqlite to a temp file (to avoid Firefox's lock)
 // and reads claude.ai cookies using a minimal embedded SQLite reader.
-func readClaudeAICookies(dbPath string) (map[string]string, error) {
+// Firefox runs in WAL mode, so cookies.sqlite-wal is read too: recently
+// changed rows (such as a refreshed cf_clearance) often exist only there.
+// Both are copied in the same attempt so they come from one moment; the
+// -shm index isn't needed, the reader replays the WAL itself.
+func readClaudeAICookies(dbPath string) ([]cookieRow, error) {
+	var data, wal []byte
+	var walErr error
+	err := retryLocked(func() error {
+		var err error
+		if data, err = copyLockedFile(dbPath); err != nil {
+			return err
+		}
+		wal, walErr = copyLockedFile(dbPath + "-wal")
+		if isLockError(walErr) {
+			return walErr
+		}
+		return nil
+	})
+	if data == nil {
+		return nil, err
+	}
+	if walErr != nil && !errors.Is(walErr, fs.ErrNotExist) {
+		firefoxLog.Warnf("Ignoring Firefox cookie WAL: %v", walErr)
+	}
+	return parseCookiesFromSQLite(data, wal)
+}
+
+// Reading a database the browser is writing to is retried a few times:
+// on Windows it can be locked for a moment mid-write.
+const (
+	lockedFileAttempts   = 5
+	lockedFileRetryDelay = 500 * time.Millisecond
+)
+
+// retryLocked runs read until it succeeds, fails with something other than
+// a lock error, or lockedFileAttempts are used up.
+func retryLocked(read func() error) error {
+	var err error
+	for attempt := 1; attempt <= lockedFileAttempts; attempt++ {
+		if err = read(); err == nil || !isLockError(err) {
+			return err
+		}
+		if attempt < lockedFileAttempts {
+			firefoxLog.Warnf("Database locked (attempt %d/%d), retrying: %v", attempt, lockedFileAttempts, err)
+			time.Sleep(lockedFileRetryDelay)
+		}
+	}
+	return err
+}
+
+// readLockedFile reads a database the browser may hold open by copying it
+// to a temp file first, retrying while it is locked.
+func readLockedFile(dbPath string) ([]byte, error) {
+	var data []byte
+	err := retryLocked(func() error {
+		var err error
+		data, err = copyLockedFile(dbPath)
+		return err
+	})
+	return data, err
+}
+
+// copyLockedFile makes one attempt at copying dbPath to a temp file and
+// reading the copy.
+func copyLockedFile(dbPath string) ([]byte, error) {
 	tmp, err := os.CreateTemp("", "claude-monitor-*.sqlite")
 	if err != nil {
 		return nil, fmt.Errorf("creating temp file: %w", err)
@@ -142,7 +706,7 @@ func readClaudeAICookies(dbPath string) (map[string]string, error) {
 	tmpPath := tmp.Name()
 	defer os.Remove(tmpPath)
 
-	src, err := os.Open(dbPath)
+	src, err := openShared(dbPath)
 	if err != nil {
 		tmp.Close()
 		return nil, fmt.Errorf("opening %s: %w", dbPath, err)
@@ -154,11 +718,7 @@ func readClaudeAICookies(dbPath string) (map[string]string, error) {
 		return nil, fmt.Errorf("copying database: %w", copyErr)
 	}
 
-	data, err := os.ReadFile(tmpPath)
-	if err != nil {
-		return nil, err
-	}
-	return parseCookiesFromSQLite(data)
+	return os.ReadFile(tmpPath)
 }
 
 // ── Minimal SQLite 3 B-tree reader (read-only, no external dependencies) ────
@@ -168,6 +728,15 @@ const sqliteMagic = "SQLite format 3\x00"
 type sqliteDB struct {
 	data     []byte
 	pageSize int
+	// usableSize is pageSize minus the bytes reserved at the end of each
+	// page (for extensions such as encryption).
+	usableSize int
+
+	// walPages holds the newest committed WAL copy of each page, which
+	// takes precedence over the main file. walSize is the database size in
+	// pages after the last commit, 0 without a WAL.
+	walPages map[int][]byte
+	walSize  int
 }
 
 func newSQLiteDB(data []byte) (*sqliteDB, error) {
@@ -178,10 +747,16 @@ func newSQLiteDB(data []byte) (*sqliteDB, error) {
 	if ps == 1 {
 		ps = 65536
 	}
-	return &sqliteDB{data: data, pageSize: ps}, nil
+	return &sqliteDB{data: data, pageSize: ps, usableSize: ps - int(data[20])}, nil
 }
 
 func (db *sqliteDB) page(n int) []byte {
+	if db.walSize > 0 && n > db.walSize {
+		return nil // truncated by a committed transaction
+	}
+	if p, ok := db.walPages[n]; ok {
+		return p
+	}
 	off := (n - 1) * db.pageSize
 	if off < 0 || off+db.pageSize > len(db.data) {
 		return nil
@@ -189,6 +764,74 @@ func (db *sqliteDB) page(n int) []byte {
 	return db.data[off : off+db.pageSize]
 }
 
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+// loadWAL applies the committed frames of a write-ahead log on top of the
+// main file. Frames are checked against the header salts and the running
+// checksum; reading stops at the first frame that fails, and frames after
+// the last commit are ignored, as SQLite itself does on recovery.
+func (db *sqliteDB) loadWAL(wal []byte) error {
+	if len(wal) < walHeaderSize {
+		return nil // empty WAL: nothing since the last checkpoint
+	}
+	var order binary.ByteOrder
+	switch binary.BigEndian.Uint32(wal[0:4]) {
+	case 0x377f0682:
+		order = binary.LittleEndian
+	case 0x377f0683:
+		order = binary.BigEndian
+	default:
+		return fmt.Errorf("not a SQLite WAL file")
+	}
+	if ps := int(binary.BigEndian.Uint32(wal[8:12])); ps != db.pageSize {
+		return fmt.Errorf("WAL page size %d differs from database page size %d", ps, db.pageSize)
+	}
+	salt := wal[16:24]
+	s0, s1 := walChecksum(order, wal[0:24], 0, 0)
+	if s0 != binary.BigEndian.Uint32(wal[24:28]) || s1 != binary.BigEndian.Uint32(wal[28:32]) {
+		return fmt.Errorf("WAL header checksum mismatch")
+	}
+
+	pages := make(map[int][]byte)
+	pending := make(map[int][]byte)
+	frameSize := walFrameHeaderSize + db.pageSize
+	for off := walHeaderSize; off+frameSize <= len(wal); off += frameSize {
+		hdr := wal[off : off+walFrameHeaderSize]
+		data := wal[off+walFrameHeaderSize : off+frameSize]
+		if string(hdr[8:16]) != string(salt) {
+			break // left over from before the WAL was last reset
+		}
+		s0, s1 = walChecksum(order, hdr[0:8], s0, s1)
+		s0, s1 = walChecksum(order, data, s0, s1)
+		if s0 != binary.BigEndian.Uint32(hdr[16:20]) || s1 != binary.BigEndian.Uint32(hdr[20:24]) {
+			break // torn write
+		}
+		pending[int(binary.BigEndian.Uint32(hdr[0:4]))] = data
+		if size := int(binary.BigEndian.Uint32(hdr[4:8])); size > 0 {
+			for n, p := range pending {
+				pages[n] = p
+			}
+			pending = make(map[int][]byte)
+			db.walSize = size
+		}
+	}
+	db.walPages = pages
+	return nil
+}
+
+// walChecksum continues the WAL's running checksum over data, whose length
+// is a multiple of 8.
+func walChecksum(order binary.ByteOrder, data []byte, s0, s1 uint32) (uint32, uint32) {
+	for i := 0; i+8 <= len(data); i += 8 {
+		s0 += order.Uint32(data[i:]) + s1
+		s1 += order.Uint32(data[i+4:]) + s0
+	}
+	return s0, s1
+}
+
 // readVarint reads a SQLite variable-length integer from data[pos].
 // Returns (value, bytes consumed); bytes=0 on error.
 func readVarint(data []byte, pos int) (int64, int) {
@@ -209,6 +852,7 @@ func readVarint(data []byte, pos int) (int64, int) {
 // sqliteVal holds one column value from a SQLite record.
 type sqliteVal struct {
 	text   string
+	blob   []byte
 	intV   int64
 	isInt  bool
 	isNull bool
@@ -272,6 +916,7 @@ func parseRecord(payload []byte) []sqliteVal {
 			if dataPos+size > len(payload) {
 				return result
 			}
+			v.blob = payload[dataPos : dataPos+size]
 			dataPos += size
 		case t >= 13 && t%2 == 1: // TEXT
 			size := int((t - 13) / 2)
@@ -285,16 +930,40 @@ func parseRecord(payload []byte) []sqliteVal {
 	return result
 }
 
-// maxInlinePayload returns the maximum bytes stored inline for this page size.
+// maxInlinePayload returns the maximum bytes stored inline in a table-leaf
+// cell; larger payloads spill into overflow pages.
 func (db *sqliteDB) maxInlinePayload() int {
-	return db.pageSize - 35
+	return db.usableSize - 35
 }
 
-// leafCellPayload extracts the inline record payload from a table-leaf cell.
+// maxIndexPayload is maxInlinePayload for index cells, leaf or interior.
+func (db *sqliteDB) maxIndexPayload() int {
+	return (db.usableSize-12)*64/255 - 23
+}
+
+// inlinePayload returns how many of payloadSize bytes a cell whose kind
+// stores at most maxLocal bytes keeps on the page itself, following the
+// SQLite file format.
+func (db *sqliteDB) inlinePayload(payloadSize int64, maxLocal int) int {
+	u := int64(db.usableSize)
+	if payloadSize <= int64(maxLocal) {
+		return int(payloadSize)
+	}
+	m := (u-12)*32/255 - 23
+	k := m + (payloadSize-m)%(u-4)
+	if k <= int64(maxLocal) {
+		return int(k)
+	}
+	return int(m)
+}
+
+// leafCellPayload extracts the record payload from a table-leaf cell,
+// following the overflow chain when the record doesn't fit on the page.
+// Returns nil if the cell or its chain is damaged.
 func (db *sqliteDB) leafCellPayload(page []byte, cellOff int) []byte {
 	pos := cellOff
 	payloadSize, n := readVarint(page, pos)
-	if n == 0 {
+	if n == 0 || payloadSize < 0 {
 		return nil
 	}
 	pos += n
@@ -303,19 +972,42 @@ func (db *sqliteDB) leafCellPayload(page []byte, cellOff int) []byte {
 		return nil
 	}
 	pos += n
+	return db.cellPayload(page, pos, payloadSize, db.maxInlinePayload())
+}
 
-	inline := payloadSize
-	if max := int64(db.maxInlinePayload()); inline > max {
-		inline = max
+// cellPayload reads a payload of payloadSize bytes starting at pos, the
+// part beyond what the cell keeps inline from its overflow chain.
+func (db *sqliteDB) cellPayload(page []byte, pos int, payloadSize int64, maxLocal int) []byte {
+	inline := db.inlinePayload(payloadSize, maxLocal)
+	if pos+inline > len(page) {
+		return nil
 	}
-	end := pos + int(inline)
-	if end > len(page) {
-		end = len(page)
+	if int64(inline) == payloadSize {
+		return page[pos : pos+inline]
 	}
-	if pos >= end {
+
+	// The first overflow page number follows the inline part. Each overflow
+	// page starts with the number of the next one (0 for the last).
+	maxPages := len(db.data)/db.pageSize + len(db.walPages)
+	if pos+inline+4 > len(page) || payloadSize > int64(maxPages)*int64(db.pageSize) {
 		return nil
 	}
-	return page[pos:end]
+	payload := make([]byte, 0, payloadSize)
+	payload = append(payload, page[pos:pos+inline]...)
+	next := int(binary.BigEndian.Uint32(page[pos+inline:]))
+	for visited := 0; int64(len(payload)) < payloadSize; visited++ {
+		ovfl := db.page(next)
+		if ovfl == nil || visited >= maxPages {
+			return nil // broken or cyclic chain
+		}
+		chunk := ovfl[4:db.usableSize]
+		if rest := payloadSize - int64(len(payload)); int64(len(chunk)) > rest {
+			chunk = chunk[:rest]
+		}
+		payload = append(payload, chunk...)
+		next = int(binary.BigEndian.Uint32(ovfl))
+	}
+	return payload
 }
 
 // walkTableBTree calls fn for every record in the B-tree rooted at pageNum.
@@ -382,49 +1074,211 @@ func (db *sqliteDB) walkTableBTree(pageNum int, fn func([]sqliteVal)) {
 
 // findTableRootPage scans sqlite_master (always on page 1) for the root page
 // of the given table. Returns 0 if not found.
-// sqlite_master columns: type(0), name(1), tbl_name(2), rootpage(3), sql(4)
 func (db *sqliteDB) findTableRootPage(tableName string) int {
-	var root int
+	root, _ := db.findTable(tableName)
+	return root
+}
+
+// findTable returns the root page and CREATE TABLE statement of tableName.
+// sqlite_master columns: type(0), name(1), tbl_name(2), rootpage(3), sql(4)
+func (db *sqliteDB) findTable(tableName string) (root int, sql string) {
 	db.walkTableBTree(1, func(cols []sqliteVal) {
-		if len(cols) >= 4 &&
+		if len(cols) >= 5 &&
 			cols[0].text == "table" &&
 			cols[1].text == tableName &&
 			cols[3].isInt {
 			root = int(cols[3].intV)
+			sql = cols[4].text
 		}
 	})
-	return root
+	return root, sql
 }
 
-// parseCookiesFromSQLite reads claude.ai cookies from raw SQLite database bytes.
-// moz_cookies columns: id(0), baseDomain(1), originAttributes(2), name(3), value(4), host(5), ...
-func parseCookiesFromSQLite(data []byte) (map[string]string, error) {
+// tableColumns maps column names to their record index by parsing a
+// CREATE TABLE statement. Table constraints are skipped; columns added later
+// with ALTER TABLE appear in the stored SQL too, so their order is correct.
+func tableColumns(createSQL string) map[string]int {
+	defs := splitDefinitions(createSQL)
+	if defs == nil {
+		return nil
+	}
+
+	cols := make(map[string]int)
+	idx := 0
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		name := unquoteIdent(fields[0])
+		cols[name] = idx
+		idx++
+	}
+	return cols
+}
+
+// splitDefinitions returns the comma-separated column definitions and
+// table constraints of a CREATE TABLE statement, nil if it has none.
+func splitDefinitions(createSQL string) []string {
+	open := strings.IndexByte(createSQL, '(')
+	end := strings.LastIndexByte(createSQL, ')')
+	if open < 0 || end <= open {
+		return nil
+	}
+
+	var defs []string
+	depth, start := 0, open+1
+	for i := open + 1; i < end; i++ {
+		switch createSQL[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, createSQL[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(defs, createSQL[start:end])
+}
+
+// cookieRow is one claude.ai row from moz_cookies.
+type cookieRow struct {
+	Name             string
+	Value            string
+	Host             string
+	OriginAttributes string    // container / private-browsing context, "" by default
+	Expiry           time.Time // zero if unknown
+	Created          time.Time // zero if unknown
+	LastAccessed     time.Time // zero if unknown
+}
+
+// Column layout of moz_cookies before Firefox dropped baseDomain; used when
+// the stored CREATE TABLE statement can't be parsed.
+var legacyCookieColumns = map[string]int{
+	"id": 0, "baseDomain": 1, "originAttributes": 2, "name": 3, "value": 4, "host": 5,
+	"path": 6, "expiry": 7, "lastAccessed": 8, "creationTime": 9,
+}
+
+// parseCookiesFromSQLite reads claude.ai cookies from raw SQLite database bytes
+// and its write-ahead log (nil if there is none). Columns are located by
+// name, since the moz_cookies layout differs between Firefox versions.
+func parseCookiesFromSQLite(data, wal []byte) ([]cookieRow, error) {
 	db, err := newSQLiteDB(data)
 	if err != nil {
 		return nil, err
 	}
+	if err := db.loadWAL(wal); err != nil {
+		firefoxLog.Warnf("Ignoring Firefox cookie WAL: %v", err)
+	}
 
-	rootPage := db.findTableRootPage("moz_cookies")
+	rootPage, createSQL := db.findTable("moz_cookies")
 	if rootPage == 0 {
 		return nil, fmt.Errorf("moz_cookies table not found (not a Firefox cookies database?)")
 	}
 
-	cookies := make(map[string]string)
-	db.walkTableBTree(rootPage, func(cols []sqliteVal) {
-		if len(cols) < 6 {
+	colIdx := tableColumns(createSQL)
+	indexed := true
+	for _, required := range []string{"name", "value", "host"} {
+		if _, ok := colIdx[required]; !ok {
+			colIdx, indexed = legacyCookieColumns, false
+			break
+		}
+	}
+	col := func(cols []sqliteVal, name string) sqliteVal {
+		if i, ok := colIdx[name]; ok && i < len(cols) {
+			return cols[i]
+		}
+		return sqliteVal{isNull: true}
+	}
+
+	var rows []cookieRow
+	visit := func(cols []sqliteVal) {
+		host := col(cols, "host").text
+		if !isClaudeHost(host) {
 			return
 		}
-		host := cols[5].text
-		if !strings.Contains(host, "claude.ai") {
+		name := col(cols, "name").text
+		value := col(cols, "value").text
+		if name == "" || value == "" {
 			return
 		}
-		name := cols[3].text
-		value := cols[4].text
-		if name != "" && value != "" {
-			cookies[name] = value
+		row := cookieRow{
+			Name:             name,
+			Value:            value,
+			Host:             host,
+			OriginAttributes: col(cols, "originAttributes").text,
 		}
-	})
+		// expiry is in seconds; creationTime and lastAccessed in microseconds
+		if v := col(cols, "expiry"); v.isInt && v.intV > 0 {
+			row.Expiry = firefoxExpiry(v.intV)
+		}
+		if v := col(cols, "creationTime"); v.isInt && v.intV > 0 {
+			row.Created = time.UnixMicro(v.intV)
+		}
+		if v := col(cols, "lastAccessed"); v.isInt && v.intV > 0 {
+			row.LastAccessed = time.UnixMicro(v.intV)
+		}
+		rows = append(rows, row)
+	}
+	if !indexed || !db.lookupCookies(rootPage, createSQL, colIdx, visit) {
+		db.walkTableBTree(rootPage, visit)
+	}
+
+	firefoxLog.Infof("Found %d claude.ai cookies in Firefox profile", len(rows))
+	return dropExpired(rows, timeNow()), nil
+}
+
+// claudeCookieNames are the cookies an import uses.
+var claudeCookieNames = []string{"sessionKey", "lastActiveOrg", "cf_clearance"}
+
+// lookupCookies finds the claude.ai rows of moz_cookies through an index,
+// which in a large cookie database is much faster than walking the whole
+// table. An index on host finds the cookies of claude.ai itself, one on
+// baseDomain (older Firefox versions) those of its subdomains too. Current
+// versions only have the index of the UNIQUE (name, host, ...) constraint,
+// through which the cookies the import uses are looked up by name. It
+// returns false if no index could be used.
+func (db *sqliteDB) lookupCookies(root int, createSQL string, colIdx map[string]int, fn func([]sqliteVal)) bool {
+	return db.lookupRows("moz_cookies", root, createSQL, colIdx, "host", []string{".claude.ai", "claude.ai"}, fn) ||
+		db.lookupRows("moz_cookies", root, createSQL, colIdx, "baseDomain", []string{"claude.ai"}, fn) ||
+		db.lookupRows("moz_cookies", root, createSQL, colIdx, "name", claudeCookieNames, fn)
+}
 
-	log.Printf("Found %d claude.ai cookies in Firefox profile", len(cookies))
-	return cookies, nil
+// isClaudeHost reports whether a cookie's host is claude.ai or one of its
+// subdomains. Domain cookies are stored with a leading dot (".claude.ai").
+func isClaudeHost(host string) bool {
+	host = strings.ToLower(strings.TrimPrefix(host, "."))
+	return host == "claude.ai" || strings.HasSuffix(host, ".claude.ai")
+}
+
+// firefoxExpiry converts a moz_cookies expiry. It has been seconds since
+// the epoch; recent Firefox versions store milliseconds, which as seconds
+// would lie thousands of years ahead.
+func firefoxExpiry(v int64) time.Time {
+	if v > 1e11 {
+		return time.UnixMilli(v)
+	}
+	return time.Unix(v, 0)
+}
+
+// dropExpired removes cookies whose expiry has passed. The browser deletes
+// them only lazily, and a sessionKey left behind by logging out would
+// otherwise be imported and fail with 401.
+func dropExpired(rows []cookieRow, now time.Time) []cookieRow {
+	kept := rows[:0]
+	for _, r := range rows {
+		if !r.Expiry.IsZero() && !r.Expiry.After(now) {
+			firefoxLog.Warnf("Skipping expired %s cookie (%s, expired %s ago)", r.Name, containerLabel(r.OriginAttributes), shortDuration(now.Sub(r.Expiry)))
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
 }
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadClaudeAICookiesMergesWAL(t *testing.T) {
+	report, err := importFirefoxProfile(filepath.Join("testdata", "firefox", "wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"sessionKey":    "sk-ant-sid01-fresh",
+		"lastActiveOrg": "org-wal",
+		"cf_clearance":  "cf-fresh",
+	} {
+		if got := report.Selected[name].Value; got != want {
+			t.Errorf("%s = %q, want %q from the WAL", name, got, want)
+		}
+	}
+	if len(report.Rows) != 3 {
+		t.Errorf("got %d rows, want 3", len(report.Rows))
+	}
+}
+
+func TestLoadWALStopsAtBadFrame(t *testing.T) {
+	dir := filepath.Join("testdata", "firefox", "wal")
+	data, err := os.ReadFile(filepath.Join(dir, "cookies.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal, err := os.ReadFile(filepath.Join(dir, "cookies.sqlite-wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := func(wal []byte) map[string]string {
+		t.Helper()
+		rows, err := parseCookiesFromSQLite(data, wal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]string)
+		for _, r := range rows {
+			m[r.Name] = r.Value
+		}
+		return m
+	}
+
+	if got := values(nil); got["sessionKey"] != "sk-ant-sid01-stale" || got["cf_clearance"] != "cf-stale" {
+		t.Errorf("without WAL: %v, want the stale values", got)
+	}
+
+	// The fixture holds one frame per commit; corrupting the second leaves
+	// only the cf_clearance update.
+	torn := append([]byte(nil), wal...)
+	torn[len(torn)-1] ^= 0xff
+	if got := values(torn); got["sessionKey"] != "sk-ant-sid01-stale" || got["cf_clearance"] != "cf-fresh" {
+		t.Errorf("with a torn last frame: %v, want fresh cf_clearance and stale sessionKey", got)
+	}
+
+	if got := values(wal[:len(wal)-100]); got["sessionKey"] != "sk-ant-sid01-stale" || got["cf_clearance"] != "cf-fresh" {
+		t.Errorf("with a truncated last frame: %v, want fresh cf_clearance and stale sessionKey", got)
+	}
+}
+
+func TestReadClaudeAICookiesOverflow(t *testing.T) {
+	report, err := importFirefoxProfile(filepath.Join("testdata", "firefox", "overflow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cf-" + strings.Repeat("0123456789abcdef", 600) + "-end"
+	if got := report.Selected["cf_clearance"].Value; got != want {
+		t.Errorf("cf_clearance has %d bytes ending in %q, want %d bytes ending in -end", len(got), got[max(0, len(got)-8):], len(want))
+	}
+	if got := report.Selected["sessionKey"].Value; got != "sk-ant-sid01-overflow" {
+		t.Errorf("sessionKey = %q", got)
+	}
+}
+
+func TestImportFirefoxContainers(t *testing.T) {
+	profile := filepath.Join("testdata", "firefox", "containers")
+	savedConfig := configPath
+	t.Cleanup(func() { configPath = savedConfig })
+
+	for _, tc := range []struct {
+		config   string
+		session  string
+		org      string
+		decision string
+	}{
+		{`{}`, "sk-ant-sid01-work", "org-work", "container ^userContextId=3 (most recently used sessionKey of 3 containers)"},
+		{`{"firefox_container": 5}`, "sk-ant-sid01-personal", "org-personal", "container ^userContextId=5 (firefox_container)"},
+		{`{"firefox_container": 0}`, "sk-ant-sid01-stale", "", "default container (firefox_container)"},
+		{`{"firefox_container": 9}`, "", "", "no cookies in userContextId 9 (firefox_container)"},
+	} {
+		configPath = writeTestConfig(t, tc.config)
+		report, err := importFirefoxProfile(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := report.Selected["sessionKey"].Value; got != tc.session {
+			t.Errorf("%s: sessionKey = %q, want %q", tc.config, got, tc.session)
+		}
+		if got := report.Selected["lastActiveOrg"].Value; got != tc.org {
+			t.Errorf("%s: lastActiveOrg = %q, want %q", tc.config, got, tc.org)
+		}
+		if report.Decision != tc.decision {
+			t.Errorf("%s: decision %q, want %q", tc.config, report.Decision, tc.decision)
+		}
+	}
+}
+
+func TestSelectContainerCookies(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	rows := []cookieRow{
+		{Name: "sessionKey", Value: "sk-new", OriginAttributes: "^userContextId=2", LastAccessed: at(20)},
+		{Name: "sessionKey", Value: "sk-old", OriginAttributes: "^userContextId=2", LastAccessed: at(10)},
+		{Name: "cf_clearance", Value: "cf-container", OriginAttributes: "^userContextId=2", LastAccessed: at(5)},
+		{Name: "cf_clearance", Value: "cf-default", LastAccessed: at(30)},
+	}
+	selected, decision := selectContainerCookies(rows, nil)
+	if selected["sessionKey"].Value != "sk-new" || selected["cf_clearance"].Value != "cf-container" {
+		t.Errorf("selected %v", selected)
+	}
+	if want := "container ^userContextId=2 (the only one with a sessionKey)"; decision != want {
+		t.Errorf("decision %q, want %q", decision, want)
+	}
+
+	// Ties on lastAccessed go to the later expiry
+	rows = []cookieRow{
+		{Name: "sessionKey", Value: "sk-a", OriginAttributes: "^userContextId=1", LastAccessed: at(10), Expiry: at(200)},
+		{Name: "sessionKey", Value: "sk-b", OriginAttributes: "^userContextId=4", LastAccessed: at(10), Expiry: at(100)},
+	}
+	if selected, _ := selectContainerCookies(rows, nil); selected["sessionKey"].Value != "sk-a" {
+		t.Errorf("tie: selected %q, want sk-a", selected["sessionKey"].Value)
+	}
+}
+
+func TestUserContextID(t *testing.T) {
+	for oa, want := range map[string]int{
+		"":                                      0,
+		"^userContextId=3":                      3,
+		"^privateBrowsingId=1&userContextId=12": 12,
+		"^partitionKey=%28https%2Cexample.com%29": 0,
+	} {
+		if got := userContextID(oa); got != want {
+			t.Errorf("userContextID(%q) = %d, want %d", oa, got, want)
+		}
+	}
+}
+
+// fakeFirefoxDir creates a Firefox directory under root with a relative
+// default profile; cookies sets whether the profile has cookies.sqlite.
+func fakeFirefoxDir(t *testing.T, root, rel string, cookies bool, mod time.Time) string {
+	t.Helper()
+	dir := filepath.Join(root, filepath.FromSlash(rel))
+	profile := filepath.Join(dir, "abcd.default-release")
+	if err := os.MkdirAll(profile, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ini := "[Profile0]\nName=default-release\nIsRelative=1\nPath=abcd.default-release\nDefault=1\n"
+	if err := os.WriteFile(filepath.Join(dir, "profiles.ini"), []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cookies {
+		db := filepath.Join(profile, "cookies.sqlite")
+		if err := os.WriteFile(db, []byte(sqliteMagic), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(db, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestProbeFirefoxDirs(t *testing.T) {
+	home := t.TempDir()
+	deb := filepath.Join(home, ".mozilla", "firefox")
+	snap := filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox")
+	flatpak := filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox")
+	candidates := []string{deb, snap, flatpak}
+
+	_, err := probeFirefoxDirs(candidates)
+	if err == nil || !strings.Contains(err.Error(), snap) {
+		t.Errorf("nothing installed: error = %v, want one listing the checked directories", err)
+	}
+
+	// A leftover deb profile without cookies loses to the Snap
+	old := time.Now().Add(-time.Hour)
+	fakeFirefoxDir(t, home, ".mozilla/firefox", false, old)
+	if got, err := probeFirefoxDirs(candidates); err != nil || got != deb {
+		t.Errorf("profiles.ini only: got %q, %v; want the fallback %q", got, err, deb)
+	}
+	fakeFirefoxDir(t, home, "snap/firefox/common/.mozilla/firefox", true, old)
+	if got, err := probeFirefoxDirs(candidates); err != nil || got != snap {
+		t.Errorf("Snap with cookies: got %q, %v; want %q", got, err, snap)
+	}
+
+	// Of several usable ones the most recently written cookies win
+	fakeFirefoxDir(t, home, ".var/app/org.mozilla.firefox/.mozilla/firefox", true, time.Now())
+	if got, err := probeFirefoxDirs(candidates); err != nil || got != flatpak {
+		t.Errorf("newer Flatpak cookies: got %q, %v; want %q", got, err, flatpak)
+	}
+}
+
+func TestFindFirefoxProfilesDirOverride(t *testing.T) {
+	savedConfig := configPath
+	t.Cleanup(func() { configPath = savedConfig })
+
+	dir := fakeFirefoxDir(t, t.TempDir(), "custom/firefox", true, time.Now())
+	data, _ := json.Marshal(map[string]string{"firefox_dir": dir})
+	configPath = writeTestConfig(t, string(data))
+	if got, err := findFirefoxProfilesDir(); err != nil || got != dir {
+		t.Errorf("firefox_dir set: got %q, %v; want %q", got, err, dir)
+	}
+
+	data, _ = json.Marshal(map[string]string{"firefox_dir": filepath.Join(dir, "missing")})
+	configPath = writeTestConfig(t, string(data))
+	if _, err := findFirefoxProfilesDir(); err == nil || !strings.Contains(err.Error(), "firefox_dir") {
+		t.Errorf("missing firefox_dir: error = %v, want one about firefox_dir", err)
+	}
+}
+
+func TestFindDefaultProfile(t *testing.T) {
+	writeProfiles := func(t *testing.T, ini string, cookies map[string]time.Time) string {
+		t.Helper()
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "profiles.ini"), []byte(ini), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for profile, mod := range cookies {
+			db := filepath.Join(dir, "Profiles", profile, "cookies.sqlite")
+			if err := os.MkdirAll(filepath.Dir(db), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(db, []byte(sqliteMagic), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(db, mod, mod); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	now := time.Now()
+	const profiles = `[Profile1]
+Name=default
+IsRelative=1
+Path=Profiles/old.default
+Default=1
+
+[Profile0]
+Name=default-release
+IsRelative=1
+Path=Profiles/new.default-release
+
+[Profile2]
+Name=esr
+IsRelative=1
+Path=Profiles/esr.default-esr
+
+[General]
+StartWithLastProfile=1
+Version=2
+`
+	for _, tc := range []struct {
+		name    string
+		ini     string
+		cookies map[string]time.Time
+		want    string
+	}{
+		{"install section beats a stale Default=1",
+			profiles + "\n[Install308046B0AF4A39CB]\nDefault=Profiles/new.default-release\nLocked=1\n",
+			nil, "new.default-release"},
+		{"several installs: the freshest cookies",
+			profiles + "\n[Install308046B0AF4A39CB]\nDefault=Profiles/new.default-release\n\n[InstallE7CF176E110C211B]\nDefault=Profiles/esr.default-esr\n",
+			map[string]time.Time{"new.default-release": now.Add(-time.Hour), "esr.default-esr": now}, "esr.default-esr"},
+		{"install pointing at an unknown profile",
+			profiles + "\n[Install308046B0AF4A39CB]\nDefault=Profiles/gone.default\n",
+			nil, "old.default"},
+		{"legacy layout: Default=1",
+			profiles, nil, "old.default"},
+		{"no default: the freshest cookies",
+			strings.ReplaceAll(profiles, "Default=1\n", ""),
+			map[string]time.Time{"old.default": now.Add(-time.Hour), "new.default-release": now}, "new.default-release"},
+		{"no default, no cookies: the first profile",
+			strings.ReplaceAll(profiles, "Default=1\n", ""), nil, "old.default"},
+	} {
+		dir := writeProfiles(t, tc.ini, tc.cookies)
+		got, err := findDefaultProfile(dir)
+		if want := filepath.Join(dir, "Profiles", tc.want); err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", tc.name, got, err, want)
+		}
+	}
+}
+
+func TestFirefoxProfileDirRemembered(t *testing.T) {
+	savedConfig := configPath
+	t.Cleanup(func() { configPath = savedConfig })
+
+	firefoxDir := fakeFirefoxDir(t, t.TempDir(), "firefox", true, time.Now())
+	profiles, err := readProfilesIni(firefoxDir)
+	if err != nil || len(profiles) != 1 || profiles[0].Name != "default-release" {
+		t.Fatalf("profiles %+v, %v", profiles, err)
+	}
+
+	work := filepath.Join(t.TempDir(), "work.profile")
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]string{"firefox_dir": firefoxDir, "firefox_profile": work})
+	configPath = writeTestConfig(t, string(data))
+	if got, err := firefoxProfileDir(); err != nil || got != work {
+		t.Errorf("firefox_profile set: got %q, %v; want %q", got, err, work)
+	}
+
+	// A remembered profile that is gone must not silently become the default one
+	data, _ = json.Marshal(map[string]string{"firefox_dir": firefoxDir, "firefox_profile": filepath.Join(work, "missing")})
+	configPath = writeTestConfig(t, string(data))
+	if _, err := firefoxProfileDir(); err == nil || !strings.Contains(err.Error(), "firefox_profile") {
+		t.Errorf("missing firefox_profile: error = %v, want one about firefox_profile", err)
+	}
+
+	data, _ = json.Marshal(map[string]string{"firefox_dir": firefoxDir})
+	configPath = writeTestConfig(t, string(data))
+	if got, err := firefoxProfileDir(); err != nil || got != profiles[0].Dir {
+		t.Errorf("no firefox_profile: got %q, %v; want the default %q", got, err, profiles[0].Dir)
+	}
+}
+
+func TestExpiredCookiesSkipped(t *testing.T) {
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+
+	// The fixtures expire on 2100-01-01
+	profile := filepath.Join("testdata", "firefox", "containers")
+	timeNow = func() time.Time { return time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC) }
+	if report, err := importFirefoxProfile(profile); err != nil || len(report.Rows) == 0 {
+		t.Fatalf("before expiry: %v rows, %v", report, err)
+	}
+	timeNow = func() time.Time { return time.Date(2100, 1, 2, 0, 0, 0, 0, time.UTC) }
+	report, err := importFirefoxProfile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != 0 {
+		t.Errorf("after expiry: %d rows, want none", len(report.Rows))
+	}
+	if _, _, _, err := report.credentials(); err == nil {
+		t.Error("after expiry: an expired sessionKey was imported")
+	}
+
+	for v, want := range map[int64]time.Time{
+		4102444800:    time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		4102444800123: time.Date(2100, 1, 1, 0, 0, 0, 123e6, time.UTC),
+	} {
+		if got := firefoxExpiry(v); !got.Equal(want) {
+			t.Errorf("firefoxExpiry(%d) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestImportIgnoresLookalikeHosts(t *testing.T) {
+	// The basic fixture also has newer cookies of notclaude.ai.evil.com,
+	// .claude.ai.evil.com and fakeclaude.ai
+	report, err := importFirefoxProfile(filepath.Join("testdata", "firefox", "basic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, org, cfc, err := report.credentials()
+	if err != nil || sk != "sk-ant-sid01-default" || org != "org-default" || cfc != "cf-default" {
+		t.Errorf("credentials %q, %q, %q, %v; want the claude.ai ones", sk, org, cfc, err)
+	}
+
+	for host, want := range map[string]bool{
+		"claude.ai":             true,
+		".claude.ai":            true,
+		"api.claude.ai":         true,
+		"Claude.AI":             true,
+		"fakeclaude.ai":         false,
+		"notclaude.ai.evil.com": false,
+		".claude.ai.evil.com":   false,
+		"":                      false,
+	} {
+		if got := isClaudeHost(host); got != want {
+			t.Errorf("isClaudeHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestFirefoxForks(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("directory layout checked for Linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	all, err := firefoxDirCandidates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 8 || all[0] != filepath.Join(home, ".mozilla", "firefox") {
+		t.Errorf("candidates %q, want 8 with Firefox first", all)
+	}
+	librewolf, err := firefoxDirCandidates(" LibreWolf ")
+	if err != nil || len(librewolf) != 2 || librewolf[0] != filepath.Join(home, ".librewolf") {
+		t.Errorf("browser librewolf: %q, %v", librewolf, err)
+	}
+	if _, err := firefoxDirCandidates("netscape"); err == nil || !strings.Contains(err.Error(), "floorp") {
+		t.Errorf("unknown browser: error = %v, want one listing the choices", err)
+	}
+
+	// Only Waterfox is installed: found, and named as the cookies' source
+	dir := fakeFirefoxDir(t, home, ".waterfox", true, time.Now())
+	if got, err := probeFirefoxDirs(all); err != nil || got != dir {
+		t.Errorf("probe: got %q, %v; want %q", got, err, dir)
+	}
+	for profile, want := range map[string]string{
+		filepath.Join(dir, "abcd.default-release"):                                      "Waterfox",
+		filepath.Join(home, ".var", "app", "one.ablaze.floorp", ".floorp", "x.default"): "Floorp",
+		filepath.Join(home, ".mozilla", "firefox", "x.dev-edition-default"):             "Firefox",
+		filepath.Join(home, "elsewhere", "profile"):                                     "Firefox",
+		filepath.Join(home, ".waterfox-old", "profile"):                                 "Firefox",
+	} {
+		if got := firefoxBrowserOf(profile); got != want {
+			t.Errorf("firefoxBrowserOf(%s) = %q, want %q", profile, got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/getlantern/systray"
+)
+
+// maxFirefoxProfileItems is how many profiles the "Import from Firefox"
+// submenu can list; like the organization picker, slots are created up front.
+const maxFirefoxProfileItems = 8
+
+const firefoxMenuTitle = "Import from Firefox"
+
+// firefoxMenu is "Import from Firefox": a plain item that imports from the
+// remembered or default profile, or, when profiles.ini lists more than one
+// profile, a submenu to pick the profile from. systray can't turn an item
+// into a submenu and back, so both exist and one of them is hidden.
+type firefoxMenu struct {
+	mu       sync.Mutex
+	single   *systray.MenuItem
+	parent   *systray.MenuItem
+	slots    []*systray.MenuItem
+	profiles []firefoxProfile
+
+	// selected receives the directory of a profile picked in the submenu.
+	selected chan string
+}
+
+func newFirefoxMenu() *firefoxMenu {
+	m := &firefoxMenu{
+		single:   systray.AddMenuItem(firefoxMenuTitle, "Read cookies from Firefox automatically"),
+		parent:   systray.AddMenuItem(firefoxMenuTitle, "Pick the Firefox profile to read cookies from"),
+		selected: make(chan string, 1),
+	}
+	m.parent.Hide()
+	for i := 0; i < maxFirefoxProfileItems; i++ {
+		item := m.parent.AddSubMenuItemCheckbox("", "", false)
+		item.Hide()
+		m.slots = append(m.slots, item)
+		go func(i int) {
+			for range item.ClickedCh {
+				m.mu.Lock()
+				var dir string
+				if i < len(m.profiles) {
+					dir = m.profiles[i].Dir
+				}
+				m.mu.Unlock()
+				if dir != "" {
+					m.selected <- dir
+				}
+			}
+		}(i)
+	}
+	return m
+}
+
+// load lists the profiles of the Firefox installation, checking the one
+// imports currently read from. Without Firefox the plain item stays.
+func (m *firefoxMenu) load() {
+	firefoxDir, err := findFirefoxProfilesDir()
+	if err != nil {
+		m.set(nil, "")
+		return
+	}
+	all, err := readProfilesIni(firefoxDir)
+	if err != nil {
+		firefoxLog.Warnf("Firefox profile menu: %v", err)
+	}
+	var profiles []firefoxProfile
+	for _, p := range all {
+		if p.Dir != "" {
+			profiles = append(profiles, p)
+		}
+	}
+	current := ""
+	if cfg, err := readConfigFile(configPath); err == nil {
+		current = strings.TrimSpace(cfg.FirefoxProfile)
+	}
+	if current == "" {
+		current, _ = findDefaultProfile(firefoxDir)
+	}
+	m.set(profiles, current)
+}
+
+// set shows profiles in the submenu with current checked, or only the
+// plain item if there is at most one profile.
+func (m *firefoxMenu) set(profiles []firefoxProfile, current string) {
+	m.mu.Lock()
+	m.profiles = profiles
+	m.mu.Unlock()
+	if len(profiles) > len(m.slots) {
+		firefoxLog.Infof("Firefox profile menu: showing %d of %d profiles", len(m.slots), len(profiles))
+	}
+	ui.do(func() {
+		for i, item := range m.slots {
+			if i >= len(profiles) {
+				item.Hide()
+				continue
+			}
+			item.SetTitle(truncate(profileLabel(profiles[i]), maxMenuLine))
+			item.SetTooltip(profiles[i].Dir)
+			if sameDir(profiles[i].Dir, current) {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+			item.Show()
+		}
+		if len(profiles) > 1 {
+			m.single.Hide()
+			m.parent.Show()
+		} else {
+			m.parent.Hide()
+			m.single.Show()
+		}
+	})
+}
+
+// profileLabel is a profile's menu line: its name and directory.
+func profileLabel(p firefoxProfile) string {
+	if p.Name == "" {
+		return filepath.Base(p.Dir)
+	}
+	return p.Name + " (" + filepath.Base(p.Dir) + ")"
+}
+
+// setTitle shows import feedback on whichever item is visible.
+func (m *firefoxMenu) setTitle(title string) {
+	showTitle(m.single, title)
+	showTitle(m.parent, title)
+}
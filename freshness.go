@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// minStaleAfter is the least age at which the Updated row warns that the
+// numbers are stale; at longer intervals it is three intervals.
+const minStaleAfter = 15 * time.Minute
+
+// staleAfter is the age past which the usage on screen is stale when
+// updates run every interval.
+func staleAfter(interval time.Duration) time.Duration {
+	return max(3*interval, minStaleAfter)
+}
+
+// updatedLine renders the row for the last successful update, e.g.
+// "Updated: 12:41 (3m ago)", or "Updated: 2h ago ⚠" once it is older than
+// stale. Empty before the first success.
+func updatedLine(at, now time.Time, stale time.Duration, accessible bool) string {
+	if at.IsZero() {
+		return ""
+	}
+	age := max(now.Sub(at), 0)
+	if age >= stale {
+		if accessible {
+			return "Warning: updated " + formatAge(age)
+		}
+		return "Updated: " + formatAge(age) + " " + mark(markWarning, false)
+	}
+	if accessible {
+		return fmt.Sprintf("Updated at %s, %s", at.Local().Format("15:04"), formatAge(age))
+	}
+	return fmt.Sprintf("Updated: %s (%s)", at.Local().Format("15:04"), formatAge(age))
+}
+
+// nextCheckLine renders the row for the next automatic update, e.g.
+// "Next check: in 2m (14:32)"; the time keeps it right between refreshes
+// of the menu.
+func nextCheckLine(wait time.Duration, now time.Time, accessible bool) string {
+	if wait < time.Minute {
+		// Due, or running: the Refresh item says so
+		if accessible {
+			return "Next check in less than a minute"
+		}
+		return "Next check: in <1m"
+	}
+	at := now.Add(wait).Local().Format("15:04")
+	if accessible {
+		return fmt.Sprintf("Next check in %s, at %s", spellDuration(wait), at)
+	}
+	return fmt.Sprintf("Next check: in %s (%s)", shortDuration(wait), at)
+}
+
+// timeRows renders the Updated and Next check rows from the update
+// machinery's shared state. usageMenu.apply calls it on every update and
+// on the per-minute refresh, so the relative times keep counting while
+// updates fail.
+func timeRows(now time.Time, accessible bool) (updated, next string) {
+	stale := staleAfter(scheduler.baseInterval())
+	if idlePaused.Load() {
+		// Old on purpose: no warning, and no check is due
+		stale = time.Duration(math.MaxInt64)
+		next = "Next check: when you're back"
+		if accessible {
+			next = "Next check when you're back"
+		}
+	}
+	updated = updatedLine(lastOutcome.lastSuccess(), now, stale, accessible)
+	if next != "" {
+		return updated, next
+	}
+	if at := scheduler.nextCheck(); !at.IsZero() && !monitoringPaused.Load() {
+		next = nextCheckLine(at.Sub(now), now, accessible)
+	}
+	return updated, next
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleAfter(t *testing.T) {
+	for _, tc := range []struct{ interval, want time.Duration }{
+		{time.Minute, 15 * time.Minute},
+		{5 * time.Minute, 15 * time.Minute},
+		{30 * time.Minute, 90 * time.Minute},
+	} {
+		if got := staleAfter(tc.interval); got != tc.want {
+			t.Errorf("staleAfter(%v) = %v, want %v", tc.interval, got, tc.want)
+		}
+	}
+}
+
+func TestUpdatedLine(t *testing.T) {
+	at := time.Date(2026, 5, 4, 12, 41, 0, 0, time.Local)
+	stale := 15 * time.Minute
+	for _, tc := range []struct {
+		name       string
+		at         time.Time
+		age        time.Duration
+		accessible bool
+		want       string
+	}{
+		{"never", time.Time{}, 0, false, ""},
+		{"fresh", at, 20 * time.Second, false, "Updated: 12:41 (just now)"},
+		{"minutes", at, 3 * time.Minute, false, "Updated: 12:41 (3m ago)"},
+		{"stale", at, 2 * time.Hour, false, "Updated: 2h ago ⚠"},
+		{"accessible", at, 3 * time.Minute, true, "Updated at 12:41, 3m ago"},
+		{"accessible stale", at, 2 * time.Hour, true, "Warning: updated 2h ago"},
+	} {
+		if got := updatedLine(tc.at, at.Add(tc.age), stale, tc.accessible); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNextCheckLine(t *testing.T) {
+	now := time.Date(2026, 5, 4, 14, 30, 0, 0, time.Local)
+	for _, tc := range []struct {
+		wait       time.Duration
+		accessible bool
+		want       string
+	}{
+		{2 * time.Minute, false, "Next check: in 2m (14:32)"},
+		{15 * time.Minute, true, "Next check in 15 minutes, at 14:45"},
+		{-5 * time.Second, false, "Next check: in <1m"},
+	} {
+		if got := nextCheckLine(tc.wait, now, tc.accessible); got != tc.want {
+			t.Errorf("nextCheckLine(%v) = %q, want %q", tc.wait, got, tc.want)
+		}
+	}
+}
+
+func TestUIRefreshReappliesState(t *testing.T) {
+	u := newUIUpdater()
+	applied := make(chan uiState, 4)
+	go u.run(func(prev, st uiState) { applied <- st })
+	defer u.stop()
+
+	u.publish(u.nextGeneration(), uiState{session: "Session: 12%"})
+	<-applied
+	u.refresh()
+	select {
+	case st := <-applied:
+		if st.session != "Session: 12%" {
+			t.Errorf("refresh applied %q", st.session)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("refresh didn't apply the state")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// headlessView is the updateView without a tray: updates still save state
+// and history, serve metrics, write status_file and run hooks, but show
+// nothing.
+type headlessView struct{}
+
+func (headlessView) apply(prev, st uiState) {}
+
+func (headlessView) setOrgs(orgs []Organization, current string) {}
+
+func (headlessView) accountViews(accounts []*Config, results []accountResult) []accountView {
+	return nil
+}
+
+// runHeadless runs the update loop without a tray, for servers that only
+// want its side effects, logging to stdout. SIGINT or SIGTERM cancels the
+// running update and exits.
+func runHeadless() int {
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	if c, err := readConfigFile(configPath); err == nil {
+		configureLogging(c) // so that journald gets JSON from the first line
+	}
+	uiLog.Infof("Starting %s %s without a tray", appName, currentBuild())
+	configLog.Infof("Config: %s", configPath)
+	restrictConfigMode(configPath)
+	if vars := envOverrides(); len(vars) > 0 {
+		uiLog.Infof("Credentials from the environment: %s", strings.Join(vars, ", "))
+	}
+	uiLog.Infof("State: %s", paths.stateDir)
+	logPolicy()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	view := headlessView{}
+	go ui.run(view.apply)
+	startUpdate, refreshNow, _ := updateStarters(view)
+	watchForUpdates(startUpdate, refreshNow, func(string) {})
+	go watchReleases(func(*releaseCheck) {}) // the log line is all there is to show
+
+	sig := <-stop
+	uiLog.Infof("Received %v - shutting down", sig)
+	stopUpdates()
+	closeLog()
+	return 0
+}
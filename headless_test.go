@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHeadlessUpdate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/usage") {
+			w.Write([]byte(`{"five_hour": {"utilization": 42}, "seven_day": {"utilization": 7}}`))
+			return
+		}
+		w.Write([]byte(`[{"uuid": "org-1", "name": "Personal"}]`))
+	}))
+	t.Cleanup(srv.Close)
+	cachedUsage.reset()
+	t.Cleanup(func() { cachedUsage.reset() })
+
+	savedPaths, savedConfig, savedUI, savedMetrics := paths, configPath, ui, usageMetrics
+	t.Cleanup(func() { paths, configPath, ui, usageMetrics = savedPaths, savedConfig, savedUI, savedMetrics })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	statusFile := filepath.Join(t.TempDir(), "status.json")
+	configPath = writeTestConfig(t, fmt.Sprintf(`{"session_key": "sk-ant-sid01-x", "org_id": "org-1", "api_base_url": %q, "status_file": %q}`, srv.URL, statusFile))
+	usageMetrics = &metricsStore{}
+	ui = newUIUpdater()
+	go ui.run(headlessView{}.apply)
+	t.Cleanup(ui.stop)
+
+	doUpdate(context.Background(), headlessView{})
+
+	data, err := os.ReadFile(statusFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"ok": true`) || !strings.Contains(string(data), `"utilization": 42`) {
+		t.Errorf("status file:\n%s", data)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+// componentHealth is the last reported state of an optional subsystem
+// (hooks, exporters, ...), shown under Diagnostics.
+type componentHealth struct {
+	OK     bool
+	Detail string
+	At     time.Time
+}
+
+// healthRegistry collects component health and mirrors it into menu rows.
+// Rows are created on first report, since systray can't remove items.
+type healthRegistry struct {
+	mu     sync.Mutex
+	parent *systray.MenuItem
+	state  map[string]componentHealth
+	items  map[string]*systray.MenuItem // the UI goroutine's only
+}
+
+var health = healthRegistry{
+	state: make(map[string]componentHealth),
+	items: make(map[string]*systray.MenuItem),
+}
+
+// attach sets the submenu that receives one row per component.
+func (h *healthRegistry) attach(parent *systray.MenuItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.parent = parent
+	for name, st := range h.state {
+		h.render(name, st)
+	}
+}
+
+// report records the state of component.
+func (h *healthRegistry) report(component string, ok bool, detail string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := componentHealth{OK: ok, Detail: detail, At: time.Now()}
+	h.state[component] = st
+	h.render(component, st)
+}
+
+// get returns the last reported state of component.
+func (h *healthRegistry) get(component string) (componentHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st, ok := h.state[component]
+	return st, ok
+}
+
+// components returns the names of the reported components, sorted.
+func (h *healthRegistry) components() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := make([]string, 0, len(h.state))
+	for name := range h.state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h *healthRegistry) render(component string, st componentHealth) {
+	if h.parent == nil {
+		return
+	}
+	m := mark(markOK, accessibleText.Load())
+	if !st.OK {
+		m = mark(markFailed, accessibleText.Load())
+	}
+	title := truncate(fmt.Sprintf("%s %s: %s (%s)", m, component, st.Detail, st.At.Format("15:04")), maxMenuLine)
+	parent := h.parent
+	ui.do(func() {
+		item, ok := h.items[component]
+		if !ok {
+			item = parent.AddSubMenuItem(title, "")
+			item.Disable()
+			h.items[component] = item
+			return
+		}
+		item.SetTitle(title)
+	})
+}
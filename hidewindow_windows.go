@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// hideWindow keeps console helpers (powershell, etc.) from flashing a window
+// when launched from the GUI-subsystem binary.
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
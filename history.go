@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+const (
+	// maxHistorySize is the size at which history.jsonl is renamed to
+	// history.1.jsonl (replacing the older one) and started over. At one
+	// line every five minutes that is several weeks.
+	maxHistorySize = 4 << 20
+	// historySummaryDays is how far back the History submenu looks.
+	historySummaryDays = 7
+	// historyHighPct is the session utilization the History submenu counts
+	// climbs past.
+	historyHighPct = 90
+)
+
+// historyLog appends every successful reading to history.jsonl, in the
+// format replay reads, and keeps the last week of it in memory for the
+// History submenu.
+type historyLog struct {
+	mu     sync.Mutex
+	loaded bool
+	recent []historySample // oldest first, within historySummaryDays
+	month  []spendPoint    // extra usage spend this (UTC) month, oldest first
+	failed bool            // the last write failed; logged once
+}
+
+var usageHistory = &historyLog{}
+
+// load reads the recent samples and this month's spend from both history
+// files once. Damaged lines, such as one cut short by a crash, are skipped.
+func (h *historyLog) load(now time.Time) {
+	if h.loaded {
+		return
+	}
+	h.loaded = true
+	since := now.AddDate(0, 0, -historySummaryDays)
+	month := monthStart(now)
+	for _, path := range []string{paths.oldHistoryFile(), paths.historyFile()} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64<<10), 4<<20)
+		for sc.Scan() {
+			var s historySample
+			if json.Unmarshal([]byte(strings.TrimSpace(sc.Text())), &s) != nil {
+				continue
+			}
+			if (s.Usage != nil || s.Event != "") && !s.Time.Before(since) {
+				h.recent = append(h.recent, s)
+			}
+			if p, ok := spendPointOf(s); ok && !p.at.Before(month) {
+				h.month = append(h.month, p)
+			}
+		}
+		f.Close()
+	}
+}
+
+// record appends usage, read at now, to the history, after the presence
+// changes it confirmed. A file that can't be written (say, locked by an
+// editor) is reported under Diagnostics and the reading is kept in memory
+// only.
+func (h *historyLog) record(usage *UsageResponse, changes []bucketChange, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load(now)
+	var lines []historySample
+	for _, c := range changes {
+		lines = append(lines, c.sample(now.UTC()))
+	}
+	lines = append(lines, historySample{Time: now.UTC(), Usage: usage})
+	h.recent = append(h.recent, lines...)
+	since := now.AddDate(0, 0, -historySummaryDays)
+	for len(h.recent) > 0 && h.recent[0].Time.Before(since) {
+		h.recent = h.recent[1:]
+	}
+	if p, ok := spendPointOf(lines[len(lines)-1]); ok {
+		h.month = append(h.month, p)
+	}
+	h.month = spendSince(h.month, monthStart(now))
+
+	var err error
+	for _, s := range lines {
+		if err = appendHistory(s); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		if !h.failed {
+			uiLog.Warnf("Writing usage history: %v", err)
+		}
+		h.failed = true
+		health.report("History", false, err.Error())
+		return
+	}
+	if h.failed {
+		uiLog.Infof("Usage history is being written again")
+		h.failed = false
+		health.report("History", true, "writing again")
+	}
+}
+
+// appendHistory writes one line to history.jsonl, rotating it first if it
+// has grown past maxHistorySize.
+func appendHistory(s historySample) error {
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := paths.historyFile()
+	if fi, err := os.Stat(path); err == nil && fi.Size()+int64(len(line)) >= maxHistorySize {
+		// If the file is open elsewhere the rename may fail; appending
+		// still works and the next write tries again
+		if err := os.Rename(path, paths.oldHistoryFile()); err != nil {
+			uiLog.Warnf("Rotating usage history: %v", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, werr := f.Write(append(line, '\n'))
+	f.Sync() //nolint — best-effort; a lost line only shortens the history
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	return werr
+}
+
+// historyBuckets picks a bucket out of a reading by its key in the API
+// response.
+var historyBuckets = map[string]func(*UsageResponse) *UsageBucket{
+	"five_hour":        func(u *UsageResponse) *UsageBucket { return &u.FiveHour },
+	"seven_day":        func(u *UsageResponse) *UsageBucket { return &u.SevenDay },
+	"seven_day_opus":   func(u *UsageResponse) *UsageBucket { return u.SevenDayOpus },
+	"seven_day_sonnet": func(u *UsageResponse) *UsageBucket { return u.SevenDaySonnet },
+}
+
+// historyPoint is one reading of a bucket in the history.
+type historyPoint struct {
+	at     time.Time
+	bucket *UsageBucket
+}
+
+// bucketSeries returns the readings of bucket in samples (oldest first)
+// from since on, split where the bucket was removed: a removal ends a
+// series and the next reading starts another. A reading that lacks the
+// bucket without a removal, a poll while it wasn't confirmed yet, is a
+// gap, not a zero. removed reports that the bucket is gone: its last
+// series ended with a removal.
+func bucketSeries(samples []historySample, bucket string, since time.Time) (series [][]historyPoint, removed bool) {
+	pick := historyBuckets[bucket]
+	var cur []historyPoint
+	end := func() {
+		if len(cur) > 0 {
+			series = append(series, cur)
+			cur = nil
+		}
+	}
+	for _, s := range samples {
+		switch {
+		case s.Event == historyBucketRemoved && s.Bucket == bucket:
+			end()
+			removed = true
+		case s.Usage != nil && !s.Time.Before(since):
+			if b := pick(s.Usage); b != nil {
+				// A restart forgets the presence, so a bucket can
+				// come back without an added event
+				cur = append(cur, historyPoint{s.Time, b})
+				removed = false
+			}
+		}
+	}
+	end()
+	return series, removed
+}
+
+// monthSpend returns the extra usage spend recorded this (UTC) month,
+// oldest first.
+func (h *historyLog) monthSpend(now time.Time) []spendPoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load(now)
+	return slices.Clone(spendSince(h.month, monthStart(now)))
+}
+
+// historyView is what the History submenu shows.
+type historyView struct {
+	sessionPeak, weeklyPeak, sessionHigh string
+	modelPeaks                           string // empty hides the row
+}
+
+// summary renders the History submenu from the samples in memory.
+func (h *historyLog) summary(now time.Time) historyView {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load(now)
+	return summarizeHistory(h.recent, now)
+}
+
+// summarizeHistory computes the History rows: the highest session
+// utilization today (local time), the highest weekly one and how often the
+// session went past historyHighPct in the last historySummaryDays days.
+//
+// The per-model buckets get a peak each over the readings they have; one
+// that was removed since says so rather than counting as zero.
+func summarizeHistory(samples []historySample, now time.Time) historyView {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	since := now.AddDate(0, 0, -historySummaryDays)
+
+	sessionPeak := -1.0
+	highs := 0
+	high := false
+	sessions, _ := bucketSeries(samples, "five_hour", since)
+	for _, series := range sessions {
+		for _, p := range series {
+			session := p.bucket.Utilization
+			if !p.at.Before(today) && session > sessionPeak {
+				sessionPeak = session
+			}
+			if session > historyHighPct && !high {
+				highs++
+			}
+			high = session > historyHighPct
+		}
+	}
+	weeklyPeak, _ := seriesPeak(samples, "seven_day", since)
+
+	var models []string
+	for _, b := range []struct{ key, name string }{{"seven_day_opus", "Opus"}, {"seven_day_sonnet", "Sonnet"}} {
+		peak, removed := seriesPeak(samples, b.key, since)
+		switch {
+		case peak < 0:
+		case removed:
+			models = append(models, fmt.Sprintf("%s %d%% (removed)", b.name, int(peak)))
+		default:
+			models = append(models, fmt.Sprintf("%s %d%%", b.name, int(peak)))
+		}
+	}
+
+	v := historyView{
+		sessionPeak: "Session peak today: n/a",
+		weeklyPeak:  fmt.Sprintf("Weekly peak (%d days): n/a", historySummaryDays),
+		sessionHigh: fmt.Sprintf("Session over %d%%: %s in %d days", historyHighPct, plural(highs, "time"), historySummaryDays),
+	}
+	if sessionPeak >= 0 {
+		v.sessionPeak = fmt.Sprintf("Session peak today: %d%%", int(sessionPeak))
+	}
+	if weeklyPeak >= 0 {
+		v.weeklyPeak = fmt.Sprintf("Weekly peak (%d days): %d%%", historySummaryDays, int(weeklyPeak))
+	}
+	if len(models) > 0 {
+		v.modelPeaks = truncate("Model peaks: "+strings.Join(models, ", "), maxMenuLine)
+	}
+	return v
+}
+
+// seriesPeak is the highest utilization of bucket from since on, -1
+// without a reading, and whether the bucket has been removed.
+func seriesPeak(samples []historySample, bucket string, since time.Time) (float64, bool) {
+	peak := -1.0
+	all, removed := bucketSeries(samples, bucket, since)
+	for _, series := range all {
+		for _, p := range series {
+			peak = max(peak, p.bucket.Utilization)
+		}
+	}
+	return peak, removed
+}
+
+// historyMenu is the "History" submenu: a summary of history.jsonl and an
+// item to open it.
+type historyMenu struct {
+	parent      *systray.MenuItem
+	sessionPeak *systray.MenuItem
+	weeklyPeak  *systray.MenuItem
+	sessionHigh *systray.MenuItem
+	modelPeaks  *systray.MenuItem
+	open        *systray.MenuItem
+}
+
+func newHistoryMenu() *historyMenu {
+	parent := systray.AddMenuItem("History", "Usage recorded in history.jsonl")
+	m := &historyMenu{
+		parent:      parent,
+		sessionPeak: parent.AddSubMenuItem("Session peak today: ...", ""),
+		weeklyPeak:  parent.AddSubMenuItem("Weekly peak: ...", ""),
+		sessionHigh: parent.AddSubMenuItem("Session over 90%: ...", ""),
+		modelPeaks:  parent.AddSubMenuItem("", "Weekly per-model peaks"),
+		open:        parent.AddSubMenuItem("Open history", "Open history.jsonl, one line per reading"),
+	}
+	for _, item := range []*systray.MenuItem{m.sessionPeak, m.weeklyPeak, m.sessionHigh, m.modelPeaks} {
+		item.Disable()
+	}
+	m.modelPeaks.Hide()
+	return m
+}
+
+// apply shows v; called on the UI goroutine only. The zero view, from a
+// snapshot that didn't compute one, leaves the rows as they are.
+func (m *historyMenu) apply(v historyView) {
+	if v == (historyView{}) {
+		return
+	}
+	setTitle(m.sessionPeak, v.sessionPeak)
+	setTitle(m.weeklyPeak, v.weeklyPeak)
+	setTitle(m.sessionHigh, v.sessionHigh)
+	showRow(m.modelPeaks, v.modelPeaks)
+}
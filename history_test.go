@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecord(t *testing.T) {
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	now := time.Date(2026, 5, 4, 15, 0, 0, 0, time.Local)
+	// A line cut short by a crash and one from before the summary window
+	old := `{"time":"` + now.AddDate(0, 0, -8).Format(time.RFC3339) + `","usage":{"five_hour":{"utilization":99},"seven_day":{"utilization":99}}}` + "\n"
+	if err := os.WriteFile(paths.historyFile(), []byte(old+`{"time":"2026-05-04T`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &historyLog{}
+	for i, pct := range []float64{40, 92, 95, 60, 91} {
+		h.record(&UsageResponse{FiveHour: UsageBucket{Utilization: pct}, SevenDay: UsageBucket{Utilization: 30 + pct/10}}, nil, now.Add(time.Duration(i)*time.Hour))
+	}
+	v := h.summary(now.Add(5 * time.Hour))
+	want := historyView{
+		sessionPeak: "Session peak today: 95%",
+		weeklyPeak:  "Weekly peak (7 days): 39%",
+		sessionHigh: "Session over 90%: 2 times in 7 days",
+	}
+	if v != want {
+		t.Errorf("summary = %+v\nwant %+v", v, want)
+	}
+
+	data, _ := os.ReadFile(paths.historyFile())
+	if n := strings.Count(string(data), "\n"); n != 7 {
+		t.Errorf("history has %d lines, want 7", n)
+	}
+
+	// Rotation: a full file moves to history.1.jsonl
+	if err := os.WriteFile(paths.historyFile(), bytes.Repeat([]byte("x"), maxHistorySize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.record(&UsageResponse{}, nil, now.Add(6*time.Hour))
+	if fi, err := os.Stat(paths.oldHistoryFile()); err != nil || fi.Size() != maxHistorySize {
+		t.Errorf("history.1.jsonl: %v", err)
+	}
+	if samples, err := readHistory(paths.historyFile()); err != nil || len(samples) != 1 {
+		t.Errorf("after rotation: %d samples, %v", len(samples), err)
+	}
+}
+
+func TestHistoryBucketPresence(t *testing.T) {
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	start := time.Date(2026, 5, 4, 12, 0, 0, 0, time.Local)
+	h := &historyLog{}
+	presence := bucketPresence{name: "Sonnet"}
+	var now time.Time
+	poll := 0
+	observe := func(sonnet ...float64) {
+		for _, pct := range sonnet {
+			u := &UsageResponse{FiveHour: UsageBucket{Utilization: 10}, SevenDay: UsageBucket{Utilization: 20}}
+			if pct >= 0 {
+				u.SevenDaySonnet = &UsageBucket{Utilization: pct}
+			}
+			_, ev := presence.observe(u.SevenDaySonnet)
+			now = start.Add(time.Duration(poll) * 5 * time.Minute)
+			h.record(u, appendBucketChange(nil, "seven_day_sonnet", ev), now)
+			poll++
+		}
+	}
+	const absent = -1
+
+	// Missing at first, then added: confirmed on the third reading
+	observe(absent, 5, 6, 7, 8, 9)
+	// Removed, confirmed on the third poll without it
+	observe(absent, absent, absent, absent)
+	if v := h.summary(now); v.modelPeaks != "Model peaks: Sonnet 9% (removed)" {
+		t.Errorf("after the removal: %q", v.modelPeaks)
+	}
+	if _, ok := estimatePace(h.recent, "seven_day_sonnet", 2*time.Hour, now); ok {
+		t.Error("pace of a removed bucket")
+	}
+	// And back
+	observe(20, 22, 24)
+
+	samples, err := readHistory(paths.historyFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, s := range samples {
+		if s.Event != "" {
+			events = append(events, s.Event+" "+s.Bucket)
+		}
+	}
+	want := []string{"bucket_added seven_day_sonnet", "bucket_removed seven_day_sonnet", "bucket_added seven_day_sonnet"}
+	if !slices.Equal(events, want) {
+		t.Errorf("events %q, want %q", events, want)
+	}
+
+	series, removed := bucketSeries(samples, "seven_day_sonnet", time.Time{})
+	if removed || len(series) != 2 || len(series[0]) != 5 || len(series[1]) != 3 {
+		t.Fatalf("series %v, removed %v", series, removed)
+	}
+	for _, s := range series {
+		for _, p := range s {
+			if p.bucket.Utilization == 0 {
+				t.Errorf("a zero reading at %s", p.at)
+			}
+		}
+	}
+
+	if v := summarizeHistory(samples, now); v.modelPeaks != "Model peaks: Sonnet 24%" {
+		t.Errorf("after coming back: %q", v.modelPeaks)
+	}
+	// Only the readings since it came back count: 4 points in 10 minutes
+	p, ok := estimatePace(samples, "seven_day_sonnet", 2*time.Hour, now)
+	if !ok || p.utilization != 24 || p.toLimit != 190*time.Minute {
+		t.Errorf("pace after coming back: %+v, %v", p, ok)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// hookTimeout bounds how long an on_update_command may run.
+var hookTimeout = 30 * time.Second
+
+// hookWaitDelay is how long a timed-out hook's output may stay open after
+// the shell is killed, as a program it started can hold on to it.
+const hookWaitDelay = 2 * time.Second
+
+// hookRunning guards against overlapping on_update_command runs.
+var hookRunning atomic.Bool
+
+// runUpdateHook starts cfg.OnUpdateCommand in the background with the status
+// JSON on stdin and the key numbers in CLAUDE_* environment variables.
+// If the previous run is still going, this update is skipped.
+func runUpdateHook(cfg *Config, st *statusDoc) {
+	if cfg == nil || cfg.OnUpdateCommand == "" {
+		return
+	}
+	if !hookRunning.CompareAndSwap(false, true) {
+		uiLog.Warnf("on_update_command still running, skipping this update")
+		health.report("on_update_command", false, "skipped, previous run still active")
+		return
+	}
+
+	go func() {
+		defer hookRunning.Store(false)
+		if err := execHook(cfg.OnUpdateCommand, st); err != nil {
+			uiLog.Warnf("on_update_command failed: %v", err)
+			health.report("on_update_command", false, err.Error())
+			return
+		}
+		health.report("on_update_command", true, "exit 0")
+	}()
+}
+
+func execHook(command string, st *statusDoc) error {
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	stderr, err := runShell(command, payload, hookEnv(st))
+	if err != nil && stderr != "" {
+		return fmt.Errorf("%v: %s", err, truncate(stderr, 200))
+	}
+	return err
+}
+
+// runShell runs command through the platform shell with stdin and the
+// extra environment variables env, for at most hookTimeout, and returns
+// what it wrote to stderr. A command that exits non-zero returns an
+// *exec.ExitError.
+func runShell(command string, stdin []byte, env []string) (stderr string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	hideWindow(cmd)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.WaitDelay = hookWaitDelay
+	var buf bytes.Buffer
+	cmd.Stderr = &buf
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("timed out after %v", hookTimeout)
+	}
+	return strings.TrimSpace(buf.String()), err
+}
+
+// shellCommand runs command through the platform shell so that pipes and
+// redirections in the configured string work.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// hookEnv exposes the key numbers for shell one-liners. Percentages are
+// utilization rounded down; absent buckets are left unset.
+func hookEnv(st *statusDoc) []string {
+	env := []string{fmt.Sprintf("CLAUDE_UPDATE_OK=%t", st.OK)}
+	if st.Error != "" {
+		env = append(env, "CLAUDE_ERROR="+st.Error)
+	}
+	add := func(name string, b *statusBucket) {
+		if b != nil {
+			env = append(env, fmt.Sprintf("CLAUDE_%s_PCT=%d", name, int(b.Utilization)))
+			env = append(env, fmt.Sprintf("CLAUDE_%s_RESETS_AT=%s", name, b.ResetsAt))
+		}
+	}
+	add("SESSION", st.Session)
+	add("WEEKLY", st.Weekly)
+	add("OPUS", st.Opus)
+	add("SONNET", st.Sonnet)
+	return env
+}
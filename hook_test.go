@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHook returns a command line for the platform shell that saves its
+// stdin to stdin.json and the CLAUDE_* variables to env.txt in dir, then
+// runs tail.
+func fakeHook(dir, tail string) string {
+	if runtime.GOOS == "windows" {
+		return `more > "` + filepath.Join(dir, "stdin.json") + `" & set CLAUDE_ > "` + filepath.Join(dir, "env.txt") + `" & ` + tail
+	}
+	return `cat > '` + filepath.Join(dir, "stdin.json") + `'; env | grep ^CLAUDE_ > '` + filepath.Join(dir, "env.txt") + `'; ` + tail
+}
+
+// shellLine picks the command for the platform shell.
+func shellLine(windows, sh string) string {
+	if runtime.GOOS == "windows" {
+		return windows
+	}
+	return sh
+}
+
+func hookStatus() *statusDoc {
+	return &statusDoc{
+		OK:      true,
+		Session: &statusBucket{Utilization: 42.7, Remaining: 57.3, ResetsAt: "2026-10-17T15:00:00Z"},
+		Weekly:  &statusBucket{Utilization: 63, Remaining: 37},
+	}
+}
+
+func TestExecHookInput(t *testing.T) {
+	dir := t.TempDir()
+	if err := execHook(fakeHook(dir, shellLine("exit 0", "exit 0")), hookStatus()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got statusDoc
+	b, err := os.ReadFile(filepath.Join(dir, "stdin.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("stdin is not the status JSON: %v\n%s", err, b)
+	}
+	if got.Session == nil || got.Session.Utilization != 42.7 || got.Weekly == nil || got.Weekly.Utilization != 63 {
+		t.Errorf("stdin status = %s", b)
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := strings.ReplaceAll(string(b), "\r\n", "\n")
+	for _, want := range []string{
+		"CLAUDE_UPDATE_OK=true\n",
+		"CLAUDE_SESSION_PCT=42\n",
+		"CLAUDE_SESSION_RESETS_AT=2026-10-17T15:00:00Z\n",
+		"CLAUDE_WEEKLY_PCT=63\n",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("environment lacks %q:\n%s", want, env)
+		}
+	}
+	if strings.Contains(env, "CLAUDE_OPUS_PCT") {
+		t.Errorf("absent bucket set in the environment:\n%s", env)
+	}
+}
+
+func TestExecHookFailure(t *testing.T) {
+	err := execHook(shellLine("echo no database 1>&2 & exit 3", "echo no database >&2; exit 3"), hookStatus())
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "no database") {
+		t.Errorf("execHook error = %v, want exit status 3 with stderr", err)
+	}
+}
+
+func TestExecHookTimeout(t *testing.T) {
+	saved := hookTimeout
+	hookTimeout = 200 * time.Millisecond
+	defer func() { hookTimeout = saved }()
+
+	start := time.Now()
+	err := execHook(shellLine("ping -n 30 127.0.0.1 > NUL", "sleep 30"), hookStatus())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("execHook error = %v, want a timeout", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("timed-out hook held up for %v", d)
+	}
+}
+
+// waitHook waits for the background on_update_command run to finish.
+func waitHook(t *testing.T) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); hookRunning.Load(); {
+		if time.Now().After(deadline) {
+			t.Fatal("on_update_command still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunUpdateHookHealth(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		ok      bool
+		detail  string
+	}{
+		{shellLine("exit 0", "exit 0"), true, "exit 0"},
+		{shellLine("exit 4", "exit 4"), false, "exit status 4"},
+	} {
+		runUpdateHook(&Config{OnUpdateCommand: tc.command}, hookStatus())
+		waitHook(t)
+		st, ok := health.get("on_update_command")
+		if !ok || st.OK != tc.ok || !strings.Contains(st.Detail, tc.detail) {
+			t.Errorf("%q: health %+v, want ok %v with %q", tc.command, st, tc.ok, tc.detail)
+		}
+	}
+}
+
+func TestRunUpdateHookSkipsWhileRunning(t *testing.T) {
+	dir := t.TempDir()
+	hookRunning.Store(true)
+	runUpdateHook(&Config{OnUpdateCommand: fakeHook(dir, "exit 0")}, hookStatus())
+	hookRunning.Store(false)
+
+	if st, _ := health.get("on_update_command"); st.OK || !strings.Contains(st.Detail, "skipped") {
+		t.Errorf("health %+v, want the update skipped", st)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stdin.json")); !os.IsNotExist(err) {
+		t.Errorf("hook ran while the previous run was active: %v", err)
+	}
+}
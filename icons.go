@@ -1,4 +1,117 @@
 package main
 
-// iconGray is used while loading or on error.
-var iconGray = makeGrayIcon()
+import (
+	"image"
+	"sync"
+)
+
+// iconGray is shown while loading.
+var iconGray = makeStatusIcon(statusLoading, nil)
+
+// iconStatus is what an icon says besides the usage numbers.
+type iconStatus int
+
+const (
+	statusUsage       iconStatus = iota // just the numbers
+	statusLoading                       // plain gray, nothing known yet
+	statusConfigError                   // gray with a large "!"
+	statusAPIError                      // the last usage icon dimmed, with a small "!"
+	statusPaused                        // gray with a large "‖"
+)
+
+// iconKey identifies an icon by what it is drawn from: for the usage icons
+// from makeIcon and makeCompactIcon their arguments, the split icon's three
+// percentages or a compact icon's letter and percentage, and for the
+// status icons from makeStatusIcon the status and the usage icon it dims.
+// arrowA and arrowB are the trend arrows of the buckets a and b show,
+// alert the critical alert's mark.
+type iconKey struct {
+	status         iconStatus
+	letter         string // "" for the split icon
+	a, b, c        int
+	used           bool
+	palette        iconPalette
+	arrowA, arrowB iconArrow
+	alert          iconAlert
+}
+
+// render draws the icon k stands for at size x size pixels.
+func (k iconKey) render(size int) *image.RGBA {
+	switch k.status {
+	case statusLoading:
+		return renderGrayIcon(size)
+	case statusConfigError:
+		return renderAlertIcon(size)
+	case statusPaused:
+		return renderPausedIcon(size)
+	case statusAPIError:
+		last := k
+		last.status = statusUsage
+		last.arrowA, last.arrowB, last.alert = arrowNone, arrowNone, alertNone
+		return renderDimmedIcon(size, last)
+	}
+	var img *image.RGBA
+	if k.letter != "" {
+		img = renderCompactIcon(size, k.letter, k.a, k.used, k.palette)
+	} else {
+		img = renderSplitIcon(size, k.a, k.b, k.c, k.used, k.palette)
+	}
+	drawArrows(img, k)
+	drawAlert(img, k.alert)
+	return img
+}
+
+// icon returns the encoded icon k stands for, from the cache if it has
+// been rendered before.
+func (k iconKey) icon() []byte {
+	return renderedIcons.get(k, func() []byte { return encodeIcon(k.render) })
+}
+
+// makeStatusIcon returns the icon for a status other than statusUsage. An
+// API error shows
+// lastKnown, the last usage icon, dimmed; before any usage is known
+// (lastKnown nil) it looks like a config error.
+func makeStatusIcon(status iconStatus, lastKnown *iconKey) []byte {
+	key := iconKey{status: status}
+	if status == statusAPIError {
+		if lastKnown == nil {
+			key.status = statusConfigError
+		} else {
+			key = *lastKnown
+			key.status = statusAPIError
+		}
+	}
+	return key.icon()
+}
+
+// maxCachedIcons bounds the icon cache. Only a handful of icons are in
+// use at a time; a full cache is emptied rather than tracking which are.
+const maxCachedIcons = 256
+
+// iconCache keeps rendered icons, so an update with the same numbers as
+// the last doesn't rasterize and encode them again, and hands back the
+// same bytes for them.
+type iconCache struct {
+	mu    sync.Mutex
+	icons map[iconKey][]byte
+}
+
+var renderedIcons iconCache
+
+// get returns the icon for key, rendering it with render on a miss.
+func (c *iconCache) get(key iconKey, render func() []byte) []byte {
+	c.mu.Lock()
+	icon, ok := c.icons[key]
+	c.mu.Unlock()
+	if ok {
+		return icon
+	}
+	icon = render()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.icons == nil || len(c.icons) >= maxCachedIcons {
+		c.icons = make(map[iconKey][]byte)
+	}
+	c.icons[key] = icon
+	return icon
+}
@@ -6,11 +6,13 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"runtime"
 )
 
-// digitFont maps digits '0'..'9' and '%' to a 5x7 pixel bitmap.
+// digitFont maps digits '0'..'9', '%', the uppercase letters A-Z and the
+// signs '!', '>', '+', '-' and '‖' to a 5x7 pixel bitmap.
 // Each [7]uint8 is 7 rows; within each row bit 4 = leftmost pixel.
 var digitFont = map[rune][7]uint8{
 	'0': {0b01110, 0b10001, 0b10011, 0b10101, 0b11001, 0b10001, 0b01110},
@@ -24,6 +26,37 @@ var digitFont = map[rune][7]uint8{
 	'8': {0b01110, 0b10001, 0b10001, 0b01110, 0b10001, 0b10001, 0b01110},
 	'9': {0b01110, 0b10001, 0b10001, 0b01111, 0b00001, 0b00010, 0b01100},
 	'%': {0b11000, 0b11001, 0b00010, 0b00100, 0b01000, 0b10011, 0b00011},
+	'!': {0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00000, 0b00100},
+	'>': {0b01000, 0b00100, 0b00010, 0b00001, 0b00010, 0b00100, 0b01000},
+	'+': {0b00000, 0b00100, 0b00100, 0b11111, 0b00100, 0b00100, 0b00000},
+	'-': {0b00000, 0b00000, 0b00000, 0b11111, 0b00000, 0b00000, 0b00000},
+	'‖': {0b11011, 0b11011, 0b11011, 0b11011, 0b11011, 0b11011, 0b11011},
+	'A': {0b01110, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	'B': {0b11110, 0b10001, 0b10001, 0b11110, 0b10001, 0b10001, 0b11110},
+	'C': {0b01110, 0b10001, 0b10000, 0b10000, 0b10000, 0b10001, 0b01110},
+	'D': {0b11100, 0b10010, 0b10001, 0b10001, 0b10001, 0b10010, 0b11100},
+	'E': {0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b11111},
+	'F': {0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b10000},
+	'G': {0b01110, 0b10001, 0b10000, 0b10111, 0b10001, 0b10001, 0b01111},
+	'H': {0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	'I': {0b01110, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	'J': {0b00111, 0b00010, 0b00010, 0b00010, 0b00010, 0b10010, 0b01100},
+	'K': {0b10001, 0b10010, 0b10100, 0b11000, 0b10100, 0b10010, 0b10001},
+	'L': {0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b11111},
+	'M': {0b10001, 0b11011, 0b10101, 0b10101, 0b10001, 0b10001, 0b10001},
+	'N': {0b10001, 0b11001, 0b10101, 0b10011, 0b10001, 0b10001, 0b10001},
+	'O': {0b01110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	'P': {0b11110, 0b10001, 0b10001, 0b11110, 0b10000, 0b10000, 0b10000},
+	'Q': {0b01110, 0b10001, 0b10001, 0b10001, 0b10101, 0b10010, 0b01101},
+	'R': {0b11110, 0b10001, 0b10001, 0b11110, 0b10100, 0b10010, 0b10001},
+	'S': {0b01111, 0b10000, 0b10000, 0b01110, 0b00001, 0b00001, 0b11110},
+	'T': {0b11111, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100},
+	'U': {0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	'V': {0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01010, 0b00100},
+	'W': {0b10001, 0b10001, 0b10001, 0b10101, 0b10101, 0b10101, 0b01010},
+	'X': {0b10001, 0b10001, 0b01010, 0b00100, 0b01010, 0b10001, 0b10001},
+	'Y': {0b10001, 0b10001, 0b01010, 0b00100, 0b00100, 0b00100, 0b00100},
+	'Z': {0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b11111},
 }
 
 const (
@@ -34,6 +67,48 @@ const (
 	glyphGap = 1 * fontScale
 )
 
+// iconPalette is how an icon is drawn: on a background colored by level,
+// or monochrome on a transparent background for menu bars and taskbars
+// that the colored squares clash with.
+type iconPalette int
+
+const (
+	paletteColor iconPalette = iota
+	paletteWhite             // mono, white digits for dark bars
+	paletteBlack             // mono, black digits for light bars and macOS templates
+)
+
+// ink is the color of a mono palette's digits.
+func (p iconPalette) ink() color.RGBA {
+	if p == paletteBlack {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+}
+
+// monoBarHeight is the height of the level bar under a mono icon's
+// digits, shown when a bucket is in the warning or critical band.
+const monoBarHeight = 6
+
+// monoNeedsBar reports whether a mono icon marks remaining with a bar.
+func monoNeedsBar(remaining int) bool {
+	return remaining < 50
+}
+
+// fillOver composites c over the pixels of r, so translucent colors blend
+// with what is already drawn, transparency included.
+func fillOver(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r, &image.Uniform{C: c}, image.Point{}, draw.Over)
+}
+
+// drawLevelBar draws a mono icon's level bar along the bottom between x0
+// and x1, if remaining is low enough to need one.
+func drawLevelBar(img *image.RGBA, x0, x1, remaining int) {
+	if size := img.Rect.Dy(); monoNeedsBar(remaining) {
+		fillOver(img, image.Rect(x0, size-barHeight(size), x1, size), levelColor(remaining))
+	}
+}
+
 // levelColor returns the background color for a given remaining-% value.
 // green >= 50%, amber 20-49%, red < 20%.
 func levelColor(remaining int) color.RGBA {
@@ -47,17 +122,19 @@ func levelColor(remaining int) color.RGBA {
 	}
 }
 
-// textWidth returns the pixel width of s rendered with the scaled bitmap font.
-func textWidth(s string) int {
+// textWidthScaled returns the pixel width of s rendered with the bitmap
+// font at font pixels of scale x scale.
+func textWidthScaled(s string, scale int) int {
 	if len(s) == 0 {
 		return 0
 	}
-	return len(s)*(glyphW+glyphGap) - glyphGap
+	return len(s)*6*scale - scale
 }
 
-// startXInHalf returns the x offset to center text in a half of the icon.
-func startXInHalf(halfW int, s string) int {
-	x := (halfW - textWidth(s)) / 2
+// startXInHalf returns the x offset to center text drawn at scale in a
+// half of the icon.
+func startXInHalf(halfW int, s string, scale int) int {
+	x := (halfW - textWidthScaled(s, scale)) / 2
 	if x < 0 {
 		x = 0
 	}
@@ -67,36 +144,42 @@ func startXInHalf(halfW int, s string) int {
 // drawTextOutlined renders s onto img at (x, y) with a dark outline for contrast.
 // Draws dark outline at 4 cardinal offsets, then white text on top.
 func drawTextOutlined(img *image.RGBA, s string, x, y int) {
+	drawTextOutlinedScaled(img, s, x, y, fontScale)
+}
+
+// drawTextOutlinedScaled is drawTextOutlined for font pixels of scale x scale.
+func drawTextOutlinedScaled(img *image.RGBA, s string, x, y, scale int) {
 	outline := color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0xc0}
 	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
 
 	// Outline offsets (N, S, E, W)
 	offsets := [][2]int{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
 	for _, off := range offsets {
-		drawTextRaw(img, s, x+off[0], y+off[1], outline)
+		drawTextRaw(img, s, x+off[0], y+off[1], scale, outline)
 	}
 	// White foreground
-	drawTextRaw(img, s, x, y, white)
+	drawTextRaw(img, s, x, y, scale, white)
 }
 
-// drawTextRaw renders s onto img at (x, y) using the given color and 2x scale.
-func drawTextRaw(img *image.RGBA, s string, x, y int, c color.RGBA) {
+// drawTextRaw renders s onto img at (x, y) using the given color, with each
+// font pixel drawn as a scale x scale block.
+func drawTextRaw(img *image.RGBA, s string, x, y, scale int, c color.RGBA) {
 	cx := x
+	advance := 6 * scale // 5 pixels wide plus 1 pixel gap
 	for _, ch := range s {
 		glyph, ok := digitFont[ch]
 		if !ok {
-			cx += glyphW + glyphGap
+			cx += advance
 			continue
 		}
 		for row, bits := range glyph {
 			for col := 0; col < 5; col++ {
 				if bits&(1<<uint(4-col)) != 0 {
-					// Draw 2x2 block for each font pixel
-					for dy := 0; dy < fontScale; dy++ {
-						for dx := 0; dx < fontScale; dx++ {
-							px := cx + col*fontScale + dx
-							py := y + row*fontScale + dy
-							if px >= 0 && px < iconSize && py >= 0 && py < iconSize {
+					for dy := 0; dy < scale; dy++ {
+						for dx := 0; dx < scale; dx++ {
+							px := cx + col*scale + dx
+							py := y + row*scale + dy
+							if image.Pt(px, py).In(img.Rect) {
 								img.SetRGBA(px, py, c)
 							}
 						}
@@ -104,11 +187,22 @@ func drawTextRaw(img *image.RGBA, s string, x, y int, c color.RGBA) {
 				}
 			}
 		}
-		cx += glyphW + glyphGap
+		cx += advance
 	}
 }
 
-// formatPct formats a remaining percentage for display.
+// iconNumber is the percentage an icon shows for a bucket with remaining
+// percent left: remaining itself, or with used the utilization, both
+// clamped to 0-100. Colors always follow remaining.
+func iconNumber(remaining int, used bool) int {
+	remaining = max(0, min(remaining, 100))
+	if used {
+		return 100 - remaining
+	}
+	return remaining
+}
+
+// formatPct formats a percentage for display.
 // 0-99 -> "N%", 100 -> "100" (no % to save space).
 func formatPct(pct int) string {
 	if pct < 0 {
@@ -123,120 +217,321 @@ func formatPct(pct int) string {
 	return fmt.Sprintf("%d%%", pct)
 }
 
-// wrapInICO wraps raw PNG bytes in a single-image ICO container.
+// icoImage is one image of an ICO file: a size x size PNG.
+type icoImage struct {
+	size int
+	png  []byte
+}
+
+// wrapInICO packs PNG images into one ICO container, one ICONDIRENTRY
+// each, the image data following the directory in the same order.
 // Windows Vista+ supports PNG-compressed ICO images.
-func wrapInICO(pngData []byte, width, height int) []byte {
-	const headerSize = 6 + 16 // ICONDIR + one ICONDIRENTRY
-
-	buf := make([]byte, headerSize+len(pngData))
-
-	// ICONDIR (6 bytes)
-	buf[0] = 0 // reserved
-	buf[1] = 0
-	buf[2] = 1 // type = ICO
-	buf[3] = 0
-	buf[4] = 1 // count = 1 image
-	buf[5] = 0
-
-	// ICONDIRENTRY (16 bytes starting at offset 6)
-	w := byte(width)
-	h := byte(height)
-	if width == 256 {
-		w = 0
-	}
-	if height == 256 {
-		h = 0
-	}
-	buf[6] = w  // width
-	buf[7] = h  // height
-	buf[8] = 0  // color count (0 = no palette)
-	buf[9] = 0  // reserved
-	buf[10] = 1 // planes (LE)
-	buf[11] = 0
-	buf[12] = 32 // bit count (LE)
-	buf[13] = 0
-	binary.LittleEndian.PutUint32(buf[14:18], uint32(len(pngData)))
-	binary.LittleEndian.PutUint32(buf[18:22], uint32(headerSize))
-
-	copy(buf[headerSize:], pngData)
+func wrapInICO(images ...icoImage) []byte {
+	headerSize := 6 + 16*len(images) // ICONDIR + one ICONDIRENTRY per image
+
+	size := headerSize
+	for _, im := range images {
+		size += len(im.png)
+	}
+	buf := make([]byte, headerSize, size)
+
+	// ICONDIR (6 bytes): reserved, type = ICO, count
+	binary.LittleEndian.PutUint16(buf[2:4], 1)
+	binary.LittleEndian.PutUint16(buf[4:6], uint16(len(images)))
+
+	offset := headerSize
+	for i, im := range images {
+		// ICONDIRENTRY (16 bytes); 0 stands for a width or height of 256
+		e := buf[6+16*i : 6+16*(i+1)]
+		e[0] = byte(im.size) // width
+		e[1] = byte(im.size) // height
+		e[2] = 0             // color count (0 = no palette)
+		e[3] = 0             // reserved
+		binary.LittleEndian.PutUint16(e[4:6], 1)  // planes
+		binary.LittleEndian.PutUint16(e[6:8], 32) // bit count
+		binary.LittleEndian.PutUint32(e[8:12], uint32(len(im.png)))
+		binary.LittleEndian.PutUint32(e[12:16], uint32(offset))
+		offset += len(im.png)
+	}
+	for _, im := range images {
+		buf = append(buf, im.png...)
+	}
 	return buf
 }
 
-// makeIcon generates a 64x64 icon showing session and weekly remaining percentages.
+// iconPNG returns the PNG image inside an icon from makeIcon: the icon
+// itself, or on Windows the largest image of its ICO container.
+func iconPNG(icon []byte) []byte {
+	if len(icon) < 6 || icon[0] != 0 || icon[1] != 0 || icon[2] != 1 || icon[3] != 0 {
+		return icon
+	}
+	var best []byte
+	bestSize := 0
+	for i := 0; i < int(binary.LittleEndian.Uint16(icon[4:6])); i++ {
+		if len(icon) < 6+16*(i+1) {
+			break
+		}
+		e := icon[6+16*i:]
+		size := int(e[0])
+		if size == 0 {
+			size = 256
+		}
+		n := int(binary.LittleEndian.Uint32(e[8:12]))
+		off := int(binary.LittleEndian.Uint32(e[12:16]))
+		if size > bestSize && off >= 0 && n >= 0 && off+n <= len(icon) {
+			best, bestSize = icon[off:off+n], size
+		}
+	}
+	return best
+}
+
+// icoSizes are the images packed into a Windows icon: the tray takes the
+// one that matches its size (16 at 100% scaling, 20 at 125%, 24 at 150%,
+// 32 at 200%) instead of shrinking the 64x64 one into a blur.
+var icoSizes = []int{16, 20, 24, 32, iconSize}
+
+// smallIcon is the size below which icons use the 1x font and a simpler
+// layout.
+const smallIcon = 48
+
+// iconRenderer draws an icon at size x size pixels.
+type iconRenderer func(size int) *image.RGBA
+
+// encodeIcon renders an icon as a PNG at iconSize, or on Windows as an
+// ICO with an image for each of icoSizes.
+func encodeIcon(render iconRenderer) []byte {
+	if runtime.GOOS == "windows" {
+		return encodeICO(render)
+	}
+	return encodePNG(render(iconSize))
+}
+
+// encodeICO renders an icon at each of icoSizes and packs them into an ICO.
+func encodeICO(render iconRenderer) []byte {
+	images := make([]icoImage, 0, len(icoSizes))
+	for _, size := range icoSizes {
+		images = append(images, icoImage{size: size, png: encodePNG(render(size))})
+	}
+	return wrapInICO(images...)
+}
+
+func encodePNG(img image.Image) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// fillFramed fills img's columns x0 to x1 with bg, then draws the 1px
+// dark border around the whole icon.
+func fillFramed(img *image.RGBA, x0, x1 int, bg color.RGBA) {
+	size := img.Rect.Dx()
+	draw.Draw(img, image.Rect(x0, 0, x1, size), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	border := color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x80}
+	for i := 0; i < size; i++ {
+		img.SetRGBA(i, 0, border)      // top
+		img.SetRGBA(i, size-1, border) // bottom
+		img.SetRGBA(0, i, border)      // left
+		img.SetRGBA(size-1, i, border) // right
+	}
+}
+
+// barHeight is the height of a mono icon's level bar at size.
+func barHeight(size int) int {
+	return max(2, size*monoBarHeight/iconSize)
+}
+
+// splitLabel is the text for one half of the split icon at size: "42%"
+// at full size, "42" at the small sizes, where "100" doesn't fit and a
+// full half goes without. Below 24 pixels the halves only show color.
+func splitLabel(n, size int) string {
+	switch {
+	case size >= smallIcon:
+		return formatPct(n)
+	case size < 24 || n >= 100:
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+// makeIcon generates an icon showing session and weekly remaining percentages,
+// or the used percentages with used set.
 // Left half = sessionRemaining, right half = weeklyRemaining.
-// Colors: green >= 50%, amber 20-49%, red < 20%.
+// Colors: green >= 50%, amber 20-49%, red < 20% remaining. The right half's color is
+// taken from weeklyColorRemaining, which may be lower than weeklyRemaining
+// when a per-model weekly bucket is tighter.
 // Text is rendered with a dark outline for readability.
-func makeIcon(sessionRemaining, weeklyRemaining int) []byte {
-	const half = iconSize / 2
+// A mono palette draws the digits alone on a transparent background, with
+// a level bar under a half whose bucket is running low.
+// Icons are cached by their arguments.
+func makeIcon(sessionRemaining, weeklyRemaining, weeklyColorRemaining int, used bool, palette iconPalette) []byte {
+	return iconKey{a: sessionRemaining, b: weeklyRemaining, c: weeklyColorRemaining, used: used, palette: palette}.icon()
+}
 
-	img := image.NewRGBA(image.Rect(0, 0, iconSize, iconSize))
+// renderSplitIcon draws makeIcon's icon at size x size pixels.
+func renderSplitIcon(size, sessionRemaining, weeklyRemaining, weeklyColorRemaining int, used bool, palette iconPalette) *image.RGBA {
+	half := size / 2
+	scale := fontScale
+	if size < smallIcon {
+		scale = 1
+	}
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
 
-	sessionColor := levelColor(sessionRemaining)
-	weeklyColor := levelColor(weeklyRemaining)
+	// Render text centered vertically: (64 - 14) / 2 = 25 at full size
+	textY := (size - 7*scale) / 2
+	sessionStr := splitLabel(iconNumber(sessionRemaining, used), size)
+	weeklyStr := splitLabel(iconNumber(weeklyRemaining, used), size)
 
-	// Fill background halves
-	for y := 0; y < iconSize; y++ {
-		for x := 0; x < half; x++ {
-			img.SetRGBA(x, y, sessionColor)
-		}
-		for x := half; x < iconSize; x++ {
-			img.SetRGBA(x, y, weeklyColor)
+	if palette != paletteColor {
+		ink := palette.ink()
+		if size < 24 {
+			// No room for digits: each half is a gauge of what is left
+			drawGauge(img, 1, half-1, sessionRemaining, ink)
+			drawGauge(img, half+1, size-1, weeklyColorRemaining, ink)
+			return img
 		}
+		divider := ink
+		divider.A = 0x60
+		bar := barHeight(size)
+		fillOver(img, image.Rect(half-1, size/16, half+1, size-bar-size/16), divider)
+		drawLevelBar(img, 0, half-1, sessionRemaining)
+		drawLevelBar(img, half+1, size, weeklyColorRemaining)
+		drawTextRaw(img, sessionStr, 1+startXInHalf(half-2, sessionStr, scale), textY, scale, ink)
+		drawTextRaw(img, weeklyStr, half+1+startXInHalf(half-2, weeklyStr, scale), textY, scale, ink)
+		return img
 	}
 
-	// Draw 1px dark border around the icon
-	border := color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x80}
-	for i := 0; i < iconSize; i++ {
-		img.SetRGBA(i, 0, border)              // top
-		img.SetRGBA(i, iconSize-1, border)      // bottom
-		img.SetRGBA(0, i, border)              // left
-		img.SetRGBA(iconSize-1, i, border)      // right
-	}
+	// Fill background halves, with the dark border around the icon
+	fillFramed(img, 0, half, levelColor(sessionRemaining))
+	fillFramed(img, half, size, levelColor(weeklyColorRemaining))
 
-	// Draw 1px vertical divider in semi-transparent black
+	// Draw the vertical divider in semi-transparent black: 2px at full
+	// size, 1px in the small ones
 	divider := color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x60}
-	for y := 0; y < iconSize; y++ {
-		img.SetRGBA(half-1, y, divider)
+	for y := 0; y < size; y++ {
+		if size >= smallIcon {
+			img.SetRGBA(half-1, y, divider)
+		}
 		img.SetRGBA(half, y, divider)
 	}
 
-	// Render text centered vertically: (64 - 14) / 2 = 25
-	textY := (iconSize - glyphH) / 2
-	sessionStr := formatPct(sessionRemaining)
-	weeklyStr := formatPct(weeklyRemaining)
-
-	drawTextOutlined(img, sessionStr, 1+startXInHalf(half-2, sessionStr), textY)
-	drawTextOutlined(img, weeklyStr, half+1+startXInHalf(half-2, weeklyStr), textY)
+	drawTextOutlinedScaled(img, sessionStr, 1+startXInHalf(half-2, sessionStr, scale), textY, scale)
+	drawTextOutlinedScaled(img, weeklyStr, half+1+startXInHalf(half-2, weeklyStr, scale), textY, scale)
+	return img
+}
 
-	var pngBuf bytes.Buffer
-	png.Encode(&pngBuf, img)
-	if runtime.GOOS == "windows" {
-		return wrapInICO(pngBuf.Bytes(), iconSize, iconSize)
+// drawGauge fills columns x0 to x1 of a tiny mono icon from the bottom,
+// as high as remaining is, in ink or, when running low, the level color.
+func drawGauge(img *image.RGBA, x0, x1, remaining int, ink color.RGBA) {
+	size := img.Rect.Dy()
+	h := max(1, (size-2)*max(0, min(remaining, 100))/100)
+	c := ink
+	if monoNeedsBar(remaining) {
+		c = levelColor(remaining)
 	}
-	return pngBuf.Bytes()
+	fillOver(img, image.Rect(x0, size-1-h, x1, size-1), c)
 }
 
-// makeGrayIcon returns a 64x64 solid gray icon used for loading/error states.
-func makeGrayIcon() []byte {
-	img := image.NewRGBA(image.Rect(0, 0, iconSize, iconSize))
-	gray := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
-	for y := 0; y < iconSize; y++ {
-		for x := 0; x < iconSize; x++ {
-			img.SetRGBA(x, y, gray)
+// makeCompactIcon generates an icon showing one remaining percentage
+// (the used one with used set) in large digits, colored by level, with the
+// bucket's letter (S, W, O or N) in the top-left corner. A mono palette
+// draws them alone on a transparent background, with a level bar across
+// the bottom when the bucket is running low. Icons are cached by their
+// arguments.
+func makeCompactIcon(letter string, remaining int, used bool, palette iconPalette) []byte {
+	return iconKey{letter: letter, a: remaining, used: used, palette: palette}.icon()
+}
+
+// renderCompactIcon draws makeCompactIcon's icon at size x size pixels. The
+// small sizes leave out the letter and draw the number as large as fits.
+func renderCompactIcon(size int, letter string, remaining int, used bool, palette iconPalette) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	remaining = max(0, min(remaining, 100))
+	num := fmt.Sprint(iconNumber(remaining, used))
+
+	var x, y, scale int
+	if size >= smallIcon {
+		// Two digits fit at 4x; "100" needs 3x. The number sits below
+		// the letter, centered horizontally.
+		scale = 4
+		if textWidthScaled(num, scale) > size-4 {
+			scale = 3
+		}
+		y = 3 + glyphH + (size-3-glyphH-7*scale)/2
+	} else {
+		letter = ""
+		scale = 0
+		for s := 3; s > 0; s-- {
+			if textWidthScaled(num, s) <= size-2 && 7*s <= size-2 {
+				scale = s
+				break
+			}
+		}
+		if scale == 0 {
+			num, scale = "", 1 // "100" at 16px: the color says it
 		}
+		y = (size - 7*scale) / 2
 	}
-	// Dark border
-	border := color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x80}
-	for i := 0; i < iconSize; i++ {
-		img.SetRGBA(i, 0, border)
-		img.SetRGBA(i, iconSize-1, border)
-		img.SetRGBA(0, i, border)
-		img.SetRGBA(iconSize-1, i, border)
+	x = (size - textWidthScaled(num, scale)) / 2
+
+	if palette != paletteColor {
+		ink := palette.ink()
+		drawLevelBar(img, 0, size, remaining)
+		lift := barHeight(size) / 2
+		drawTextRaw(img, letter, 3, 1, fontScale, ink)
+		drawTextRaw(img, num, x, y-lift, scale, ink)
+		return img
 	}
-	var buf bytes.Buffer
-	png.Encode(&buf, img)
-	if runtime.GOOS == "windows" {
-		return wrapInICO(buf.Bytes(), iconSize, iconSize)
+
+	fillFramed(img, 0, size, levelColor(remaining))
+	drawTextOutlined(img, letter, 3, 3)
+	drawTextOutlinedScaled(img, num, x, y, scale)
+	return img
+}
+
+// renderGrayIcon draws the plain gray icon shown while loading.
+func renderGrayIcon(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	fillFramed(img, 0, size, color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff})
+	return img
+}
+
+// renderAlertIcon draws the gray icon with a large "!" across it, for a
+// config error or an update that failed before any usage was known.
+func renderAlertIcon(size int) *image.RGBA {
+	img := renderGrayIcon(size)
+	scale := max(1, (size-6)/7)
+	drawTextOutlinedScaled(img, "!", (size-5*scale)/2, (size-7*scale)/2, scale)
+	return img
+}
+
+// renderPausedIcon draws the gray icon with a large "‖" across it, while
+// monitoring is paused.
+func renderPausedIcon(size int) *image.RGBA {
+	img := renderGrayIcon(size)
+	scale := max(1, (size-6)/7)
+	drawTextOutlinedScaled(img, "‖", (size-5*scale)/2, (size-7*scale)/2, scale)
+	return img
+}
+
+// renderDimmedIcon draws the usage icon last at half opacity, with a small
+// "!" in the top-right corner, for an update that failed after usage was
+// known: the numbers still say roughly where things stood.
+func renderDimmedIcon(size int, last iconKey) *image.RGBA {
+	img := last.render(size)
+	// RGBA is alpha-premultiplied, so halving every channel halves the
+	// opacity and keeps the colors
+	for i := range img.Pix {
+		img.Pix[i] /= 2
 	}
-	return buf.Bytes()
+	scale := fontScale
+	if size < smallIcon {
+		scale = 1
+	}
+	x := size - 5*scale - 1
+	if last.palette != paletteColor {
+		drawTextRaw(img, "!", x, 1, scale, last.palette.ink())
+	} else {
+		drawTextOutlinedScaled(img, "!", x, 1, scale)
+	}
+	return img
 }
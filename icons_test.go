@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden icons in testdata/icons")
+
+// goldenIcons are the renderers the golden images in testdata/icons are
+// taken from, one file per renderer and size.
+var goldenIcons = []struct {
+	name   string
+	render iconRenderer
+}{
+	{"split", func(size int) *image.RGBA { return renderSplitIcon(size, 58, 10, 10, false, paletteColor) }},
+	{"split-full", func(size int) *image.RGBA { return renderSplitIcon(size, 100, 42, 42, false, paletteColor) }},
+	{"split-mono", func(size int) *image.RGBA { return renderSplitIcon(size, 80, 10, 10, false, paletteWhite) }},
+	{"compact", func(size int) *image.RGBA { return renderCompactIcon(size, "W", 42, false, paletteColor) }},
+	{"compact-full", func(size int) *image.RGBA { return renderCompactIcon(size, "S", 100, false, paletteColor) }},
+	{"compact-mono", func(size int) *image.RGBA { return renderCompactIcon(size, "S", 30, false, paletteBlack) }},
+	{"gray", renderGrayIcon},
+	{"alert", renderAlertIcon},
+	{"paused", renderPausedIcon},
+	{"dimmed", iconKey{status: statusAPIError, a: 58, b: 10, c: 10}.render},
+	{"dimmed-mono", iconKey{status: statusAPIError, letter: "S", a: 30, palette: paletteBlack}.render},
+}
+
+func TestIconGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "icons")
+	for _, g := range goldenIcons {
+		for _, size := range icoSizes {
+			name := fmt.Sprintf("%s-%d.png", g.name, size)
+			got := g.render(size)
+			if got.Rect.Dx() != size || got.Rect.Dy() != size {
+				t.Errorf("%s: rendered %v", name, got.Rect)
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if *updateGolden {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, encodePNG(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -run TestIconGolden -update to create it)", err)
+			}
+			want, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if n := diffPixels(got, want); n > 0 {
+				t.Errorf("%s: %d pixels differ from the golden image", name, n)
+			}
+		}
+	}
+}
+
+// diffPixels counts the pixels where a and b differ, all of them when the
+// sizes don't match.
+func diffPixels(a, b image.Image) int {
+	if a.Bounds() != b.Bounds() {
+		return a.Bounds().Dx() * a.Bounds().Dy()
+	}
+	n := 0
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if color.NRGBAModel.Convert(a.At(x, y)) != color.NRGBAModel.Convert(b.At(x, y)) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestEncodeICO(t *testing.T) {
+	ico := encodeICO(renderGrayIcon)
+	if got := int(binary.LittleEndian.Uint16(ico[4:6])); got != len(icoSizes) {
+		t.Fatalf("%d images, want %d", got, len(icoSizes))
+	}
+	next := 6 + 16*len(icoSizes)
+	for i, size := range icoSizes {
+		e := ico[6+16*i:]
+		n := int(binary.LittleEndian.Uint32(e[8:12]))
+		off := int(binary.LittleEndian.Uint32(e[12:16]))
+		if int(e[0]) != size || int(e[1]) != size {
+			t.Errorf("entry %d is %dx%d, want %d", i, e[0], e[1], size)
+		}
+		if off != next {
+			t.Errorf("entry %d at offset %d, want %d", i, off, next)
+		}
+		next = off + n
+		img, err := png.Decode(bytes.NewReader(ico[off : off+n]))
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if b := img.Bounds(); b.Dx() != size {
+			t.Errorf("entry %d: image is %v, want %d wide", i, b, size)
+		}
+	}
+	if next != len(ico) {
+		t.Errorf("images end at %d, file is %d bytes", next, len(ico))
+	}
+
+	// iconPNG takes the largest image out of an ICO, and a PNG as it is
+	img, err := png.Decode(bytes.NewReader(iconPNG(ico)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != iconSize {
+		t.Errorf("iconPNG picked a %v image", b)
+	}
+	if p := encodePNG(renderGrayIcon(iconSize)); !bytes.Equal(iconPNG(p), p) {
+		t.Error("iconPNG changed a PNG")
+	}
+}
+
+func TestDigitFont(t *testing.T) {
+	for _, r := range "0123456789%ABCDEFGHIJKLMNOPQRSTUVWXYZ!>+-‖" {
+		if _, ok := digitFont[r]; !ok {
+			t.Errorf("no glyph for %q", r)
+		}
+	}
+}
+
+func TestStatusIcon(t *testing.T) {
+	decode := func(icon []byte) image.Image {
+		t.Helper()
+		img, err := png.Decode(bytes.NewReader(iconPNG(icon)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	at := func(icon []byte, x, y int) color.NRGBA {
+		return color.NRGBAModel.Convert(decode(icon).At(x, y)).(color.NRGBA)
+	}
+
+	loading := makeStatusIcon(statusLoading, nil)
+	if !bytes.Equal(loading, iconGray) {
+		t.Error("loading icon isn't iconGray")
+	}
+	if n := diffPixels(decode(loading), renderGrayIcon(iconSize)); n > 0 {
+		t.Errorf("loading icon: %d pixels differ from the plain gray icon", n)
+	}
+
+	// A config error puts a large "!" on the gray icon; so does an API
+	// error before anything is known
+	config := makeStatusIcon(statusConfigError, nil)
+	if got := at(config, iconSize/2, iconSize/4); got.R != 0xff || got.G != 0xff {
+		t.Errorf("config error icon center is %v, want the white \"!\"", got)
+	}
+	if !bytes.Equal(makeStatusIcon(statusAPIError, nil), config) {
+		t.Error("API error without usage differs from the config error icon")
+	}
+
+	// An API error dims the last usage icon to half opacity and marks the
+	// top-right corner
+	last := iconKey{a: 58, b: 10, c: 10}
+	api := makeStatusIcon(statusAPIError, &last)
+	if got := at(api, 4, iconSize-4); got.A != 0x7f {
+		t.Errorf("dimmed icon alpha is %#x, want half", got.A)
+	}
+	if got, want := at(api, 4, iconSize-4).G, levelColor(58).G; got < want-2 || got > want+2 {
+		t.Errorf("dimmed session half lost its color: G %#x, want %#x", got, want)
+	}
+	if got := at(api, iconSize-6, 4); got.A != 0xff || got.R != 0xff {
+		t.Errorf("no \"!\" in the corner: %v", got)
+	}
+	if got := makeStatusIcon(statusAPIError, &iconKey{a: 80, b: 10, c: 10}); bytes.Equal(got, api) {
+		t.Error("API error icon ignores the last usage")
+	}
+}
+
+func TestIconCache(t *testing.T) {
+	a := makeIcon(58, 10, 10, false, paletteColor)
+	b := makeIcon(58, 10, 10, false, paletteColor)
+	if &a[0] != &b[0] {
+		t.Error("same arguments rendered the icon again")
+	}
+	if c := makeIcon(58, 10, 10, true, paletteColor); bytes.Equal(a, c) {
+		t.Error("used icon came from the remaining icon's cache entry")
+	}
+	if c := makeCompactIcon("S", 58, false, paletteColor); bytes.Equal(a, c) {
+		t.Error("compact icon came from the split icon's cache entry")
+	}
+}
+
+// BenchmarkMakeIcon compares an update with the numbers of the last one,
+// served from the cache, with rendering the icon afresh.
+func BenchmarkMakeIcon(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			makeIcon(58, 10, 10, false, paletteColor)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encodeIcon(func(size int) *image.RGBA {
+				return renderSplitIcon(size, 58, 10, 10, false, paletteColor)
+			})
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultIdlePauseAfter is how long the user may be away before
+	// automatic updates stop, unless idle_pause_after is set.
+	defaultIdlePauseAfter = 30 * time.Minute
+	minIdlePauseAfter     = time.Minute
+)
+
+// idleProbeInterval is how often the idle watcher asks the OS, which is
+// also how long a return takes to be noticed.
+var idleProbeInterval = 30 * time.Second
+
+// idlePaused is set while the user is away: automatic updates don't start,
+// as with a pause, until there is input again.
+var idlePaused atomic.Bool
+
+// errIdleUnsupported is what userIdle returns where nothing tells how long
+// the user has been away.
+var errIdleUnsupported = errors.New("not supported on this platform")
+
+// parseIdlePauseAfter parses idle_pause_after; empty is the default of
+// 30 minutes and "0" never pauses.
+func parseIdlePauseAfter(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultIdlePauseAfter, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("idle_pause_after must be a duration such as \"30m\", got %q", s)
+	}
+	if d != 0 && d < minIdlePauseAfter {
+		return 0, fmt.Errorf("idle_pause_after must be 0 or at least %s, got %q", shortDuration(minIdlePauseAfter), s)
+	}
+	return d, nil
+}
+
+// idlePauseAfter returns idle_pause_after or the default; 0 is off.
+func (c *Config) idlePauseAfter() time.Duration {
+	if c == nil {
+		return defaultIdlePauseAfter
+	}
+	d, err := parseIdlePauseAfter(c.IdlePauseAfter)
+	if err != nil {
+		return defaultIdlePauseAfter
+	}
+	return d
+}
+
+// idleWatch decides from what the OS reports whether the user is away.
+type idleWatch struct {
+	// lockedSince is when the session was first seen locked, for a lock
+	// reported without an idle time.
+	lockedSince time.Time
+	away        bool
+}
+
+// update takes how long there has been no input and whether the session
+// is locked, and reports whether the user just went away or came back.
+func (w *idleWatch) update(idle time.Duration, locked bool, after time.Duration, now time.Time) bool {
+	if !locked {
+		w.lockedSince = time.Time{}
+	} else {
+		if w.lockedSince.IsZero() {
+			w.lockedSince = now
+		}
+		idle = max(idle, now.Sub(w.lockedSince))
+	}
+	away := after > 0 && idle >= after
+	changed := away != w.away
+	w.away = away
+	return changed
+}
+
+// watchIdle stops automatic updates while the user has been away for
+// idle_pause_after, and calls refreshNow when they are back. Where the OS
+// can't tell, updates run as always.
+func watchIdle(refreshNow func()) {
+	var w idleWatch
+	unsupported := false
+	for {
+		time.Sleep(idleProbeInterval)
+		after := defaultIdlePauseAfter
+		if cfg, err := readConfigFile(configPath); err == nil {
+			after = cfg.idlePauseAfter()
+		}
+		idle, locked := time.Duration(0), false
+		if after > 0 {
+			var err error
+			idle, locked, err = userIdle()
+			if err != nil {
+				if !unsupported {
+					uiLog.Infof("Idle detection unavailable, updating while away: %v", err)
+					unsupported = true
+				}
+				idle, locked = 0, false
+			} else if unsupported {
+				uiLog.Infof("Idle detection available again")
+				unsupported = false
+			}
+		}
+		if !w.update(idle, locked, after, time.Now()) {
+			continue
+		}
+		idlePaused.Store(w.away)
+		if !w.away {
+			uiLog.Infof("Back after being away, refreshing")
+			refreshNow()
+			continue
+		}
+		uiLog.Infof("Away for %s, updates paused until there is input", shortDuration(max(idle, after)))
+		st := ui.state()
+		st.showIdle()
+		ui.publish(ui.nextGeneration(), st)
+		ui.refresh()
+	}
+}
+
+// idleNote is the tooltip line that says updates are paused on purpose,
+// so old numbers aren't taken for a failure.
+const idleNote = "Updates paused while you're away"
+
+// showIdle adds idleNote to the tooltip; the icon and rows keep the last
+// numbers.
+func (st *uiState) showIdle() {
+	if !strings.HasSuffix(st.tooltip, idleNote) {
+		st.tooltip += "\n" + idleNote
+	}
+}
+
+// parseLoginctlIdle parses the output of
+// "loginctl show-session -p IdleHint -p IdleSinceHint -p LockedHint":
+// IdleSinceHint is microseconds since the epoch.
+func parseLoginctlIdle(out string, now time.Time) (idle time.Duration, locked bool, err error) {
+	props := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[k] = v
+		}
+	}
+	hint, ok := props["IdleHint"]
+	if !ok {
+		return 0, false, errors.New("loginctl reported no IdleHint")
+	}
+	locked = props["LockedHint"] == "yes"
+	if hint != "yes" {
+		return 0, locked, nil
+	}
+	since, err := strconv.ParseInt(props["IdleSinceHint"], 10, 64)
+	if err != nil || since <= 0 {
+		// Idle, since when unknown: count from now like a lock
+		return 0, true, nil
+	}
+	return max(now.Sub(time.UnixMicro(since)), 0), locked, nil
+}
+
+// parseIoregIdle parses HIDIdleTime, in nanoseconds, from the output of
+// "ioreg -c IOHIDSystem -d 4".
+func parseIoregIdle(out string) (time.Duration, error) {
+	for _, line := range strings.Split(out, "\n") {
+		_, v, ok := strings.Cut(line, `"HIDIdleTime" = `)
+		if !ok {
+			continue
+		}
+		ns, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("HIDIdleTime %q: %v", strings.TrimSpace(v), err)
+		}
+		return time.Duration(ns), nil
+	}
+	return 0, errors.New("ioreg reported no HIDIdleTime")
+}
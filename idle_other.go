@@ -0,0 +1,34 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// userIdle returns how long there has been no input and whether the
+// session is locked: from IOKit's HIDIdleTime on macOS, from logind's idle
+// and lock hints, which the desktop sets, elsewhere.
+func userIdle() (time.Duration, bool, error) {
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("ioreg", "-c", "IOHIDSystem", "-d", "4").Output()
+		if err != nil {
+			return 0, false, err
+		}
+		idle, err := parseIoregIdle(string(out))
+		return idle, false, err
+	}
+	session := os.Getenv("XDG_SESSION_ID")
+	if session == "" {
+		return 0, false, errIdleUnsupported
+	}
+	out, err := exec.Command("loginctl", "show-session", session,
+		"-p", "IdleHint", "-p", "IdleSinceHint", "-p", "LockedHint").Output()
+	if err != nil {
+		return 0, false, err
+	}
+	return parseLoginctlIdle(string(out), time.Now())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIdlePauseAfter(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"", defaultIdlePauseAfter, false},
+		{"0", 0, false},
+		{"1h", time.Hour, false},
+		{" 5m ", 5 * time.Minute, false},
+		{"30s", 0, true},
+		{"soon", 0, true},
+	} {
+		got, err := parseIdlePauseAfter(tc.in)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("parseIdlePauseAfter(%q) = %v, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestIdleWatch(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	after := 30 * time.Minute
+	var w idleWatch
+	if w.update(10*time.Minute, false, after, now) || w.away {
+		t.Fatal("away after 10 minutes")
+	}
+	if !w.update(31*time.Minute, false, after, now) || !w.away {
+		t.Fatal("not away after 31 minutes")
+	}
+	if w.update(40*time.Minute, false, after, now) {
+		t.Error("went away twice")
+	}
+	if !w.update(0, false, after, now) || w.away {
+		t.Fatal("not back after input")
+	}
+
+	// A lock without an idle time counts from when it was seen
+	if w.update(0, true, after, now) || w.away {
+		t.Fatal("away as soon as locked")
+	}
+	if !w.update(0, true, after, now.Add(after)) || !w.away {
+		t.Fatal("not away after being locked for the threshold")
+	}
+	if !w.update(0, false, after, now.Add(time.Hour)) || w.away {
+		t.Fatal("not back after unlocking")
+	}
+
+	// 0 never pauses
+	if w.update(24*time.Hour, true, 0, now) || w.away {
+		t.Error("away with idle_pause_after 0")
+	}
+}
+
+func TestParseLoginctlIdle(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-45 * time.Minute).UnixMicro()
+	for _, tc := range []struct {
+		out    string
+		idle   time.Duration
+		locked bool
+		err    bool
+	}{
+		{"IdleHint=no\nIdleSinceHint=0\nLockedHint=no\n", 0, false, false},
+		{"IdleHint=yes\nIdleSinceHint=" + strconv.FormatInt(since, 10) + "\nLockedHint=no\n", 45 * time.Minute, false, false},
+		{"IdleHint=no\nIdleSinceHint=0\nLockedHint=yes\n", 0, true, false},
+		{"IdleHint=yes\nIdleSinceHint=0\nLockedHint=no\n", 0, true, false},
+		{"", 0, false, true},
+	} {
+		idle, locked, err := parseLoginctlIdle(tc.out, now)
+		if (err != nil) != tc.err || idle != tc.idle || locked != tc.locked {
+			t.Errorf("parseLoginctlIdle(%q) = %v, %v, %v", tc.out, idle, locked, err)
+		}
+	}
+}
+
+func TestParseIoregIdle(t *testing.T) {
+	out := `  | |   "HIDIdleTime" = 125000000000
+  | |   "HIDKeyboardModifierMappingPairs" = ()`
+	if d, err := parseIoregIdle(out); err != nil || d != 125*time.Second {
+		t.Errorf("parseIoregIdle = %v, %v", d, err)
+	}
+	if _, err := parseIoregIdle("nothing"); err == nil {
+		t.Error("no error without HIDIdleTime")
+	}
+}
+
+func TestIdleSkipsUpdates(t *testing.T) {
+	idlePaused.Store(true)
+	t.Cleanup(func() { idlePaused.Store(false) })
+
+	startUpdate, refreshNow, _ := updateStarters(headlessView{})
+	startUpdate()
+	refreshNow()
+	if n := activeUpdates.Load(); n != 0 {
+		t.Errorf("%d updates started while away", n)
+	}
+
+	updated, next := timeRows(time.Now(), false)
+	if strings.Contains(updated, mark(markWarning, false)) || next != "Next check: when you're back" {
+		t.Errorf("rows while away: %q, %q", updated, next)
+	}
+
+	st := uiState{tooltip: appName}
+	st.showIdle()
+	st.showIdle()
+	if st.tooltip != appName+"\n"+idleNote {
+		t.Errorf("tooltip %q", st.tooltip)
+	}
+}
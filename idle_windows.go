@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	procGetLastInputInfo = user32.NewProc("GetLastInputInfo")
+	procGetTickCount     = syscall.NewLazyDLL("kernel32.dll").NewProc("GetTickCount")
+)
+
+// lastInputInfo is LASTINPUTINFO.
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// userIdle returns how long there has been no keyboard or mouse input in
+// this session. A locked session gets none, so it counts as idle too.
+func userIdle() (time.Duration, bool, error) {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if r, _, err := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0, false, err
+	}
+	now, _, _ := procGetTickCount.Call()
+	// Both wrap after 49.7 days; the difference in uint32 doesn't
+	return time.Duration(uint32(now)-info.dwTime) * time.Millisecond, false, nil
+}
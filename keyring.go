@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// credentialStoreKeyring is the credential_store value that keeps
+	// sessionKey and cf_clearance in the OS keyring.
+	credentialStoreKeyring = "keyring"
+	// keyringMarker stands in config.json for a secret kept in the keyring.
+	keyringMarker = "@keyring"
+)
+
+// errKeyringNotFound means the keyring has no entry under that name.
+var errKeyringNotFound = errors.New("not in the keyring")
+
+// secretStore is the OS keyring: Windows Credential Manager, the macOS
+// Keychain or the Secret Service on Linux. Entries belong to the app and
+// are named after the config field they stand in for.
+type secretStore interface {
+	get(name string) (string, error)
+	set(name, value string) error
+}
+
+// keyring caches what it reads, since config.json is read on every update
+// and each lookup may start a helper process.
+var keyring = &cachedKeyring{store: osKeyring{}}
+
+type cachedKeyring struct {
+	mu     sync.Mutex
+	store  secretStore
+	values map[string]string
+}
+
+func (k *cachedKeyring) get(name string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if v, ok := k.values[name]; ok {
+		return v, nil
+	}
+	v, err := k.store.get(name)
+	if err != nil {
+		return "", err
+	}
+	if k.values == nil {
+		k.values = make(map[string]string)
+	}
+	k.values[name] = v
+	return v, nil
+}
+
+func (k *cachedKeyring) set(name, value string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if v, ok := k.values[name]; ok && v == value {
+		return nil
+	}
+	if err := k.store.set(name, value); err != nil {
+		return err
+	}
+	if k.values == nil {
+		k.values = make(map[string]string)
+	}
+	k.values[name] = value
+	return nil
+}
+
+// secretRef is a credential field of config.json and the keyring entry
+// that holds it in keyring mode.
+type secretRef struct {
+	name  string
+	value *string
+}
+
+// secretRefs lists the credential fields of cfg: the flat session_key and
+// cf_clearance, and those of every entry of accounts, named by account.
+func secretRefs(cfg *Config) []secretRef {
+	refs := []secretRef{
+		{"session_key", &cfg.SessionKey},
+		{"cf_clearance", &cfg.CfClearance},
+	}
+	for i := range cfg.Accounts {
+		a := &cfg.Accounts[i]
+		refs = append(refs,
+			secretRef{a.Name + "/session_key", &a.SessionKey},
+			secretRef{a.Name + "/cf_clearance", &a.CfClearance})
+	}
+	return refs
+}
+
+// resolveKeyringSecrets replaces the markers in cfg with the values from
+// the keyring, whatever credential_store says, so that switching back to
+// the file keeps working until the cookies are imported again.
+func resolveKeyringSecrets(cfg *Config) error {
+	for _, r := range secretRefs(cfg) {
+		if strings.TrimSpace(*r.value) != keyringMarker {
+			continue
+		}
+		v, err := keyring.get(r.name)
+		if err != nil {
+			return fmt.Errorf("reading %s from the keyring: %w", r.name, err)
+		}
+		*r.value = v
+	}
+	return nil
+}
+
+// credentialStore returns credential_store as the policy may set it.
+func credentialStore(cfg *Config) string {
+	mode := cfg.CredentialStore
+	if pol, err := readPolicy(policyPath()); err == nil && pol.managed("credential_store") {
+		managed := Config{CredentialStore: mode}
+		pol.apply(&managed)
+		mode = managed.CredentialStore
+	}
+	return mode
+}
+
+// hasPlaintextSecret reports whether value is a secret to move to the
+// keyring: not empty, a marker or a template placeholder.
+func hasPlaintextSecret(value string) bool {
+	v := strings.TrimSpace(value)
+	return v != "" && v != keyringMarker && !strings.HasPrefix(v, "PASTE_")
+}
+
+// storeKeyringSecrets moves the secrets cfg holds in plain text into the
+// keyring, leaving markers, if credential_store is "keyring". updateConfig
+// calls it before every write, which makes saving cookies and migrating a
+// plaintext config.json the same thing.
+func storeKeyringSecrets(cfg *Config) error {
+	if credentialStore(cfg) != credentialStoreKeyring {
+		return nil
+	}
+	for _, r := range secretRefs(cfg) {
+		if !hasPlaintextSecret(*r.value) {
+			continue
+		}
+		if err := keyring.set(r.name, strings.TrimSpace(*r.value)); err != nil {
+			return fmt.Errorf("saving %s to the keyring: %w", r.name, err)
+		}
+		*r.value = keyringMarker
+	}
+	return nil
+}
+
+// migrateSecretsToKeyring rewrites config.json if credential_store is
+// "keyring" but secrets are still in the file, e.g. right after the
+// setting was turned on or a hand edit pasted a new sessionKey.
+func migrateSecretsToKeyring(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var raw Config
+	if json.Unmarshal(data, &raw) != nil || credentialStore(&raw) != credentialStoreKeyring {
+		return
+	}
+	plain := false
+	for _, r := range secretRefs(&raw) {
+		plain = plain || hasPlaintextSecret(*r.value)
+	}
+	if !plain {
+		return
+	}
+	if err := updateConfig(path, func(*Config) {}); err != nil {
+		configLog.Warnf("Failed to move credentials to the keyring: %v", err)
+		health.report("Keyring", false, err.Error())
+		return
+	}
+	configLog.Infof("Moved credentials from config.json to the keyring")
+	health.report("Keyring", true, "credentials stored in the keyring")
+}
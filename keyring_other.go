@@ -0,0 +1,69 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// osKeyring keeps secrets in the macOS Keychain through security(1), or in
+// the Secret Service (GNOME Keyring, KWallet) through secret-tool(1), as
+// generic passwords of service appDirName.
+type osKeyring struct{}
+
+func (osKeyring) get(name string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", appDirName, "-a", name, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", appDirName, "account", name)
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// security exits 44 for a missing item; secret-tool 1, saying nothing
+		msg := strings.TrimSpace(string(exitErr.Stderr))
+		if runtime.GOOS == "darwin" && exitErr.ExitCode() == 44 || runtime.GOOS != "darwin" && msg == "" {
+			return "", errKeyringNotFound
+		}
+		if msg != "" {
+			return "", fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+func (osKeyring) set(name, value string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// Commands read from stdin keep the secret out of the process list
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -w %s\n",
+			shellQuote(appDirName), shellQuote(name), shellQuote(appName+" "+name), shellQuote(value)))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label="+appName+" "+name, "service", appDirName, "account", name)
+		cmd.Stdin = strings.NewReader(value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for the command line security -i reads.
+func shellQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// memoryKeyring is a secretStore for tests.
+type memoryKeyring map[string]string
+
+func (m memoryKeyring) get(name string) (string, error) {
+	v, ok := m[name]
+	if !ok {
+		return "", errKeyringNotFound
+	}
+	return v, nil
+}
+
+func (m memoryKeyring) set(name, value string) error {
+	m[name] = value
+	return nil
+}
+
+func TestKeyringCredentialStore(t *testing.T) {
+	store := memoryKeyring{}
+	saved := keyring
+	t.Cleanup(func() { keyring = saved })
+	keyring = &cachedKeyring{store: store}
+
+	path := writeTestConfig(t, `{"session_key": "sk-ant-sid01-old", "org_id": "org", "cf_clearance": "cf-old", "credential_store": "keyring"}`)
+	migrateSecretsToKeyring(path)
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "sk-ant") || strings.Contains(string(data), "cf-old") || strings.Count(string(data), keyringMarker) != 2 {
+		t.Errorf("secrets left in config.json after migration:\n%s", data)
+	}
+	if store["session_key"] != "sk-ant-sid01-old" || store["cf_clearance"] != "cf-old" {
+		t.Errorf("keyring after migration: %v", store)
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SessionKey != "sk-ant-sid01-old" || cfg.CfClearance != "cf-old" {
+		t.Errorf("markers not resolved: %q, %q", cfg.SessionKey, cfg.CfClearance)
+	}
+
+	// An import writes to the keyring; an empty cf_clearance keeps the old one
+	if err := saveFirefoxConfig(path, "sk-ant-sid01-new", "org", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if strings.Contains(string(data), "sk-ant") {
+		t.Errorf("import wrote the sessionKey to config.json:\n%s", data)
+	}
+	if cfg, err := loadConfig(path); err != nil || cfg.SessionKey != "sk-ant-sid01-new" || cfg.CfClearance != "cf-old" {
+		t.Errorf("after import: %+v, %v", cfg, err)
+	}
+
+	// Accounts are stored under their names
+	path = writeTestConfig(t, `{"credential_store": "keyring", "accounts": [{"name": "work", "session_key": "sk-ant-sid01-work", "org_id": "org"}]}`)
+	migrateSecretsToKeyring(path)
+	if store["work/session_key"] != "sk-ant-sid01-work" {
+		t.Errorf("account key not stored: %v", store)
+	}
+
+	// A marker without an entry is an error, not an empty key
+	delete(store, "work/session_key")
+	keyring = &cachedKeyring{store: store}
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "work/session_key") {
+		t.Errorf("missing entry: %v", err)
+	}
+}
+
+func TestKeyringFileModeLeavesSecrets(t *testing.T) {
+	store := memoryKeyring{}
+	saved := keyring
+	t.Cleanup(func() { keyring = saved })
+	keyring = &cachedKeyring{store: store}
+
+	path := writeTestConfig(t, `{"session_key": "sk-ant-sid01-x", "org_id": "org"}`)
+	migrateSecretsToKeyring(path)
+	if err := saveFirefoxConfig(path, "sk-ant-sid01-y", "org", "cf"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "sk-ant-sid01-y") || len(store) != 0 {
+		t.Errorf("file mode used the keyring: %v\n%s", store, data)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// errorKind names the kind of a failed update for the "Last error" row:
+// "Cloudflare 403", "HTTP 401", "DNS", "TLS", "network", "network config"
+// or "config"; "error" for anything else.
+func errorKind(err error) string {
+	var herr *ErrHTTP
+	var nerr *ErrNetConfig
+	var dnsErr *net.DNSError
+	var uaErr x509.UnknownAuthorityError
+	switch {
+	case isCloudflare(err):
+		return "Cloudflare 403"
+	case errors.As(err, &herr):
+		return fmt.Sprintf("HTTP %d", herr.StatusCode)
+	case errors.As(err, &nerr):
+		return "network config"
+	case errors.As(err, &dnsErr):
+		return "DNS"
+	case errors.As(err, &uaErr):
+		return "TLS"
+	case isNetworkError(err):
+		return "network"
+	case errors.Is(err, errConfigUpdate):
+		return "config"
+	}
+	return "error"
+}
+
+// errConfigUpdate marks an update that failed on config.json itself.
+var errConfigUpdate = errors.New("config error")
+
+// updateOutcome remembers how the latest updates went, for the "Last
+// error" row and what clicking it copies.
+type updateOutcome struct {
+	mu       sync.Mutex
+	err      error
+	kind     string
+	at       time.Time // of the latest failure, or success
+	failures int       // in a row
+
+	succeeded time.Time // of the latest success, kept while updates fail
+}
+
+var lastOutcome = &updateOutcome{}
+
+// record notes the outcome of an update cycle, returning the kind of the
+// previous cycle's failure, "" if it succeeded.
+func (o *updateOutcome) record(err error, now time.Time) (prevKind string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	prevKind = o.kind
+	o.at = now
+	if err == nil {
+		o.err, o.kind, o.failures = nil, "", 0
+		o.succeeded = now
+		return prevKind
+	}
+	o.err, o.kind = err, errorKind(err)
+	o.failures++
+	return prevKind
+}
+
+// restore takes the time of the usage snapshot saved by the previous run
+// as the last success, until an update succeeds.
+func (o *updateOutcome) restore(at time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.succeeded.IsZero() {
+		o.succeeded = at
+	}
+}
+
+// lastSuccess is when the usage was last fetched, zero if never.
+func (o *updateOutcome) lastSuccess() time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.succeeded
+}
+
+// line renders the row, e.g. "Last error: Cloudflare 403 — 12:41, 3
+// attempts"; empty unless the last update failed, as the Updated row
+// covers success.
+func (o *updateOutcome) line(accessible bool) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err == nil {
+		return ""
+	}
+	at := o.at.Local().Format("15:04")
+	if accessible {
+		return fmt.Sprintf("Last error: %s at %s, %s", o.kind, at, plural(o.failures, "attempt"))
+	}
+	return truncate(fmt.Sprintf("Last error: %s — %s, %s", o.kind, at, plural(o.failures, "attempt")), maxMenuLine)
+}
+
+// details is the full text of the last error for a bug report, or the
+// time of the last successful update.
+func (o *updateOutcome) details() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err == nil {
+		return "Last update: " + o.at.Format(time.RFC3339) + " OK"
+	}
+	return fmt.Sprintf("%s: %s (%s, %s in a row)", o.at.Format(time.RFC3339), scrubSecrets(o.err.Error()), o.kind, plural(o.failures, "failed update"))
+}
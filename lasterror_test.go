@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorKind(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{&ErrCloudflare{Msg: "HTTP 403: Cloudflare challenge"}, "Cloudflare 403"},
+		{&ErrHTTP{StatusCode: 401, Msg: "HTTP 401: unauthorized"}, "HTTP 401"},
+		{&ErrNetConfig{Msg: "proxy_url: bad scheme"}, "network config"},
+		{fmt.Errorf("fetch: %w", errTimeout{}), "network"},
+		{fmt.Errorf("%w: %w", errConfigUpdate, errNoOrgID), "config"},
+		{context.Canceled, "error"},
+	} {
+		if got := errorKind(tc.err); got != tc.want {
+			t.Errorf("errorKind(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+// errTimeout is a net.Error.
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+func TestUpdateOutcome(t *testing.T) {
+	o := &updateOutcome{}
+	if o.line(false) != "" {
+		t.Error("row shown before the first update")
+	}
+	at := time.Date(2026, 5, 4, 12, 41, 0, 0, time.Local)
+	cf := &ErrCloudflare{Msg: "HTTP 403: Just a moment... sessionKey=" + testSessionKey}
+	for i := 0; i < 3; i++ {
+		o.record(cf, at)
+	}
+	if got, want := o.line(false), "Last error: Cloudflare 403 — 12:41, 3 attempts"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if d := o.details(); strings.Contains(d, testSessionKey) || !strings.Contains(d, "3 failed updates in a row") {
+		t.Errorf("details: %q", d)
+	}
+
+	o.record(nil, at.Add(14*time.Minute))
+	if got := o.line(false); got != "" {
+		t.Errorf("row shown after a success: %q", got)
+	}
+	o.record(cf, at.Add(20*time.Minute))
+	if got, want := o.line(true), "Last error: Cloudflare 403 at 13:01, 1 attempt"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := o.lastSuccess(), at.Add(14*time.Minute); !got.Equal(want) {
+		t.Errorf("last success %v, want %v", got, want)
+	}
+	o.restore(at) // a snapshot older than the success is ignored
+	if got, want := o.lastSuccess(), at.Add(14*time.Minute); !got.Equal(want) {
+		t.Errorf("last success %v after restore, want %v", got, want)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// systemLocale returns the locale of the desktop where the environment
+// doesn't tell: apps started from the macOS Dock or login items get no
+// LANG, so it asks for AppleLocale, such as "de_DE". Elsewhere the
+// environment is all there is, and it returns "".
+func systemLocale() string {
+	if runtime.GOOS != "darwin" {
+		return ""
+	}
+	out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetUserDefaultLocaleName = syscall.NewLazyDLL("kernel32.dll").NewProc("GetUserDefaultLocaleName")
+
+// localeNameMaxLength is LOCALE_NAME_MAX_LENGTH.
+const localeNameMaxLength = 85
+
+// systemLocale returns the user's locale from the regional settings, such
+// as "de-DE", or "" if it can't be read.
+func systemLocale() string {
+	var buf [localeNameMaxLength]uint16
+	if n, _, _ := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf))); n == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf[:])
+}
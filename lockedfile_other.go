@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// isLockError reports whether err means another process holds the file
+// open; only Windows has mandatory locks that get in the way of reading.
+func isLockError(err error) bool { return false }
+
+// openShared opens path for reading; see the Windows version.
+func openShared(path string) (*os.File, error) { return os.Open(path) }
@@ -0,0 +1,42 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const (
+	errorSharingViolation syscall.Errno = 32 // ERROR_SHARING_VIOLATION
+	errorLockViolation    syscall.Errno = 33 // ERROR_LOCK_VIOLATION
+)
+
+// isLockError reports whether err means another process holds the file
+// open in a way that keeps us out, usually only while it writes.
+func isLockError(err error) bool {
+	return errors.Is(err, errorSharingViolation) || errors.Is(err, errorLockViolation)
+}
+
+// openShared opens path for reading. If os.Open is refused because the
+// browser holds the file, it is opened again sharing read, write and delete
+// access with backup semantics, which a writer that did not deny reading
+// has to allow.
+func openShared(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if err == nil || !isLockError(err) {
+		return f, err
+	}
+	name, perr := syscall.UTF16PtrFromString(path)
+	if perr != nil {
+		return nil, err
+	}
+	h, cerr := syscall.CreateFile(name, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL|syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if cerr != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: cerr}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -2,31 +2,46 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"math/rand"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/getlantern/systray"
 )
 
 const (
-	appName        = "Claude Monitor"
-	updateInterval = 5 * time.Minute
+	appName   = "Claude Monitor"
+	claudeURL = "https://claude.ai"
 )
 
 var (
 	configPath string
-	logFile    *os.File
+	appLog     *rotatingLog
 
 	// cancelUpdate cancels the currently running doUpdate (if any).
 	cancelUpdate context.CancelFunc
 	updateMu     sync.Mutex
+	// updateWG counts running doUpdate goroutines, so shutdown can wait.
+	updateWG sync.WaitGroup
+	// shuttingDown stops new updates from starting; guarded by updateMu.
+	shuttingDown bool
+	// activeUpdates is the number of doUpdate calls still running, including
+	// cancelled ones that haven't returned yet.
+	activeUpdates atomic.Int32
+	closeLogOnce  sync.Once
+
+	sonnetPresence = bucketPresence{name: "Sonnet"}
+	opusPresence   = bucketPresence{name: "Opus"}
 )
 
 func main() {
@@ -35,221 +50,1426 @@ func main() {
 		log.Fatal("Cannot determine executable path:", err)
 	}
 	exeDir := filepath.Dir(exePath)
-	configPath = filepath.Join(exeDir, "config.json")
+
+	paths = resolvePaths(exeDir)
+	if err := paths.ensureDirs(); err != nil {
+		uiLog.Warnf("Cannot create app directories, using executable directory: %v", err)
+		paths = appPaths{configDir: exeDir, stateDir: exeDir}
+	}
+	migrated := migrateLegacyFiles(exeDir, paths)
+	configPath = configPathFromEnv(paths.configFile())
+
+	args, cfgFlag, err := parseConfigFlag(os.Args[1:])
+	if err != nil {
+		attachConsole()
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfgFlag != "" {
+		configPath = cfgFlag
+	}
+	if len(args) > 0 && args[0] == "--crash-test" {
+		crashTest, args = true, args[1:]
+	}
+
+	if code, ok := runCLI(args); ok {
+		os.Exit(code)
+	}
 
 	// Setup logging
-	logPath := filepath.Join(exeDir, "claude-monitor.log")
-	logFile, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	appLog, err = openRotatingLog(paths.logFile())
 	if err == nil {
-		log.SetOutput(logFile)
+		setLogOutput(appLog)
+		if c, cerr := readConfigFile(configPath); cerr == nil {
+			appLog.configure(c)
+			configureLogging(c)
+			knownSecrets.remember(c)
+		}
 	}
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
-	log.Println("Starting", appName)
+	uiLog.Infof("Starting %s %s", appName, currentBuild())
+	for _, msg := range migrated {
+		uiLog.Infof("%s", msg)
+	}
+	configLog.Infof("Config: %s", configPath)
+	restrictConfigMode(configPath)
+	if vars := envOverrides(); len(vars) > 0 {
+		uiLog.Infof("Credentials from the environment: %s", strings.Join(vars, ", "))
+	}
+	uiLog.Infof("State and log: %s", paths.stateDir)
+	logPolicy()
 
 	systray.Run(onReady, onExit)
 }
 
 func onReady() {
+	refreshAccessibility()
 	systray.SetIcon(iconGray)
 	systray.SetTitle("")
 	systray.SetTooltip(appName + ": loading...")
 
 	mHeader := systray.AddMenuItem(appName, "")
 	mHeader.Disable()
+	mOrgWarning := systray.AddMenuItem("", "The configured org_id may not be the right organization")
+	mOrgWarning.Hide()
+	mSessionExpiry := systray.AddMenuItem("", "Open claude.ai to log in again, then import the cookies")
+	mSessionExpiry.Hide()
 	systray.AddSeparator()
 
 	mSession := systray.AddMenuItem("Session (5h): ...", "5-hour sliding window limit")
 	mSession.Disable()
+	mSessionPace := systray.AddMenuItem("", "Time to the session limit at the pace of the last hour")
+	mSessionPace.Disable()
+	mSessionPace.Hide()
 	mWeekly := systray.AddMenuItem("Weekly: ...", "Weekly limit")
 	mWeekly.Disable()
+	mWeeklyPace := systray.AddMenuItem("", "Time to the weekly limit at the pace of the last 12 hours")
+	mWeeklyPace.Disable()
+	mWeeklyPace.Hide()
+	mOpus := systray.AddMenuItem("Opus: ...", "Weekly Opus limit")
+	mOpus.Disable()
 	mSonnet := systray.AddMenuItem("Sonnet: ...", "Weekly Sonnet limit")
 	mSonnet.Disable()
+	mExtra := systray.AddMenuItem("Extra usage: ...", "Pay-as-you-go credits this month")
+	mExtra.Disable()
+	mExtra.Hide()
+	spending := newSpendingMenu()
+	history := newHistoryMenu()
+	accounts := newAccountMenus()
 
 	systray.AddSeparator()
 	mRefresh := systray.AddMenuItem("Refresh now", "Fetch data now")
-	mFirefox := systray.AddMenuItem("Import from Firefox", "Read cookies from Firefox automatically")
+	mUpdated := systray.AddMenuItem("", "When the usage was last fetched successfully")
+	mUpdated.Disable()
+	mUpdated.Hide()
+	mNextCheck := systray.AddMenuItem("", "When the usage is fetched next")
+	mNextCheck.Disable()
+	mNextCheck.Hide()
+	mLastError := systray.AddMenuItem("", "Copy the details of the last error, for a bug report")
+	mLastError.Hide()
+	mPause := systray.AddMenuItemCheckbox("Pause monitoring", "Stop fetching usage until resumed; Refresh now still fetches once", false)
+	mStatusWindow := systray.AddMenuItem("Status window", "Keep the usage in view in a small window")
+	mOpenClaude := systray.AddMenuItem("Open claude.ai", "Open claude.ai in the browser")
+	mOpenUsage := systray.AddMenuItem("Open usage settings", "See the usage and message counts on claude.ai")
+	mCopyStatus := systray.AddMenuItem("Copy status", "Copy a one-line usage summary to the clipboard")
+	firefox := newFirefoxMenu()
+	go firefox.load()
+	chrome := newChromeMenu()
+	go chrome.load()
+	mSafari := systray.AddMenuItem("Import from Safari", "Read cookies from Safari (needs Full Disk Access)")
+	if runtime.GOOS != "darwin" {
+		mSafari.Hide()
+	}
+	mClipboard := systray.AddMenuItem("Import from clipboard", "Read cookies from a request copied with \"Copy as cURL\" in DevTools, or a Cookie header")
 	mEditCfg := systray.AddMenuItem("Open config", "Edit config.json")
 	mOpenLog := systray.AddMenuItem("Open log", "Open log file")
+	mMoveData := systray.AddMenuItem("Move old data here", "")
+	mMoveData.Hide()
+	mSaveResp := systray.AddMenuItem("Save last API response", "Write the last failed API response to a file")
+	mDiagnostics := systray.AddMenuItem("Diagnostics", "")
+	mCloudflare := mDiagnostics.AddSubMenuItem("Cloudflare: ...", "cf_clearance age and last Cloudflare block")
+	mCloudflare.Disable()
+	mDiagBundle := mDiagnostics.AddSubMenuItem("Save diagnostics bundle", "Zip config, state and log, with credentials masked, and the last failed API responses for a bug report")
+	mTestNotify := mDiagnostics.AddSubMenuItem("Test notification", "Show a desktop notification to check that they work")
+	health.attach(mDiagnostics)
+	mNotifications := systray.AddMenuItem("Notifications", "Alerts sent beyond the desktop")
+	mTelegramTest := mNotifications.AddSubMenuItem("Send test message", "Send a Telegram message to check telegram_bot_token and telegram_chat_id")
+	mSettings := systray.AddMenuItem("Settings", "")
+	orgs := newOrgMenu()
 	systray.AddSeparator()
+	mAbout := systray.AddMenuItem("About "+appName, "Version and build of this copy, for bug reports")
+	mNewRelease := systray.AddMenuItem("", "Open the release page on GitHub")
+	mNewRelease.Hide()
+	var newRelease atomic.Pointer[releaseCheck]
+	go watchReleases(func(r *releaseCheck) {
+		newRelease.Store(r)
+		ui.do(func() {
+			if r == nil {
+				mNewRelease.Hide()
+				return
+			}
+			mNewRelease.SetTitle("Update available: " + r.Latest)
+			mNewRelease.Show()
+		})
+	})
 	mQuit := systray.AddMenuItem("Quit", "Close application")
 
-	// Check config — try auto-importing from Firefox on first run
+	// Data left in a state directory used before (e.g. next to an older
+	// copy of the executable) can be moved over once
+	oldDataDirs, err := recordDataDir(paths.stateDir)
+	if err != nil {
+		uiLog.Warnf("Failed to record state directory: %v", err)
+	}
+	if len(oldDataDirs) > 0 {
+		uiLog.Warnf("Data left in previously used directories: %s", strings.Join(oldDataDirs, ", "))
+		mMoveData.SetTooltip("Move log and state from " + strings.Join(oldDataDirs, ", ") + " to " + paths.stateDir)
+		mMoveData.Show()
+	}
+
+	// Check config — try auto-importing from a browser on first run
 	cfg, err := loadConfig(configPath)
 	if err != nil {
-		log.Println("Config not ready, trying Firefox auto-import:", err)
-		if sk, org, cfc, ferr := findFirefoxCookies(); ferr == nil {
-			if werr := saveFirefoxConfig(configPath, sk, org, cfc); werr == nil {
-				log.Println("Config auto-imported from Firefox")
-				mHeader.SetTitle("✓ Cookies imported from Firefox!")
+		firefoxLog.Warnf("Config not ready, trying browser auto-import: %v", err)
+		if browser, sk, org, cfc, ferr := findBrowserCookies(); ferr == nil {
+			if werr := updateConfigAuto(configPath, firefoxCookies(sk, org, cfc)); werr == nil {
+				firefoxLog.Infof("Config auto-imported from %s", browser)
+				mHeader.SetTitle("Cookies imported from " + browser + " " + mark(markOK, accessibleText.Load()))
 				cfg, err = loadConfig(configPath)
 			} else {
-				log.Println("Failed to save Firefox config:", werr)
+				firefoxLog.Warnf("Failed to save imported config: %v", werr)
 			}
 		} else {
-			log.Println("Firefox auto-import failed:", ferr)
+			firefoxLog.Warnf("Browser auto-import failed: %v", ferr)
 		}
-		if err != nil {
-			createTemplateConfig(configPath)
+		if errors.Is(err, errNoOrgID) {
+			apiLog.Infof("org_id not set, will look up organizations on first update")
+		} else if err != nil {
+			if _, serr := os.Stat(configPath); os.IsNotExist(serr) {
+				createTemplateConfig(configPath)
+			}
 			systray.SetTooltip(appName + ": setup config.json!")
-			mHeader.SetTitle("! Setup config.json first")
+			mHeader.SetTitle(mark(markError, accessibleText.Load()) + " Setup config.json first")
 		}
 	}
-	if cfg != nil {
-		log.Println("Config loaded, org_id:", cfg.OrgID[:min(8, len(cfg.OrgID))]+"...")
+	if cfg != nil && len(cfg.Accounts) > 0 {
+		configLog.Infof("Config loaded, %d accounts", len(cfg.Accounts))
+	} else if cfg != nil {
+		configLog.Infof("Config loaded, org_id: %s", shortID(cfg.OrgID))
 	}
 
-	// startUpdate cancels any in-flight update and starts a new one in a goroutine.
-	startUpdate := func() {
-		updateMu.Lock()
-		if cancelUpdate != nil {
-			cancelUpdate()
+	menu := &usageMenu{
+		refresh:       mRefresh,
+		updated:       mUpdated,
+		nextCheck:     mNextCheck,
+		lastError:     mLastError,
+		session:       mSession,
+		weekly:        mWeekly,
+		sessionPace:   mSessionPace,
+		weeklyPace:    mWeeklyPace,
+		opus:          mOpus,
+		sonnet:        mSonnet,
+		extra:         mExtra,
+		cloudflare:    mCloudflare,
+		orgs:          orgs,
+		accounts:      accounts,
+		orgWarning:    mOrgWarning,
+		sessionExpiry: mSessionExpiry,
+		spending:      spending,
+		history:       history,
+	}
+	// Keep the relative times in the menu current between updates
+	go func() {
+		defer recoverCrash("menu refresh", nil)
+		for range time.Tick(time.Minute) {
+			ui.refresh()
 		}
-		ctx, cancel := context.WithCancel(context.Background())
-		cancelUpdate = cancel
-		updateMu.Unlock()
+	}()
+
+	// Show the last known numbers right away; fresh data replaces them soon
+	initial := ui.state()
+	if saved, err := loadState(statePath()); err == nil {
+		uiLog.Infof("Restored usage snapshot from %s", saved.FetchedAt.Format(time.RFC3339))
+		lastOutcome.restore(saved.FetchedAt)
+		settings, _ := readConfigFile(configPath)
+		renderUsage(&initial, settings, saved.Usage, formatAge(time.Since(saved.FetchedAt)))
+	} else if !os.IsNotExist(err) {
+		uiLog.Warnf("Ignoring state file: %v", err)
+	}
+	if c, err := readConfigFile(configPath); err == nil && c.Paused {
+		uiLog.Infof("Monitoring is paused")
+		monitoringPaused.Store(true)
+		initial.showPaused()
+		mPause.Check()
+	}
+	initial.fillDiskLines()
+	initial.history = usageHistory.summary(time.Now())
+	initial.pace = usageHistory.pace(time.Now())
+	ui.publish(ui.nextGeneration(), initial)
+
+	startUpdate, refreshNow, fetchOnce := updateStarters(menu)
+
+	// manualRefresh is refreshNow for the Refresh item and icon clicks,
+	// which fetches while paused too. It does nothing while an update
+	// runs: restarting it would only begin the retries anew.
+	manualRefresh := func() {
+		if activeUpdates.Load() > 0 {
+			uiLog.Infof("Refresh already in progress")
+			return
+		}
+		fetchOnce()
+	}
+
+	// importFirefox imports cookies from profileDir, or from firefox_profile
+	// or the default profile if it is "". A profile picked in the submenu
+	// is remembered once it has yielded a session.
+	importFirefox := func(profileDir string) {
+		firefox.setTitle("Importing...")
+		var browser, sk, org, cfc string
+		var err error
+		if profileDir == "" {
+			firefoxLog.Infof("Importing cookies from Firefox")
+			browser, sk, org, cfc, err = findFirefoxCookies()
+		} else {
+			firefoxLog.Infof("Importing cookies from Firefox profile %s", profileDir)
+			browser, sk, org, cfc, err = firefoxCookiesFrom(profileDir)
+		}
+		if err == nil {
+			if werr := saveFirefoxImport(configPath, profileDir, sk, org, cfc); werr == nil {
+				firefoxLog.Infof("%s cookies saved to config", browser)
+				firefox.setTitle("Import from " + browser + " " + mark(markOK, accessibleText.Load()))
+				go firefox.load()
+				go chrome.load()
+				go cookieFiles.locate()
+				refreshNow()
+			} else {
+				configLog.Warnf("Failed to save config: %v", werr)
+				firefox.setTitle(firefoxMenuTitle + " " + mark(markFailed, accessibleText.Load()))
+			}
+		} else {
+			firefoxLog.Warnf("Firefox import failed: %v", err)
+			firefox.setTitle(firefoxMenuTitle + " " + mark(markFailed, accessibleText.Load()))
+		}
+		// Reset title after a few seconds
+		time.AfterFunc(4*time.Second, func() { firefox.setTitle(firefoxMenuTitle) })
+	}
+
+	// Icon activation: middle-/double-click runs the configured action
+	var iconAction atomic.Value
+	iconAction.Store(iconActionRefresh)
+	if c, err := readConfigFile(configPath); err == nil {
+		iconAction.Store(normalizeIconAction(c.IconAction))
+	}
+	runIconAction := func(action string) {
+		uiLog.Infof("Icon activated: %s", action)
+		switch action {
+		case iconActionOpenClaude:
+			openURL(claudeURL)
+		case iconActionCopyStatus:
+			if err := copyStatus(); err != nil {
+				uiLog.Warnf("Copy status failed: %v", err)
+			}
+		case iconActionStatus:
+			showStatusWindow()
+		default:
+			manualRefresh()
+		}
+	}
+
+	pol := loadPolicy()
+	if keys := pol.keys(); len(keys) > 0 {
+		mManaged := mSettings.AddSubMenuItem(truncate("Managed by policy: "+strings.Join(keys, ", "), maxMenuLine),
+			"Set by your administrator in "+pol.path)
+		mManaged.Disable()
+	}
+
+	mIconClick := mSettings.AddSubMenuItem("Icon middle-/double-click", "")
+	mIconClick.Disable()
+	activated := make(chan struct{}, 1)
+	hooked := hookIconActivation(activated)
+	if hooked && pol.managed("icon_action") {
+		// The choice is fixed; the items only show which action applies
+		mIconClick.SetTitle("Icon middle-/double-click (managed)")
+		for _, a := range iconActions {
+			if a.key == iconAction.Load() {
+				mIconClick.AddSubMenuItemCheckbox(a.title, "Set by policy", true).Disable()
+			}
+		}
+		mIconClick.Enable()
+		go func() {
+			defer recoverCrash("menu handler", nil)
+			for range activated {
+				runIconAction(iconAction.Load().(string))
+			}
+		}()
+	} else if hooked {
+		var actionItems []*systray.MenuItem
+		for _, a := range iconActions {
+			item := mIconClick.AddSubMenuItemCheckbox(a.title, "", a.key == iconAction.Load())
+			actionItems = append(actionItems, item)
+		}
+		mIconClick.Enable()
+		for i, a := range iconActions {
+			go func(i int, key string) {
+				defer recoverCrash("menu handler", nil)
+				for range actionItems[i].ClickedCh {
+					checkOnly(actionItems, i)
+					iconAction.Store(key)
+					if err := updateConfig(configPath, func(c *Config) { c.IconAction = key }); err != nil {
+						uiLog.Warnf("Failed to save icon_action: %v", err)
+					}
+				}
+			}(i, a.key)
+		}
+		go func() {
+			defer recoverCrash("menu handler", nil)
+			for range activated {
+				runIconAction(iconAction.Load().(string))
+			}
+		}()
+	} else {
+		mIconClick.SetTitle("Icon click actions: not supported on this platform")
+	}
+
+	// Icon shows ▸ one item per icon_mode
+	mIconMode := mSettings.AddSubMenuItem("Icon shows", "What the tray icon's numbers are")
+	mode := iconModeSplit
+	if c, err := readConfigFile(configPath); err == nil {
+		mode = c.iconMode()
+	}
+	var modeItems []*systray.MenuItem
+	for _, m := range iconModes {
+		modeItems = append(modeItems, mIconMode.AddSubMenuItemCheckbox(m.title, "", m.key == mode))
+	}
+	if pol.managed("icon_mode") || pol.managed("icon_style") {
+		mIconMode.SetTitle("Icon shows (managed)")
+		for _, item := range modeItems {
+			item.Disable()
+		}
+	}
+	for i, m := range iconModes {
+		go func(i int, key string) {
+			defer recoverCrash("menu handler", nil)
+			for range modeItems[i].ClickedCh {
+				checkOnly(modeItems, i)
+				if err := updateConfig(configPath, func(c *Config) {
+					c.IconMode = key
+					c.IconStyle = ""
+				}); err != nil {
+					uiLog.Warnf("Failed to save icon_mode: %v", err)
+				}
+				uiLog.Infof("Icon mode set to %s", key)
+				refreshNow()
+			}
+		}(i, m.key)
+	}
+
+	// Update every ▸ 1m / 5m / 15m / 30m / Adaptive. A hand-set interval
+	// that isn't one of the choices leaves them all unchecked.
+	mInterval := mSettings.AddSubMenuItem("Update every", "How often usage is fetched")
+	current := defaultUpdateInterval
+	adaptive := false
+	if c, err := readConfigFile(configPath); err == nil {
+		current, adaptive = c.updateInterval(), c.AdaptivePolling
+	}
+	scheduler.setBase(current)
+	intervalManaged := pol.managed("update_interval") || pol.managed("adaptive_polling")
+	var intervalItems []*systray.MenuItem
+	for _, d := range updateIntervalChoices {
+		intervalItems = append(intervalItems, mInterval.AddSubMenuItemCheckbox(shortDuration(d), "", !adaptive && d == current))
+	}
+	intervalItems = append(intervalItems, mInterval.AddSubMenuItemCheckbox("Adaptive",
+		"Every 1-2 minutes near a limit or a reset, 15-30 minutes when usage is low", adaptive))
+	if intervalManaged {
+		mInterval.SetTitle("Update every (managed)")
+		for _, item := range intervalItems {
+			item.Disable()
+		}
+	}
+	for i := range intervalItems {
+		go func(i int) {
+			defer recoverCrash("menu handler", nil)
+			for range intervalItems[i].ClickedCh {
+				checkOnly(intervalItems, i)
+				if i == len(updateIntervalChoices) {
+					if err := updateConfig(configPath, func(c *Config) { c.AdaptivePolling = true }); err != nil {
+						uiLog.Warnf("Failed to save adaptive_polling: %v", err)
+					}
+					uiLog.Infof("Adaptive polling turned on")
+					refreshNow()
+					continue
+				}
+				d := updateIntervalChoices[i]
+				wasAdaptive := false
+				if err := updateConfig(configPath, func(c *Config) {
+					wasAdaptive = c.AdaptivePolling
+					c.UpdateInterval = shortDuration(d)
+					c.AdaptivePolling = false
+				}); err != nil {
+					uiLog.Warnf("Failed to save update_interval: %v", err)
+				}
+				uiLog.Infof("Update interval set to %s", shortDuration(d))
+				scheduler.setBase(d)
+				if wasAdaptive {
+					refreshNow() // hides Next check
+				}
+			}
+		}(i)
+	}
 
-		go doUpdate(ctx, mSession, mWeekly, mSonnet)
+	// Start at login: registered with the OS, not in config.json, so the
+	// checkmark reads the registration
+	mAutostart := mSettings.AddSubMenuItemCheckbox("Start at login", "Start "+appName+" when you log in", false)
+	if exe, err := os.Executable(); err != nil {
+		uiLog.Warnf("Cannot determine executable path for autostart: %v", err)
+		mAutostart.Disable()
+	} else {
+		enabled := syncAutostart(exe)
+		if enabled {
+			mAutostart.Check()
+		}
+		go func() {
+			defer recoverCrash("menu handler", nil)
+			for range mAutostart.ClickedCh {
+				if err := setAutostart(!enabled, exe); err != nil {
+					uiLog.Warnf("Failed to change autostart: %v", err)
+					continue // the checkmark stays as it was
+				}
+				enabled = !enabled
+				if enabled {
+					ui.do(mAutostart.Check)
+				} else {
+					ui.do(mAutostart.Uncheck)
+				}
+			}
+		}()
 	}
 
+	// The menu is built; from here on only the UI goroutine changes it
+	go ui.run(menu.apply)
+
 	// Menu click handlers
 	go func() {
+		defer recoverCrash("menu handler", nil)
+		if crashTest {
+			panic("--crash-test")
+		}
 		for {
 			select {
+			case uuid := <-orgs.selected:
+				apiLog.Infof("Organization picked: %s", shortID(uuid))
+				if err := updateConfig(configPath, func(c *Config) { c.OrgID = uuid }); err != nil {
+					apiLog.Warnf("Failed to save org_id: %v", err)
+					break
+				}
+				orgs.mu.Lock()
+				list := orgs.orgs
+				orgs.mu.Unlock()
+				orgs.set(list, uuid)
+				refreshNow()
+			case <-mSessionExpiry.ClickedCh:
+				openURL(claudeURL)
+			case <-mOrgWarning.ClickedCh:
+				cfg, err := loadConfigNoOrg(configPath)
+				if err != nil || len(cfg.Accounts) > 0 {
+					openPath(configPath)
+					break
+				}
+				apiLog.Infof("Re-running organization discovery")
+				if _, err := discoverOrg(context.Background(), cfg, menu, updateConfig); err != nil {
+					apiLog.Warnf("Organization discovery failed: %v", err)
+					break
+				}
+				refreshNow()
 			case <-mRefresh.ClickedCh:
-				log.Println("Manual refresh")
-				startUpdate()
-			case <-mFirefox.ClickedCh:
-				log.Println("Importing cookies from Firefox")
-				mFirefox.SetTitle("Importing...")
-				if sk, org, cfc, err := findFirefoxCookies(); err == nil {
+				uiLog.Infof("Manual refresh")
+				manualRefresh()
+			case <-mPause.ClickedCh:
+				if monitoringPaused.Load() {
+					ui.do(mPause.Uncheck)
+					setPaused(false, refreshNow)
+				} else {
+					ui.do(mPause.Check)
+					setPaused(true, refreshNow)
+				}
+			case <-mStatusWindow.ClickedCh:
+				go showStatusWindow()
+			case <-mOpenClaude.ClickedCh:
+				openURL(claudeURL)
+			case <-mOpenUsage.ClickedCh:
+				openURL(claudeUsageURL)
+			case <-mCopyStatus.ClickedCh:
+				if err := copyStatus(); err == nil {
+					showTitle(mCopyStatus, "Copy status "+mark(markOK, accessibleText.Load()))
+				} else {
+					uiLog.Warnf("Copy status failed: %v", err)
+					showTitle(mCopyStatus, truncate(mark(markFailed, accessibleText.Load())+" "+err.Error(), maxMenuLine))
+				}
+				ui.doAfter(4*time.Second, func() { mCopyStatus.SetTitle("Copy status") })
+			case <-firefox.single.ClickedCh:
+				importFirefox("")
+			case dir := <-firefox.selected:
+				importFirefox(dir)
+			case b := <-chrome.selected:
+				firefoxLog.Infof("Importing cookies from %s", b.name)
+				showTitle(chrome.parent, "Importing...")
+				if sk, org, cfc, err := chromeCookiesFrom(b); err == nil {
+					save := firefoxCookies(sk, org, cfc)
+					if werr := updateConfig(configPath, func(c *Config) {
+						save(c)
+						c.ChromeBrowser = strings.ToLower(b.name)
+					}); werr == nil {
+						firefoxLog.Infof("%s cookies saved to config", b.name)
+						showTitle(chrome.parent, "Import from "+b.name+" "+mark(markOK, accessibleText.Load()))
+						go chrome.load()
+						go cookieFiles.locate()
+						refreshNow()
+					} else {
+						configLog.Warnf("Failed to save config: %v", werr)
+						showTitle(chrome.parent, "Import from "+b.name+" "+mark(markFailed, accessibleText.Load()))
+					}
+				} else {
+					firefoxLog.Warnf("%s import failed: %v", b.name, err)
+					showTitle(chrome.parent, "Import from "+b.name+" "+mark(markFailed, accessibleText.Load()))
+				}
+				ui.doAfter(4*time.Second, func() { chrome.parent.SetTitle(chromeMenuTitle) })
+			case <-mSafari.ClickedCh:
+				firefoxLog.Infof("Importing cookies from Safari")
+				showTitle(mSafari, "Importing...")
+				reset := 4 * time.Second
+				if sk, org, cfc, err := findSafariCookies(); err == nil {
 					if werr := saveFirefoxConfig(configPath, sk, org, cfc); werr == nil {
-						log.Println("Firefox cookies saved to config")
-						mFirefox.SetTitle("Import from Firefox ✓")
-						startUpdate()
+						firefoxLog.Infof("Safari cookies saved to config")
+						showTitle(mSafari, "Import from Safari "+mark(markOK, accessibleText.Load()))
+						refreshNow()
 					} else {
-						log.Println("Failed to save config:", werr)
-						mFirefox.SetTitle("Import from Firefox ✗")
+						configLog.Warnf("Failed to save config: %v", werr)
+						showTitle(mSafari, "Import from Safari "+mark(markFailed, accessibleText.Load()))
 					}
+				} else if errors.Is(err, errSafariAccess) {
+					firefoxLog.Warnf("%v", err)
+					// Long enough to read, and to find the setting
+					showTitle(mSafari, mark(markFailed, accessibleText.Load())+" Safari: grant Full Disk Access in System Settings")
+					reset = 30 * time.Second
 				} else {
-					log.Println("Firefox import failed:", err)
-					mFirefox.SetTitle("Import from Firefox ✗")
+					firefoxLog.Warnf("Safari import failed: %v", err)
+					showTitle(mSafari, "Import from Safari "+mark(markFailed, accessibleText.Load()))
+				}
+				ui.doAfter(reset, func() { mSafari.SetTitle("Import from Safari") })
+			case <-mClipboard.ClickedCh:
+				firefoxLog.Infof("Importing cookies from the clipboard")
+				reset := 4 * time.Second
+				text, err := readClipboard()
+				var sk, org, cfc string
+				if err == nil {
+					sk, org, cfc, err = parsePastedCookies(text)
+				}
+				if err == nil {
+					if werr := saveFirefoxConfig(configPath, sk, org, cfc); werr == nil {
+						firefoxLog.Infof("Clipboard cookies saved to config: org_id=%s cf_clearance=%v", shortID(org), cfc != "")
+						showTitle(mClipboard, "Import from clipboard "+mark(markOK, accessibleText.Load()))
+						refreshNow()
+					} else {
+						configLog.Warnf("Failed to save config: %v", werr)
+						showTitle(mClipboard, "Import from clipboard "+mark(markFailed, accessibleText.Load()))
+					}
+				} else {
+					firefoxLog.Warnf("Clipboard import failed: %v", err)
+					// Say what was wrong with what was copied
+					showTitle(mClipboard, truncate(mark(markFailed, accessibleText.Load())+" "+err.Error(), maxMenuLine))
+					reset = 10 * time.Second
+				}
+				ui.doAfter(reset, func() { mClipboard.SetTitle("Import from clipboard") })
+			case <-mLastError.ClickedCh:
+				if err := copyToClipboard(lastOutcome.details()); err != nil {
+					uiLog.Warnf("Copy last error failed: %v", err)
 				}
-				// Reset title after a few seconds
-				go func() {
-					time.Sleep(4 * time.Second)
-					mFirefox.SetTitle("Import from Firefox")
-				}()
 			case <-mEditCfg.ClickedCh:
-				openFile(configPath)
+				openPath(configPath)
 			case <-mOpenLog.ClickedCh:
-				dir := filepath.Dir(configPath)
-				openFile(filepath.Join(dir, "claude-monitor.log"))
-			case <-mQuit.ClickedCh:
-				updateMu.Lock()
-				if cancelUpdate != nil {
-					cancelUpdate()
+				openPath(paths.logFile())
+			case <-mMoveData.ClickedCh:
+				msgs, err := moveOldData(paths.stateDir, oldDataDirs)
+				for _, msg := range msgs {
+					uiLog.Infof("%s", msg)
+				}
+				if err != nil {
+					uiLog.Warnf("Move old data: %v", err)
+					showTitle(mMoveData, "Move old data here "+mark(markFailed, accessibleText.Load()))
+					ui.doAfter(4*time.Second, func() { mMoveData.SetTitle("Move old data here") })
+					break
+				}
+				showTitle(mMoveData, "Move old data here "+mark(markOK, accessibleText.Load()))
+				ui.doAfter(4*time.Second, mMoveData.Hide)
+			case <-mSaveResp.ClickedCh:
+				path := filepath.Join(paths.stateDir, "last-api-response.txt")
+				if err := saveLastFailedResponse(path); err != nil {
+					uiLog.Warnf("Save last API response: %v", err)
+					showTitle(mSaveResp, "Save last API response "+mark(markFailed, accessibleText.Load()))
+				} else {
+					uiLog.Infof("Last API response saved to %s", path)
+					openPath(path)
+				}
+				ui.doAfter(4*time.Second, func() { mSaveResp.SetTitle("Save last API response") })
+			case <-history.open.ClickedCh:
+				openPath(paths.historyFile())
+			case <-mDiagBundle.ClickedCh:
+				path := diagBundlePath(time.Now())
+				title := "Save diagnostics bundle " + mark(markOK, accessibleText.Load())
+				if err := writeDiagBundle(path, time.Now()); err != nil {
+					uiLog.Warnf("Save diagnostics bundle: %v", err)
+					title = "Save diagnostics bundle " + mark(markFailed, accessibleText.Load())
+				} else {
+					uiLog.Infof("Diagnostics bundle saved to %s", path)
+					openPath(filepath.Dir(path)) // the folder, to attach the file
 				}
-				updateMu.Unlock()
-				systray.Quit()
+				showTitle(mDiagBundle, title)
+				ui.doAfter(4*time.Second, func() { mDiagBundle.SetTitle("Save diagnostics bundle") })
+			case <-mTestNotify.ClickedCh:
+				go func() {
+					defer recoverCrash("menu handler", nil)
+					title := "Test notification " + mark(markOK, accessibleText.Load())
+					if err := notify(appName, "Notifications work. Thresholds are set with notify_session_at and notify_weekly_at."); err != nil {
+						uiLog.Warnf("Test notification failed: %v", err)
+						health.report("Notifications", false, err.Error())
+						title = truncate("Test notification "+mark(markFailed, accessibleText.Load())+" "+err.Error(), maxMenuLine)
+					} else {
+						health.report("Notifications", true, "shown")
+					}
+					showTitle(mTestNotify, title)
+					ui.doAfter(4*time.Second, func() { mTestNotify.SetTitle("Test notification") })
+				}()
+			case <-mTelegramTest.ClickedCh:
+				go func() {
+					defer recoverCrash("menu handler", nil)
+					title := "Send test message " + mark(markOK, accessibleText.Load())
+					if err := sendTelegramTest(); err != nil {
+						uiLog.Warnf("Telegram test message failed: %v", err)
+						health.report("Telegram", false, err.Error())
+						title = truncate("Send test message "+mark(markFailed, accessibleText.Load())+" "+err.Error(), maxMenuLine)
+					} else {
+						health.report("Telegram", true, "test message sent")
+					}
+					showTitle(mTelegramTest, title)
+					ui.doAfter(4*time.Second, func() { mTelegramTest.SetTitle("Send test message") })
+				}()
+			case <-mNewRelease.ClickedCh:
+				if r := newRelease.Load(); r != nil {
+					openURL(r.URL)
+				}
+			case <-mAbout.ClickedCh:
+				if err := showAbout(); err != nil {
+					uiLog.Warnf("About: %v", err)
+				}
+			case <-mQuit.ClickedCh:
+				shutdown()
+				return
 			}
 		}
 	}()
 
-	// Auto-update loop with jitter to avoid predictable request patterns
+	// Nobody reads the numbers while away from the desk; the tray only,
+	// headless has no user to wait for
+	go watchIdle(refreshNow)
+
+	// Report on hand edits of config.json right away instead of at the
+	// next poll, which would silently keep using the old settings
+	var feedbackTimer *time.Timer
+	watchForUpdates(startUpdate, refreshNow, func(msg string) {
+		showTitle(mHeader, msg)
+		if feedbackTimer != nil {
+			feedbackTimer.Stop()
+		}
+		feedbackTimer = ui.doAfter(configFeedbackFor, func() { mHeader.SetTitle(appName) })
+	})
+}
+
+// updateStarters returns startUpdate, which cancels any in-flight update
+// and starts a new one for view in a goroutine, and refreshNow, the same
+// for user-initiated refreshes, which also end any failure backoff. Both
+// do nothing while monitoring is paused or the user is away; fetchOnce is
+// refreshNow that fetches during a pause too, for Refresh now.
+func updateStarters(view updateView) (startUpdate, refreshNow, fetchOnce func()) {
+	start := func(whilePaused bool) {
+		updateMu.Lock()
+		defer updateMu.Unlock()
+		if shuttingDown || ((monitoringPaused.Load() || idlePaused.Load()) && !whilePaused) {
+			return
+		}
+		if cancelUpdate != nil {
+			cancelUpdate()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelUpdate = cancel
+
+		updateWG.Add(1)
+		activeUpdates.Add(1)
+		go func() {
+			defer updateWG.Done()
+			defer activeUpdates.Add(-1)
+			// The next update starts as usual
+			defer recoverCrash("update", func() {})
+			doUpdate(ctx, view)
+		}()
+	}
+	startUpdate = func() { start(false) }
+	refreshNow = func() {
+		scheduler.reset()
+		start(false)
+	}
+	fetchOnce = func() {
+		scheduler.reset()
+		start(true)
+	}
+	return startUpdate, refreshNow, fetchOnce
+}
+
+// watchForUpdates starts everything besides the menu that triggers
+// updates: the poll loop and the network, config.json, browser cookie and
+// resume watchers. configFeedback shows the verdict on a hand edit of
+// config.json.
+func watchForUpdates(startUpdate, refreshNow func(), configFeedback func(string)) {
+	go watchNetwork(startUpdate)
+
+	go watchConfig(configFeedback, refreshNow)
+
+	// cf_clearance rotates while claude.ai is open in the browser; pick up
+	// the new one before it's rejected
+	go watchBrowserCookies(startUpdate)
+
+	// A re-login in Firefox shows up as a write to its cookie database
 	go func() {
-		time.Sleep(2 * time.Second)
-		startUpdate()
+		cookieFiles.locate()
+		cookieFiles.run(func(profileDir string) {
+			if refreshBrowserCookies("after Firefox wrote its cookies", func() (string, string, string, string, error) {
+				return firefoxCookiesFrom(profileDir)
+			}) {
+				startUpdate()
+			}
+		})
+	}()
 
-		for {
-			// ±30 second jitter around updateInterval
-			jitter := time.Duration(rand.Int63n(60)-30) * time.Second
-			time.Sleep(updateInterval + jitter)
+	// After sleep the data is as old as the nap; refresh right away. This
+	// also re-arms the auto-update timer below.
+	watchResume(refreshNow)
+
+	go pollLoop(startUpdate)
+}
+
+// pollLoop is the auto-update loop, with jitter to avoid predictable
+// request patterns. A panic in it is reported and the loop started again.
+func pollLoop(startUpdate func()) {
+	defer recoverCrash("update loop", restartAfter(func() { pollLoop(startUpdate) }))
+	time.Sleep(2 * time.Second)
+	startUpdate()
+
+	for {
+		// The interval is update_interval, stretched after repeated
+		// failures. The timer is re-armed whenever it may have
+		// changed: a new setting, a failure or a manual refresh.
+		// A limit that resets before then cuts the wait short.
+		now := time.Now()
+		wait := scheduler.wait(now)
+		scheduler.armed(wait, now)
+		ui.refresh()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
 			startUpdate()
+		case <-scheduler.changed:
+			timer.Stop()
 		}
+	}
+}
+
+// shutdownTimeout bounds how long Quit waits for an in-flight update.
+const shutdownTimeout = 3 * time.Second
+
+// shutdown stops the app in order: no new updates, cancel and wait for the
+// running one, stop the UI goroutine, close the log, then quit the tray.
+func shutdown() {
+	uiLog.Infof("Shutting down")
+	stopUpdates()
+	closeLog()
+	systray.Quit()
+}
+
+// stopUpdates is the part of shutting down that the tray and headless
+// modes share: it stops new updates, cancels the running one and waits for
+// it, then stops the watchers and the UI goroutine.
+func stopUpdates() {
+	updateMu.Lock()
+	shuttingDown = true
+	if cancelUpdate != nil {
+		cancelUpdate()
+	}
+	updateMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		updateWG.Wait()
+		close(done)
 	}()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		uiLog.Warnf("Update still running after %v - quitting anyway", shutdownTimeout)
+	}
+
+	cookieFiles.stop()
+	metricsServer.stop()
+	ui.stop()
+}
+
+// closeLog flushes and closes the log file; later log output is dropped.
+func closeLog() {
+	closeLogOnce.Do(func() {
+		uiLog.Infof("Exiting %s", appName)
+		if appLog != nil {
+			log.SetOutput(io.Discard)
+			appLog.Close()
+		}
+	})
 }
 
 func onExit() {
-	log.Println("Exiting", appName)
-	if logFile != nil {
-		logFile.Close()
+	closeLog()
+}
+
+// usageMenu holds the menu items that display usage data.
+type usageMenu struct {
+	refresh   *systray.MenuItem // retitled while an update runs
+	updated   *systray.MenuItem // hidden until the first successful update
+	nextCheck *systray.MenuItem // hidden until the update loop starts
+	lastError *systray.MenuItem // hidden until the first update
+	session   *systray.MenuItem
+	weekly    *systray.MenuItem
+
+	sessionPace, weeklyPace *systray.MenuItem // hidden until there is a pace
+	opus                    *systray.MenuItem
+	sonnet                  *systray.MenuItem
+	extra                   *systray.MenuItem
+
+	cloudflare    *systray.MenuItem // Diagnostics ▸ Cloudflare line
+	orgs          *orgMenu
+	accounts      *accountMenus
+	orgWarning    *systray.MenuItem
+	sessionExpiry *systray.MenuItem
+	spending      *spendingMenu
+	history       *historyMenu
+}
+
+// cloudflareLine renders the Diagnostics ▸ Cloudflare row from config and
+// state, and returns what it goes by for the metrics and status.
+func cloudflareLine() (string, cloudflareInfo) {
+	var clearance string
+	if cfg, err := readConfigFile(configPath); err == nil {
+		clearance = strings.TrimSpace(cfg.CfClearance)
 	}
+	st, _ := readStateFile(statePath())
+	return cloudflareDiagnostics(clearance, st, time.Now()), cloudflareInfoOf(clearance, st)
 }
 
-func doUpdate(ctx context.Context, mSession, mWeekly, mSonnet *systray.MenuItem) {
-	cfg, err := loadConfig(configPath)
+// sessionExpiryLine renders the sessionKey expiry warning from config and
+// state; "" hides it.
+func sessionExpiryLine() string {
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return ""
+	}
+	st, _ := readStateFile(statePath())
+	return sessionExpiryWarning(strings.TrimSpace(cfg.SessionKey), st, time.Now(), accessibleText.Load())
+}
+
+// doUpdate fetches usage and publishes the resulting UI snapshot. It makes
+// no systray calls itself; see uiUpdater and updateView.
+func doUpdate(ctx context.Context, view updateView) {
+	gen := ui.nextGeneration()
+	refreshAccessibility()
+	st := ui.state()
+	st.progress = "" // left over if the previous update was cancelled
+
+	migrateSecretsToKeyring(configPath)
+	cfg, err := loadConfigWithDiscoveredOrg(ctx, view)
+	var usage *UsageResponse
+	defer func() {
+		if ctx.Err() == nil {
+			usageMetrics.record(usage, err, time.Now())
+			usageMetrics.setCloudflare(st.cloudflareInfo)
+			writeStatusFile(cfg, usageMetrics.status(time.Now()))
+			doc := newStatusDoc(usage, err)
+			doc.Cloudflare = st.cloudflareInfo.status(doc.UpdatedAt)
+			runUpdateHook(cfg, doc)
+		}
+	}()
 	if err != nil {
-		log.Println("Config error:", err)
-		systray.SetIcon(iconGray)
-		systray.SetTooltip(appName + ": config error")
-		mSession.SetTitle("! Error: setup config.json")
+		configLog.Errorf("Config error: %v", err)
+		lastOutcome.record(fmt.Errorf("%w: %w", errConfigUpdate, err), time.Now())
+		st.lastError = lastOutcome.line(accessibleText.Load())
+		st.setStatusIcon(statusConfigError)
+		st.tooltip = appName + ": config error"
+		st.session = mark(markError, accessibleText.Load()) + " Setup config.json"
+		st.lines[0] = nil
+		st.accounts = view.accountViews(nil, nil)
+		st.fillDiskLines()
+		ui.publish(gen, st)
+		return
+	}
+
+	metricsServer.configure(cfg)
+	appLog.configure(cfg)
+	configureLogging(cfg)
+	knownSecrets.remember(cfg)
+	if !cfg.AdaptivePolling {
+		scheduler.setBase(cfg.updateInterval())
+	}
+
+	busy := st
+	busy.progress = refreshingText
+	ui.publish(gen, busy)
+
+	// Accounts are fetched concurrently; one failing leaves the others'
+	// rows intact. Retries of the account shown in the icon are reported
+	// as they happen.
+	accounts := cfg.accountConfigs()
+	primary := cfg.primaryAccount()
+	results := make([]accountResult, len(accounts))
+	var wg sync.WaitGroup
+	for i, acc := range accounts {
+		var progress func(retryProgress)
+		if i == primary {
+			progress = publishProgress(ui, gen)
+		}
+		wg.Add(1)
+		go func(i int, acc *Config) {
+			defer wg.Done()
+			r := &results[i]
+			r.usage, r.err = fetchAccount(ctx, acc, view, progress)
+			if r.err == nil {
+				reason, checked := checkOrgMembership(ctx, acc, r.usage)
+				if reason != "" {
+					r.orgWarning = orgWarning(acc, reason)
+				}
+				r.orgNotes = orgMismatchNotices.check(acc, reason, checked)
+			}
+		}(i, acc)
+	}
+	wg.Wait()
+
+	iconAccount := cfg.IconAccount
+	cfg = accounts[primary]
+	usage, err = results[primary].usage, results[primary].err
+	if err != nil && ctx.Err() != nil {
+		// Context was cancelled (quit or new refresh) — don't update UI
 		return
 	}
 
-	usage, err := fetchUsage(ctx, cfg)
+	st.accounts = view.accountViews(accounts, results)
+	var warnings []string
+	for _, r := range results {
+		if r.orgWarning != "" {
+			warnings = append(warnings, r.orgWarning)
+		}
+	}
+	st.orgWarning = truncate(strings.Join(warnings, "; "), maxMenuLine)
+	for i, r := range results {
+		if r.err != nil && i != primary {
+			apiLog.Errorf("API error (%s): %v", accounts[i].accountName, r.err)
+		}
+	}
+
+	failed := true
+	for _, r := range results {
+		if r.err == nil {
+			failed = false
+		}
+	}
+	if cfg.AdaptivePolling && err == nil {
+		d, why := adaptiveInterval(usage, time.Now())
+		if d != scheduler.baseInterval() {
+			uiLog.Infof("Adaptive polling: every %s (%s)", shortDuration(d), why)
+		}
+		scheduler.setBase(d)
+	}
+	if err == nil {
+		scheduler.setNextReset(nextReset(usage, time.Now()))
+	}
+	scheduler.record(!failed)
+	networkDown.Store(failed && isNetworkError(err))
+	prevKind := lastOutcome.record(err, time.Now())
+	st.lastError = lastOutcome.line(accessibleText.Load())
+
+	notes := presentUpdate(&st, cfg, usage, err, scheduler.backingOff(), time.Now())
+	for _, r := range results {
+		notes = append(notes, r.orgNotes...)
+	}
+	for _, ev := range outcomeEvents(prevKind, err, time.Now()) {
+		ev := ev
+		notes = append(notes, notification{event: &ev})
+	}
+	if err == nil {
+		if err := saveState(statePath(), usage); err != nil {
+			uiLog.Warnf("Failed to save state: %v", err)
+		}
+		usageHistory.record(usage, st.bucketChanges, time.Now())
+		st.history = usageHistory.summary(time.Now())
+		st.pace = usageHistory.pace(time.Now())
+		notes = append(notes, checkPaceAlerts(cfg, st.pace)...)
+	}
 
-	// On Cloudflare 403, try to auto-refresh cookies from Firefox and retry once
+	if len(accounts) > 1 {
+		var tooltip []string
+		for i, r := range results {
+			if r.err != nil {
+				tooltip = append(tooltip, accounts[i].accountName+": error")
+				continue
+			}
+			tooltip = append(tooltip, accounts[i].accountName+" "+renderTooltip(r.usage, "", accessibleText.Load()))
+		}
+		st.tooltip = strings.Join(tooltip, "\n")
+		if iconAccount == iconAccountWorst {
+			if w := worstUsage(results); w != nil {
+				st.setUsageIcon(usageIcon(cfg, w, w.SevenDayOpus, w.SevenDaySonnet))
+			}
+		}
+	}
+	st.fillDiskLines()
+	if monitoringPaused.Load() {
+		st.showPaused()
+	} else if idlePaused.Load() {
+		st.showIdle()
+	}
+	ui.publish(gen, st)
+	postNotifications(cfg, notes)
+
+	for i, r := range results {
+		if r.err == nil {
+			uiLog.Infof("OK%s: session=%d%% weekly=%d%%", accountLabel(accounts[i]),
+				int(r.usage.FiveHour.Utilization), int(r.usage.SevenDay.Utilization))
+		}
+	}
+}
+
+// notification is something an update has to tell the user, such as a
+// spend alert. An event goes to the integrations too; one without a title
+// is for them alone.
+type notification struct {
+	title, body string
+	event       *alertEvent
+}
+
+// presentUpdate renders the outcome of the primary account's update into
+// st and runs the checks that raise notifications, which it returns. It is
+// the part of an update that replay runs too, so it fetches nothing and
+// doesn't post or export anything itself.
+func presentUpdate(st *uiState, cfg *Config, usage *UsageResponse, err error, retryIn time.Duration, now time.Time) []notification {
+	if err != nil {
+		showUpdateError(st, err, retryIn)
+		return nil
+	}
+	notes := checkUsageAlerts(cfg, usage)
+	notes = append(notes, checkSpendAlerts(cfg, usage.ExtraUsage, now)...)
+	renderUsage(st, cfg, usage, "")
+	return notes
+}
+
+// postNotifications passes on what an update raised: to the log and as
+// desktop notifications, shown one after another in the background, and
+// the events to cfg's integrations.
+func postNotifications(cfg *Config, notes []notification) {
+	if len(notes) == 0 {
+		return
+	}
+	if end, quiet := cfg.quietUntil(timeNow()); quiet {
+		silence(cfg, notes, end)
+		return
+	}
+	sendNotifications(cfg, notes)
+}
+
+// sendNotifications posts notes regardless of quiet hours.
+func sendNotifications(cfg *Config, notes []notification) {
+	var shown []notification
+	var events []alertEvent
+	for _, n := range notes {
+		if n.event != nil {
+			events = append(events, *n.event)
+		}
+		if n.title != "" {
+			uiLog.Infof("%s: %s", n.title, n.body)
+			shown = append(shown, n)
+		}
+	}
+	dispatchEvents(cfg, events)
+	if len(shown) == 0 {
+		return
+	}
+	go func() {
+		for _, n := range shown {
+			if err := notify(n.title, n.body); err != nil {
+				uiLog.Warnf("Notification failed: %v", err)
+				health.report("Notifications", false, err.Error())
+				return
+			}
+		}
+		health.report("Notifications", true, "shown")
+	}()
+}
+
+// accountLabel returns " (name)" for an entry of the accounts array, or ""
+// for the flat single-account form, for use in log lines.
+func accountLabel(cfg *Config) string {
+	if cfg.accountName == "" {
+		return ""
+	}
+	return " (" + cfg.accountName + ")"
+}
+
+// fetchAccount fetches one account's usage, fixing a stale org_id and
+// refreshing cf_clearance from Firefox when that lets a retry succeed.
+// progress is passed on to fetchUsage.
+func fetchAccount(ctx context.Context, cfg *Config, view updateView, progress func(retryProgress)) (*UsageResponse, error) {
+	usage, err := fetchUsage(ctx, cfg, progress)
+
+	// The org may have been left or deleted since it was saved: if it is no
+	// longer listed, switch to one that is and retry once. Org discovery
+	// only manages the flat org_id, not entries of the accounts array.
+	if err != nil && isOrgRejected(err) && cfg.accountIndex < 0 && !cfg.StrictNetwork {
+		if orgList, lerr := fetchOrganizations(ctx, cfg); lerr != nil {
+			apiLog.Warnf("Organization check failed: %v", lerr)
+		} else if findOrganization(orgList, cfg.OrgID) {
+			view.setOrgs(orgList, cfg.OrgID)
+		} else {
+			apiLog.Warnf("Configured org_id is not among this session's organizations")
+			if _, derr := discoverOrg(ctx, cfg, view, updateConfigAuto); derr != nil {
+				apiLog.Warnf("Organization discovery failed: %v", derr)
+			} else if c, lerr := reloadAccount(cfg); lerr == nil {
+				cfg = c
+				usage, err = fetchUsage(ctx, cfg, progress)
+			}
+		}
+	}
+
+	// On Cloudflare 403, try to auto-refresh cookies from the browser and retry once
 	if err != nil && isCloudflare(err) {
-		log.Println("Cloudflare block detected, attempting Firefox cookie refresh...")
-		if sk, org, cfc, ferr := findFirefoxCookies(); ferr == nil && cfc != "" {
-			if werr := saveFirefoxConfig(configPath, sk, org, cfc); werr == nil {
-				log.Println("cf_clearance refreshed from Firefox, retrying...")
-				cfg, _ = loadConfig(configPath)
-				usage, err = fetchUsage(ctx, cfg)
+		recordCloudflareBlock(time.Now())
+		if !cfg.autoImport() {
+			firefoxLog.Warnf("Cloudflare block detected%s; auto_import is off, update cf_clearance in config.json", accountLabel(cfg))
+			return usage, err
+		}
+		firefoxLog.Warnf("Cloudflare block detected%s, attempting browser cookie refresh...", accountLabel(cfg))
+		if browser, sk, org, cfc, ferr := findBrowserCookies(); ferr == nil && cfc != "" {
+			var werr error
+			if cfg.accountIndex < 0 {
+				werr = updateConfigAuto(configPath, firefoxCookies(sk, org, cfc))
+			} else {
+				// cf_clearance belongs to the browser, not the account, so
+				// it applies whichever account the browser is logged in to
+				idx := cfg.accountIndex
+				werr = updateConfigAuto(configPath, func(c *Config) {
+					if idx < len(c.Accounts) {
+						c.Accounts[idx].CfClearance = cfc
+					}
+				})
+			}
+			if werr != nil {
+				firefoxLog.Warnf("Failed to save refreshed cf_clearance: %v", werr)
+			} else {
+				firefoxLog.Infof("cf_clearance refreshed from %s, retrying...", browser)
+				if c, lerr := reloadAccount(cfg); lerr == nil {
+					cfg = c
+					usage, err = fetchUsage(ctx, cfg, progress)
+				}
 			}
 		} else if ferr != nil {
-			log.Println("Firefox cookie refresh failed:", ferr)
+			firefoxLog.Warnf("Browser cookie refresh failed: %v", ferr)
 		}
 	}
+	return usage, err
+}
 
+// reloadAccount re-reads config.json and returns the same account as cfg.
+func reloadAccount(cfg *Config) (*Config, error) {
+	c, err := loadConfig(configPath)
 	if err != nil {
-		if ctx.Err() != nil {
-			// Context was cancelled (quit or new refresh) — don't update UI
-			return
+		return nil, err
+	}
+	accounts := c.accountConfigs()
+	if cfg.accountIndex < 0 {
+		return accounts[0], nil
+	}
+	if cfg.accountIndex >= len(accounts) {
+		return nil, fmt.Errorf("account %q no longer configured", cfg.accountName)
+	}
+	return accounts[cfg.accountIndex], nil
+}
+
+// showUpdateError dims the icon and puts a short description of err in the
+// session row. A non-zero retryIn is mentioned in the tooltip.
+func showUpdateError(st *uiState, err error, retryIn time.Duration) {
+	accessible := accessibleText.Load()
+	var retry string
+	if retryIn > 0 {
+		if accessible {
+			retry = ", retrying in " + plural(int(retryIn.Minutes()), "minute")
+		} else {
+			retry = " — retrying in " + shortDuration(retryIn)
 		}
-		log.Println("API error:", err)
-		systray.SetIcon(iconGray)
-		systray.SetTooltip(appName + ": API error")
-		mSession.SetTitle("! API error (see log)")
+	}
+	st.setStatusIcon(statusAPIError)
+	st.lines[0] = nil // the session row shows the error
+	var nerr *ErrNetConfig
+	if errors.As(err, &nerr) {
+		apiLog.Errorf("Network config error: %v", err)
+		st.tooltip = appName + ": network config error" + retry
+		st.session = mark(markError, accessible) + " " + truncate(nerr.Msg, 60)
+		return
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		apiLog.Errorf("DNS error: %v", err)
+		st.tooltip = appName + ": DNS lookup failed" + retry
+		st.session = mark(markError, accessible) + " DNS lookup failed for " + truncate(dnsErr.Name, 40)
 		return
 	}
+	var uaErr x509.UnknownAuthorityError
+	if errors.As(err, &uaErr) {
+		apiLog.Errorf("TLS error: %v", err)
+		st.tooltip = appName + ": TLS certificate not trusted" + retry
+		st.session = mark(markError, accessible) + " TLS certificate not trusted (set ca_cert_file)"
+		return
+	}
+	apiLog.Errorf("API error: %v", err)
+	st.tooltip = appName + ": API error" + retry
+	st.session = mark(markError, accessible) + " API error (see log)"
+}
 
-	sessionPct := int(usage.FiveHour.Utilization)
-	weeklyPct := int(usage.SevenDay.Utilization)
+// loadConfigWithDiscoveredOrg loads config.json for an update. A config
+// without org_id is completed by looking the organization up with the
+// session key and saving it.
+func loadConfigWithDiscoveredOrg(ctx context.Context, view updateView) (*Config, error) {
+	cfg, err := loadConfig(configPath)
+	if !errors.Is(err, errNoOrgID) {
+		return cfg, err
+	}
+	cfg, err = loadConfigNoOrg(configPath)
+	if err != nil {
+		return nil, err
+	}
+	apiLog.Infof("org_id not configured, looking up organizations")
+	if _, err := discoverOrg(ctx, cfg, view, updateConfigAuto); err != nil {
+		return nil, err
+	}
+	return loadConfig(configPath)
+}
+
+// renderUsage fills the icon, tooltip and rows of st from usage. A non-empty
+// stale label (e.g. "3m ago") marks data restored from a previous run.
+// cfg supplies display settings and may be nil.
+func renderUsage(st *uiState, cfg *Config, usage *UsageResponse, stale string) {
+	opts := bucketLineOpts{Stale: stale, Accessible: accessibleText.Load(), ResetFormat: cfg.resetFormat()}
 
-	// Tooltip: compact two numbers
-	systray.SetTooltip(fmt.Sprintf("S:%d%% W:%d%%", sessionPct, weeklyPct))
+	st.tooltip = renderTooltip(usage, stale, opts.Accessible)
 
-	// Generate two-color icon: left=session remaining, right=weekly remaining
-	systray.SetIcon(makeIcon(100-sessionPct, 100-weeklyPct))
+	opus, opusChange := opusPresence.observe(usage.SevenDayOpus)
+	sonnet, sonnetChange := sonnetPresence.observe(usage.SevenDaySonnet)
+	st.bucketChanges = appendBucketChange(nil, "seven_day_opus", opusChange)
+	st.bucketChanges = appendBucketChange(st.bucketChanges, "seven_day_sonnet", sonnetChange)
+
+	// Restored readings are too old to say where usage is heading
+	var trends bucketTrends
+	if stale == "" {
+		trends = recentTrends.observe(usage, opus, sonnet, timeNow())
+	}
+
+	key, template := usageIcon(cfg, usage, opus, sonnet)
+	if cfg.iconTrend() {
+		key = trends.withArrows(key)
+	}
+	st.setUsageIcon(key, template)
+	st.title = renderTrayTitle(cfg.trayTitleFormat(), usage, opus, sonnet, cfg.iconShowsUsed())
+	blinkFor, alerting := cfg.criticalBlink()
+	st.critical = alerting && anyCritical(usage, opus, sonnet)
+	if stale == "" {
+		criticalAlert.observe(st.critical, blinkFor, timeNow())
+	}
 
 	// Detailed menu items
-	mSession.SetTitle(fmt.Sprintf("Session (5h): %d%% — reset %s",
-		sessionPct, formatReset(usage.FiveHour.ResetsAt)))
-	mWeekly.SetTitle(fmt.Sprintf("Weekly: %d%% — reset %s",
-		weeklyPct, formatReset(usage.SevenDay.ResetsAt)))
-
-	if usage.SevenDaySonnet != nil {
-		mSonnet.SetTitle(fmt.Sprintf("Sonnet: %d%% — reset %s",
-			int(usage.SevenDaySonnet.Utilization),
-			formatReset(usage.SevenDaySonnet.ResetsAt)))
-	} else {
-		mSonnet.SetTitle("Sonnet: n/a")
+	st.lines = [4]*bucketLine{
+		newBucketLine("Session (5h)", &usage.FiveHour, opts),
+		newBucketLine("Weekly", &usage.SevenDay, opts),
+		newBucketLine("Opus", opus, opts),
+		newBucketLine("Sonnet", sonnet, opts),
+	}
+	for i, l := range st.lines {
+		l.trend = trends[i]
 	}
+	st.renderLines()
 
-	log.Printf("OK: session=%d%% weekly=%d%%", sessionPct, weeklyPct)
+	st.extra = ""
+	if e := usage.ExtraUsage; e != nil && e.IsEnabled {
+		st.extra = renderExtraUsageLine(e, cfg.extraUsageWarnPct(), opts)
+	}
+	now := timeNow()
+	st.spending = renderSpending(usage.ExtraUsage, usageHistory.monthSpend(now), lastSpendAlert(now), now)
+
+	fetched := now
+	if stale != "" {
+		fetched = lastOutcome.lastSuccess()
+	}
+	lastStatus.set(usage, opus, sonnet, fetched)
+}
+
+// usageIcon returns the key of the two-color icon: left=session remaining,
+// right=weekly remaining. The right half is colored by the tighter of weekly
+// and Opus, since on Max plans the Opus limit usually runs out first.
+// icon_mode "session" or "weekly" shows only that bucket, "worst" the most
+// constrained one. template reports a macOS template image, for
+// icon_style "mono". cfg may be nil.
+func usageIcon(cfg *Config, usage *UsageResponse, opus, sonnet *UsageBucket) (key iconKey, template bool) {
+	used := cfg.iconShowsUsed()
+	palette := cfg.iconPalette()
+	one := func(r bucketReading) (iconKey, bool) {
+		p, template := templatePalette(palette, r.remaining)
+		return iconKey{letter: r.letter, a: r.remaining, used: used, palette: p}, template
+	}
+	readings := compactReadings(usage, opus, sonnet)
+	switch cfg.iconMode() {
+	case iconModeSession:
+		return one(readings[0])
+	case iconModeWeekly:
+		return one(readings[1])
+	case iconModeWorst:
+		return one(worstBucket.pick(readings))
+	}
+	sessionPct := int(usage.FiveHour.Utilization)
+	weeklyPct := int(usage.SevenDay.Utilization)
+	weeklyColor := 100 - weeklyPct
+	if opus != nil {
+		weeklyColor = min(weeklyColor, 100-int(opus.Utilization))
+	}
+	p, template := templatePalette(palette, min(100-sessionPct, weeklyColor))
+	return iconKey{a: 100 - sessionPct, b: 100 - weeklyPct, c: weeklyColor, used: used, palette: p}, template
 }
 
-func formatReset(isoTime string) string {
+// timeNow is the clock the renderers use; replay points it at the recorded
+// sample time.
+var timeNow = time.Now
+
+// resetDuration parses an API reset timestamp and returns the time left.
+func resetDuration(isoTime string) (time.Duration, bool) {
+	t, ok := parseResetTime(isoTime)
+	if !ok {
+		return 0, false
+	}
+	return t.Sub(timeNow()), true
+}
+
+// parseResetTime parses an API reset timestamp.
+func parseResetTime(isoTime string) (time.Time, bool) {
 	t, err := time.Parse(time.RFC3339Nano, isoTime)
 	if err != nil {
 		t, err = time.Parse("2006-01-02T15:04:05.000000+00:00", isoTime)
 		if err != nil {
-			return "?"
+			return time.Time{}, false
 		}
 	}
+	return t, true
+}
 
-	diff := time.Until(t)
+// resetTime parses an API reset timestamp, zero if it is missing or
+// malformed.
+func resetTime(isoTime string) time.Time {
+	t, _ := parseResetTime(isoTime)
+	return t
+}
+
+// formatReset renders the time left until at, e.g. "in 2h 13m" or
+// "in 3d 4h"; "soon" once it has passed and "?" for a zero at.
+func formatReset(at time.Time) string {
+	if at.IsZero() {
+		return "?"
+	}
+	diff := at.Sub(timeNow())
 	if diff <= 0 {
 		return "soon"
 	}
@@ -257,7 +1477,7 @@ func formatReset(isoTime string) string {
 	h := int(diff.Hours())
 	m := int(diff.Minutes()) % 60
 
-	if h > 24 {
+	if h >= 24 {
 		return fmt.Sprintf("in %dd %dh", h/24, h%24)
 	}
 	if h > 0 {
@@ -266,10 +1486,11 @@ func formatReset(isoTime string) string {
 	return fmt.Sprintf("in %dm", m)
 }
 
-func openFile(path string) {
-	if runtime.GOOS == "windows" {
-		exec.Command("notepad.exe", path).Start()
-	} else {
-		exec.Command("xdg-open", path).Start()
+// truncate shortens s to at most n runes, marking the cut with "…".
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
 	}
+	return string(r[:n-1]) + "…"
 }
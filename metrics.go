@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsStore holds what /metrics, /status and status_file report: the
+// last successful reading, kept while later updates fail, the latest error,
+// a count of the failures and the Cloudflare diagnostics.
+type metricsStore struct {
+	mu         sync.Mutex
+	usage      *UsageResponse
+	updated    time.Time
+	lastErr    string
+	errors     uint64
+	cloudflare cloudflareInfo
+}
+
+var usageMetrics = &metricsStore{}
+
+// record notes the outcome of an update.
+func (m *metricsStore) record(usage *UsageResponse, err error, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.errors++
+		m.lastErr = err.Error()
+		return
+	}
+	m.lastErr = ""
+	if usage != nil {
+		m.usage, m.updated = usage, now.UTC()
+	}
+}
+
+// setCloudflare replaces the Cloudflare diagnostics, read with each update.
+func (m *metricsStore) setCloudflare(ci cloudflareInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cloudflare = ci
+}
+
+// status returns the status document for the last reading and the latest
+// error, as of now.
+func (m *metricsStore) status(now time.Time) *statusDoc {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := newStatusDoc(m.usage, nil)
+	st.UpdatedAt = now.UTC()
+	if m.usage != nil {
+		updated := m.updated
+		st.LastUpdate = &updated
+	}
+	if m.lastErr != "" {
+		st.OK, st.Error = false, m.lastErr
+	}
+	st.Cloudflare = m.cloudflare.status(now)
+	return st
+}
+
+// serveStatus answers /status with the status document.
+func (m *metricsStore) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.status(timeNow()))
+}
+
+// write renders the metrics in the Prometheus text format. Buckets the
+// account doesn't have are left out.
+func (m *metricsStore) write(w io.Writer) {
+	m.mu.Lock()
+	usage, updated, errs, cf := m.usage, m.updated, m.errors, m.cloudflare
+	m.mu.Unlock()
+
+	metric := func(name, kind, help string, v float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	if usage != nil {
+		metric("claude_session_utilization", "gauge", "Utilization of the 5-hour session limit, percent.", usage.FiveHour.Utilization)
+		metric("claude_weekly_utilization", "gauge", "Utilization of the weekly limit, percent.", usage.SevenDay.Utilization)
+		if b := usage.SevenDayOpus; b != nil {
+			metric("claude_opus_utilization", "gauge", "Utilization of the weekly Opus limit, percent.", b.Utilization)
+		}
+		if b := usage.SevenDaySonnet; b != nil {
+			metric("claude_sonnet_utilization", "gauge", "Utilization of the weekly Sonnet limit, percent.", b.Utilization)
+		}
+		if e := usage.ExtraUsage; e != nil && e.IsEnabled && e.UsedCredits != nil {
+			metric("claude_extra_used_credits", "gauge", "Extra usage credits spent this month.", *e.UsedCredits)
+		}
+		metric("claude_last_update_timestamp_seconds", "gauge", "Time of the last successful update, in seconds since the epoch.", float64(updated.Unix()))
+	}
+	metric("claude_update_errors_total", "counter", "Updates that failed since the app started.", float64(errs))
+	if !cf.clearanceCreated.IsZero() {
+		metric("claude_cf_clearance_age_seconds", "gauge", "Age of the cf_clearance cookie in config.json.", timeNow().Sub(cf.clearanceCreated).Seconds())
+	}
+	if !cf.lastBlock.IsZero() {
+		metric("claude_cloudflare_last_block_timestamp_seconds", "gauge", "Time Cloudflare last blocked a request, in seconds since the epoch.", float64(cf.lastBlock.Unix()))
+	}
+}
+
+func (m *metricsStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// checkMetricsListen rejects a metrics_listen address that other machines
+// could reach, unless allowRemote is set. An empty host listens on every
+// interface.
+func checkMetricsListen(addr string, allowRemote bool) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("metrics_listen: %w", err)
+	}
+	if allowRemote || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("metrics_listen %q is not a loopback address; set metrics_allow_remote to serve it", addr)
+}
+
+// metricsListener runs the HTTP server for metrics_listen: /metrics and
+// /status.
+type metricsListener struct {
+	mu          sync.Mutex
+	addr        string // as configured, whether or not it is served
+	allowRemote bool
+	srv         *http.Server
+}
+
+var metricsServer = &metricsListener{}
+
+// configure starts, moves or stops the server to match cfg; called on every
+// update so that config edits take effect. Problems are logged and shown
+// under Diagnostics once per setting. A nil config leaves it as is.
+func (l *metricsListener) configure(cfg *Config) {
+	if cfg == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cfg.MetricsListen == l.addr && cfg.MetricsAllowRemote == l.allowRemote {
+		return
+	}
+	l.closeLocked()
+	l.addr, l.allowRemote = cfg.MetricsListen, cfg.MetricsAllowRemote
+	if l.addr == "" {
+		return
+	}
+	if err := checkMetricsListen(l.addr, l.allowRemote); err != nil {
+		uiLog.Warnf("Metrics: %v", err)
+		health.report("Metrics", false, err.Error())
+		return
+	}
+	ln, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		uiLog.Warnf("Metrics: %v", err)
+		health.report("Metrics", false, err.Error())
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", usageMetrics)
+	mux.HandleFunc("/status", usageMetrics.serveStatus)
+	l.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	uiLog.Infof("Serving metrics on http://%s/metrics", ln.Addr())
+	health.report("Metrics", true, "listening on "+ln.Addr().String())
+	go func(srv *http.Server) {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			uiLog.Warnf("Metrics: %v", err)
+			health.report("Metrics", false, err.Error())
+		}
+	}(l.srv)
+}
+
+// stop closes the server, if it runs.
+func (l *metricsListener) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeLocked()
+	l.addr, l.allowRemote = "", false
+}
+
+func (l *metricsListener) closeLocked() {
+	if l.srv != nil {
+		l.srv.Close()
+		l.srv = nil
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsStore(t *testing.T) {
+	m := &metricsStore{}
+	used := 12.5
+	m.record(&UsageResponse{
+		FiveHour:       UsageBucket{Utilization: 42},
+		SevenDay:       UsageBucket{Utilization: 17},
+		SevenDaySonnet: &UsageBucket{Utilization: 3},
+		ExtraUsage:     &ExtraUsage{IsEnabled: true, UsedCredits: &used},
+	}, nil, time.Unix(1700000000, 0))
+	m.record(nil, errors.New("HTTP 500"), time.Unix(1700000300, 0))
+
+	var out strings.Builder
+	m.write(&out)
+	got := out.String()
+	// The failed update leaves the last reading in place
+	for _, want := range []string{
+		"# TYPE claude_session_utilization gauge\nclaude_session_utilization 42\n",
+		"\nclaude_weekly_utilization 17\n",
+		"\nclaude_sonnet_utilization 3\n",
+		"\nclaude_extra_used_credits 12.5\n",
+		"\nclaude_last_update_timestamp_seconds 1.7e+09\n",
+		"# TYPE claude_update_errors_total counter\nclaude_update_errors_total 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "claude_opus_utilization") {
+		t.Errorf("reported an absent bucket:\n%s", got)
+	}
+	if strings.Contains(got, "cf_clearance") || strings.Contains(got, "cloudflare") {
+		t.Errorf("reported Cloudflare diagnostics nobody recorded:\n%s", got)
+	}
+}
+
+func TestMetricsCloudflare(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	m := &metricsStore{}
+	m.setCloudflare(cloudflareInfo{
+		clearanceCreated: now.Add(-9 * time.Hour),
+		clearanceExpires: now.Add(15 * time.Hour),
+		lastBlock:        now.Add(-48 * time.Hour),
+	})
+	var out strings.Builder
+	m.write(&out)
+	for _, want := range []string{
+		"# TYPE claude_cf_clearance_age_seconds gauge\nclaude_cf_clearance_age_seconds 32400\n",
+		"# TYPE claude_cloudflare_last_block_timestamp_seconds gauge\nclaude_cloudflare_last_block_timestamp_seconds 1.6998272e+09\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCheckMetricsListen(t *testing.T) {
+	for _, tt := range []struct {
+		addr   string
+		remote bool
+		ok     bool
+	}{
+		{"127.0.0.1:9877", false, true},
+		{"[::1]:9877", false, true},
+		{"localhost:9877", false, true},
+		{":9877", false, false},
+		{"0.0.0.0:9877", false, false},
+		{"192.168.1.5:9877", false, false},
+		{"0.0.0.0:9877", true, true},
+		{"9877", true, false},
+	} {
+		if err := checkMetricsListen(tt.addr, tt.remote); (err == nil) != tt.ok {
+			t.Errorf("checkMetricsListen(%q, %v) = %v", tt.addr, tt.remote, err)
+		}
+	}
+}
+
+func TestMetricsListener(t *testing.T) {
+	l := &metricsListener{}
+	t.Cleanup(l.stop)
+	l.configure(&Config{MetricsListen: "127.0.0.1:0"})
+	if l.srv == nil {
+		t.Fatal("server not started")
+	}
+	l.configure(&Config{MetricsListen: "0.0.0.0:0"})
+	if l.srv != nil {
+		t.Fatal("serving a non-loopback address")
+	}
+	l.configure(&Config{MetricsListen: "0.0.0.0:0", MetricsAllowRemote: true})
+	if l.srv == nil {
+		t.Fatal("metrics_allow_remote ignored")
+	}
+	l.configure(&Config{})
+	if l.srv != nil {
+		t.Fatal("still serving without metrics_listen")
+	}
+}
+
+func TestMetricsHTTP(t *testing.T) {
+	m := &metricsStore{}
+	m.record(&UsageResponse{FiveHour: UsageBucket{Utilization: 5}}, nil, time.Now())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	mux.HandleFunc("/status", m.serveStatus)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(string(body), "\nclaude_session_utilization 5\n") {
+		t.Errorf("body:\n%s", body)
+	}
+
+	resp, err = http.Get(srv.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st statusDoc
+	err = json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if err != nil || !st.OK || st.Session == nil || st.Session.Utilization != 5 || st.LastUpdate == nil {
+		t.Errorf("/status: %+v, %v", st, err)
+	}
+
+	resp, err = http.Post(srv.URL+"/metrics", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %s", resp.Status)
+	}
+}
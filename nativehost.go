@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Native messaging lets a companion WebExtension push claude.ai cookies to
+// the app as soon as the browser sets them, so a cf_clearance bound to the
+// browser's TLS fingerprint is used the moment it exists.
+
+const (
+	// nativeHostName is the host name the extension connects to.
+	nativeHostName = "claude_monitor"
+	// nativeHostExtension is the ID of the companion extension, the only
+	// one the manifest allows to start the host.
+	nativeHostExtension = "claude-monitor@nocturnal-ru.github.io"
+	// maxNativeMessage bounds a message from the browser; a claude.ai
+	// cookie list is a few KB.
+	maxNativeMessage = 1 << 20
+)
+
+// isNativeHostLaunch reports whether args are how a browser starts a native
+// messaging host: Firefox passes the manifest path and the extension ID.
+func isNativeHostLaunch(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	return args[0] == "--native-host" || filepath.Base(args[0]) == nativeHostName+".json"
+}
+
+// nativeCookie is a cookie as the extension sends it: a cookies.Cookie
+// object of the WebExtension API.
+type nativeCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain"`
+	ExpirationDate float64 `json:"expirationDate"` // seconds since the epoch; 0 for session cookies
+}
+
+// nativeMessage is a message from the extension.
+type nativeMessage struct {
+	Cookies []nativeCookie `json:"cookies"`
+}
+
+// nativeReply answers each message.
+type nativeReply struct {
+	OK      bool   `json:"ok"`
+	Changed bool   `json:"changed,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// readNativeMessage reads one message: a 32-bit length in native byte order
+// (little-endian on every platform the app runs on), then that much JSON.
+// At the end of in it returns io.EOF.
+func readNativeMessage(in io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(in, binary.LittleEndian, &size); err != nil {
+		return nil, err
+	}
+	if size > maxNativeMessage {
+		return nil, fmt.Errorf("message of %d bytes is too large", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(in, data); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	return data, nil
+}
+
+func writeNativeMessage(out io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(out, binary.LittleEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// runNativeHost serves the extension until the browser closes the pipe.
+// Changed cookies are written to config.json; the running tray instance
+// notices the edit and refreshes.
+func runNativeHost(in io.Reader, out io.Writer) int {
+	for {
+		data, err := readNativeMessage(in)
+		if errors.Is(err, io.EOF) {
+			return 0
+		}
+		if err != nil {
+			firefoxLog.Warnf("Native host: %v", err)
+			return 1
+		}
+		reply := nativeReply{OK: true}
+		if reply.Changed, err = applyNativeMessage(data); err != nil {
+			firefoxLog.Warnf("Native host: %v", err)
+			reply = nativeReply{Error: err.Error()}
+		}
+		if err := writeNativeMessage(out, reply); err != nil {
+			firefoxLog.Warnf("Native host: %v", err)
+			return 1
+		}
+	}
+}
+
+// applyNativeMessage saves the credentials in a message from the extension
+// if they differ from config.json, and reports whether they did.
+func applyNativeMessage(data []byte) (bool, error) {
+	var msg nativeMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return false, fmt.Errorf("parsing message: %w", err)
+	}
+	var rows []cookieRow
+	for _, c := range msg.Cookies {
+		if !isClaudeHost(c.Domain) || c.Name == "" || c.Value == "" {
+			continue
+		}
+		row := cookieRow{Name: c.Name, Value: c.Value, Host: c.Domain}
+		if c.ExpirationDate > 0 {
+			row.Expiry = time.Unix(int64(c.ExpirationDate), 0)
+		}
+		rows = append(rows, row)
+	}
+	selected := make(map[string]cookieRow)
+	for _, r := range dropExpired(rows, timeNow()) {
+		selected[r.Name] = r
+	}
+	sk := selected["sessionKey"].Value
+	if sk == "" {
+		return false, errors.New("no sessionKey among the cookies")
+	}
+	if !strings.HasPrefix(sk, sessionKeyPrefix) {
+		return false, fmt.Errorf("sessionKey doesn't start with %q", sessionKeyPrefix)
+	}
+
+	cfg, err := readConfigFile(configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	save := cookieUpdate(cfg, sk, selected["lastActiveOrg"].Value, selected["cf_clearance"].Value)
+	if save == nil {
+		return false, nil
+	}
+	if err := updateConfig(configPath, save); err != nil {
+		return false, fmt.Errorf("saving config: %w", err)
+	}
+	firefoxLog.Infof("Native host: cookies from the browser extension saved to config")
+	return true, nil
+}
+
+// nativeHostManifest returns the manifest that lets the companion extension
+// start exe as the host.
+func nativeHostManifest(exe string) ([]byte, error) {
+	return json.MarshalIndent(map[string]any{
+		"name":               nativeHostName,
+		"description":        appName + " cookie handoff",
+		"path":               exe,
+		"type":               "stdio",
+		"allowed_extensions": []string{nativeHostExtension},
+	}, "", "  ")
+}
+
+// cmdInstallNativeHost writes the native messaging manifest for browser
+// (only "firefox" so far) to where the browser looks for it.
+func cmdInstallNativeHost(args []string, out io.Writer) int {
+	if len(args) != 1 || !strings.EqualFold(args[0], "firefox") {
+		fmt.Fprintln(out, "Usage: claude-monitor --install-native-host firefox")
+		return 2
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	data, err := nativeHostManifest(exe)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	dir, err := nativeManifestDir()
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	path := filepath.Join(dir, nativeHostName+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	if err := registerNativeHost(path); err != nil {
+		fmt.Fprintln(out, "Error registering the manifest:", err)
+		return 1
+	}
+	fmt.Fprintln(out, "Native messaging manifest written to", path)
+	return 0
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// nativeManifestDir is the per-user directory Firefox reads native
+// messaging manifests from.
+func nativeManifestDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting home directory: %w", err)
+	}
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "Mozilla", "NativeMessagingHosts"), nil
+	}
+	return filepath.Join(home, ".mozilla", "native-messaging-hosts"), nil
+}
+
+// registerNativeHost has nothing to do: the manifest's location is enough.
+func registerNativeHost(string) error {
+	return nil
+}
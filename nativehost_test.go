@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNativeHost(t *testing.T) {
+	path := writeTestConfig(t, `{"session_key": "sk-ant-sid01-old", "org_id": "org-1", "cf_clearance": "cf-old"}`)
+	savedConfig := configPath
+	t.Cleanup(func() { configPath = savedConfig })
+	configPath = path
+
+	future := float64(time.Now().Add(24 * time.Hour).Unix())
+	var in bytes.Buffer
+	for _, msg := range []string{
+		`{"cookies": [
+			{"name": "sessionKey", "value": "sk-ant-sid01-new", "domain": ".claude.ai", "expirationDate": ` + jsonFloat(future) + `},
+			{"name": "cf_clearance", "value": "cf-new", "domain": ".claude.ai"},
+			{"name": "cf_clearance", "value": "cf-evil", "domain": "claude.ai.example.com"}
+		]}`,
+		`{"cookies": [{"name": "sessionKey", "value": "sk-ant-sid01-new", "domain": "claude.ai"}]}`,
+		`{"cookies": [{"name": "sessionKey", "value": "sk-ant-sid01-expired", "domain": "claude.ai", "expirationDate": 1000}]}`,
+	} {
+		if err := writeNativeMessage(&in, json.RawMessage(compactJSON(t, msg))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if code := runNativeHost(&in, &out); code != 0 {
+		t.Fatalf("runNativeHost = %d", code)
+	}
+	var replies []nativeReply
+	for out.Len() > 0 {
+		data, err := readNativeMessage(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r nativeReply
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, r)
+	}
+	want := []nativeReply{{OK: true, Changed: true}, {OK: true}, {Error: "no sessionKey among the cookies"}}
+	if len(replies) != len(want) {
+		t.Fatalf("replies = %+v", replies)
+	}
+	for i := range want {
+		if replies[i] != want[i] {
+			t.Errorf("reply %d = %+v, want %+v", i, replies[i], want[i])
+		}
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SessionKey != "sk-ant-sid01-new" || cfg.OrgID != "org-1" || cfg.CfClearance != "cf-new" {
+		t.Errorf("config = %q %q %q", cfg.SessionKey, cfg.OrgID, cfg.CfClearance)
+	}
+}
+
+func TestIsNativeHostLaunch(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"--native-host"}, true},
+		{[]string{"/home/u/.mozilla/native-messaging-hosts/claude_monitor.json", nativeHostExtension}, true},
+		{[]string{"replay"}, false},
+		{nil, false},
+	} {
+		if got := isNativeHostLaunch(tt.args); got != tt.want {
+			t.Errorf("isNativeHostLaunch(%q) = %v", tt.args, got)
+		}
+	}
+}
+
+func jsonFloat(f float64) string {
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+func compactJSON(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
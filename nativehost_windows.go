@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// nativeManifestDir is where the manifest is kept on Windows; Firefox finds
+// it through the registry, so it sits next to config.json.
+func nativeManifestDir() (string, error) {
+	return paths.configDir, nil
+}
+
+// registerNativeHost points Firefox's registry key for the host at the
+// manifest.
+func registerNativeHost(manifestPath string) error {
+	cmd := exec.Command("reg", "add", `HKCU\Software\Mozilla\NativeMessagingHosts\`+nativeHostName,
+		"/ve", "/t", "REG_SZ", "/d", manifestPath, "/f")
+	hideWindow(cmd)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("reg add: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
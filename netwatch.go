@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// netProbeInterval is how often connectivity is probed while updates
+	// are failing. Nothing is probed while they succeed.
+	netProbeInterval = 20 * time.Second
+	netProbeTimeout  = 5 * time.Second
+	// minRestoreRefresh rate-limits refreshes triggered by the network
+	// coming back, so flapping Wi-Fi can't cause a request storm.
+	minRestoreRefresh = time.Minute
+)
+
+// networkDown is set when the last update failed with a network error, so a
+// failure that happened while offline counts even if no probe saw it.
+var networkDown atomic.Bool
+
+// isNetworkError reports whether err means the API was unreachable rather
+// than answering with an error.
+func isNetworkError(err error) bool {
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// watchNetwork probes connectivity while the last update failed and calls
+// refresh when the network transitions from offline to online.
+func watchNetwork(refresh func()) {
+	var lastRefresh time.Time
+	offline := false
+	for {
+		time.Sleep(netProbeInterval)
+		if !scheduler.failing() {
+			offline = false
+			continue
+		}
+		cfg, err := loadConfigNoOrg(configPath)
+		if err != nil {
+			continue
+		}
+
+		if err := probeNetwork(cfg); err != nil {
+			if !offline {
+				apiLog.Warnf("Network unreachable: %v", err)
+			}
+			offline = true
+			continue
+		}
+		if !offline && !networkDown.Load() {
+			continue // online all along; the failure is something else
+		}
+		offline = false
+		networkDown.Store(false)
+		if time.Since(lastRefresh) < minRestoreRefresh {
+			apiLog.Infof("Network restored, refresh skipped (rate limit)")
+			continue
+		}
+		lastRefresh = time.Now()
+		apiLog.Infof("Network restored, refreshing")
+		refresh()
+	}
+}
+
+// probeNetwork opens (and closes) a TCP connection to the API host, or to
+// the proxy when one is in use. No request is sent.
+func probeNetwork(cfg *Config) error {
+	addr, err := probeAddr(cfg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), netProbeTimeout)
+	defer cancel()
+	conn, err := dialContextFor(cfg.ForceIPv4, net.DefaultResolver)(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probeAddr returns the host:port the API is reached through.
+func probeAddr(cfg *Config) (string, error) {
+	target, err := url.Parse(cfg.apiURL(""))
+	if err != nil {
+		return "", err
+	}
+	if cfg.ProxyURL != "" {
+		if target, err = parseProxyURL(cfg.ProxyURL); err != nil {
+			return "", err
+		}
+	} else if proxy, err := http.ProxyFromEnvironment(&http.Request{URL: target}); err == nil && proxy != nil {
+		target = proxy
+	}
+
+	port := target.Port()
+	if port == "" {
+		switch target.Scheme {
+		case "http":
+			port = "80"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(target.Hostname(), port), nil
+}
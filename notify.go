@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// toastScript shows a Windows toast with the title and body passed in the
+// environment, which spares quoting them for PowerShell. Toasts need the
+// ID of a registered app; PowerShell's own is used.
+const toastScript = `$ErrorActionPreference = 'Stop'
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:CLAUDE_MONITOR_TITLE)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:CLAUDE_MONITOR_BODY)) > $null
+$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+
+// notify shows a desktop notification using whatever the platform provides
+// (a PowerShell toast, osascript, notify-send).
+func notify(title, body string) error {
+	var args []string
+	var env []string
+	switch runtime.GOOS {
+	case "windows":
+		args = []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", toastScript}
+		env = []string{"CLAUDE_MONITOR_TITLE=" + title, "CLAUDE_MONITOR_BODY=" + body}
+	case "darwin":
+		args = []string{"osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, body}
+	default:
+		args = []string{"notify-send", "--app-name=" + appName, title, body}
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return fmt.Errorf("%s not found", args[0])
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	hideWindow(cmd)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
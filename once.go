@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// Exit codes of --once.
+const (
+	onceOK = 0
+	// onceAuthError means the credentials or the config need fixing: the
+	// API rejected the session or org, Cloudflare blocked the request, or
+	// config.json is unusable. Bad arguments exit with this too.
+	onceAuthError = 1
+	// onceNetworkError means trying again later may work: the API was
+	// unreachable or failed on its side.
+	onceNetworkError = 2
+)
+
+// onceBucket is a usage bucket as --once prints it: the API's fields plus
+// what the menu derives from them.
+type onceBucket struct {
+	UsageBucket
+	Remaining float64 `json:"remaining"`
+	ResetsIn  string  `json:"resets_in,omitempty"` // "in 2h 10m", "soon"
+}
+
+// onceResult mirrors UsageResponse.
+type onceResult struct {
+	FiveHour       *onceBucket `json:"five_hour"`
+	SevenDay       *onceBucket `json:"seven_day"`
+	SevenDayOpus   *onceBucket `json:"seven_day_opus,omitempty"`
+	SevenDaySonnet *onceBucket `json:"seven_day_sonnet,omitempty"`
+	ExtraUsage     *ExtraUsage `json:"extra_usage,omitempty"`
+}
+
+func newOnceBucket(b *UsageBucket) *onceBucket {
+	if b == nil {
+		return nil
+	}
+	ob := &onceBucket{UsageBucket: *b, Remaining: max(0, 100-b.Utilization)}
+	if r := formatReset(resetTime(b.ResetsAt)); r != "?" {
+		ob.ResetsIn = r
+	}
+	return ob
+}
+
+func newOnceResult(u *UsageResponse) *onceResult {
+	return &onceResult{
+		FiveHour:       newOnceBucket(&u.FiveHour),
+		SevenDay:       newOnceBucket(&u.SevenDay),
+		SevenDayOpus:   newOnceBucket(u.SevenDayOpus),
+		SevenDaySonnet: newOnceBucket(u.SevenDaySonnet),
+		ExtraUsage:     u.ExtraUsage,
+	}
+}
+
+// onceExitCode picks the exit code for a failed --once.
+func onceExitCode(err error) int {
+	var herr *ErrHTTP
+	switch {
+	case isNetworkError(err):
+		return onceNetworkError
+	case errors.As(err, &herr) && (herr.StatusCode >= 500 || herr.StatusCode == http.StatusTooManyRequests):
+		return onceNetworkError
+	}
+	return onceAuthError
+}
+
+// cmdOnce fetches usage like one update of the tray app, with its retries
+// and Cloudflare recovery, prints it to out and exits. Nothing is logged
+// unless --log is given.
+func cmdOnce(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("--once", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	format := fs.String("format", "json", "output `format`: json, or plain for key=value lines")
+	logToFile := fs.Bool("log", false, "append to claude-monitor.log in the state directory")
+	if err := fs.Parse(args); err != nil {
+		return onceAuthError
+	}
+	if *format != "json" && *format != "plain" {
+		fmt.Fprintf(errOut, "Unknown --format %q (json or plain)\n", *format)
+		return onceAuthError
+	}
+	log.SetOutput(io.Discard)
+	if *logToFile {
+		f, err := openRotatingLog(paths.logFile())
+		if err != nil {
+			fmt.Fprintln(errOut, "Error:", err)
+			return onceAuthError
+		}
+		defer f.Close()
+		setLogOutput(f)
+		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+		apiLog.Infof("Fetching usage once")
+	}
+
+	ctx := context.Background()
+	cfg, err := loadConfigWithDiscoveredOrg(ctx, headlessView{})
+	var usage *UsageResponse
+	if err == nil {
+		usage, err = fetchAccount(ctx, cfg.accountConfigs()[cfg.primaryAccount()], headlessView{}, nil)
+	}
+	if err != nil {
+		apiLog.Errorf("Fetch failed: %v", err)
+		if *format == "json" {
+			writeOnceJSON(out, map[string]string{"error": err.Error()})
+		}
+		fmt.Fprintln(errOut, "Error:", err)
+		return onceExitCode(err)
+	}
+	apiLog.Infof("OK: session=%d%% weekly=%d%%", int(usage.FiveHour.Utilization), int(usage.SevenDay.Utilization))
+
+	if *format == "json" {
+		writeOnceJSON(out, newOnceResult(usage))
+		return onceOK
+	}
+	for _, row := range []struct {
+		key string
+		b   *UsageBucket
+	}{
+		{"session", &usage.FiveHour},
+		{"weekly", &usage.SevenDay},
+		{"opus", usage.SevenDayOpus},
+		{"sonnet", usage.SevenDaySonnet},
+	} {
+		if row.b == nil {
+			continue
+		}
+		fmt.Fprintf(out, "%s=%d%%\n", row.key, int(row.b.Utilization))
+		if ob := newOnceBucket(row.b); ob.ResetsIn != "" {
+			fmt.Fprintf(out, "%s_resets=%s\n", row.key, ob.ResetsIn)
+		}
+	}
+	return onceOK
+}
+
+func writeOnceJSON(out io.Writer, v any) {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCmdOnce(t *testing.T) {
+	savedDelays, savedConfig, savedPaths := retryDelays, configPath, paths
+	t.Cleanup(func() { retryDelays, configPath, paths = savedDelays, savedConfig, savedPaths })
+	retryDelays = []time.Duration{time.Millisecond}
+	savedLog := log.Writer()
+	t.Cleanup(func() { log.SetOutput(savedLog) })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	cachedUsage.reset()
+	t.Cleanup(func() { cachedUsage.reset() })
+
+	resets := time.Now().Add(2*time.Hour + 10*time.Minute + 30*time.Second).UTC().Format(time.RFC3339)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprintf(w, `{"five_hour": {"utilization": 38, "resets_at": %q}, "seven_day": {"utilization": 12}, "seven_day_sonnet": {"utilization": 3}}`, resets)
+	}))
+	t.Cleanup(srv.Close)
+	configPath = writeTestConfig(t, fmt.Sprintf(`{"session_key": "sk-ant-sid01-x", "org_id": "org-1", "api_base_url": %q}`, srv.URL))
+
+	var out, errOut bytes.Buffer
+	if code := cmdOnce(nil, &out, &errOut); code != onceOK {
+		t.Fatalf("exit %d: %s", code, errOut.String())
+	}
+	var got onceResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("%v in:\n%s", err, out.String())
+	}
+	if got.FiveHour.Utilization != 38 || got.FiveHour.Remaining != 62 || got.FiveHour.ResetsIn != "in 2h 10m" ||
+		got.SevenDay.Utilization != 12 || got.SevenDaySonnet.Utilization != 3 || got.SevenDayOpus != nil {
+		t.Errorf("JSON:\n%s", out.String())
+	}
+
+	out.Reset()
+	cachedUsage.reset()
+	if code := cmdOnce([]string{"--format=plain"}, &out, &errOut); code != onceOK {
+		t.Fatalf("plain: exit %d: %s", code, errOut.String())
+	}
+	if want := "session=38%\nsession_resets=in 2h 10m\nweekly=12%\nsonnet=3%\n"; out.String() != want {
+		t.Errorf("plain:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	for _, tt := range []struct {
+		status int
+		code   int
+	}{
+		{http.StatusUnauthorized, onceAuthError},
+		{http.StatusServiceUnavailable, onceNetworkError},
+	} {
+		status = tt.status
+		out.Reset()
+		cachedUsage.reset()
+		if code := cmdOnce(nil, &out, &errOut); code != tt.code {
+			t.Errorf("HTTP %d: exit %d, want %d", tt.status, code, tt.code)
+		}
+		if !strings.Contains(out.String(), `"error"`) {
+			t.Errorf("HTTP %d: no error in output:\n%s", tt.status, out.String())
+		}
+	}
+
+	srv.Close()
+	if code := cmdOnce([]string{"--format=plain"}, &out, &errOut); code != onceNetworkError {
+		t.Errorf("server down: exit %d", code)
+	}
+	if code := cmdOnce([]string{"--format=yaml"}, &out, &errOut); code != onceAuthError {
+		t.Errorf("bad format: exit %d", code)
+	}
+}
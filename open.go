@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// claudeUsageURL is where claude.ai shows the usage the tray numbers come
+// from, message counts included.
+const claudeUsageURL = "https://claude.ai/settings/usage"
+
+// openKind is what openCommand opens.
+type openKind int
+
+const (
+	openURLKind  openKind = iota // in the default browser
+	openDirKind                  // in the file manager
+	openFileKind                 // in a text editor: config, log, history and responses are all text
+)
+
+// openCommand returns the command line that opens target on goos.
+// Windows opens URLs through url.dll rather than cmd's start, which would
+// need a URL's & and ^ escaped, and text files in Notepad since .json and
+// .jsonl usually have no program associated.
+func openCommand(goos, target string, kind openKind) []string {
+	switch goos {
+	case "windows":
+		switch kind {
+		case openDirKind:
+			return []string{"explorer.exe", target}
+		case openFileKind:
+			return []string{"notepad.exe", target}
+		}
+		return []string{"rundll32", "url.dll,FileProtocolHandler", target}
+	case "darwin":
+		if kind == openFileKind {
+			return []string{"open", "-t", target} // the default text editor
+		}
+		return []string{"open", target}
+	}
+	return []string{"xdg-open", target}
+}
+
+// start runs an openCommand without waiting for it, so menu handlers
+// don't block; failures are logged, including the opener's own.
+func start(what string, args []string) {
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		uiLog.Warnf("Failed to open %s: %v", what, err)
+		return
+	}
+	go func() {
+		// explorer.exe exits with 1 even when it opened the folder
+		if err := cmd.Wait(); err != nil && args[0] != "explorer.exe" {
+			uiLog.Warnf("Failed to open %s: %s: %v", what, args[0], err)
+		}
+	}()
+}
+
+// openURL opens url in the default browser.
+func openURL(url string) {
+	start(url, openCommand(runtime.GOOS, url, openURLKind))
+}
+
+// openPath opens a directory in the file manager, or a file in a text
+// editor.
+func openPath(path string) {
+	kind := openFileKind
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		kind = openDirKind
+	}
+	start(path, openCommand(runtime.GOOS, path, kind))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenCommand(t *testing.T) {
+	const url = "https://claude.ai/settings/usage?a=1&b=2"
+	for _, tc := range []struct {
+		goos   string
+		target string
+		kind   openKind
+		want   string
+	}{
+		{"windows", url, openURLKind, "rundll32 url.dll,FileProtocolHandler " + url},
+		{"windows", `C:\Users\me\AppData`, openDirKind, `explorer.exe C:\Users\me\AppData`},
+		{"windows", `C:\cfg\config.json`, openFileKind, `notepad.exe C:\cfg\config.json`},
+		{"darwin", url, openURLKind, "open " + url},
+		{"darwin", "/tmp/bundles", openDirKind, "open /tmp/bundles"},
+		{"darwin", "/tmp/config.json", openFileKind, "open -t /tmp/config.json"},
+		{"linux", url, openURLKind, "xdg-open " + url},
+		{"linux", "/tmp/config.json", openFileKind, "xdg-open /tmp/config.json"},
+	} {
+		if got := strings.Join(openCommand(tc.goos, tc.target, tc.kind), " "); got != tc.want {
+			t.Errorf("%s %q: got %q, want %q", tc.goos, tc.target, got, tc.want)
+		}
+	}
+}
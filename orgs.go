@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+// Organization is one entry of GET /api/organizations.
+type Organization struct {
+	UUID         string   `json:"uuid"`
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+}
+
+func (o Organization) has(capability string) bool {
+	for _, c := range o.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchOrganizations lists the organizations the session key belongs to.
+func fetchOrganizations(ctx context.Context, cfg *Config) ([]Organization, error) {
+	req, err := newAPIRequest(ctx, cfg, cfg.apiURL("/organizations"))
+	if err != nil {
+		return nil, err
+	}
+	_, body, err := doAPIRequest(cfg, req)
+	if err != nil {
+		return nil, err
+	}
+	var orgs []Organization
+	if err := json.Unmarshal(body, &orgs); err != nil {
+		return nil, fmt.Errorf("parsing organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// pickOrganization chooses the organization whose usage is most likely
+// wanted: a paid (Max, then Pro) plan first, otherwise the first one.
+func pickOrganization(orgs []Organization) (Organization, bool) {
+	for _, capability := range []string{"claude_max", "claude_pro"} {
+		for _, o := range orgs {
+			if o.has(capability) {
+				return o, true
+			}
+		}
+	}
+	if len(orgs) == 0 {
+		return Organization{}, false
+	}
+	return orgs[0], true
+}
+
+func findOrganization(orgs []Organization, uuid string) bool {
+	for _, o := range orgs {
+		if o.UUID == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverOrg looks up the organizations for cfg's session key, shows
+// them in view's organization picker and saves a pick to config.json with save (updateConfig
+// or updateConfigAuto). It returns the chosen org ID.
+func discoverOrg(ctx context.Context, cfg *Config, view updateView, save func(string, func(*Config)) error) (string, error) {
+	orgs, err := fetchOrganizations(ctx, cfg)
+	if err != nil {
+		return "", fmt.Errorf("listing organizations: %w", err)
+	}
+	view.setOrgs(orgs, cfg.OrgID)
+	org, ok := pickOrganization(orgs)
+	if !ok {
+		return "", fmt.Errorf("session has no organizations")
+	}
+	if err := save(configPath, func(c *Config) { c.OrgID = org.UUID }); err != nil {
+		return "", fmt.Errorf("saving org_id: %w", err)
+	}
+	apiLog.Infof("Organization selected: %s (%s), %d available", org.Name, shortID(org.UUID), len(orgs))
+	view.setOrgs(orgs, org.UUID)
+	return org.UUID, nil
+}
+
+// isOrgRejected reports whether err means the usage endpoint doesn't
+// accept the configured org (left the team, org deleted, stale cookie).
+func isOrgRejected(err error) bool {
+	var herr *ErrHTTP
+	if !errors.As(err, &herr) {
+		return false
+	}
+	return herr.StatusCode == http.StatusForbidden || herr.StatusCode == http.StatusNotFound
+}
+
+// maxOrgMenuItems is how many organizations the picker can list; systray
+// can't add items after the fact without them piling up, so slots are
+// created up front and hidden.
+const maxOrgMenuItems = 8
+
+// orgMenu is the "Organization" submenu, shown only when the session
+// belongs to more than one organization.
+type orgMenu struct {
+	mu     sync.Mutex
+	parent *systray.MenuItem
+	slots  []*systray.MenuItem
+	orgs   []Organization
+
+	// selected receives the UUID of an organization picked in the menu.
+	selected chan string
+}
+
+func newOrgMenu() *orgMenu {
+	m := &orgMenu{
+		parent:   systray.AddMenuItem("Organization", "Which organization's usage to show"),
+		selected: make(chan string, 1),
+	}
+	m.parent.Hide()
+	for i := 0; i < maxOrgMenuItems; i++ {
+		item := m.parent.AddSubMenuItemCheckbox("", "", false)
+		item.Hide()
+		m.slots = append(m.slots, item)
+		go func(i int) {
+			for range item.ClickedCh {
+				m.mu.Lock()
+				var uuid string
+				if i < len(m.orgs) {
+					uuid = m.orgs[i].UUID
+				}
+				m.mu.Unlock()
+				if uuid != "" {
+					m.selected <- uuid
+				}
+			}
+		}(i)
+	}
+	return m
+}
+
+// set shows orgs in the submenu with current checked.
+func (m *orgMenu) set(orgs []Organization, current string) {
+	m.mu.Lock()
+	m.orgs = orgs
+	m.mu.Unlock()
+	if len(orgs) > len(m.slots) {
+		apiLog.Infof("Organization menu: showing %d of %d organizations", len(m.slots), len(orgs))
+	}
+	ui.do(func() {
+		for i, item := range m.slots {
+			if i >= len(orgs) {
+				item.Hide()
+				continue
+			}
+			name := orgs[i].Name
+			if name == "" {
+				name = orgs[i].UUID
+			}
+			item.SetTitle(truncate(name, maxMenuLine))
+			if orgs[i].UUID == current {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+			item.Show()
+		}
+		if len(orgs) > 1 {
+			m.parent.Show()
+		} else {
+			m.parent.Hide()
+		}
+	})
+}
+
+// orgCheckInterval is how often the configured org_id is checked against
+// the session's organizations list.
+const orgCheckInterval = 24 * time.Hour
+
+// orgCheck is a cached result of checkOrgMembership, keyed by org ID and
+// session key fingerprint.
+type orgCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Listed    bool      `json:"listed"`
+}
+
+// checkOrgMembership verifies, at most once per orgCheckInterval, that
+// cfg.OrgID is still among the session's organizations. The usage endpoint
+// can keep answering for an org that was merged away, just with numbers
+// that no longer match the web UI. It returns why the org looks wrong, or
+// "" when everything looks fine; checked is false when the check was
+// skipped or failed, which tells nothing either way.
+func checkOrgMembership(ctx context.Context, cfg *Config, usage *UsageResponse) (reason string, checked bool) {
+	if usage != nil && usage.redirectedTo != "" {
+		apiLog.Warnf("Usage request%s was redirected to %s", accountLabel(cfg), usage.redirectedTo)
+		return "usage request redirected", true
+	}
+	if cfg.StrictNetwork {
+		return "", false
+	}
+
+	key := orgCheckKey(cfg)
+	if st, err := readStateFile(statePath()); err == nil {
+		if c := st.OrgChecks[key]; c != nil && time.Since(c.CheckedAt) < orgCheckInterval {
+			if !c.Listed {
+				return "org not in your organizations", true
+			}
+			return "", true
+		}
+	}
+
+	orgs, err := fetchOrganizations(ctx, cfg)
+	if err != nil {
+		// Not cached, so the next update tries again
+		apiLog.Warnf("Organization check%s failed: %v", accountLabel(cfg), err)
+		return "", false
+	}
+	listed := findOrganization(orgs, cfg.OrgID)
+	err = updateState(statePath(), func(st *appState) {
+		if st.OrgChecks == nil {
+			st.OrgChecks = make(map[string]*orgCheck)
+		}
+		st.OrgChecks[key] = &orgCheck{CheckedAt: time.Now(), Listed: listed}
+	})
+	if err != nil {
+		apiLog.Warnf("Failed to save organization check: %v", err)
+	}
+	if !listed {
+		apiLog.Warnf("org_id %s%s is not among the session's %d organizations",
+			shortID(cfg.OrgID), accountLabel(cfg), len(orgs))
+		return "org not in your organizations", true
+	}
+	return "", true
+}
+
+// orgCheckKey identifies cfg's org and session key in the state file.
+func orgCheckKey(cfg *Config) string {
+	return cfg.OrgID + ":" + secretFingerprint(cfg.SessionKey)
+}
+
+func orgWarning(cfg *Config, reason string) string {
+	w := mark(markWarning, accessibleText.Load())
+	if cfg.accountName != "" {
+		return w + " " + cfg.accountName + ": " + reason + ", check org_id"
+	}
+	return w + " " + reason + ", click to re-detect"
+}
+
+// orgNotices remembers the org mismatches the user was notified about, so
+// that a mismatch raises one notification rather than one per update.
+type orgNotices struct {
+	mu       sync.Mutex
+	notified map[string]string // reason, by orgCheckKey; loaded from state
+}
+
+var orgMismatchNotices orgNotices
+
+// check returns the notification for a mismatch found for cfg that wasn't
+// notified yet. A check that finds the org fine forgets the mismatch, so a
+// later one is notified again; one that wasn't made changes nothing.
+func (n *orgNotices) check(cfg *Config, reason string, checked bool) []notification {
+	if !checked {
+		return nil
+	}
+	key := orgCheckKey(cfg)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.notified == nil {
+		n.notified = map[string]string{}
+		if st, err := readStateFile(statePath()); err == nil {
+			maps.Copy(n.notified, st.OrgNotices)
+		}
+	}
+	if n.notified[key] == reason {
+		return nil
+	}
+	if reason == "" {
+		delete(n.notified, key)
+	} else {
+		n.notified[key] = reason
+	}
+	notified := maps.Clone(n.notified)
+	if err := updateState(statePath(), func(st *appState) { st.OrgNotices = notified }); err != nil {
+		apiLog.Warnf("Failed to save organization notices: %v", err)
+	}
+	if reason == "" {
+		return nil
+	}
+	body := "The usage shown may belong to another organization. Click the warning in the menu to re-detect it."
+	if cfg.accountName != "" {
+		body = "The usage shown for " + cfg.accountName + " may belong to another organization. Check its org_id in config.json."
+	}
+	return []notification{{title: "Organization mismatch: " + reason, body: body}}
+}
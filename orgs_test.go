@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestOrgMismatchNotifiedOnce(t *testing.T) {
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	cfg := &Config{SessionKey: "sk-ant-sid01-org", OrgID: "org-merged"}
+	const reason = "org not in your organizations"
+
+	var n orgNotices
+	if notes := n.check(cfg, reason, true); len(notes) != 1 || notes[0].title != "Organization mismatch: "+reason {
+		t.Fatalf("first mismatch: %+v", notes)
+	}
+	if notes := n.check(cfg, reason, true); len(notes) != 0 {
+		t.Errorf("same mismatch notified again: %+v", notes)
+	}
+	// A skipped check says nothing; after a restart the notice is
+	// remembered from the state file
+	n.check(cfg, "", false)
+	var restarted orgNotices
+	if notes := restarted.check(cfg, reason, true); len(notes) != 0 {
+		t.Errorf("notified again after a restart: %+v", notes)
+	}
+	// Once the org checks out, a new mismatch is news again
+	restarted.check(cfg, "", true)
+	if notes := restarted.check(cfg, "usage request redirected", true); len(notes) != 1 {
+		t.Errorf("mismatch after a fix: %+v", notes)
+	}
+}
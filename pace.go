@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionPaceWindow and weeklyPaceWindow are how far back the pace of
+	// the session and weekly buckets is measured. The weekly bucket moves
+	// too slowly for an hour to say much.
+	sessionPaceWindow = time.Hour
+	weeklyPaceWindow  = 12 * time.Hour
+	// paceMinSpan is the shortest stretch of readings a pace is computed
+	// from.
+	paceMinSpan = 10 * time.Minute
+	// paceResetDrop is how far utilization must fall between two readings,
+	// in points, for the window to count as reset in between.
+	paceResetDrop = 1
+)
+
+// bucketPace is how fast a bucket has been filling up lately.
+type bucketPace struct {
+	utilization float64
+	resetsAt    string
+	// idle means utilization rose by less than a point over the readings.
+	idle bool
+	// toLimit is when the bucket fills up at the current pace, from the
+	// latest reading; beforeReset says that comes before the reset.
+	toLimit     time.Duration
+	beforeReset bool
+}
+
+// estimatePace measures the pace of bucket, keyed as in historyBuckets,
+// in samples (oldest first) over the last window: the utilization gained
+// since the earliest reading in it, per hour. A drop in utilization means
+// the limit reset, so only readings after it count, and so do only those
+// of the bucket's latest series (see bucketSeries). It reports false if
+// the readings span less than paceMinSpan, the latest is older than
+// window, the bucket is full or has been removed.
+func estimatePace(samples []historySample, bucket string, window time.Duration, now time.Time) (bucketPace, bool) {
+	all, removed := bucketSeries(samples, bucket, time.Time{})
+	if removed || len(all) == 0 {
+		return bucketPace{}, false
+	}
+	series := all[len(all)-1]
+	var last, first *UsageBucket
+	var lastAt, firstAt time.Time
+	for i := len(series) - 1; i >= 0; i-- {
+		b, at := series[i].bucket, series[i].at
+		if last == nil {
+			if now.Sub(at) > window {
+				return bucketPace{}, false
+			}
+			last, lastAt = b, at
+			first, firstAt = b, at
+			continue
+		}
+		if lastAt.Sub(at) > window || b.Utilization > first.Utilization+paceResetDrop {
+			break
+		}
+		first, firstAt = b, at
+	}
+	span := lastAt.Sub(firstAt)
+	if last == nil || span < paceMinSpan || last.Utilization >= 100 {
+		return bucketPace{}, false
+	}
+
+	p := bucketPace{utilization: last.Utilization, resetsAt: last.ResetsAt}
+	gained := last.Utilization - first.Utilization
+	if gained < 1 {
+		p.idle = true
+		return p, true
+	}
+	perHour := gained / span.Hours()
+	p.toLimit = time.Duration((100 - last.Utilization) / perHour * float64(time.Hour))
+	p.toLimit -= now.Sub(lastAt)
+	if p.toLimit < 0 {
+		p.toLimit = 0
+	}
+	if left, ok := resetDuration(last.ResetsAt); ok && p.toLimit < left {
+		p.beforeReset = true
+	}
+	return p, true
+}
+
+// paceView is what the pace rows under the session and weekly rows show;
+// an empty row is hidden.
+type paceView struct {
+	session, weekly string
+	// sessionPace and weeklyPace are the estimates behind the rows, for
+	// the notifications.
+	sessionPace, weeklyPace *bucketPace
+}
+
+// pace estimates both buckets from the samples in memory.
+func (h *historyLog) pace(now time.Time) paceView {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.load(now)
+	return renderPace(h.recent, now, accessibleText.Load())
+}
+
+func renderPace(samples []historySample, now time.Time, accessible bool) paceView {
+	var v paceView
+	if p, ok := estimatePace(samples, "five_hour", sessionPaceWindow, now); ok {
+		v.session, v.sessionPace = renderPaceLine("Session", p, accessible), &p
+	}
+	if p, ok := estimatePace(samples, "seven_day", weeklyPaceWindow, now); ok {
+		v.weekly, v.weeklyPace = renderPaceLine("Weekly", p, accessible), &p
+	}
+	return v
+}
+
+// renderPaceLine formats a pace row, e.g.
+// "Session: 62% — ~1h 40m at current pace (resets in 2h 10m)", marked with
+// a warning when the limit is reached before the reset. Spelled out in
+// words the row is kept short enough for the menu.
+func renderPaceLine(name string, p bucketPace, accessible bool) string {
+	var line string
+	switch {
+	case accessible && p.idle:
+		line = name + " pace: idle"
+	case accessible:
+		// The bucket row above has the percentage and reset in words
+		line = fmt.Sprintf("%s: about %s to the limit", name, spellDuration(p.toLimit))
+	case p.idle:
+		line = fmt.Sprintf("%s: %d%% — pace: idle", name, int(p.utilization))
+	default:
+		line = fmt.Sprintf("%s: %d%% — ~%s at current pace", name, int(p.utilization), paceDuration(p.toLimit))
+		if r := formatReset(resetTime(p.resetsAt)); r != "?" {
+			line += " (resets " + r + ")"
+		}
+	}
+	if p.beforeReset {
+		line = mark(markWarning, accessible) + " " + line
+	}
+	return truncate(line, maxMenuLine)
+}
+
+// paceDuration formats d like formatReset does: "1h 40m", "2d 3h", "25m".
+func paceDuration(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	switch {
+	case h >= 24:
+		return fmt.Sprintf("%dd %dh", h/24, h%24)
+	case h > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// spellDuration is paceDuration in words.
+func spellDuration(d time.Duration) string {
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	switch {
+	case h >= 24:
+		return plural(h/24, "day") + " " + plural(h%24, "hour")
+	case h > 0:
+		return plural(h, "hour") + " " + plural(m, "minute")
+	}
+	return plural(m, "minute")
+}
+
+// paceAlertState records the windows, by reset time, that a pace
+// notification was raised for, so each window warns once.
+type paceAlertState struct {
+	SessionResetsAt string `json:"session_resets_at,omitempty"`
+	WeeklyResetsAt  string `json:"weekly_resets_at,omitempty"`
+}
+
+// checkPaceAlerts returns a notification for each bucket projected to hit
+// its limit before it resets, once per window, if notify_pace is set.
+func checkPaceAlerts(cfg *Config, v paceView) []notification {
+	if cfg == nil || !cfg.NotifyPace || (v.sessionPace == nil && v.weeklyPace == nil) {
+		return nil
+	}
+	var fired []notification
+	err := updateState(statePath(), func(st *appState) {
+		if st.PaceAlerts == nil {
+			st.PaceAlerts = &paceAlertState{}
+		}
+		check := func(name string, p *bucketPace, warned *string) {
+			if p == nil || !p.beforeReset || p.resetsAt == "" || *warned == p.resetsAt {
+				return
+			}
+			*warned = p.resetsAt
+			fired = append(fired, notification{
+				title: fmt.Sprintf("%s limit in ~%s", name, paceDuration(p.toLimit)),
+				body:  fmt.Sprintf("At the current pace the %s limit runs out before it resets %s", strings.ToLower(name), formatReset(resetTime(p.resetsAt))),
+			})
+		}
+		check("Session", v.sessionPace, &st.PaceAlerts.SessionResetsAt)
+		check("Weekly", v.weeklyPace, &st.PaceAlerts.WeeklyResetsAt)
+	})
+	if err != nil {
+		uiLog.Warnf("Failed to save pace alerts: %v", err)
+		return nil
+	}
+	return fired
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderPace(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	resets := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	sample := func(ago time.Duration, session, weekly float64) historySample {
+		return historySample{Time: now.Add(-ago), Usage: &UsageResponse{
+			FiveHour: UsageBucket{Utilization: session, ResetsAt: resets(2*time.Hour + 10*time.Minute)},
+			SevenDay: UsageBucket{Utilization: weekly, ResetsAt: resets(72 * time.Hour)},
+		}}
+	}
+
+	// Session: 57 points in the 40 minutes since the reset, 38 left: 26m,
+	// before the reset. Weekly: flat.
+	v := renderPace([]historySample{
+		sample(90*time.Minute, 80, 40), // before the reset, ignored
+		sample(40*time.Minute, 5, 40),
+		sample(30*time.Minute, 42, 40),
+		{Time: now.Add(-20 * time.Minute), Error: "timeout"},
+		sample(0, 62, 40),
+	}, now, false)
+	if want := "⚠ Session: 62% — ~26m at current pace (resets in 2h 10m)"; v.session != want {
+		t.Errorf("session = %q, want %q", v.session, want)
+	}
+	if v.weekly != "Weekly: 40% — pace: idle" {
+		t.Errorf("weekly = %q", v.weekly)
+	}
+
+	// Readings spanning too little time, or too old, give no estimate
+	if v := renderPace([]historySample{sample(5*time.Minute, 10, 10), sample(0, 20, 20)}, now, false); v.session != "" || v.weekly != "" {
+		t.Errorf("short span: %+v", v)
+	}
+	if v := renderPace([]historySample{sample(20*time.Hour, 10, 10), sample(13*time.Hour, 20, 20)}, now, false); v.session != "" || v.weekly != "" {
+		t.Errorf("old readings: %+v", v)
+	}
+}
+
+func TestEstimatePace(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	const session = "five_hour"
+	reading := func(ago time.Duration, pct float64, resetsIn time.Duration) historySample {
+		return historySample{Time: now.Add(-ago), Usage: &UsageResponse{
+			FiveHour: UsageBucket{Utilization: pct, ResetsAt: now.Add(resetsIn).Format(time.RFC3339)},
+		}}
+	}
+
+	// 10 points an hour with 50 left: 5 hours, after the reset in 2
+	p, ok := estimatePace([]historySample{reading(time.Hour, 40, 2*time.Hour), reading(0, 50, 2*time.Hour)}, session, 2*time.Hour, now)
+	if !ok || p.idle || p.toLimit != 5*time.Hour || p.beforeReset {
+		t.Errorf("steady pace: %+v, %v", p, ok)
+	}
+
+	// A reset between polls restarts the window
+	p, ok = estimatePace([]historySample{
+		reading(50*time.Minute, 90, 10*time.Minute),
+		reading(30*time.Minute, 0, 5*time.Hour),
+		reading(0, 30, 5*time.Hour),
+	}, session, time.Hour, now)
+	if !ok || p.toLimit != 70*time.Minute || !p.beforeReset {
+		t.Errorf("after a reset: %+v, %v", p, ok)
+	}
+
+	if _, ok := estimatePace([]historySample{reading(time.Hour, 90, time.Hour), reading(0, 100, time.Hour)}, session, 2*time.Hour, now); ok {
+		t.Error("estimated the pace of a full bucket")
+	}
+}
+
+func TestRenderPaceLineAccessible(t *testing.T) {
+	p := bucketPace{utilization: 62, toLimit: 100 * time.Minute, beforeReset: true}
+	if got, want := renderPaceLine("Session", p, true), "Warning: Session: about 1 hour 40 minutes to the limit"; got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+	if got, want := renderPaceLine("Weekly", bucketPace{utilization: 3, idle: true}, true), "Weekly pace: idle"; got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestCheckPaceAlerts(t *testing.T) {
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	resets := time.Now().Add(2 * time.Hour).Format(time.RFC3339)
+	v := paceView{sessionPace: &bucketPace{utilization: 80, resetsAt: resets, toLimit: 30 * time.Minute, beforeReset: true}}
+	if notes := checkPaceAlerts(&Config{}, v); notes != nil {
+		t.Errorf("without notify_pace: %v", notes)
+	}
+	cfg := &Config{NotifyPace: true}
+	if notes := checkPaceAlerts(cfg, v); len(notes) != 1 || notes[0].title != "Session limit in ~30m" {
+		t.Errorf("first warning: %+v", notes)
+	}
+	if notes := checkPaceAlerts(cfg, v); notes != nil {
+		t.Errorf("same window warned again: %+v", notes)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// sessionKeyPrefix starts every claude.ai sessionKey.
+const sessionKeyPrefix = "sk-ant-"
+
+// parsePastedCookies extracts the credentials from text copied out of the
+// browser's DevTools: a request copied with "Copy as cURL" (bash or cmd
+// flavor), a Cookie header or just its value, or a bare sessionKey.
+func parsePastedCookies(text string) (sessionKey, orgID, cfClearance string, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", "", "", errors.New("the clipboard is empty")
+	}
+
+	header := text
+	if words := shellWords(text); len(words) > 0 && isCurl(words[0]) {
+		var ok bool
+		if header, ok = curlCookies(words); !ok {
+			return "", "", "", errors.New("the cURL command sends no cookies; copy a request to claude.ai")
+		}
+	} else if !strings.Contains(text, "=") && strings.HasPrefix(text, sessionKeyPrefix) {
+		header = "sessionKey=" + text
+	}
+
+	cookies := parseCookieHeader(header)
+	sessionKey = cookies["sessionKey"]
+	switch {
+	case sessionKey == "":
+		return "", "", "", errors.New("no sessionKey in the clipboard; copy a claude.ai request as cURL, or its Cookie header")
+	case !strings.HasPrefix(sessionKey, sessionKeyPrefix):
+		return "", "", "", fmt.Errorf("sessionKey doesn't start with %q; copy it again", sessionKeyPrefix)
+	}
+	return sessionKey, cookies["lastActiveOrg"], cookies["cf_clearance"], nil
+}
+
+// parseCookieHeader splits a Cookie header, with or without the "Cookie:"
+// name, into its name=value pairs.
+func parseCookieHeader(h string) map[string]string {
+	h = strings.TrimSpace(h)
+	if len(h) > 7 && strings.EqualFold(h[:7], "cookie:") {
+		h = h[7:]
+	}
+	cookies := make(map[string]string)
+	for _, part := range strings.FieldsFunc(h, func(r rune) bool { return r == ';' || r == '\n' || r == '\r' }) {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		name, value = strings.TrimSpace(name), strings.Trim(strings.TrimSpace(value), `"`)
+		if name != "" && value != "" {
+			cookies[name] = value
+		}
+	}
+	return cookies
+}
+
+func isCurl(word string) bool {
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(word, `\`, "/")))
+	return name == "curl" || name == "curl.exe"
+}
+
+// curlCookies returns the cookies a curl command line sends, from a Cookie
+// header (-H) or -b/--cookie.
+func curlCookies(words []string) (string, bool) {
+	for i := 1; i+1 < len(words); i++ {
+		switch words[i] {
+		case "-H", "--header":
+			if h := words[i+1]; len(h) > 7 && strings.EqualFold(h[:7], "cookie:") {
+				return h, true
+			}
+		case "-b", "--cookie":
+			return words[i+1], true
+		}
+	}
+	return "", false
+}
+
+// shellWords splits a command line the way a shell would, as far as
+// "Copy as cURL" output needs: single, double and $'...' quotes, and
+// backslash-newline continuations. The cmd flavor, recognized by its ^"
+// quotes, escapes with ^ instead of backslash.
+func shellWords(s string) []string {
+	escape := byte('\\')
+	if strings.Contains(s, `^"`) {
+		escape = '^'
+	}
+	var words []string
+	var cur strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == escape && i+1 < len(s):
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+			if s[i] == '\n' {
+				continue // line continuation
+			}
+			if escape == '^' && s[i] == '"' {
+				// ^"...^" is cmd's way of passing a quoted argument
+				i++
+				for ; i < len(s) && !(s[i] == '^' && i+1 < len(s) && s[i+1] == '"'); i++ {
+					if s[i] == '^' && i+1 < len(s) {
+						i++
+					}
+					cur.WriteByte(s[i])
+				}
+				i++
+			} else {
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == '\'' && escape == '\\':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && escape == '\\' && i+1 < len(s) && s[i+1] == '\'':
+			// ANSI-C quoting, used when a value contains a quote
+			for i += 2; i < len(s) && s[i] != '\''; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && escape == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				cur.WriteByte(s[i])
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePastedCookies(t *testing.T) {
+	const cookies = "anthropic-device-id=d1; sessionKey=sk-ant-sid01-paste; lastActiveOrg=org-paste; cf_clearance=cf.paste-1"
+	tests := []struct {
+		name, text  string
+		sk, org, cf string
+	}{
+		{"cookie value", cookies, "sk-ant-sid01-paste", "org-paste", "cf.paste-1"},
+		{"cookie header", "Cookie: " + cookies + "\n", "sk-ant-sid01-paste", "org-paste", "cf.paste-1"},
+		{"bare sessionKey", "  sk-ant-sid01-paste\n", "sk-ant-sid01-paste", "", ""},
+		{"curl bash", "curl 'https://claude.ai/api/organizations/org-paste/usage' \\\n" +
+			"  -H 'accept: */*' \\\n" +
+			"  -b '" + cookies + "' \\\n" +
+			"  -H 'user-agent: Mozilla/5.0'", "sk-ant-sid01-paste", "org-paste", "cf.paste-1"},
+		{"curl firefox", "curl 'https://claude.ai/api/organizations/org-paste/usage' --compressed " +
+			"-H 'Accept: */*' -H 'Cookie: " + cookies + "' -H 'Connection: keep-alive'",
+			"sk-ant-sid01-paste", "org-paste", "cf.paste-1"},
+		{"curl ansi-c quoting", `curl 'https://claude.ai/api/x' -H $'cookie: sessionKey=sk-ant-sid01-paste; note=it\'s'`,
+			"sk-ant-sid01-paste", "", ""},
+		{"curl cmd", "curl ^\"https://claude.ai/api/organizations/org-paste/usage^\" ^\r\n" +
+			"  -H ^\"accept: */*^\" ^\r\n" +
+			"  -b ^\"" + cookies + "^\" ^\r\n" +
+			"  -H ^\"user-agent: Mozilla/5.0^\"", "sk-ant-sid01-paste", "org-paste", "cf.paste-1"},
+	}
+	for _, tt := range tests {
+		sk, org, cf, err := parsePastedCookies(tt.text)
+		if err != nil || sk != tt.sk || org != tt.org || cf != tt.cf {
+			t.Errorf("%s: got %q, %q, %q, %v", tt.name, sk, org, cf, err)
+		}
+	}
+
+	for _, tt := range []struct{ name, text, want string }{
+		{"empty", " \n", "empty"},
+		{"curl without cookies", "curl 'https://claude.ai/api/x' -H 'accept: */*'", "sends no cookies"},
+		{"no sessionKey", "lastActiveOrg=org; cf_clearance=cf", "no sessionKey"},
+		{"wrong prefix", "sessionKey=abc123", "doesn't start with"},
+		{"unrelated text", "hello world", "no sessionKey"},
+	} {
+		if _, _, _, err := parsePastedCookies(tt.text); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const appDirName = "claude-monitor"
+
+// appPaths says where each kind of file lives.
+//
+//	config: Linux $XDG_CONFIG_HOME/claude-monitor, Windows %APPDATA%\claude-monitor,
+//	        macOS ~/Library/Application Support/claude-monitor
+//	state:  Linux $XDG_STATE_HOME/claude-monitor, Windows %LOCALAPPDATA%\claude-monitor,
+//	        macOS ~/Library/Application Support/claude-monitor
+//
+// State covers everything the app writes on its own: log, state.json, saved
+// API responses.
+type appPaths struct {
+	configDir string
+	stateDir  string
+}
+
+var paths appPaths
+
+func (p appPaths) configFile() string { return filepath.Join(p.configDir, "config.json") }
+func (p appPaths) logFile() string    { return filepath.Join(p.stateDir, "claude-monitor.log") }
+func (p appPaths) stateFile() string  { return filepath.Join(p.stateDir, "state.json") }
+
+// historyFile is the usage history; oldHistoryFile is where it is rotated to.
+func (p appPaths) historyFile() string    { return filepath.Join(p.stateDir, "history.jsonl") }
+func (p appPaths) oldHistoryFile() string { return filepath.Join(p.stateDir, "history.1.jsonl") }
+
+// resolvePaths picks the platform directories, falling back to exeDir when
+// the user directories can't be determined.
+func resolvePaths(exeDir string) appPaths {
+	configBase, err := os.UserConfigDir()
+	if err != nil {
+		return appPaths{configDir: exeDir, stateDir: exeDir}
+	}
+	return appPaths{
+		configDir: filepath.Join(configBase, appDirName),
+		stateDir:  filepath.Join(userStateDir(configBase), appDirName),
+	}
+}
+
+// userStateDir returns the base directory for mutable application state.
+func userStateDir(configBase string) string {
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return dir
+		}
+	case "darwin":
+		return configBase
+	default:
+		if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+			return dir
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, ".local", "state")
+		}
+	}
+	return configBase
+}
+
+// migrateLegacyFiles moves files that older versions kept next to the
+// executable into their new directories. Files already present at the new
+// location are never overwritten. It returns one message per action for
+// the log (the log itself is one of the files being moved).
+func migrateLegacyFiles(exeDir string, p appPaths) []string {
+	moves := []struct{ name, dir string }{
+		{"config.json", p.configDir},
+		{"README-config.txt", p.configDir},
+		{"claude-monitor.log", p.stateDir},
+		{"state.json", p.stateDir},
+	}
+
+	var msgs []string
+	for _, m := range moves {
+		src := filepath.Join(exeDir, m.name)
+		dst := filepath.Join(m.dir, m.name)
+		if filepath.Clean(src) == filepath.Clean(dst) {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if _, err := os.Stat(dst); err == nil {
+			msgs = append(msgs, fmt.Sprintf("Legacy %s left in place, %s already exists", src, dst))
+			continue
+		}
+		if err := moveFile(src, dst); err != nil {
+			msgs = append(msgs, fmt.Sprintf("Failed to migrate %s: %v", src, err))
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("Migrated %s -> %s", src, dst))
+	}
+	return msgs
+}
+
+// moveFile renames src to dst, copying when a rename isn't possible
+// (different volumes). If the source can't be removed afterwards (read-only
+// install directory) the copy still counts as migrated.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	os.Remove(src) //nolint — best-effort, see above
+	return nil
+}
+
+// oldDataFiles are the files in the state directory that "Move old data
+// here" carries over from a previously used one.
+var oldDataFiles = []string{"claude-monitor.log", "state.json", "last-api-response.txt"}
+
+// oldLogName receives the log of a previously used state directory; it is
+// appended to, so several old locations can be merged.
+const oldLogName = "claude-monitor.old.log"
+
+// hasOldData reports whether dir holds any of oldDataFiles.
+func hasOldData(dir string) bool {
+	for _, name := range oldDataFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDataDir adds the current state directory to those listed in its
+// state.json and returns the others that still hold data. Entries whose
+// data is gone are dropped. Only directories are recorded: the paths of the
+// files themselves are always derived anew from appPaths.
+func recordDataDir(current string) (orphaned []string, err error) {
+	err = updateState(filepath.Join(current, "state.json"), func(st *appState) {
+		dirs := []string{current}
+		for _, dir := range st.DataDirs {
+			if !sameDir(dir, current) && hasOldData(dir) {
+				dirs = append(dirs, dir)
+				orphaned = append(orphaned, dir)
+			}
+		}
+		st.DataDirs = dirs
+	})
+	return orphaned, err
+}
+
+func sameDir(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// moveOldData moves the data files of each of dirs into current. Files
+// already in current win: an old log is appended to oldLogName, an old
+// state.json only contributes the directories it recorded, and an old
+// last-api-response.txt is dropped. Moved directories leave the list in
+// state.json. Running it again finds nothing left to move.
+func moveOldData(current string, dirs []string) ([]string, error) {
+	var msgs []string
+	var recorded []string
+	for _, dir := range dirs {
+		if sameDir(dir, current) {
+			continue
+		}
+		if old, err := readStateFile(filepath.Join(dir, "state.json")); err == nil {
+			recorded = append(recorded, old.DataDirs...)
+		}
+		for _, name := range oldDataFiles {
+			src := filepath.Join(dir, name)
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			dst := filepath.Join(current, name)
+			var msg string
+			var err error
+			switch _, statErr := os.Stat(dst); {
+			case os.IsNotExist(statErr):
+				err = moveFile(src, dst)
+				msg = fmt.Sprintf("Moved %s -> %s", src, dst)
+			case name == "claude-monitor.log":
+				dst = filepath.Join(current, oldLogName)
+				if err = appendFile(src, dst); err == nil {
+					err = os.Remove(src)
+				}
+				msg = fmt.Sprintf("Appended %s to %s", src, dst)
+			default:
+				err = os.Remove(src)
+				msg = fmt.Sprintf("Removed %s, %s is newer", src, dst)
+			}
+			if err != nil {
+				return msgs, fmt.Errorf("moving %s: %w", src, err)
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+
+	err := updateState(filepath.Join(current, "state.json"), func(st *appState) {
+		dirs := []string{current}
+		for _, dir := range append(st.DataDirs, recorded...) {
+			if !sameDir(dir, current) && !containsDir(dirs, dir) && hasOldData(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		st.DataDirs = dirs
+	})
+	return msgs, err
+}
+
+func containsDir(dirs []string, dir string) bool {
+	for _, d := range dirs {
+		if sameDir(d, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendFile appends the contents of src to dst, creating dst if needed.
+func appendFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ensureDirs creates the config and state directories.
+func (p appPaths) ensureDirs() error {
+	for _, dir := range []string{p.configDir, p.stateDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
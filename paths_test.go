@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMoveOldData(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "old-exe-dir")
+	older := filepath.Join(root, "older-exe-dir")
+	current := filepath.Join(root, "state")
+
+	// A run from older, then one from old, which recorded both
+	writeFiles(t, older, map[string]string{"claude-monitor.log": "older log\n"})
+	writeFiles(t, old, map[string]string{
+		"claude-monitor.log":    "old log\n",
+		"state.json":            `{"data_dirs": ["` + filepath.ToSlash(old) + `", "` + filepath.ToSlash(older) + `"]}`,
+		"last-api-response.txt": "HTTP 403",
+	})
+	// The current location: a fresh log, and state.json that knows old
+	writeFiles(t, current, map[string]string{
+		"claude-monitor.log": "new log\n",
+		"state.json":         `{"data_dirs": ["` + filepath.ToSlash(current) + `", "` + filepath.ToSlash(old) + `"]}`,
+	})
+
+	orphaned, err := recordDataDir(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphaned) != 1 || !sameDir(orphaned[0], old) {
+		t.Fatalf("orphaned = %q, want [%s]", orphaned, old)
+	}
+
+	if _, err := moveOldData(current, orphaned); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(current, "claude-monitor.log")); got != "new log\n" {
+		t.Errorf("current log = %q, want it untouched", got)
+	}
+	if got := readFile(t, filepath.Join(current, oldLogName)); got != "old log\n" {
+		t.Errorf("%s = %q", oldLogName, got)
+	}
+	if got := readFile(t, filepath.Join(current, "last-api-response.txt")); got != "HTTP 403" {
+		t.Errorf("last-api-response.txt = %q", got)
+	}
+	if hasOldData(old) {
+		t.Errorf("%s still holds data", old)
+	}
+
+	// old's state.json pointed at older, which is now known here
+	orphaned, err = recordDataDir(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphaned) != 1 || !sameDir(orphaned[0], older) {
+		t.Fatalf("after the first move orphaned = %q, want [%s]", orphaned, older)
+	}
+	if _, err := moveOldData(current, orphaned); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(current, oldLogName)); got != "old log\nolder log\n" {
+		t.Errorf("%s = %q, want both old logs", oldLogName, got)
+	}
+
+	// Idempotent: nothing is left to find or move
+	msgs, err := moveOldData(current, []string{old, older})
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("second move: %q, %v; want nothing to do", msgs, err)
+	}
+	if orphaned, err := recordDataDir(current); err != nil || len(orphaned) != 0 {
+		t.Errorf("orphaned after moving = %q, %v", orphaned, err)
+	}
+	st, err := readStateFile(filepath.Join(current, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.DataDirs) != 1 || !sameDir(st.DataDirs[0], current) {
+		t.Errorf("data_dirs = %q, want only the current directory", st.DataDirs)
+	}
+}
+
+func TestMoveOldDataIntoEmptyDir(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "old")
+	current := filepath.Join(root, "new")
+	writeFiles(t, old, map[string]string{"claude-monitor.log": "old log\n", "state.json": `{"fetched_at": "2026-01-02T03:04:05Z"}`})
+
+	if _, err := moveOldData(current, []string{old}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(current, "claude-monitor.log")); got != "old log\n" {
+		t.Errorf("log = %q, want the old one moved as is", got)
+	}
+	st, err := readStateFile(filepath.Join(current, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.FetchedAt.IsZero() || len(st.DataDirs) != 1 {
+		t.Errorf("state.json = %+v, want the old snapshot with the current directory recorded", st)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+// monitoringPaused is set while "Pause monitoring" is checked: automatic
+// updates don't start, and only Refresh now fetches, once.
+var monitoringPaused atomic.Bool
+
+// showPaused replaces the icon with the paused one. The rows keep the last
+// numbers, from before the pause or from a Refresh now during it.
+func (st *uiState) showPaused() {
+	st.setStatusIcon(statusPaused)
+	st.tooltip = appName + ": paused"
+	st.progress = ""
+}
+
+// setPaused pauses or resumes monitoring and saves it as paused in
+// config.json, so a pause outlasts a restart. Pausing cancels the running
+// update and shows the paused icon; resuming fetches right away through
+// refreshNow.
+func setPaused(paused bool, refreshNow func()) {
+	if err := updateConfig(configPath, func(c *Config) { c.Paused = paused }); err != nil {
+		uiLog.Warnf("Failed to save paused: %v", err)
+	}
+	monitoringPaused.Store(paused)
+	if !paused {
+		uiLog.Infof("Monitoring resumed")
+		refreshNow()
+		return
+	}
+
+	uiLog.Infof("Monitoring paused")
+	updateMu.Lock()
+	if cancelUpdate != nil {
+		cancelUpdate()
+	}
+	updateMu.Unlock()
+	st := ui.state()
+	st.showPaused()
+	ui.publish(ui.nextGeneration(), st)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPausedSkipsUpdates(t *testing.T) {
+	monitoringPaused.Store(true)
+	t.Cleanup(func() { monitoringPaused.Store(false) })
+
+	startUpdate, refreshNow, _ := updateStarters(headlessView{})
+	startUpdate()
+	refreshNow()
+	if n := activeUpdates.Load(); n != 0 {
+		t.Errorf("%d updates started while paused", n)
+	}
+}
+
+func TestShowPaused(t *testing.T) {
+	var st uiState
+	st.setUsageIcon(iconKey{a: 8, b: 60, c: 60}, false)
+	st.critical = true
+	st.progress = refreshingText
+	st.showPaused()
+	if !bytes.Equal(st.icon, iconKey{status: statusPaused}.icon()) {
+		t.Error("not the paused icon")
+	}
+	if st.critical || st.progress != "" || st.tooltip != appName+": paused" {
+		t.Errorf("paused state: critical %v, progress %q, tooltip %q", st.critical, st.progress, st.tooltip)
+	}
+	if st.lastUsage == nil {
+		t.Error("pausing forgot the last usage icon")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// policyFileName is the system-wide overlay an administrator can deploy
+// next to per-user config.json files.
+const policyFileName = "policy.json"
+
+// policyIgnoredKeys stay per-user: a policy can't set credentials.
+var policyIgnoredKeys = map[string]bool{
+	"session_key":  true,
+	"org_id":       true,
+	"cf_clearance": true,
+	"accounts":     true,
+}
+
+// policyPath returns where the policy file is looked for:
+//
+//	Linux   /etc/claude-monitor/policy.json
+//	Windows %ProgramData%\claude-monitor\policy.json
+//	macOS   /Library/Application Support/claude-monitor/policy.json
+var policyPath = func() string {
+	switch runtime.GOOS {
+	case "windows":
+		dir := os.Getenv("ProgramData")
+		if dir == "" {
+			dir = `C:\ProgramData`
+		}
+		return filepath.Join(dir, appDirName, policyFileName)
+	case "darwin":
+		return filepath.Join("/Library/Application Support", appDirName, policyFileName)
+	default:
+		return filepath.Join("/etc", appDirName, policyFileName)
+	}
+}
+
+// policy is the parsed overlay: the settings it enforces, keyed by their
+// config.json name.
+type policy struct {
+	path     string
+	settings map[string]json.RawMessage
+	ignored  []string // credential keys present in the file, not applied
+}
+
+// readPolicy loads the policy file. A missing file is no policy (nil, nil);
+// a broken one is an error, so that a typo by the administrator doesn't
+// silently unlock managed settings.
+func readPolicy(path string) (*policy, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading policy: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing policy %s: %w", path, err)
+	}
+	p := &policy{path: path, settings: make(map[string]json.RawMessage)}
+	for k, v := range raw {
+		if policyIgnoredKeys[k] {
+			p.ignored = append(p.ignored, k)
+			continue
+		}
+		p.settings[k] = v
+	}
+	sort.Strings(p.ignored)
+	return p, nil
+}
+
+// apply overlays the managed settings on cfg. Policy wins for every key it
+// lists, replacing the user's value whole: a managed spend_alert_usd list
+// doesn't pick up entries from config.json. Keys it doesn't list keep the
+// user's value.
+func (p *policy) apply(cfg *Config) error {
+	if p == nil || len(p.settings) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(p.settings)
+	if err != nil {
+		return err
+	}
+	var managed Config
+	if err := json.Unmarshal(data, &managed); err != nil {
+		return fmt.Errorf("applying policy %s: %w", p.path, err)
+	}
+	dst, src := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(managed)
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if _, ok := p.settings[name]; ok && name != "" && name != "-" {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return nil
+}
+
+// keys returns the managed config keys, sorted.
+func (p *policy) keys() []string {
+	if p == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(p.settings))
+	for k := range p.settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// managed reports whether key is set by the policy.
+func (p *policy) managed(key string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.settings[key]
+	return ok
+}
+
+// loadPolicy reads the policy for display purposes; errors read as none.
+func loadPolicy() *policy {
+	p, _ := readPolicy(policyPath())
+	return p
+}
+
+// logPolicy reports the policy in effect at startup.
+func logPolicy() {
+	path := policyPath()
+	p, err := readPolicy(path)
+	switch {
+	case err != nil:
+		configLog.Warnf("Policy: %v", err)
+	case p != nil:
+		configLog.Infof("Policy %s manages: %s", path, strings.Join(p.keys(), ", "))
+		if len(p.ignored) > 0 {
+			configLog.Warnf("Policy: ignoring per-user keys %s", strings.Join(p.ignored, ", "))
+		}
+	}
+}
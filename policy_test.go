@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readWithPolicy reads config.json and policy.json with the given contents
+// from a temporary directory.
+func readWithPolicy(t *testing.T, config, pol string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	policyFile := filepath.Join(dir, policyFileName)
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(policyFile, []byte(pol), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saved := policyPath
+	policyPath = func() string { return policyFile }
+	t.Cleanup(func() { policyPath = saved })
+	cfg, err := readConfigFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestPolicyReplacesUserValues(t *testing.T) {
+	user := `{
+		"session_key": "sk-ant-sid01-user",
+		"org_id": "user-org",
+		"icon_action": "copy_status",
+		"accessibility": "on",
+		"spend_alert_usd": [20, 40, 60]
+	}`
+	pol := `{
+		"icon_action": "refresh",
+		"spend_alert_usd": [100],
+		"session_key": "sk-ant-sid01-admin"
+	}`
+	cfg := readWithPolicy(t, user, pol)
+
+	if cfg.IconAction != "refresh" {
+		t.Errorf("icon_action = %q, want the policy's", cfg.IconAction)
+	}
+	if want := []float64{100}; !reflect.DeepEqual(cfg.SpendAlertUSD, want) {
+		t.Errorf("spend_alert_usd = %v, want only the policy's %v", cfg.SpendAlertUSD, want)
+	}
+
+	// Keys the policy doesn't list, and credentials, stay the user's
+	if cfg.Accessibility != "on" {
+		t.Errorf("accessibility = %q, want the user's", cfg.Accessibility)
+	}
+	if cfg.SessionKey != "sk-ant-sid01-user" || cfg.OrgID != "user-org" {
+		t.Errorf("credentials = %q, %q, want the user's", cfg.SessionKey, cfg.OrgID)
+	}
+}
+
+func TestPolicyNullClearsUserValue(t *testing.T) {
+	cfg := readWithPolicy(t,
+		`{"session_key": "sk-ant-sid01-user", "org_id": "org", "spend_alert_usd": [20]}`,
+		`{"spend_alert_usd": null}`)
+	if cfg.SpendAlertUSD != nil {
+		t.Errorf("spend_alert_usd = %v, want none", cfg.SpendAlertUSD)
+	}
+}
+
+func TestReadPolicyIgnoresCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), policyFileName)
+	os.WriteFile(path, []byte(`{"org_id": "x", "accounts": [], "icon_action": "copy_status"}`), 0644)
+	p, err := readPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"icon_action"}; !reflect.DeepEqual(p.keys(), want) {
+		t.Errorf("keys = %v, want %v", p.keys(), want)
+	}
+	if want := []string{"accounts", "org_id"}; !reflect.DeepEqual(p.ignored, want) {
+		t.Errorf("ignored = %v, want %v", p.ignored, want)
+	}
+}
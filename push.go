@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	pushTypeNtfy = "ntfy"
+	// defaultNtfyServer is the public ntfy server, for a push block
+	// without server.
+	defaultNtfyServer = "https://ntfy.sh"
+)
+
+// pushTimeout bounds a publish request.
+var pushTimeout = 10 * time.Second
+
+// PushConfig is the push block of config.json: a push notification
+// service events are published to. Only ntfy is supported.
+type PushConfig struct {
+	Type string `json:"type"`
+	// Server is the ntfy server's URL; default https://ntfy.sh.
+	Server string `json:"server,omitempty"`
+	Topic  string `json:"topic"`
+	// Token is an access token for a server that requires one.
+	Token string `json:"token,omitempty"`
+}
+
+// validatePush checks the push block of config.json; nil is fine.
+func validatePush(p *PushConfig) error {
+	if p == nil {
+		return nil
+	}
+	if p.Type != pushTypeNtfy {
+		return fmt.Errorf("push: type must be \"ntfy\", got %q", p.Type)
+	}
+	if strings.TrimSpace(p.Topic) == "" || strings.Contains(p.Topic, "/") {
+		return fmt.Errorf("push: topic must be a topic name, got %q", p.Topic)
+	}
+	if p.Server != "" {
+		u, err := url.Parse(p.Server)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("push: server must be an absolute http:// or https:// URL, got %q", redactURL(p.Server))
+		}
+	}
+	return nil
+}
+
+// ntfySink publishes events to an ntfy topic.
+type ntfySink struct {
+	topicURL string
+	token    string
+	cfg      *Config // for status_format
+}
+
+// push returns the sink cfg's push block sets up, and false without one.
+func (c *Config) push() (ntfySink, bool) {
+	if c == nil || c.Push == nil || validatePush(c.Push) != nil {
+		return ntfySink{}, false
+	}
+	server := strings.TrimSpace(c.Push.Server)
+	if server == "" {
+		server = defaultNtfyServer
+	}
+	return ntfySink{
+		topicURL: strings.TrimSuffix(server, "/") + "/" + url.PathEscape(strings.TrimSpace(c.Push.Topic)),
+		token:    strings.TrimSpace(c.Push.Token),
+		cfg:      c,
+	}, true
+}
+
+func (s ntfySink) name() string { return "ntfy " + redactURL(s.topicURL) }
+
+// wants takes threshold crossings and auth errors.
+func (ntfySink) wants(ev alertEvent) bool {
+	return ev.Event == eventThresholdCrossed || ev.Event == eventAuthError
+}
+
+// ntfyMessage returns the title, priority and tags ev is published with:
+// high priority for an auth error or a bucket in the critical band,
+// default otherwise.
+func ntfyMessage(ev alertEvent) (title, priority, tags string) {
+	switch ev.Event {
+	case eventThresholdCrossed:
+		title = fmt.Sprintf("%s usage passed %g%%", bucketTitle(ev.Bucket), ev.Threshold)
+		if ev.Utilization != nil && 100-*ev.Utilization < criticalRemaining {
+			return title, "high", "rotating_light"
+		}
+		return title, "default", "warning"
+	case eventAuthError:
+		return "claude.ai rejected the session key (" + ev.Error + ")", "high", "no_entry"
+	}
+	return ev.Event, "default", "information_source"
+}
+
+// deliver publishes ev with the "Copy status" summary as its message.
+func (s ntfySink) deliver(ev alertEvent) error {
+	title, priority, tags := ntfyMessage(ev)
+	body, err := lastStatus.text(s.cfg, timeNow())
+	if err != nil {
+		body = title
+	}
+	req, err := http.NewRequest(http.MethodPost, s.topicURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	req.Header.Set("Priority", priority)
+	req.Header.Set("Tags", tags)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := (&http.Client{Timeout: pushTimeout}).Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
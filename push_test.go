@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNtfyDeliver(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got, body = r, string(data)
+	}))
+	defer srv.Close()
+	t.Cleanup(func() { lastStatus.set(nil, nil, nil, time.Time{}) })
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.Local)
+	lastStatus.set(&UsageResponse{FiveHour: UsageBucket{Utilization: 96}, SevenDay: UsageBucket{Utilization: 40}}, nil, nil, now)
+	s, ok := (&Config{Push: &PushConfig{Type: "ntfy", Server: srv.URL + "/", Topic: "claude", Token: "tk_secret"}}).push()
+	if !ok {
+		t.Fatal("push not set up")
+	}
+	ev := bucketEvent(eventThresholdCrossed, "session", &UsageBucket{Utilization: 96}, now)
+	ev.Threshold = 95
+	if err := s.deliver(ev); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/claude" {
+		t.Errorf("published to %s", got.URL.Path)
+	}
+	for h, want := range map[string]string{
+		"Title":         "Session usage passed 95%",
+		"Priority":      "high",
+		"Tags":          "rotating_light",
+		"Authorization": "Bearer tk_secret",
+	} {
+		if v := got.Header.Get(h); v != want {
+			t.Errorf("%s: %q, want %q", h, v, want)
+		}
+	}
+	if !strings.HasPrefix(body, "Claude usage: session 96%") {
+		t.Errorf("message %q", body)
+	}
+}
+
+func TestNtfyMessage(t *testing.T) {
+	pct := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		ev             alertEvent
+		priority, tags string
+	}{
+		{alertEvent{Event: eventThresholdCrossed, Bucket: "weekly", Threshold: 50, Utilization: pct(52)}, "default", "warning"},
+		{alertEvent{Event: eventThresholdCrossed, Bucket: "weekly", Threshold: 90, Utilization: pct(91)}, "high", "rotating_light"},
+		{alertEvent{Event: eventAuthError, Error: "HTTP 401"}, "high", "no_entry"},
+	} {
+		if _, p, tags := ntfyMessage(tc.ev); p != tc.priority || tags != tc.tags {
+			t.Errorf("%+v: %s %s", tc.ev, p, tags)
+		}
+	}
+}
+
+func TestValidatePush(t *testing.T) {
+	for _, tc := range []struct {
+		p  *PushConfig
+		ok bool
+	}{
+		{nil, true},
+		{&PushConfig{Type: "ntfy", Topic: "claude"}, true},
+		{&PushConfig{Type: "ntfy", Server: "https://ntfy.example.com", Topic: "claude"}, true},
+		{&PushConfig{Type: "gotify", Topic: "claude"}, false},
+		{&PushConfig{Type: "ntfy"}, false},
+		{&PushConfig{Type: "ntfy", Topic: "claude", Server: "ntfy.example.com"}, false},
+	} {
+		if err := validatePush(tc.p); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.p, err)
+		}
+	}
+	if s, _ := (&Config{Push: &PushConfig{Type: "ntfy", Topic: "claude"}}).push(); s.topicURL != "https://ntfy.sh/claude" {
+		t.Errorf("default server: %s", s.topicURL)
+	}
+}
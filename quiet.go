@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuietHours is the quiet_hours block of config.json: a daily window, in
+// local time, in which notifications and the integrations stay silent.
+// From after To spans midnight.
+type QuietHours struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Digest holds what the window silences and sends it, deduplicated,
+	// when the window ends, instead of dropping it.
+	Digest bool `json:"digest,omitempty"`
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validateQuietHours checks the quiet_hours block of config.json; nil is
+// fine.
+func validateQuietHours(q *QuietHours) error {
+	if q == nil {
+		return nil
+	}
+	from, err := parseClock(q.From)
+	if err != nil {
+		return fmt.Errorf("quiet_hours: from: %v", err)
+	}
+	to, err := parseClock(q.To)
+	if err != nil {
+		return fmt.Errorf("quiet_hours: to: %v", err)
+	}
+	if from == to {
+		return fmt.Errorf("quiet_hours: from and to are both %s", q.From)
+	}
+	return nil
+}
+
+// quietUntil reports whether now falls in cfg's quiet hours, and if so
+// when they end.
+func (c *Config) quietUntil(now time.Time) (time.Time, bool) {
+	if c == nil || c.QuietHours == nil {
+		return time.Time{}, false
+	}
+	from, err1 := parseClock(c.QuietHours.From)
+	to, err2 := parseClock(c.QuietHours.To)
+	if err1 != nil || err2 != nil || from == to {
+		return time.Time{}, false
+	}
+	local := now.Local()
+	m := local.Hour()*60 + local.Minute()
+	if from < to && (m < from || m >= to) || from > to && m < from && m >= to {
+		return time.Time{}, false
+	}
+	end := time.Date(local.Year(), local.Month(), local.Day(), to/60, to%60, 0, 0, time.Local)
+	if !end.After(now) {
+		end = time.Date(local.Year(), local.Month(), local.Day()+1, to/60, to%60, 0, 0, time.Local)
+	}
+	return end, true
+}
+
+// quietDigest holds what quiet hours silenced with digest on, until they
+// end.
+type quietDigest struct {
+	mu    sync.Mutex
+	cfg   *Config
+	notes []notification
+	timer *time.Timer
+}
+
+var quietHeld quietDigest
+
+// hold queues notes for the digest sent at end, in place of what it
+// already holds for the same thing: the same threshold of a bucket
+// crossed again, another reset of the same limit, or a notification
+// with the same title.
+func (q *quietDigest) hold(cfg *Config, notes []notification, end time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+	for _, n := range notes {
+		replaced := false
+		for i, held := range q.notes {
+			if digestKey(held) == digestKey(n) {
+				q.notes[i], replaced = n, true
+				break
+			}
+		}
+		if !replaced {
+			q.notes = append(q.notes, n)
+		}
+	}
+	if q.timer == nil {
+		q.timer = time.AfterFunc(end.Sub(timeNow()), q.flush)
+	}
+}
+
+func digestKey(n notification) string {
+	if n.event != nil {
+		return fmt.Sprintf("%s/%s/%g", n.event.Event, n.event.Bucket, n.event.Threshold)
+	}
+	return n.title
+}
+
+// take empties the digest and returns what it held.
+func (q *quietDigest) take() (*Config, []notification) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	cfg, notes := q.cfg, q.notes
+	q.cfg, q.notes = nil, nil
+	return cfg, notes
+}
+
+// flush sends the digest: the held events to the integrations, and the
+// held notifications as one.
+func (q *quietDigest) flush() {
+	cfg, notes := q.take()
+	if len(notes) == 0 {
+		return
+	}
+	uiLog.Infof("Quiet hours over, sending %d held", len(notes))
+	sendNotifications(cfg, digestNotes(notes))
+}
+
+// digestNotes folds the titled notes into a single notification listing
+// them; their events go on as notes of their own.
+func digestNotes(notes []notification) []notification {
+	var out, shown []notification
+	for _, n := range notes {
+		if n.title == "" {
+			out = append(out, n)
+			continue
+		}
+		shown = append(shown, notification{title: n.title, body: n.body})
+		if n.event != nil {
+			out = append(out, notification{event: n.event})
+		}
+	}
+	if len(shown) <= 1 {
+		return append(out, shown...)
+	}
+	titles := make([]string, len(shown))
+	for i, n := range shown {
+		titles[i] = n.title
+	}
+	return append(out, notification{
+		title: fmt.Sprintf("During quiet hours: %d alerts", len(shown)),
+		body:  strings.Join(titles, "\n"),
+	})
+}
+
+// silence keeps notes quiet until end: held for the digest, or dropped.
+func silence(cfg *Config, notes []notification, end time.Time) {
+	what := "dropped"
+	if cfg.QuietHours.Digest {
+		what = "held until " + end.Format("15:04")
+		quietHeld.hold(cfg, notes, end)
+	}
+	for _, n := range notes {
+		name := n.title
+		if name == "" {
+			name = n.event.Event
+		}
+		uiLog.Infof("Quiet hours: %s %s", name, what)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuietUntil(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 10, 17, h, m, 0, 0, time.Local) }
+	overnight := &Config{QuietHours: &QuietHours{From: "22:00", To: "08:00"}}
+	daytime := &Config{QuietHours: &QuietHours{From: "12:30", To: "13:30"}}
+	for _, tc := range []struct {
+		cfg   *Config
+		now   time.Time
+		quiet bool
+		end   time.Time
+	}{
+		{overnight, at(21, 59), false, time.Time{}},
+		{overnight, at(22, 0), true, at(8, 0).AddDate(0, 0, 1)},
+		{overnight, at(2, 0), true, at(8, 0)},
+		{overnight, at(8, 0), false, time.Time{}},
+		{daytime, at(12, 45), true, at(13, 30)},
+		{daytime, at(2, 0), false, time.Time{}},
+		{&Config{}, at(2, 0), false, time.Time{}},
+	} {
+		end, quiet := tc.cfg.quietUntil(tc.now)
+		if quiet != tc.quiet || !end.Equal(tc.end) {
+			t.Errorf("%+v at %s: %v until %s", tc.cfg.QuietHours, tc.now.Format("15:04"), quiet, end)
+		}
+	}
+}
+
+func TestQuietHoursDigest(t *testing.T) {
+	var mu sync.Mutex
+	var got []alertEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev alertEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		got = append(got, ev)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+	saved := timeNow
+	t.Cleanup(func() {
+		timeNow = saved
+		quietHeld.take()
+	})
+	now := time.Date(2026, 10, 17, 2, 0, 0, 0, time.Local)
+	timeNow = func() time.Time { return now }
+
+	cfg := &Config{
+		QuietHours: &QuietHours{From: "22:00", To: "08:00", Digest: true},
+		Webhooks:   []Webhook{{URL: srv.URL}},
+	}
+	crossing := func(pct, threshold float64) notification {
+		ev := bucketEvent(eventThresholdCrossed, "session", &UsageBucket{Utilization: pct}, now)
+		ev.Threshold = threshold
+		return notification{event: &ev}
+	}
+	// The same threshold crossed twice in the night is sent once, with the
+	// latest reading
+	postNotifications(cfg, []notification{crossing(81, 80)})
+	postNotifications(cfg, []notification{crossing(83, 80), crossing(91, 90)})
+	_, held := quietHeld.take()
+	if len(held) != 2 || *held[0].event.Utilization != 83 || held[1].event.Threshold != 90 {
+		t.Fatalf("held %+v", held)
+	}
+
+	postNotifications(cfg, held)
+	quietHeld.flush()
+	delivered := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got)
+	}
+	for deadline := time.Now().Add(5 * time.Second); delivered() < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0].Threshold != 80 || got[1].Threshold != 90 {
+		t.Errorf("delivered %+v", got)
+	}
+}
+
+func TestDigestNotes(t *testing.T) {
+	ev := alertEvent{Event: eventThresholdCrossed, Bucket: "weekly", Threshold: 90}
+	out := digestNotes([]notification{
+		{title: "Weekly usage at 91%", body: "…", event: &ev},
+		{title: "Spend alert", body: "$20 spent"},
+		{event: &alertEvent{Event: eventLimitReset, Bucket: "session"}},
+	})
+	if len(out) != 3 || out[0].title != "" || out[0].event != &ev || out[1].event.Event != eventLimitReset {
+		t.Fatalf("digest %+v", out)
+	}
+	if d := out[2]; d.title != "During quiet hours: 2 alerts" || d.body != "Weekly usage at 91%\nSpend alert" || d.event != nil {
+		t.Errorf("summary %+v", d)
+	}
+	if out := digestNotes([]notification{{title: "Spend alert", body: "$20 spent"}}); len(out) != 1 || out[0].body != "$20 spent" {
+		t.Errorf("single note %+v", out)
+	}
+}
+
+func TestValidateQuietHours(t *testing.T) {
+	for _, tc := range []struct {
+		q  *QuietHours
+		ok bool
+	}{
+		{nil, true},
+		{&QuietHours{From: "22:00", To: "08:00"}, true},
+		{&QuietHours{From: "9:00", To: "17:30", Digest: true}, true},
+		{&QuietHours{From: "22:00"}, false},
+		{&QuietHours{From: "25:00", To: "08:00"}, false},
+		{&QuietHours{From: "08:00", To: "08:00"}, false},
+	} {
+		if err := validateQuietHours(tc.q); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.q, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultReleaseCheckInterval is how often check_updates looks for a
+	// new release unless check_updates_interval is set.
+	defaultReleaseCheckInterval = 24 * time.Hour
+	minReleaseCheckInterval     = time.Hour
+	// releasesPage lists the releases; each has its page under /tag/.
+	releasesPage = "https://github.com/Nocturnal-ru/claude-monitor/releases"
+	// releaseWatchTick is how often the watcher wakes to see whether a
+	// check is due or the setting changed.
+	releaseWatchTick = time.Hour
+)
+
+var (
+	// latestReleaseAPI is the GitHub endpoint for the newest release,
+	// swapped out in tests.
+	latestReleaseAPI = "https://api.github.com/repos/Nocturnal-ru/claude-monitor/releases/latest"
+	// releaseCheckTimeout bounds a check.
+	releaseCheckTimeout = 15 * time.Second
+	// releaseWatchDelay keeps the first check out of the way of the
+	// first usage update.
+	releaseWatchDelay = 30 * time.Second
+)
+
+// releaseCheck is the result of the last successful check for a new
+// release, kept in state.json so a restart doesn't check again.
+type releaseCheck struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest,omitempty"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// parseReleaseCheckInterval parses check_updates_interval; empty is the
+// default of a day.
+func parseReleaseCheckInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultReleaseCheckInterval, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("check_updates_interval must be a duration such as \"24h\", got %q", s)
+	}
+	if d < minReleaseCheckInterval {
+		return 0, fmt.Errorf("check_updates_interval must be at least %s, got %q", shortDuration(minReleaseCheckInterval), s)
+	}
+	return d, nil
+}
+
+// releaseCheckInterval returns check_updates_interval or the default.
+func (c *Config) releaseCheckInterval() time.Duration {
+	if c == nil {
+		return defaultReleaseCheckInterval
+	}
+	d, err := parseReleaseCheckInterval(c.CheckUpdatesInterval)
+	if err != nil {
+		return defaultReleaseCheckInterval
+	}
+	return d
+}
+
+// fetchLatestRelease asks GitHub for the newest release through cfg's
+// proxy and CA settings.
+func fetchLatestRelease(ctx context.Context, cfg *Config) (*releaseCheck, error) {
+	client, err := httpClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", latestReleaseAPI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", appDirName+"/"+currentBuild().Version)
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return nil, uerr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release); err != nil {
+		return nil, fmt.Errorf("parsing the release: %w", err)
+	}
+	if _, ok := parseSemver(release.TagName); !ok {
+		return nil, fmt.Errorf("release tag %q is not a version", release.TagName)
+	}
+	if release.HTMLURL == "" {
+		release.HTMLURL = releasesPage + "/tag/" + release.TagName
+	}
+	return &releaseCheck{Latest: release.TagName, URL: release.HTMLURL}, nil
+}
+
+// pollRelease checks for a new release if the last check is older than
+// cfg's interval, and returns the newest release known, or nil. A failed
+// check is logged and tried again at the next tick.
+func pollRelease(cfg *Config, now time.Time) *releaseCheck {
+	var last *releaseCheck
+	if st, err := readStateFile(statePath()); err == nil {
+		last = st.ReleaseCheck
+	}
+	if last != nil && now.Sub(last.CheckedAt) < cfg.releaseCheckInterval() {
+		return last
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), releaseCheckTimeout)
+	defer cancel()
+	found, err := fetchLatestRelease(ctx, cfg)
+	if err != nil {
+		uiLog.Infof("Checking for a new release: %v", err)
+		return last
+	}
+	found.CheckedAt = now
+	if err := updateState(statePath(), func(st *appState) { st.ReleaseCheck = found }); err != nil {
+		uiLog.Warnf("Failed to save release check: %v", err)
+	}
+	return found
+}
+
+// watchReleases checks for a new release while check_updates is on and
+// calls show with a release newer than this build, or nil when there is
+// none or checking was turned off.
+func watchReleases(show func(*releaseCheck)) {
+	time.Sleep(releaseWatchDelay)
+	announced := ""
+	for {
+		var newer *releaseCheck
+		if cfg, err := readConfigFile(configPath); err == nil && cfg.CheckUpdates {
+			if r := pollRelease(cfg, time.Now()); r != nil && newerRelease(currentBuild().Version, r.Latest) {
+				newer = r
+			}
+		}
+		if newer != nil && newer.Latest != announced {
+			uiLog.Infof("Update available: %s (%s)", newer.Latest, newer.URL)
+			announced = newer.Latest
+		}
+		show(newer)
+		time.Sleep(releaseWatchTick)
+	}
+}
+
+// semver is a parsed semantic version; build metadata is dropped.
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseSemver parses "v1.4.0", "1.4", "v2.0.0-rc.1+abc" and the like.
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	s, _, _ = strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return semver{}, false
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	v := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		if pre == "" {
+			return semver{}, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 as v is older than, the same as or newer
+// than w, by semver precedence: a pre-release comes before its release,
+// numeric identifiers compare as numbers and before alphanumeric ones.
+func (v semver) compare(w semver) int {
+	for _, d := range []int{v.major - w.major, v.minor - w.minor, v.patch - w.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, b := v.pre[i], w.pre[i]
+		an, aerr := strconv.Atoi(a)
+		bn, berr := strconv.Atoi(b)
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				return sign(an - bn)
+			}
+		case aerr == nil:
+			return -1
+		case berr == nil:
+			return 1
+		case a != b:
+			return sign(strings.Compare(a, b))
+		}
+	}
+	return sign(len(v.pre) - len(w.pre))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// newerRelease reports whether tag is a newer version than current. A
+// build without a version, such as "dev", has nothing to compare.
+func newerRelease(current, tag string) bool {
+	c, ok1 := parseSemver(current)
+	t, ok2 := parseSemver(tag)
+	return ok1 && ok2 && t.compare(c) > 0
+}
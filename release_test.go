@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewerRelease(t *testing.T) {
+	for _, tc := range []struct {
+		current, tag string
+		want         bool
+	}{
+		{"v1.3.2", "v1.4.0", true},
+		{"v1.4.0", "v1.4.0", false},
+		{"v1.10.0", "v1.9.9", false},
+		{"1.4.0", "v1.4.1", true},
+		{"v1.4.0-rc.1", "v1.4.0", true},
+		{"v1.4.0", "v1.5.0-beta", true},
+		{"v1.4.0-rc.2", "v1.4.0-rc.10", true},
+		{"v1.4.0-rc.1", "v1.4.0-beta.9", false},
+		{"v1.4.0-alpha", "v1.4.0-alpha.1", true},
+		{"v1.4.0+build.5", "v1.4.0", false},
+		{"dev", "v1.4.0", false},
+		{"v1.4.0", "nightly", false},
+	} {
+		if got := newerRelease(tc.current, tc.tag); got != tc.want {
+			t.Errorf("newerRelease(%q, %q) = %v", tc.current, tc.tag, got)
+		}
+	}
+}
+
+func TestPollRelease(t *testing.T) {
+	requests := 0
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("no User-Agent, which GitHub requires")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"tag_name": "v1.4.0", "html_url": "https://github.com/Nocturnal-ru/claude-monitor/releases/tag/v1.4.0"}`))
+	}))
+	defer srv.Close()
+	savedAPI, savedPaths := latestReleaseAPI, paths
+	t.Cleanup(func() { latestReleaseAPI, paths = savedAPI, savedPaths })
+	latestReleaseAPI = srv.URL
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	cfg := &Config{CheckUpdates: true}
+	now := time.Now()
+	status = http.StatusInternalServerError
+	if r := pollRelease(cfg, now); r != nil {
+		t.Errorf("failed check returned %+v", r)
+	}
+	status = http.StatusOK
+	r := pollRelease(cfg, now)
+	if r == nil || r.Latest != "v1.4.0" || r.URL == "" {
+		t.Fatalf("found %+v", r)
+	}
+	// Within the interval the saved result is used, after it GitHub is asked again
+	pollRelease(cfg, now.Add(23*time.Hour))
+	if requests != 2 {
+		t.Errorf("%d requests within a day, want 2", requests)
+	}
+	pollRelease(cfg, now.Add(25*time.Hour))
+	if requests != 3 {
+		t.Errorf("%d requests after a day, want 3", requests)
+	}
+}
+
+func TestParseReleaseCheckInterval(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 24 * time.Hour, true},
+		{"168h", 168 * time.Hour, true},
+		{"10m", 0, false},
+		{"daily", 0, false},
+	} {
+		got, err := parseReleaseCheckInterval(tc.in)
+		if (err == nil) != tc.ok || (tc.ok && got != tc.want) {
+			t.Errorf("%q: %v, %v", tc.in, got, err)
+		}
+	}
+}
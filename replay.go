@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historySample is one line of a usage history file (JSON Lines): the
+// response of one update, the error it failed with, or a per-model bucket
+// appearing or disappearing.
+type historySample struct {
+	Time  time.Time      `json:"time"`
+	Usage *UsageResponse `json:"usage,omitempty"`
+	Error string         `json:"error,omitempty"`
+	// Event is historyBucketAdded or historyBucketRemoved, for the bucket
+	// keyed Bucket, e.g. "seven_day_sonnet". Its series ends or starts
+	// over there.
+	Event  string `json:"event,omitempty"`
+	Bucket string `json:"bucket,omitempty"`
+}
+
+const (
+	historyBucketAdded   = "bucket_added"
+	historyBucketRemoved = "bucket_removed"
+)
+
+// readHistory parses a JSON Lines history file, sorted by time. Malformed
+// lines are reported with their line number.
+func readHistory(path string) ([]historySample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []historySample
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var s historySample
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filepath.Base(path), n, err)
+		}
+		if s.Usage == nil && s.Error == "" && s.Event == "" {
+			return nil, fmt.Errorf("%s:%d: sample has neither usage nor error", filepath.Base(path), n)
+		}
+		samples = append(samples, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	return samples, nil
+}
+
+// parseSpeed parses "60x" / "60" as a time compression factor; "max" or 0
+// means no pacing at all.
+func parseSpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" || s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(s, "x")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid speed %q (want e.g. 60x or max)", s)
+	}
+	return v, nil
+}
+
+// cmdReplay feeds a recorded history through the same pipeline that drives
+// the tray: each sample is rendered by presentUpdate and published to a
+// uiUpdater, whose apply step writes what the app would have shown: one icon
+// PNG per sample plus timeline.txt with the tooltip, the menu rows and the
+// notifications it would have posted. Nothing is fetched, posted or
+// written to config, and on_update_command is not run; state goes to the
+// output directory.
+func cmdReplay(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(out)
+	historyPath := fs.String("history", "", "history `file` to replay (JSON Lines)")
+	outDir := fs.String("out", "replay-out", "`directory` for icons and timeline.txt")
+	speedFlag := fs.String("speed", "max", "playback speed, e.g. 60x (one recorded minute per second), or max")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *historyPath == "" {
+		fmt.Fprintln(out, "Error: --history is required")
+		return 2
+	}
+	speed, err := parseSpeed(*speedFlag)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 2
+	}
+
+	samples, err := readHistory(*historyPath)
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	if len(samples) == 0 {
+		fmt.Fprintln(out, "Error: history is empty")
+		return 1
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+
+	// Keep the renderers away from the real state.json
+	paths.stateDir = *outDir
+
+	timeline, err := os.Create(filepath.Join(*outDir, "timeline.txt"))
+	if err != nil {
+		fmt.Fprintln(out, "Error:", err)
+		return 1
+	}
+	defer timeline.Close()
+	rec := &replayRecorder{dir: *outDir, w: io.MultiWriter(timeline, out), applied: make(chan error)}
+
+	// The replay has a display of its own, so the snapshots take the same
+	// path as in the tray without touching it
+	display := newUIUpdater()
+	go display.run(rec.apply)
+	defer display.stop()
+
+	for i, s := range samples {
+		if speed > 0 && i > 0 {
+			time.Sleep(time.Duration(float64(s.Time.Sub(samples[i-1].Time)) / speed))
+		}
+		if s.Event != "" {
+			// The presence debounce replays the change from the readings
+			continue
+		}
+		sampleTime := s.Time
+		timeNow = func() time.Time { return sampleTime }
+
+		var fetchErr error
+		if s.Error != "" {
+			fetchErr = errors.New(s.Error)
+		}
+		st := display.state()
+		rec.sample, rec.index = s, i+1
+		rec.notes = presentUpdate(&st, nil, s.Usage, fetchErr, 0, sampleTime)
+		display.publish(display.nextGeneration(), st)
+		if err := <-rec.applied; err != nil {
+			fmt.Fprintln(out, "Error:", err)
+			return 1
+		}
+	}
+	fmt.Fprintf(out, "Replayed %d samples into %s\n", len(samples), *outDir)
+	return 0
+}
+
+// replayRecorder is the display of a replay: instead of changing the tray
+// it writes each snapshot to the output directory. sample, index and notes
+// describe the snapshot being published; cmdReplay sets them and waits on
+// applied before the next one.
+type replayRecorder struct {
+	dir string
+	w   io.Writer
+
+	sample  historySample
+	index   int
+	notes   []notification
+	applied chan error
+}
+
+// apply writes st's icon as a PNG and its rows to the timeline, followed by
+// a dry-run line for each notification the update would have posted.
+func (r *replayRecorder) apply(_, st uiState) {
+	at := r.sample.Time.UTC()
+	header := at.Format(time.RFC3339)
+	if st.icon != nil {
+		name := fmt.Sprintf("%04d-%s.png", r.index, at.Format("20060102T150405Z"))
+		if err := os.WriteFile(filepath.Join(r.dir, name), iconPNG(st.icon), 0644); err != nil {
+			r.applied <- err
+			return
+		}
+		header += "  " + name
+	}
+	fmt.Fprintln(r.w, header)
+	for _, row := range []string{st.tooltip, st.session, st.weekly, st.opus, st.sonnet, st.extra} {
+		if row != "" {
+			fmt.Fprintf(r.w, "    %s\n", row)
+		}
+	}
+	for _, n := range r.notes {
+		fmt.Fprintf(r.w, "    [dry run] notification: %s: %s\n", n.title, n.body)
+	}
+	r.applied <- nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplayHistory(t *testing.T) {
+	t.Setenv("LC_ALL", "C")
+	savedDir, savedNow := paths.stateDir, timeNow
+	defer func() { paths.stateDir, timeNow = savedDir, savedNow }()
+
+	out := t.TempDir()
+	var stdout bytes.Buffer
+	if code := cmdReplay([]string{"--history", filepath.Join("testdata", "replay", "history.jsonl"), "--out", out}, &stdout); code != 0 {
+		t.Fatalf("replay exited %d: %s", code, stdout.String())
+	}
+
+	pngMagic := []byte("\x89PNG\r\n\x1a\n")
+	for _, name := range []string{"0001-20261005T100000Z.png", "0002-20261005T100500Z.png", "0003-20261005T101000Z.png"} {
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.HasPrefix(data, pngMagic) {
+			t.Errorf("%s is not a PNG", name)
+		}
+	}
+	first, _ := os.ReadFile(filepath.Join(out, "0001-20261005T100000Z.png"))
+	if failed, _ := os.ReadFile(filepath.Join(out, "0002-20261005T100500Z.png")); bytes.Equal(first, failed) {
+		t.Error("the failed update has the same icon as the good one")
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "timeline.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeline := string(data)
+	for _, want := range []string{
+		"2026-10-05T10:00:00Z  0001-20261005T100000Z.png\n    S:42% W:10%\n    Session (5h): 42% — resets in 2h 13m\n",
+		"    [dry run] notification: Spend alert: You've spent $45.00 of your $50.00 monthly cap (90%)\n",
+		"2026-10-05T10:05:00Z  0002-20261005T100500Z.png\n",
+		"    " + mark(markError, false) + " API error (see log)\n",
+		"2026-10-05T10:10:00Z  0003-20261005T101000Z.png\n    S:97% W:12%\n",
+	} {
+		if !strings.Contains(timeline, want) {
+			t.Errorf("timeline lacks %q:\n%s", want, timeline)
+		}
+	}
+	// The 80% alert fired at the first sample; once a month is enough
+	if n := strings.Count(timeline, "[dry run]"); n != 1 {
+		t.Errorf("%d dry-run notifications, want 1", n)
+	}
+	if !strings.HasPrefix(stdout.String(), timeline) || !strings.Contains(stdout.String(), "Replayed 3 samples") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if _, err := os.Stat(filepath.Join(out, "state.json")); err != nil {
+		t.Errorf("spend alert state not kept in the output directory: %v", err)
+	}
+}
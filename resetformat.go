@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Values of the reset_format config setting.
+const (
+	resetFormatRelative = "relative" // "in 6h 40m" (default)
+	resetFormatAbsolute = "absolute" // "Thu 09:00", or "at 18:35" today
+	resetFormatBoth     = "both"     // "in 6h 40m (Thu 09:00)"
+)
+
+// resetFormat returns reset_format, normalized; a nil config yields the
+// default.
+func (c *Config) resetFormat() string {
+	if c == nil {
+		return resetFormatRelative
+	}
+	switch f := strings.ToLower(strings.TrimSpace(c.ResetFormat)); f {
+	case resetFormatAbsolute, resetFormatBoth:
+		return f
+	}
+	return resetFormatRelative
+}
+
+// validResetFormat reports whether s is a value reset_format accepts.
+func validResetFormat(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", resetFormatRelative, resetFormatAbsolute, resetFormatBoth:
+		return true
+	}
+	return false
+}
+
+// formatResetAt renders at as a wall-clock time in now's time zone: "at
+// 18:35" when it falls on the same day as now, with the weekday otherwise,
+// e.g. "Thu 09:00". Accessible mode reads "on Thursday at 09:00".
+func formatResetAt(at, now time.Time, accessible bool) string {
+	local := at.In(now.Location())
+	clock := local.Format("15:04")
+	if y, m, d := local.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return "at " + clock
+	}
+	if accessible {
+		return "on " + local.Format("Monday") + " at " + clock
+	}
+	return local.Format("Mon") + " " + clock
+}
+
+// resetText renders a future reset time for a menu row in the given
+// reset_format: "in 6h 40m", "Thu 09:00" or both.
+func resetText(at, now time.Time, format string, accessible bool) string {
+	relative := formatReset
+	if accessible {
+		relative = spellReset
+	}
+	switch format {
+	case resetFormatAbsolute:
+		return formatResetAt(at, now, accessible)
+	case resetFormatBoth:
+		if accessible {
+			return relative(at) + ", " + formatResetAt(at, now, true)
+		}
+		return relative(at) + " (" + formatResetAt(at, now, false) + ")"
+	}
+	return relative(at)
+}
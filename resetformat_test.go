@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // the DST cases need zones the test machine may lack
+)
+
+func TestFormatResetAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name       string
+		now, at    time.Time
+		accessible bool
+		want       string
+	}{
+		{"later today", time.Date(2026, 5, 4, 12, 0, 0, 0, berlin), time.Date(2026, 5, 4, 18, 35, 0, 0, berlin), false, "at 18:35"},
+		{"tomorrow", time.Date(2026, 5, 4, 22, 0, 0, 0, berlin), time.Date(2026, 5, 5, 3, 0, 0, 0, berlin), false, "Tue 03:00"},
+		{"next week", time.Date(2026, 5, 4, 12, 0, 0, 0, berlin), time.Date(2026, 5, 7, 9, 0, 0, 0, berlin), false, "Thu 09:00"},
+		{"accessible", time.Date(2026, 5, 4, 12, 0, 0, 0, berlin), time.Date(2026, 5, 7, 9, 0, 0, 0, berlin), true, "on Thursday at 09:00"},
+		{"accessible today", time.Date(2026, 5, 4, 12, 0, 0, 0, berlin), time.Date(2026, 5, 4, 18, 35, 0, 0, berlin), true, "at 18:35"},
+		// The API speaks UTC; the row shows the local wall clock
+		{"from UTC", time.Date(2026, 5, 4, 12, 0, 0, 0, berlin), time.Date(2026, 5, 7, 7, 0, 0, 0, time.UTC), false, "Thu 09:00"},
+		// Spring forward: 02:00 CET becomes 03:00 CEST on 29 March, a
+		// 23-hour day; 22 hours after 00:30 is 23:30 the same day
+		{"spring forward, same day", time.Date(2026, 3, 29, 0, 30, 0, 0, berlin), time.Date(2026, 3, 29, 0, 30, 0, 0, berlin).Add(22 * time.Hour), false, "at 23:30"},
+		// 24 hours after Saturday 20:00 CET is Sunday 21:00 CEST
+		{"spring forward, next day", time.Date(2026, 3, 28, 20, 0, 0, 0, berlin), time.Date(2026, 3, 28, 20, 0, 0, 0, berlin).Add(24 * time.Hour), false, "Sun 21:00"},
+		// Fall back: 03:00 CEST becomes 02:00 CET on 25 October, a 25-hour
+		// day; 24 hours after 00:30 is still the same day
+		{"fall back, same day", time.Date(2026, 10, 25, 0, 30, 0, 0, berlin), time.Date(2026, 10, 25, 0, 30, 0, 0, berlin).Add(24 * time.Hour), false, "at 23:30"},
+		{"fall back, weekly", time.Date(2026, 10, 29, 9, 0, 0, 0, newYork), time.Date(2026, 10, 29, 9, 0, 0, 0, newYork).Add(7 * 24 * time.Hour), false, "Thu 08:00"},
+	} {
+		if got := formatResetAt(tc.at, tc.now, tc.accessible); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestResetText(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.Local)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+
+	at := now.Add(6*time.Hour + 40*time.Minute)
+	for _, tc := range []struct {
+		format     string
+		accessible bool
+		want       string
+	}{
+		{"", false, "in 6h 40m"},
+		{resetFormatRelative, false, "in 6h 40m"},
+		{resetFormatAbsolute, false, "at 18:40"},
+		{resetFormatBoth, false, "in 6h 40m (at 18:40)"},
+		{resetFormatBoth, true, "in 6 hours 40 minutes, at 18:40"},
+	} {
+		if got := resetText(at, now, tc.format, tc.accessible); got != tc.want {
+			t.Errorf("%q (accessible %v): got %q, want %q", tc.format, tc.accessible, got, tc.want)
+		}
+	}
+}
+
+func TestResetFormatSetting(t *testing.T) {
+	for in, want := range map[string]string{"": resetFormatRelative, "Both": resetFormatBoth, " absolute ": resetFormatAbsolute} {
+		if got := (&Config{ResetFormat: in}).resetFormat(); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+	if validResetFormat("weekday") {
+		t.Error("accepted an unknown format")
+	}
+}
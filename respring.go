@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	failedResponseRingSize = 5
+	maxStoredBodyBytes     = 64 << 10
+	errorSnippetBytes      = 500
+)
+
+// failedResponse is one non-200 API response kept for diagnostics.
+type failedResponse struct {
+	Time   time.Time
+	URL    string
+	Status int
+	Body   string // sanitized, capped at maxStoredBodyBytes
+}
+
+// responseRing keeps the complete bodies of the last few failed responses in
+// memory, so they can be inspected without logging every body in full.
+type responseRing struct {
+	mu    sync.Mutex
+	items [failedResponseRingSize]failedResponse
+	count int
+	next  int
+}
+
+var failedResponses responseRing
+
+func (r *responseRing) add(fr failedResponse) {
+	fr.Body = scrubSecrets(fr.Body)
+	if len(fr.Body) > maxStoredBodyBytes {
+		fr.Body = cutBytes(fr.Body, maxStoredBodyBytes) + "\n[truncated]"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[r.next] = fr
+	r.next = (r.next + 1) % len(r.items)
+	if r.count < len(r.items) {
+		r.count++
+	}
+}
+
+// latest returns the most recently recorded failure.
+func (r *responseRing) latest() (failedResponse, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count == 0 {
+		return failedResponse{}, false
+	}
+	return r.items[(r.next-1+len(r.items))%len(r.items)], true
+}
+
+// all returns the recorded failures, oldest first.
+func (r *responseRing) all() []failedResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]failedResponse, 0, r.count)
+	for i := r.count; i > 0; i-- {
+		out = append(out, r.items[(r.next-i+len(r.items))%len(r.items)])
+	}
+	return out
+}
+
+var (
+	scriptStylePattern = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	tagPattern         = regexp.MustCompile(`(?s)<[^>]*>`)
+	spacePattern       = regexp.MustCompile(`\s+`)
+)
+
+// stripHTML reduces an HTML page (e.g. a Cloudflare challenge) to its visible
+// text so that error snippets stay readable in the log.
+func stripHTML(s string) string {
+	s = scriptStylePattern.ReplaceAllString(s, " ")
+	s = tagPattern.ReplaceAllString(s, " ")
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
+
+// errorSnippet returns the sanitized, tag-free start of a response body
+// for inclusion in error messages.
+func errorSnippet(body string) string {
+	s := stripHTML(scrubSecrets(body))
+	if len(s) > errorSnippetBytes {
+		s = cutBytes(s, errorSnippetBytes) + "..."
+	}
+	return s
+}
+
+// cutBytes returns the longest prefix of s, which is longer than n bytes,
+// that fits in n bytes without splitting a character.
+func cutBytes(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// saveLastFailedResponse writes the most recent failed response to path.
+func saveLastFailedResponse(path string) error {
+	fr, ok := failedResponses.latest()
+	if !ok {
+		return fmt.Errorf("no failed API response recorded since startup")
+	}
+	return os.WriteFile(path, []byte(fr.String()), 0644)
+}
+
+// String renders fr as saved: time, URL and status, then the body.
+func (fr failedResponse) String() string {
+	return fmt.Sprintf("Time:   %s\nURL:    %s\nStatus: %d\n\n%s\n",
+		fr.Time.Format(time.RFC3339), scrubSecrets(fr.URL), fr.Status, fr.Body)
+}
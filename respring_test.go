@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestStripHTML(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{`{"type":"error","error":{"type":"permission_error"}}`, `{"type":"error","error":{"type":"permission_error"}}`},
+		{"<html><head><title>Just a moment...</title><style>body{color:red}</style></head>" +
+			"<body><h1>Checking your browser</h1><script>var x = '<b>';</script>\n<p>Ray ID: 8f1</p></body></html>",
+			"Just a moment... Checking your browser Ray ID: 8f1"},
+		{"<SCRIPT type=\"text/javascript\">\nwindow._cf = 1;\n</SCRIPT>Blocked", "Blocked"},
+		{"a  \n\t b", "a b"},
+		{"", ""},
+	} {
+		if got := stripHTML(tc.in); got != tc.want {
+			t.Errorf("stripHTML(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestErrorSnippet(t *testing.T) {
+	long := "<p>" + strings.Repeat("é", errorSnippetBytes) + "</p>"
+	s := errorSnippet(long)
+	if !strings.HasSuffix(s, "...") || len(s) > errorSnippetBytes+3 {
+		t.Errorf("snippet of %d bytes, want at most %d plus ...", len(s), errorSnippetBytes)
+	}
+	if !strings.HasPrefix(s, "é") || strings.ContainsRune(s, '�') {
+		t.Errorf("snippet cut inside a character: %q", s[len(s)-8:])
+	}
+	if s := errorSnippet(`{"error": "bad sessionKey=sk-ant-REDACTED"}`); strings.Contains(s, "abcdefghijklmnop") {
+		t.Errorf("snippet leaks the session key: %q", s)
+	}
+}
+
+func TestResponseRing(t *testing.T) {
+	var r responseRing
+	if _, ok := r.latest(); ok || len(r.all()) != 0 {
+		t.Fatal("empty ring has responses")
+	}
+	for i := 1; i <= failedResponseRingSize+2; i++ {
+		r.add(failedResponse{Time: time.Unix(int64(i), 0), Status: 500 + i, Body: fmt.Sprintf("body %d", i)})
+	}
+	all := r.all()
+	if len(all) != failedResponseRingSize {
+		t.Fatalf("ring holds %d, want %d", len(all), failedResponseRingSize)
+	}
+	for i, fr := range all {
+		if want := 503 + i; fr.Status != want {
+			t.Errorf("all()[%d].Status = %d, want %d (oldest first, two dropped)", i, fr.Status, want)
+		}
+	}
+	if fr, _ := r.latest(); fr.Status != 500+failedResponseRingSize+2 {
+		t.Errorf("latest = %d", fr.Status)
+	}
+
+	r.add(failedResponse{Body: "sessionKey=sk-ant-sid01-" + strings.Repeat("x", 40) + " " + strings.Repeat("y", maxStoredBodyBytes)})
+	fr, _ := r.latest()
+	if strings.Contains(fr.Body, "xxxxxxxx") {
+		t.Error("stored body keeps the session key")
+	}
+	if len(fr.Body) > maxStoredBodyBytes+len("\n[truncated]") || !strings.HasSuffix(fr.Body, "[truncated]") {
+		t.Errorf("stored body of %d bytes not capped", len(fr.Body))
+	}
+
+	// The cap falls inside a two-byte character
+	r.add(failedResponse{Body: "x" + strings.Repeat("é", maxStoredBodyBytes)})
+	fr, _ = r.latest()
+	if body := strings.TrimSuffix(fr.Body, "\n[truncated]"); !utf8.ValidString(body) || len(body) != maxStoredBodyBytes-1 {
+		t.Errorf("stored body of %d bytes cut inside a character", len(body))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// clockCheckInterval is how often the wall clock is compared with the
+	// time that should have passed.
+	clockCheckInterval = 30 * time.Second
+	// clockJumpThreshold is how far the wall clock may run ahead of a check
+	// interval before it's treated as a suspend/resume.
+	clockJumpThreshold = 2 * time.Minute
+	// resumeDebounce keeps one resume, noticed both by a power event and
+	// by the clock jump, from refreshing twice.
+	resumeDebounce = time.Minute
+)
+
+// watchResume calls onResume once the system is back from sleep: right
+// away where the OS announces it (power events on Windows), otherwise at
+// the next clock check.
+func watchResume(onResume func()) {
+	var mu sync.Mutex
+	var last time.Time
+	resumed := func(how string) {
+		mu.Lock()
+		now := time.Now()
+		dup := !last.IsZero() && now.Sub(last) < resumeDebounce
+		if !dup {
+			last = now
+		}
+		mu.Unlock()
+		if dup {
+			return
+		}
+		uiLog.Infof("Resumed from sleep (%s), refreshing", how)
+		onResume()
+	}
+	if err := watchPowerEvents(func() { resumed("power event") }); err != nil {
+		uiLog.Warnf("Power events not available, watching the clock only: %v", err)
+	}
+	go watchClockJumps(func(gap time.Duration) { resumed("clock jumped " + shortDuration(gap) + " ahead") })
+}
+
+// watchClockJumps calls onResume when the wall clock jumps ahead, which is
+// what a system sleep looks like from inside the process: Go timers run on
+// the monotonic clock, which stops while suspended on most systems, so the
+// regular update timer would otherwise fire hours late.
+func watchClockJumps(onResume func(gap time.Duration)) {
+	last := time.Now().Round(0) // Round(0) drops the monotonic reading
+	for {
+		time.Sleep(clockCheckInterval)
+		now := time.Now().Round(0)
+		if gap := now.Sub(last) - clockCheckInterval; gap > clockJumpThreshold {
+			onResume(gap)
+		}
+		last = now
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// watchPowerEvents would call onResume when the OS announces a resume;
+// only Windows is asked, elsewhere the clock check notices it.
+func watchPowerEvents(onResume func()) error {
+	return errors.New("not supported on this platform")
+}
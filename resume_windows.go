@@ -0,0 +1,53 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var procPowerRegisterSuspendResumeNotification = syscall.NewLazyDLL("powrprof.dll").NewProc("PowerRegisterSuspendResumeNotification")
+
+const (
+	deviceNotifyCallback  = 2    // DEVICE_NOTIFY_CALLBACK
+	pbtAPMResumeAutomatic = 0x12 // PBT_APMRESUMEAUTOMATIC
+)
+
+// deviceNotifySubscribeParameters is DEVICE_NOTIFY_SUBSCRIBE_PARAMETERS.
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+var (
+	// powerParams must stay reachable while registered: Windows keeps
+	// the pointer.
+	powerParams   deviceNotifySubscribeParameters
+	powerResumed  func()
+	powerCallback = syscall.NewCallback(func(context, typ, setting uintptr) uintptr {
+		if typ == pbtAPMResumeAutomatic {
+			go powerResumed()
+		}
+		return 0
+	})
+)
+
+// watchPowerEvents calls onResume when Windows resumes from sleep or
+// hibernation, through PowerRegisterSuspendResumeNotification (Windows 8
+// and later). PBT_APMRESUMEAUTOMATIC comes with every resume;
+// PBT_APMRESUMESUSPEND only follows it when a user is present.
+func watchPowerEvents(onResume func()) error {
+	if err := procPowerRegisterSuspendResumeNotification.Find(); err != nil {
+		return err
+	}
+	powerResumed = onResume
+	powerParams.callback = powerCallback
+	var handle uintptr
+	if r, _, _ := procPowerRegisterSuspendResumeNotification.Call(deviceNotifyCallback,
+		uintptr(unsafe.Pointer(&powerParams)), uintptr(unsafe.Pointer(&handle))); r != 0 {
+		return fmt.Errorf("PowerRegisterSuspendResumeNotification: %w", syscall.Errno(r))
+	}
+	return nil
+}
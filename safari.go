@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errSafariAccess means macOS refused to let us read Safari's cookies.
+var errSafariAccess = errors.New("Safari cookies need Full Disk Access: System Settings → Privacy & Security → Full Disk Access, add claude-monitor and try again")
+
+// safariCookieFiles lists where Safari keeps Cookies.binarycookies: inside
+// its sandbox container since Safari 13, directly in ~/Library before.
+func safariCookieFiles() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("getting home directory: %w", err)
+	}
+	return []string{
+		filepath.Join(home, "Library", "Containers", "com.apple.Safari", "Data", "Library", "Cookies", "Cookies.binarycookies"),
+		filepath.Join(home, "Library", "Cookies", "Cookies.binarycookies"),
+	}, nil
+}
+
+// findSafariCookies reads claude.ai cookies from Safari. Without Full Disk
+// Access the error is errSafariAccess.
+func findSafariCookies() (sessionKey, orgID, cfClearance string, err error) {
+	files, err := safariCookieFiles()
+	if err != nil {
+		return "", "", "", err
+	}
+	var data []byte
+	var path string
+	for _, path = range files {
+		if data, err = os.ReadFile(path); err == nil || !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		firefoxLog.Warnf("Reading %s: %v", path, err)
+		return "", "", "", errSafariAccess
+	case errors.Is(err, fs.ErrNotExist):
+		return "", "", "", fmt.Errorf("Safari cookies not found (checked %s)", files[0])
+	case err != nil:
+		return "", "", "", fmt.Errorf("reading Safari cookies: %w", err)
+	}
+	firefoxLog.Infof("Safari cookies: %s", path)
+
+	rows, err := parseBinaryCookies(data)
+	if err != nil {
+		return "", "", "", fmt.Errorf("reading Safari cookies: %w", err)
+	}
+	rows = dropExpired(rows, timeNow())
+	firefoxLog.Infof("Found %d claude.ai cookies in Safari", len(rows))
+
+	selected := make(map[string]cookieRow)
+	for _, r := range rows {
+		if cur, ok := selected[r.Name]; !ok || r.Created.After(cur.Created) {
+			selected[r.Name] = r
+		}
+	}
+	report := &importReport{
+		Browser:    "Safari",
+		ProfileDir: filepath.Dir(path),
+		Rows:       rows,
+		Selected:   selected,
+		Decision:   "most recently created row per cookie name",
+	}
+	if sessionKey, orgID, cfClearance, err = report.credentials(); err != nil {
+		return "", "", "", err
+	}
+	recordSessionKey(report.Selected["sessionKey"])
+	if row, ok := report.Selected["cf_clearance"]; ok {
+		recordClearance(row)
+	}
+	return sessionKey, orgID, cfClearance, nil
+}
+
+// macEpoch is the Unix time of 2001-01-01, the epoch of Safari's timestamps.
+const macEpoch = 978307200
+
+func macTime(seconds float64) time.Time {
+	if seconds <= 0 || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}
+	}
+	return time.Unix(macEpoch+int64(seconds), 0)
+}
+
+// parseBinaryCookies reads the claude.ai cookies of a Cookies.binarycookies
+// file: "cook", the page count and page sizes (big-endian), then the
+// pages. Damaged pages and records are skipped.
+func parseBinaryCookies(data []byte) ([]cookieRow, error) {
+	if len(data) < 8 || string(data[:4]) != "cook" {
+		return nil, fmt.Errorf("not a Safari cookie file")
+	}
+	pages := int(binary.BigEndian.Uint32(data[4:]))
+	pos := 8
+	if pages < 0 || pages > (len(data)-pos)/4 {
+		return nil, fmt.Errorf("bad page count %d", pages)
+	}
+	sizes := make([]int, pages)
+	for i := range sizes {
+		sizes[i] = int(binary.BigEndian.Uint32(data[pos:]))
+		pos += 4
+	}
+
+	var rows []cookieRow
+	for _, size := range sizes {
+		if size < 0 || size > len(data)-pos {
+			break
+		}
+		rows = append(rows, parseCookiePage(data[pos:pos+size])...)
+		pos += size
+	}
+	return rows, nil
+}
+
+// parseCookiePage reads one page: a 0x00000100 header, then (little-endian)
+// the cookie count and the offset of each cookie record.
+func parseCookiePage(page []byte) []cookieRow {
+	if len(page) < 8 || binary.BigEndian.Uint32(page) != 0x00000100 {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(page[4:]))
+	if count < 0 || count > (len(page)-8)/4 {
+		return nil
+	}
+	var rows []cookieRow
+	for i := 0; i < count; i++ {
+		off := int(binary.LittleEndian.Uint32(page[8+4*i:]))
+		if off < 0 || off+4 > len(page) {
+			continue
+		}
+		size := int(binary.LittleEndian.Uint32(page[off:]))
+		if size < 56 || size > len(page)-off {
+			continue
+		}
+		if row, ok := parseCookieRecord(page[off : off+size]); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// parseCookieRecord reads a cookie record: its size, flags, the offsets of
+// the domain, name, path and value strings (NUL-terminated, relative to
+// the record), and the expiry and creation times as float64 seconds since
+// 2001-01-01 at offsets 40 and 48.
+func parseCookieRecord(rec []byte) (cookieRow, bool) {
+	str := func(at int) string {
+		o := int(binary.LittleEndian.Uint32(rec[at:]))
+		if o < 56 || o >= len(rec) {
+			return ""
+		}
+		s := rec[o:]
+		if end := bytes.IndexByte(s, 0); end >= 0 {
+			s = s[:end]
+		}
+		return string(s)
+	}
+	host := str(16)
+	if !isClaudeHost(host) {
+		return cookieRow{}, false
+	}
+	row := cookieRow{
+		Name:    str(20),
+		Value:   str(28),
+		Host:    host,
+		Expiry:  macTime(math.Float64frombits(binary.LittleEndian.Uint64(rec[40:]))),
+		Created: macTime(math.Float64frombits(binary.LittleEndian.Uint64(rec[48:]))),
+	}
+	return row, row.Name != "" && row.Value != ""
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type safariCookie struct {
+	host, name, value string
+	expiry, created   time.Time
+}
+
+// binaryCookies writes cookies in the Cookies.binarycookies format, one
+// page per slice.
+func binaryCookies(pages ...[]safariCookie) []byte {
+	mac := func(t time.Time) uint64 { return math.Float64bits(float64(t.Unix() - macEpoch)) }
+	var body [][]byte
+	for _, cookies := range pages {
+		var recs [][]byte
+		for _, c := range cookies {
+			strs := []string{c.host, c.name, "/", c.value}
+			rec := make([]byte, 56)
+			for i, s := range strs {
+				binary.LittleEndian.PutUint32(rec[16+4*i:], uint32(len(rec)))
+				rec = append(append(rec, s...), 0)
+			}
+			binary.LittleEndian.PutUint32(rec, uint32(len(rec)))
+			binary.LittleEndian.PutUint64(rec[40:], mac(c.expiry))
+			binary.LittleEndian.PutUint64(rec[48:], mac(c.created))
+			recs = append(recs, rec)
+		}
+		page := binary.BigEndian.AppendUint32(nil, 0x00000100)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(recs)))
+		off := len(page) + 4*len(recs) + 4
+		for _, rec := range recs {
+			page = binary.LittleEndian.AppendUint32(page, uint32(off))
+			off += len(rec)
+		}
+		page = append(page, 0, 0, 0, 0)
+		body = append(body, append(page, bytes.Join(recs, nil)...))
+	}
+	out := binary.BigEndian.AppendUint32([]byte("cook"), uint32(len(body)))
+	for _, p := range body {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(p)))
+	}
+	return append(out, bytes.Join(body, nil)...)
+}
+
+func TestParseBinaryCookies(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	later := now.Add(30 * 24 * time.Hour)
+	data := binaryCookies(
+		[]safariCookie{
+			{"example.com", "sessionKey", "sk-ant-sid01-other", later, now},
+			{"claude.ai", "sessionKey", "sk-ant-sid01-safari", later, now},
+		},
+		[]safariCookie{
+			{".claude.ai", "cf_clearance", "cf-safari", later, now},
+			{"claude.ai", "lastActiveOrg", "org-safari", later, now},
+		},
+	)
+	rows, err := parseBinaryCookies(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]cookieRow)
+	for _, r := range rows {
+		got[r.Name] = r
+	}
+	if len(rows) != 3 || got["sessionKey"].Value != "sk-ant-sid01-safari" || got["cf_clearance"].Host != ".claude.ai" {
+		t.Errorf("rows %+v", rows)
+	}
+	if r := got["lastActiveOrg"]; !r.Expiry.Equal(later) || !r.Created.Equal(now) {
+		t.Errorf("lastActiveOrg expiry %v created %v, want %v and %v", r.Expiry, r.Created, later, now)
+	}
+
+	// A damaged second page leaves the first
+	if rows, err := parseBinaryCookies(data[:len(data)-10]); err != nil || len(rows) != 1 {
+		t.Errorf("truncated: %d rows, %v", len(rows), err)
+	}
+	if _, err := parseBinaryCookies([]byte("SQLite format 3\x00")); err == nil {
+		t.Error("parsed a non-Safari file")
+	}
+}
+
+func TestFindSafariCookies(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	if _, _, _, err := findSafariCookies(); err == nil {
+		t.Error("no cookie file: no error")
+	}
+
+	now := time.Now().Truncate(time.Second)
+	path := filepath.Join(home, "Library", "Cookies", "Cookies.binarycookies")
+	writeFiles(t, filepath.Dir(path), map[string]string{filepath.Base(path): string(binaryCookies([]safariCookie{
+		{"claude.ai", "sessionKey", "sk-ant-sid01-expired", now.Add(-time.Hour), now.Add(-48 * time.Hour)},
+		{"claude.ai", "sessionKey", "sk-ant-sid01-safari", now.Add(time.Hour), now.Add(-72 * time.Hour)},
+		{".claude.ai", "cf_clearance", "cf-safari", now.Add(time.Hour), now},
+	}))})
+	sk, org, cfc, err := findSafariCookies()
+	if err != nil || sk != "sk-ant-sid01-safari" || org != "" || cfc != "cf-safari" {
+		t.Errorf("got %q, %q, %q, %v", sk, org, cfc, err)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't apply to root")
+	}
+	if err := os.Chmod(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := findSafariCookies(); err != errSafariAccess {
+		t.Errorf("unreadable file: error = %v, want errSafariAccess", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Secrets are masked the same way wherever they might be shown: logs,
+// Diagnostics, the import report and saved API responses.
+
+// maskSecret shows the first 6 and last 2 characters of a secret plus its
+// length, e.g. "sk-ant…a9 (len 108)". Short values are hidden entirely.
+func maskSecret(v string) string {
+	switch {
+	case v == "":
+		return "(empty)"
+	case len(v) <= 12:
+		return fmt.Sprintf("*** (len %d)", len(v))
+	}
+	return fmt.Sprintf("%s…%s (len %d)", v[:6], v[len(v)-2:], len(v))
+}
+
+// shortID abbreviates an identifier that isn't a credential but needn't be
+// logged in full, such as an org UUID: "1a2b3c4d...".
+func shortID(id string) string {
+	return id[:min(8, len(id))] + "..."
+}
+
+// secretFields are the names, in config.json, cookies and headers, whose
+// values are credentials.
+var secretFields = map[string]bool{
+	"session_key":  true,
+	"sessionkey":   true,
+	"cf_clearance": true,
+	"cfclearance":  true,
+	"cookie":       true,
+}
+
+// isSecretField reports whether a field with this name holds a credential.
+// Matching ignores case; org IDs and other identifiers are not secrets.
+func isSecretField(name string) bool {
+	return secretFields[strings.ToLower(name)]
+}
+
+var (
+	// Session keys are recognizable by prefix wherever they appear
+	sessionKeyPattern = regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{8,}`)
+	// "session_key": "...", in JSON
+	secretJSONPattern = regexp.MustCompile(`(?i)("(?:session_?key|cf_?clearance|cookie)"\s*:\s*")([^"]*)(")`)
+	// sessionKey=...; and cf_clearance=... in cookie headers and query strings
+	secretPairPattern = regexp.MustCompile(`(?i)\b(session_?key|cf_?clearance)=([^;&\s"]+)`)
+)
+
+// scrubSecrets masks credentials in arbitrary text or JSON: values of the
+// secret fields and anything shaped like a session key. Other text,
+// including org UUIDs, is left alone so the output stays useful.
+func scrubSecrets(s string) string {
+	s = secretJSONPattern.ReplaceAllStringFunc(s, func(m string) string {
+		g := secretJSONPattern.FindStringSubmatch(m)
+		if g[2] == "" {
+			return m
+		}
+		return g[1] + maskSecret(g[2]) + g[3]
+	})
+	s = secretPairPattern.ReplaceAllStringFunc(s, func(m string) string {
+		g := secretPairPattern.FindStringSubmatch(m)
+		if g[2] == "true" || g[2] == "false" {
+			return m // "cf_clearance=true" in the log says whether there is one
+		}
+		return g[1] + "=" + maskSecret(g[2])
+	})
+	s = sessionKeyPattern.ReplaceAllStringFunc(s, maskSecret)
+	return knownSecrets.mask(s)
+}
+
+// minKnownSecret keeps short values, which could match ordinary words, out
+// of knownSecrets.
+const minKnownSecret = 16
+
+// secretSet holds the credentials of the config in use, so that they are
+// masked even where nothing marks them as secrets, such as a bare
+// cf_clearance echoed in a response body.
+type secretSet struct {
+	mu     sync.Mutex
+	values []string
+}
+
+var knownSecrets = &secretSet{}
+
+// remember replaces the set with the credentials of cfg and its accounts.
+func (k *secretSet) remember(cfg *Config) {
+	if cfg == nil {
+		return
+	}
+	var values []string
+	add := func(v string) {
+		if v = strings.TrimSpace(v); len(v) >= minKnownSecret && v != keyringMarker {
+			values = append(values, v)
+		}
+	}
+	add(cfg.SessionKey)
+	add(cfg.CfClearance)
+	for _, a := range cfg.Accounts {
+		add(a.SessionKey)
+		add(a.CfClearance)
+	}
+	add(cfg.TelegramBotToken)
+	if cfg.Push != nil {
+		add(cfg.Push.Token)
+	}
+	// Webhooks authenticate with headers or a token in the query
+	for _, w := range cfg.Webhooks {
+		for _, v := range w.Headers {
+			add(v)
+		}
+		if u, err := url.Parse(w.URL); err == nil {
+			add(u.RawQuery)
+		}
+	}
+	k.mu.Lock()
+	k.values = values
+	k.mu.Unlock()
+}
+
+func (k *secretSet) mask(s string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, v := range k.values {
+		if strings.Contains(s, v) {
+			s = strings.ReplaceAll(s, v, maskSecret(v))
+		}
+	}
+	return s
+}
+
+// scrubbingWriter masks secrets in everything written through it. The
+// standard logger writes each line in one call, so no secret is split.
+type scrubbingWriter struct{ w io.Writer }
+
+func (s scrubbingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(s.w, scrubSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// setLogOutput points the standard logger at w with secrets masked. All
+// logging goes through it, so new log lines can't leak a sessionKey.
+func setLogOutput(w io.Writer) {
+	log.SetOutput(scrubbingWriter{w})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// Realistically shaped credentials; none of them is real.
+const (
+	testSessionKey = "sk-ant-REDACTED"
+	testClearance  = "Jd8x2kPq9mL4vN7bR1tY5wZ3cF6hK0sA.uG2iO8eE-1760693000-1.2.1.1-Xk9PqLm4Vn7BrT1yW5zC3fH6jK0sAuG2iO8e"
+	testOrgID      = "4e1a9b2c-7d3f-4a8e-b6c5-0f9d2e1a3b7c"
+)
+
+func TestMaskSecret(t *testing.T) {
+	for _, tc := range []struct{ v, want string }{
+		{"", "(empty)"},
+		{"short", "*** (len 5)"},
+		{"exactly12chr", "*** (len 12)"},
+		{testSessionKey, "sk-ant…AA (len 111)"},
+		{testClearance, "Jd8x2k…8e (len 97)"},
+	} {
+		if got := maskSecret(tc.v); got != tc.want {
+			t.Errorf("maskSecret(%.20q) = %q, want %q", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestIsSecretField(t *testing.T) {
+	for name, want := range map[string]bool{
+		"session_key": true, "sessionKey": true, "SESSIONKEY": true, "cf_clearance": true,
+		"Cookie": true, "org_id": false, "lastActiveOrg": false, "session": false, "tokens": false,
+		"session_key_source": false, "": false,
+	} {
+		if got := isSecretField(name); got != want {
+			t.Errorf("isSecretField(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// secretCorpus is text in which every occurrence of the secret must be
+// masked.
+var secretCorpus = []struct {
+	text, secret string
+}{
+	{`{"session_key": "` + testSessionKey + `", "org_id": "` + testOrgID + `"}`, testSessionKey},
+	{`{"sessionKey":"` + testSessionKey + `"}`, testSessionKey},
+	{`{"cf_clearance": "` + testClearance + `"}`, testClearance},
+	{`{"CfClearance": "` + testClearance + `"}`, testClearance},
+	{"Cookie: sessionKey=" + testSessionKey + "; cf_clearance=" + testClearance, testSessionKey},
+	{"Cookie: sessionKey=" + testSessionKey + "; cf_clearance=" + testClearance, testClearance},
+	{"GET /api?session_key=" + testSessionKey + "&x=1", testSessionKey},
+	{"pasted " + testSessionKey + " into the dialog", testSessionKey},
+	{"sessionKey%3D" + testSessionKey, testSessionKey},
+	{"error: invalid key \"" + testSessionKey[:40] + "\"", testSessionKey[:40]},
+}
+
+// nearMisses look like secrets, or mention them, but must come through
+// unchanged, or the logs and reports lose what makes them useful.
+var nearMisses = []string{
+	"org_id=" + testOrgID,
+	`{"org_id": "` + testOrgID + `", "lastActiveOrg": "` + testOrgID + `"}`,
+	"Firefox cookies found: org_id=4e1a9b2c... cf_clearance=true",
+	"cf_clearance=false",
+	"sessionKey valid for 26d (until 2026-11-12 10:22)",
+	"sessionKey not found — are you logged in to claude.ai in Firefox?",
+	`{"session_key": ""}`,
+	`{"session_key_source": "firefox", "tokens": 1234, "token_count": 99}`,
+	"sk-ant-sid01",        // the prefix alone
+	"sk-ant-abc",          // too short to be a key
+	"ask-anthropic-later", // no "sk-ant-" run
+	"update at 10:22:06, next 10:27:06",
+	"order 1234567:short-code",
+	"Session (5h): 42% — resets in 2h 13m",
+	"https://claude.ai/api/organizations/" + testOrgID + "/usage: HTTP 200 in 312ms",
+}
+
+func TestScrubSecretsCorpus(t *testing.T) {
+	for _, tc := range secretCorpus {
+		got := scrubSecrets(tc.text)
+		if strings.Contains(got, tc.secret) {
+			t.Errorf("secret left in %q", got)
+		}
+		if !strings.Contains(got, "(len ") {
+			t.Errorf("no mask in %q", got)
+		}
+	}
+	for _, s := range nearMisses {
+		if got := scrubSecrets(s); got != s {
+			t.Errorf("near miss changed:\n got %q\nwant %q", got, s)
+		}
+	}
+}
+
+func TestLogOutputIsScrubbed(t *testing.T) {
+	saved := log.Writer()
+	t.Cleanup(func() {
+		log.SetOutput(saved)
+		knownSecrets.remember(&Config{})
+	})
+	var buf bytes.Buffer
+	setLogOutput(&buf)
+
+	key, clearance := testSessionKey, testClearance
+	knownSecrets.remember(&Config{SessionKey: key, CfClearance: clearance})
+
+	err := &ErrHTTP{StatusCode: 400, Msg: "HTTP 400: {\"detail\": \"bad cookie " + key + "\", \"echo\": \"" + clearance + "\"}"}
+	log.Println("API error:", err)
+	log.Printf("Cookie: sessionKey=%s; cf_clearance=%s", key, clearance)
+
+	out := buf.String()
+	if strings.Contains(out, key) || strings.Contains(out, clearance) {
+		t.Errorf("secret in log:\n%s", out)
+	}
+	if !strings.Contains(out, "API error: HTTP 400") {
+		t.Errorf("message lost:\n%s", out)
+	}
+}
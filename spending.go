@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+// defaultSpendAlertPct applies when neither spend_alert_usd nor
+// spend_alert_pct is configured.
+var defaultSpendAlertPct = []float64{80, 100}
+
+// moneyFormat is how a locale writes amounts of money: the decimal and
+// thousands separators, and whether the currency sign comes last.
+type moneyFormat struct {
+	decimal, group string
+	signLast       bool
+}
+
+// usMoney is the format for locales not listed below: "$1,234.56".
+var usMoney = moneyFormat{decimal: ".", group: ","}
+
+// moneyFormats lists the locales that write money differently from
+// usMoney, by language or, where a region differs from its language,
+// by language and region.
+var moneyFormats = map[string]moneyFormat{
+	"cs": {",", "\u00a0", true},
+	"da": {",", ".", true},
+	"de": {",", ".", true},
+	"es": {",", ".", true},
+	"fi": {",", "\u00a0", true},
+	"fr": {",", "\u00a0", true},
+	"it": {",", ".", true},
+	"nb": {",", "\u00a0", true},
+	"nl": {",", ".", true},
+	"pl": {",", "\u00a0", true},
+	"pt": {",", ".", true},
+	"ru": {",", "\u00a0", true},
+	"sv": {",", "\u00a0", true},
+	"tr": {",", ".", true},
+	"uk": {",", "\u00a0", true},
+
+	"de_ch": {".", "’", false},
+	"es_mx": usMoney,
+	"pt_br": {",", ".", false},
+}
+
+// userLocale returns the locale money is formatted for: LC_ALL,
+// LC_MONETARY or LANG when set, else the system's setting.
+func userLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MONETARY", "LANG"} {
+		if locale := os.Getenv(env); locale != "" {
+			return locale
+		}
+	}
+	return cachedSystemLocale()
+}
+
+// cachedSystemLocale asks for the system locale once; it doesn't change
+// often enough to run a command per amount.
+var cachedSystemLocale = sync.OnceValue(systemLocale)
+
+// localeMoneyFormat returns the money format of locale, which may be
+// written "de_DE.UTF-8", "de-DE" or "de_DE@currency=EUR".
+func localeMoneyFormat(locale string) moneyFormat {
+	locale, _, _ = strings.Cut(strings.ToLower(locale), ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	locale = strings.ReplaceAll(locale, "-", "_")
+	if f, ok := moneyFormats[locale]; ok {
+		return f
+	}
+	lang, _, _ := strings.Cut(locale, "_")
+	if f, ok := moneyFormats[lang]; ok {
+		return f
+	}
+	return usMoney
+}
+
+// formatMoney renders a dollar amount for the user's locale: "$1,234.56"
+// by default, "1.234,56 $" in German.
+func formatMoney(v float64) string {
+	return localeMoneyFormat(userLocale()).format(v)
+}
+
+func (f moneyFormat) format(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, cents, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(d)
+	}
+	amount := b.String() + f.decimal + cents
+	if f.signLast {
+		return sign + amount + " $"
+	}
+	return sign + "$" + amount
+}
+
+// spendAlert is a crossed spend threshold.
+type spendAlert struct {
+	key     string // stable identifier stored once fired, e.g. "usd:20"
+	message string
+}
+
+// spendAlerts returns the thresholds e has crossed: absolute amounts
+// always, percentages only when there is a monthly limit.
+func spendAlerts(e *ExtraUsage, usd, pct []float64) []spendAlert {
+	if e == nil || !e.IsEnabled || e.UsedCredits == nil {
+		return nil
+	}
+	used := *e.UsedCredits
+	capped := e.MonthlyLimit != nil && *e.MonthlyLimit > 0
+
+	var alerts []spendAlert
+	for _, t := range usd {
+		if t > 0 && used >= t {
+			msg := fmt.Sprintf("Extra usage spend reached %s", formatMoney(used))
+			if capped {
+				msg = fmt.Sprintf("You've spent %s of your %s monthly cap", formatMoney(used), formatMoney(*e.MonthlyLimit))
+			}
+			alerts = append(alerts, spendAlert{key: fmt.Sprintf("usd:%g", t), message: msg})
+		}
+	}
+	if capped {
+		for _, t := range pct {
+			if t > 0 && used >= *e.MonthlyLimit*t/100 {
+				alerts = append(alerts, spendAlert{
+					key: fmt.Sprintf("pct:%g", t),
+					message: fmt.Sprintf("You've spent %s of your %s monthly cap (%d%%)",
+						formatMoney(used), formatMoney(*e.MonthlyLimit), int(used / *e.MonthlyLimit * 100)),
+				})
+			}
+		}
+	}
+	return alerts
+}
+
+// spendThresholds returns the configured thresholds or the defaults.
+func (c *Config) spendThresholds() (usd, pct []float64) {
+	if c == nil || (len(c.SpendAlertUSD) == 0 && len(c.SpendAlertPct) == 0) {
+		return nil, defaultSpendAlertPct
+	}
+	return c.SpendAlertUSD, c.SpendAlertPct
+}
+
+// spendAlertState records which thresholds already fired this month.
+type spendAlertState struct {
+	Month string   `json:"month"` // "2006-01", UTC
+	Fired []string `json:"fired"`
+	Last  string   `json:"last,omitempty"`
+}
+
+// checkSpendAlerts fires each newly crossed threshold once per month and
+// returns the notifications for those that fired.
+func checkSpendAlerts(cfg *Config, e *ExtraUsage, now time.Time) []notification {
+	usd, pct := cfg.spendThresholds()
+	crossed := spendAlerts(e, usd, pct)
+	if len(crossed) == 0 {
+		return nil
+	}
+	month := now.UTC().Format("2006-01")
+
+	var fired []notification
+	err := updateState(statePath(), func(st *appState) {
+		sa := st.SpendAlerts
+		if sa == nil || sa.Month != month {
+			sa = &spendAlertState{Month: month}
+			st.SpendAlerts = sa
+		}
+		for _, a := range crossed {
+			if containsString(sa.Fired, a.key) {
+				continue
+			}
+			sa.Fired = append(sa.Fired, a.key)
+			sort.Strings(sa.Fired)
+			sa.Last = a.message
+			fired = append(fired, notification{title: "Spend alert", body: a.message})
+		}
+	})
+	if err != nil {
+		uiLog.Warnf("Failed to save spend alerts: %v", err)
+		return nil
+	}
+	return fired
+}
+
+// lastSpendAlert returns this month's most recent spend alert, if any.
+func lastSpendAlert(now time.Time) string {
+	st, err := readStateFile(statePath())
+	if err != nil || st.SpendAlerts == nil || st.SpendAlerts.Month != now.UTC().Format("2006-01") {
+		return ""
+	}
+	return st.SpendAlerts.Last
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// monthStart returns the start of now's UTC month, which is when the
+// monthly spend starts over.
+func monthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// spendPoint is one reading of the month's extra usage spend.
+type spendPoint struct {
+	at   time.Time
+	used float64
+}
+
+// spendPointOf returns the spend a history sample read, if it has one.
+func spendPointOf(s historySample) (spendPoint, bool) {
+	if s.Usage == nil || s.Usage.ExtraUsage == nil || s.Usage.ExtraUsage.UsedCredits == nil {
+		return spendPoint{}, false
+	}
+	return spendPoint{s.Time, *s.Usage.ExtraUsage.UsedCredits}, true
+}
+
+// spendSince drops the points, oldest first, from before since.
+func spendSince(points []spendPoint, since time.Time) []spendPoint {
+	for len(points) > 0 && points[0].at.Before(since) {
+		points = points[1:]
+	}
+	return points
+}
+
+// spendRateWindow is how far back the spend rate behind the history's
+// month-end projection looks, so that it follows a change of pace.
+const spendRateWindow = 7 * 24 * time.Hour
+
+// projectFromHistory projects month-end spend from the month's readings
+// (oldest first): the latest spend plus the rate over the last
+// spendRateWindow for the rest of the month. ok is false while the
+// readings span less than a day. window is the span the rate is from.
+func projectFromHistory(points []spendPoint, now time.Time) (projected, perDay float64, window time.Duration, ok bool) {
+	if len(points) < 2 {
+		return 0, 0, 0, false
+	}
+	last := points[len(points)-1]
+	first := points[0]
+	for _, p := range points {
+		if !p.at.Before(last.at.Add(-spendRateWindow)) {
+			first = p
+			break
+		}
+	}
+	window = last.at.Sub(first.at)
+	if window < 24*time.Hour {
+		return 0, 0, 0, false
+	}
+	// Credits handed back can lower the spend; that is no negative rate
+	perDay = max(0, (last.used-first.used)/window.Hours()*24)
+	left := monthStart(now).AddDate(0, 1, 0).Sub(last.at)
+	return last.used + perDay*left.Hours()/24, perDay, window, true
+}
+
+// spendTrajectory renders the spend at the end of each week of the month
+// (days 1-7, 8-14, ...) that has readings, the current one last, e.g.
+// "$4.10 → $9.00 → $15.20". It is "" until two weeks have readings.
+func spendTrajectory(points []spendPoint) string {
+	var weeks []string
+	week := -1
+	for _, p := range points {
+		w := (p.at.UTC().Day() - 1) / 7
+		if w != week {
+			weeks = append(weeks, "")
+			week = w
+		}
+		weeks[len(weeks)-1] = formatMoney(p.used)
+	}
+	if len(weeks) < 2 {
+		return ""
+	}
+	return strings.Join(weeks, " → ")
+}
+
+// projectMonthEnd extrapolates spend so far linearly to the end of the
+// (UTC) month, for when there is no history to go by. ok is false during
+// the first day, when the rate is noise.
+func projectMonthEnd(used float64, now time.Time) (projected float64, ok bool) {
+	start := monthStart(now)
+	end := start.AddDate(0, 1, 0)
+	elapsed := now.Sub(start)
+	if elapsed < 24*time.Hour {
+		return 0, false
+	}
+	return used * float64(end.Sub(start)) / float64(elapsed), true
+}
+
+// spendingMenu is the "Spending" submenu, shown while extra usage is on.
+type spendingMenu struct {
+	parent     *systray.MenuItem
+	used       *systray.MenuItem
+	limit      *systray.MenuItem
+	rate       *systray.MenuItem
+	trajectory *systray.MenuItem
+	projection *systray.MenuItem
+	alert      *systray.MenuItem
+}
+
+func newSpendingMenu() *spendingMenu {
+	parent := systray.AddMenuItem("Spending", "Extra usage spend this month")
+	m := &spendingMenu{
+		parent:     parent,
+		used:       parent.AddSubMenuItem("Spent: ...", ""),
+		limit:      parent.AddSubMenuItem("Monthly limit: ...", ""),
+		rate:       parent.AddSubMenuItem("Average per day: ...", ""),
+		trajectory: parent.AddSubMenuItem("", ""),
+		projection: parent.AddSubMenuItem("Projected month-end: ...", ""),
+		alert:      parent.AddSubMenuItem("", ""),
+	}
+	for _, item := range []*systray.MenuItem{m.used, m.limit, m.rate, m.trajectory, m.projection, m.alert} {
+		item.Disable()
+	}
+	m.trajectory.Hide()
+	m.alert.Hide()
+	parent.Hide()
+	return m
+}
+
+// spendingView is what the Spending submenu shows; zero hides it.
+type spendingView struct {
+	shown                                bool
+	used, limit, rate, projection, alert string
+	trajectory                           string // empty hides the row
+}
+
+// renderSpending builds the submenu contents from e and month, the spend
+// recorded in the history this month. alert is the latest spend alert this
+// month, if any. The rate and projection come from the history; without a
+// day of it they are linear from the start of the month.
+func renderSpending(e *ExtraUsage, month []spendPoint, alert string, now time.Time) spendingView {
+	if e == nil || !e.IsEnabled {
+		return spendingView{}
+	}
+	accessible := accessibleText.Load()
+	v := spendingView{
+		shown:      true,
+		used:       "Spent this month: ?",
+		limit:      "Monthly limit: none (uncapped)",
+		rate:       "Average per day: n/a",
+		projection: "Projected month-end: n/a (first day)",
+	}
+
+	if e.MonthlyLimit != nil {
+		v.limit = "Monthly limit: " + formatMoney(*e.MonthlyLimit)
+	}
+	if e.UsedCredits != nil {
+		v.used = "Spent this month: " + formatMoney(*e.UsedCredits)
+		// The reading being shown may not be in the history yet
+		if n := len(month); n == 0 || month[n-1].at.Before(now) {
+			month = append(slices.Clip(month), spendPoint{now, *e.UsedCredits})
+		}
+		if t := spendTrajectory(month); t != "" {
+			v.trajectory = truncate("By week: "+t, maxMenuLine)
+		}
+		day := now.UTC().Day()
+		v.rate = fmt.Sprintf("Average per day: %s (day %d)", formatMoney(*e.UsedCredits/float64(day)), day)
+		p, perDay, window, ok := projectFromHistory(month, now)
+		if ok {
+			v.rate = fmt.Sprintf("Average per day: %s (last %s)", formatMoney(perDay), plural(int(window.Round(24*time.Hour)/(24*time.Hour)), "day"))
+		} else {
+			p, ok = projectMonthEnd(*e.UsedCredits, now)
+		}
+		if ok {
+			line := "Projected month-end: " + formatMoney(p)
+			if e.MonthlyLimit != nil && p > *e.MonthlyLimit {
+				line = mark(markWarning, accessible) + " " + line + " (over limit)"
+			}
+			v.projection = truncate(line, maxMenuLine)
+		}
+	}
+	if alert != "" {
+		v.alert = truncate(mark(markWarning, accessible)+" "+alert, maxMenuLine)
+	}
+	return v
+}
+
+// apply shows v; called on the UI goroutine only.
+func (m *spendingMenu) apply(v spendingView) {
+	if !v.shown {
+		setShown(m.parent, false)
+		return
+	}
+	setTitle(m.used, v.used)
+	setTitle(m.limit, v.limit)
+	setTitle(m.rate, v.rate)
+	showRow(m.trajectory, v.trajectory)
+	setTitle(m.projection, v.projection)
+	showRow(m.alert, v.alert)
+	setShown(m.parent, true)
+}
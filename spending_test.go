@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatMoneyLocales(t *testing.T) {
+	for _, tc := range []struct {
+		locale string
+		v      float64
+		want   string
+	}{
+		{"C", 12.345, "$12.35"},
+		{"en_US.UTF-8", 1234.5, "$1,234.50"},
+		{"de_DE.UTF-8", 1234.5, "1.234,50 $"},
+		{"de-DE", 0.5, "0,50 $"}, // as Windows writes it
+		{"de_CH", 1234.5, "$1’234.50"},
+		{"fr_FR@euro", 1234567, "1 234 567,00 $"},
+		{"pt_BR", 1234.5, "$1.234,50"},
+		{"ru_RU", -20, "-20,00 $"},
+		{"", 999.999, "$1,000.00"},
+	} {
+		if got := localeMoneyFormat(tc.locale).format(tc.v); got != tc.want {
+			t.Errorf("%q: format(%v) = %q, want %q", tc.locale, tc.v, got, tc.want)
+		}
+	}
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MONETARY", "de_AT.UTF-8")
+	if got := formatMoney(20); got != "20,00 $" {
+		t.Errorf("with LC_MONETARY de_AT: %q", got)
+	}
+}
+
+func TestProjectMonthEnd(t *testing.T) {
+	// $18 in 10.5 of October's 31 days
+	if p, ok := projectMonthEnd(18, time.Date(2026, 10, 11, 12, 0, 0, 0, time.UTC)); !ok || fmt.Sprintf("%.2f", p) != "53.14" {
+		t.Errorf("projectMonthEnd = %.2f, %v; want 53.14", p, ok)
+	}
+	// The first day's average is noise
+	if _, ok := projectMonthEnd(18, time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)); ok {
+		t.Error("projected from the first day")
+	}
+}
+
+func TestSpendAlerts(t *testing.T) {
+	t.Setenv("LC_ALL", "C")
+	used, limit := 42.0, 50.0
+	var keys []string
+	for _, a := range spendAlerts(&ExtraUsage{IsEnabled: true, UsedCredits: &used, MonthlyLimit: &limit}, []float64{20, 50}, []float64{80, 100}) {
+		keys = append(keys, a.key)
+	}
+	if strings.Join(keys, " ") != "usd:20 pct:80" {
+		t.Errorf("crossed %v, want usd:20 pct:80", keys)
+	}
+	// Without a cap only the dollar thresholds apply
+	alerts := spendAlerts(&ExtraUsage{IsEnabled: true, UsedCredits: &used}, []float64{20}, []float64{1})
+	if len(alerts) != 1 || alerts[0].message != "Extra usage spend reached $42.00" {
+		t.Errorf("uncapped alerts %+v", alerts)
+	}
+}
+
+func TestSpendingFromHistory(t *testing.T) {
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+	t.Setenv("LC_ALL", "C")
+
+	// Last month's spend, then this month's, picking up pace
+	var fixture strings.Builder
+	for _, s := range []struct {
+		day  string
+		used float64
+	}{{"09-30", 90}, {"10-02", 2}, {"10-06", 5}, {"10-09", 8}, {"10-13", 12}, {"10-16", 16}} {
+		fmt.Fprintf(&fixture, `{"time":"2026-%sT12:00:00Z","usage":{"five_hour":{"utilization":10},"seven_day":{"utilization":20},"extra_usage":{"is_enabled":true,"used_credits":%v,"monthly_limit":35}}}`+"\n", s.day, s.used)
+	}
+	if err := os.WriteFile(paths.historyFile(), []byte(fixture.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	used, limit := 18.0, 35.0
+	e := &ExtraUsage{IsEnabled: true, UsedCredits: &used, MonthlyLimit: &limit}
+	month := (&historyLog{}).monthSpend(now)
+	if len(month) != 5 {
+		t.Fatalf("month spend: %v", month)
+	}
+	v := renderSpending(e, month, "", now)
+	// $12 on the 13th to $18 on the 17th, then 14.5 days more at $1.50
+	if v.trajectory != "By week: $5.00 → $12.00 → $18.00" {
+		t.Errorf("trajectory = %q", v.trajectory)
+	}
+	if v.rate != "Average per day: $1.50 (last 4 days)" {
+		t.Errorf("rate = %q", v.rate)
+	}
+	if v.projection != "⚠ Projected month-end: $39.75 (over limit)" {
+		t.Errorf("projection = %q", v.projection)
+	}
+
+	// Without history the projection is linear from the start of the month
+	v = renderSpending(e, nil, "", now)
+	if v.trajectory != "" || v.rate != "Average per day: $1.06 (day 17)" || v.projection != "Projected month-end: $33.82" {
+		t.Errorf("without history: %+v", v)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// Index lookups for the embedded SQLite reader: rows are found through an
+// index on the column being matched instead of by walking every leaf page
+// of the table.
+
+// maxBTreeDepth bounds the descent through interior pages, so a damaged
+// file with a page cycle can't recurse forever. Real trees are a handful
+// of levels deep.
+const maxBTreeDepth = 32
+
+// sqliteIndex is an index entry of sqlite_master.
+type sqliteIndex struct {
+	name string
+	root int
+	sql  string // "" for the automatic index of a UNIQUE or PRIMARY KEY constraint
+}
+
+// findIndexes lists the indexes on tableName.
+func (db *sqliteDB) findIndexes(tableName string) []sqliteIndex {
+	var indexes []sqliteIndex
+	db.walkTableBTree(1, func(cols []sqliteVal) {
+		if len(cols) >= 5 && cols[0].text == "index" && cols[2].text == tableName && cols[3].isInt {
+			indexes = append(indexes, sqliteIndex{name: cols[1].text, root: int(cols[3].intV), sql: cols[4].text})
+		}
+	})
+	return indexes
+}
+
+// leadingColumn returns the column an index is sorted by first, or "" if
+// the index can't be used for exact text matches: partial indexes,
+// expressions and non-binary collations are skipped. An automatic index
+// belongs to a constraint of the table; which one isn't recorded, so only
+// tables with a single UNIQUE or PRIMARY KEY constraint are handled.
+func (idx sqliteIndex) leadingColumn(tableSQL string) string {
+	if idx.sql == "" {
+		var keys [][]string
+		for _, def := range splitDefinitions(tableSQL) {
+			if cols := constraintColumns(def); cols != nil {
+				keys = append(keys, cols)
+			}
+		}
+		if len(keys) != 1 || !strings.HasSuffix(idx.name, "_1") {
+			return ""
+		}
+		return keys[0][0]
+	}
+
+	upper := strings.ToUpper(idx.sql)
+	if strings.Contains(upper, " WHERE ") {
+		return ""
+	}
+	open := strings.IndexByte(idx.sql, '(')
+	end := strings.LastIndexByte(idx.sql, ')')
+	if open < 0 || end <= open {
+		return ""
+	}
+	first := strings.TrimSpace(strings.SplitN(idx.sql[open+1:end], ",", 2)[0])
+	fields := strings.Fields(first)
+	if len(fields) == 0 || strings.ContainsAny(first, "()") || strings.Contains(strings.ToUpper(first), "COLLATE") {
+		return ""
+	}
+	return unquoteIdent(fields[0])
+}
+
+// constraintColumns returns the columns of a UNIQUE or PRIMARY KEY
+// constraint, written either as a table constraint or on a column, or nil
+// if def declares neither. An INTEGER PRIMARY KEY is the rowid itself and
+// has no index.
+func constraintColumns(def string) []string {
+	fields := strings.Fields(def)
+	if len(fields) == 0 {
+		return nil
+	}
+	upper := strings.ToUpper(def)
+	switch strings.ToUpper(fields[0]) {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE":
+		if !strings.Contains(upper, "UNIQUE") && !strings.Contains(upper, "PRIMARY KEY") {
+			return nil
+		}
+		open := strings.IndexByte(def, '(')
+		end := strings.LastIndexByte(def, ')')
+		if open < 0 || end <= open {
+			return nil
+		}
+		var cols []string
+		for _, c := range strings.Split(def[open+1:end], ",") {
+			if f := strings.Fields(c); len(f) > 0 {
+				cols = append(cols, unquoteIdent(f[0]))
+			}
+		}
+		return cols
+	case "CHECK", "FOREIGN":
+		return nil
+	}
+	if strings.Contains(upper, " UNIQUE") ||
+		(strings.Contains(upper, "PRIMARY KEY") && !(len(fields) > 1 && strings.EqualFold(fields[1], "INTEGER"))) {
+		return []string{unquoteIdent(fields[0])}
+	}
+	return nil
+}
+
+func unquoteIdent(s string) string {
+	return strings.Trim(s, "\"`[]'")
+}
+
+// lookupRows calls fn for each row of a table whose column col has one of
+// the values keys, found through an index led by col. It returns false,
+// without calling fn, if there is no such index or the rows it points to
+// don't match; the caller then has to scan the table.
+func (db *sqliteDB) lookupRows(table string, tableRoot int, tableSQL string, colIdx map[string]int, col string, keys []string, fn func([]sqliteVal)) bool {
+	ci, ok := colIdx[col]
+	if !ok {
+		return false
+	}
+	for _, idx := range db.findIndexes(table) {
+		if !strings.EqualFold(idx.leadingColumn(tableSQL), col) {
+			continue
+		}
+		var rows [][]sqliteVal
+		for _, key := range keys {
+			for _, rowid := range db.indexRowids(idx.root, key) {
+				cols := db.tableRow(tableRoot, rowid)
+				if ci >= len(cols) || cols[ci].text != key {
+					firefoxLog.Warnf("Index %s doesn't match table %s, scanning the table", idx.name, table)
+					return false
+				}
+				rows = append(rows, cols)
+			}
+		}
+		for _, cols := range rows {
+			fn(cols)
+		}
+		return true
+	}
+	return false
+}
+
+// compareKey orders an index key column against a text value the way
+// SQLite does with the BINARY collation: NULL and numbers sort before
+// text, blobs after it.
+func compareKey(v sqliteVal, key string) int {
+	switch {
+	case v.isNull, v.isInt:
+		return -1
+	case v.blob != nil:
+		return 1
+	}
+	return strings.Compare(v.text, key)
+}
+
+// indexRowids returns the rowids of the entries of the index rooted at
+// pageNum whose first column equals key.
+func (db *sqliteDB) indexRowids(pageNum int, key string) []int64 {
+	var rowids []int64
+	db.searchIndex(pageNum, key, 0, func(rowid int64) { rowids = append(rowids, rowid) })
+	return rowids
+}
+
+// searchIndex descends into the subtrees of an index b-tree (interior
+// 0x02, leaf 0x0a pages) that can hold key. Unlike in a table b-tree, the
+// cells of interior pages are entries too.
+func (db *sqliteDB) searchIndex(pageNum int, key string, depth int, fn func(rowid int64)) {
+	page := db.page(pageNum)
+	if page == nil || depth > maxBTreeDepth || len(page) < 8 {
+		return
+	}
+	pageType := page[0]
+	hdrLen := 8
+	switch pageType {
+	case 0x02:
+		hdrLen = 12
+	case 0x0a:
+	default:
+		return
+	}
+	if len(page) < hdrLen {
+		return
+	}
+	cellCount := int(binary.BigEndian.Uint16(page[3:]))
+
+	prev := -1 // how the previous cell compared; the first child has no lower bound
+	for i := 0; i < cellCount; i++ {
+		pOff := hdrLen + i*2
+		if pOff+2 > len(page) {
+			return
+		}
+		pos := int(binary.BigEndian.Uint16(page[pOff:]))
+		child := 0
+		if pageType == 0x02 {
+			if pos+4 > len(page) {
+				continue
+			}
+			child = int(binary.BigEndian.Uint32(page[pos:]))
+			pos += 4
+		}
+		rec := parseRecord(db.indexCellPayload(page, pos))
+		if len(rec) < 2 {
+			continue
+		}
+		c := compareKey(rec[0], key)
+		// The child holds the entries between the previous cell and this one
+		if child > 0 && c >= 0 && prev <= 0 {
+			db.searchIndex(child, key, depth+1, fn)
+		}
+		if c == 0 && rec[len(rec)-1].isInt {
+			fn(rec[len(rec)-1].intV)
+		}
+		if c > 0 {
+			return
+		}
+		prev = c
+	}
+	if pageType == 0x02 {
+		if right := int(binary.BigEndian.Uint32(page[8:])); right > 0 {
+			db.searchIndex(right, key, depth+1, fn)
+		}
+	}
+}
+
+// indexCellPayload extracts the key record of an index cell whose payload
+// size varint is at pos.
+func (db *sqliteDB) indexCellPayload(page []byte, pos int) []byte {
+	if pos >= len(page) {
+		return nil
+	}
+	payloadSize, n := readVarint(page, pos)
+	if n == 0 || payloadSize < 0 {
+		return nil
+	}
+	return db.cellPayload(page, pos+n, payloadSize, db.maxIndexPayload())
+}
+
+// tableRow returns the record with the given rowid from the table b-tree
+// rooted at pageNum, or nil if there is none.
+func (db *sqliteDB) tableRow(pageNum int, rowid int64) []sqliteVal {
+	for depth := 0; depth <= maxBTreeDepth; depth++ {
+		page := db.page(pageNum)
+		hdrOff := 0
+		if pageNum == 1 {
+			hdrOff = 100
+		}
+		if page == nil || len(page) < hdrOff+8 {
+			return nil
+		}
+		cellCount := int(binary.BigEndian.Uint16(page[hdrOff+3:]))
+
+		switch page[hdrOff] {
+		case 0x0d: // table leaf: payload size, rowid, payload
+			for i := 0; i < cellCount; i++ {
+				pOff := hdrOff + 8 + i*2
+				if pOff+2 > len(page) {
+					return nil
+				}
+				cellOff := int(binary.BigEndian.Uint16(page[pOff:]))
+				if cellOff >= len(page) {
+					continue
+				}
+				_, n := readVarint(page, cellOff)
+				id, m := readVarint(page, cellOff+n)
+				if n == 0 || m == 0 || id != rowid {
+					continue
+				}
+				return parseRecord(db.leafCellPayload(page, cellOff))
+			}
+			return nil
+
+		case 0x05: // table interior: child page, largest rowid in it
+			next := int(binary.BigEndian.Uint32(page[hdrOff+8:]))
+			for i := 0; i < cellCount; i++ {
+				pOff := hdrOff + 12 + i*2
+				if pOff+2 > len(page) {
+					return nil
+				}
+				cellOff := int(binary.BigEndian.Uint16(page[pOff:]))
+				if cellOff+4 > len(page) {
+					continue
+				}
+				if key, n := readVarint(page, cellOff+4); n > 0 && rowid <= key {
+					next = int(binary.BigEndian.Uint32(page[cellOff:]))
+					break
+				}
+			}
+			pageNum = next
+
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// openCookieDB opens a fixture's moz_cookies table, applying its WAL.
+func openCookieDB(tb testing.TB, profile string) (db *sqliteDB, root int, createSQL string, colIdx map[string]int) {
+	tb.Helper()
+	dir := filepath.Join("testdata", "firefox", profile)
+	data, err := os.ReadFile(filepath.Join(dir, "cookies.sqlite"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if db, err = newSQLiteDB(data); err != nil {
+		tb.Fatal(err)
+	}
+	if wal, err := os.ReadFile(filepath.Join(dir, "cookies.sqlite-wal")); err == nil {
+		if err := db.loadWAL(wal); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	root, createSQL = db.findTable("moz_cookies")
+	return db, root, createSQL, tableColumns(createSQL)
+}
+
+func TestLookupCookiesMatchesScan(t *testing.T) {
+	for _, profile := range []string{"basic", "containers", "overflow", "wal", "large"} {
+		db, root, createSQL, colIdx := openCookieDB(t, profile)
+		collect := func(into *[]string) func([]sqliteVal) {
+			return func(cols []sqliteVal) {
+				name, host := cols[colIdx["name"]].text, cols[colIdx["host"]].text
+				if isClaudeHost(host) && slices.Contains(claudeCookieNames, name) {
+					*into = append(*into, name+"@"+host+"="+cols[colIdx["value"]].text)
+				}
+			}
+		}
+		var scanned, looked []string
+		db.walkTableBTree(root, collect(&scanned))
+		if !db.lookupCookies(root, createSQL, colIdx, collect(&looked)) {
+			t.Errorf("%s: no index used", profile)
+			continue
+		}
+		slices.Sort(scanned)
+		slices.Sort(looked)
+		if len(scanned) == 0 || !slices.Equal(scanned, looked) {
+			t.Errorf("%s: index lookup found %q, scan %q", profile, looked, scanned)
+		}
+	}
+}
+
+func TestIndexLeadingColumn(t *testing.T) {
+	const table = `CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT, name TEXT, host TEXT, CONSTRAINT moz_uniqueid UNIQUE (name, host, path, originAttributes))`
+	for _, tc := range []struct {
+		idx   sqliteIndex
+		table string
+		want  string
+	}{
+		{sqliteIndex{name: "sqlite_autoindex_moz_cookies_1"}, table, "name"},
+		{sqliteIndex{name: "sqlite_autoindex_t_1"}, `CREATE TABLE t (id INTEGER PRIMARY KEY, "host" TEXT UNIQUE, name TEXT)`, "host"},
+		{sqliteIndex{name: "sqlite_autoindex_t_1"}, `CREATE TABLE t (k TEXT PRIMARY KEY, v TEXT UNIQUE)`, ""},
+		{sqliteIndex{name: "sqlite_autoindex_t_1"}, `CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT)`, ""},
+		{sqliteIndex{name: "moz_basedomain", sql: "CREATE INDEX moz_basedomain ON moz_cookies (baseDomain, originAttributes)"}, table, "baseDomain"},
+		{sqliteIndex{name: "i", sql: "CREATE INDEX i ON t (\"host\" ASC)"}, table, "host"},
+		{sqliteIndex{name: "i", sql: "CREATE INDEX i ON t (host COLLATE NOCASE)"}, table, ""},
+		{sqliteIndex{name: "i", sql: "CREATE INDEX i ON t (lower(host))"}, table, ""},
+		{sqliteIndex{name: "i", sql: "CREATE INDEX i ON t (host) WHERE expiry > 0"}, table, ""},
+	} {
+		if got := tc.idx.leadingColumn(tc.table); got != tc.want {
+			t.Errorf("%s on %s: leading column %q, want %q", tc.idx.name, tc.table, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkCookieLookup(b *testing.B) {
+	db, root, createSQL, colIdx := openCookieDB(b, "large")
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			db.lookupCookies(root, createSQL, colIdx, func([]sqliteVal) {})
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			db.walkTableBTree(root, func([]sqliteVal) {})
+		}
+	})
+}
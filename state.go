@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxStateAge is how old a saved snapshot may be and still be shown on startup.
+const maxStateAge = 24 * time.Hour
+
+// appState is persisted to state.json. It carries the last successful usage
+// snapshot (so the tray has something to show immediately after a restart)
+// and diagnostics that should survive a relaunch.
+type appState struct {
+	FetchedAt time.Time      `json:"fetched_at"`
+	Usage     *UsageResponse `json:"usage"`
+
+	// LastCloudflareBlock is when the API last answered with a Cloudflare 403.
+	LastCloudflareBlock time.Time `json:"last_cloudflare_block,omitempty"`
+	// Clearance describes the most recently imported cf_clearance cookie.
+	Clearance *clearanceInfo `json:"clearance,omitempty"`
+	// SessionKey describes the most recently imported sessionKey cookie.
+	SessionKey *clearanceInfo `json:"session_key_cookie,omitempty"`
+	// OrgChecks caches the daily organization membership check.
+	OrgChecks map[string]*orgCheck `json:"org_checks,omitempty"`
+	// OrgNotices holds the org mismatches already notified, by the same
+	// key as OrgChecks.
+	OrgNotices map[string]string `json:"org_notices,omitempty"`
+	// SpendAlerts tracks which spend thresholds fired this month.
+	SpendAlerts *spendAlertState `json:"spend_alerts,omitempty"`
+	// UsageAlerts is the reading the usage notifications were last
+	// checked against.
+	UsageAlerts *usageAlertState `json:"usage_alerts,omitempty"`
+	// PaceAlerts records the windows a pace notification was raised for.
+	PaceAlerts *paceAlertState `json:"pace_alerts,omitempty"`
+	// DataDirs lists the state directories this installation has used,
+	// current first, so data left behind after a move can be found.
+	DataDirs []string `json:"data_dirs,omitempty"`
+	// ReleaseCheck is the last successful check_updates check.
+	ReleaseCheck *releaseCheck `json:"release_check,omitempty"`
+}
+
+// clearanceInfo records cookie metadata for an imported cf_clearance or
+// sessionKey value. Only a fingerprint of the value is stored, enough to
+// tell whether config.json still holds the same token.
+type clearanceInfo struct {
+	Fingerprint string    `json:"fingerprint"`
+	Created     time.Time `json:"created,omitempty"`
+	Expires     time.Time `json:"expires,omitempty"`
+}
+
+var stateMu sync.Mutex
+
+func statePath() string {
+	return paths.stateFile()
+}
+
+// readStateFile parses state.json without any validation.
+func readStateFile(path string) (*appState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st appState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	return &st, nil
+}
+
+// loadState reads the usage snapshot, rejecting corrupted, empty or outdated
+// files. A missing file is reported with an error satisfying os.IsNotExist.
+func loadState(path string) (*appState, error) {
+	st, err := readStateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if st.Usage == nil || st.FetchedAt.IsZero() {
+		return nil, fmt.Errorf("%s has no usage snapshot", filepath.Base(path))
+	}
+	age := time.Since(st.FetchedAt)
+	if age > maxStateAge || age < -time.Minute {
+		return nil, fmt.Errorf("snapshot from %s is too old", st.FetchedAt.Format(time.RFC3339))
+	}
+	return st, nil
+}
+
+// saveState records a successful usage snapshot.
+func saveState(path string, usage *UsageResponse) error {
+	return updateState(path, func(st *appState) {
+		st.FetchedAt = time.Now()
+		st.Usage = usage
+	})
+}
+
+// updateState applies fn to state.json and atomically writes it back.
+// A corrupted file is replaced rather than blocking the update.
+func updateState(path string, fn func(*appState)) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	st, err := readStateFile(path)
+	if err != nil {
+		st = &appState{}
+	}
+	fn(st)
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// secretFingerprint identifies a cookie value (cf_clearance, session key)
+// without storing it.
+func secretFingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// formatAge renders a duration as a short "… ago" label.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
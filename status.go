@@ -0,0 +1,69 @@
+package main
+
+import "time"
+
+// statusDoc is the machine-readable result of one update cycle, handed to
+// external consumers such as the on_update_command hook. Field names are
+// part of the public interface; only add fields, never rename them.
+type statusDoc struct {
+	UpdatedAt  time.Time     `json:"updated_at"`
+	OK         bool          `json:"ok"`
+	Error      string        `json:"error,omitempty"`
+	Session    *statusBucket `json:"session,omitempty"`
+	Weekly     *statusBucket `json:"weekly,omitempty"`
+	Opus       *statusBucket `json:"opus,omitempty"`
+	Sonnet     *statusBucket `json:"sonnet,omitempty"`
+	ExtraUsage *ExtraUsage   `json:"extra_usage,omitempty"`
+	// LastUpdate is when the usage numbers were read. After a failed
+	// update the status file and /status keep the previous numbers, so
+	// this is how consumers tell they are stale.
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+	// Cloudflare tells how old cf_clearance is and when Cloudflare last
+	// blocked a request; absent while neither is known.
+	Cloudflare *statusCloudflare `json:"cloudflare,omitempty"`
+}
+
+// statusCloudflare is the cloudflare object in statusDoc.
+type statusCloudflare struct {
+	ClearanceAgeSeconds *int64     `json:"clearance_age_seconds,omitempty"`
+	ClearanceExpiresAt  *time.Time `json:"clearance_expires_at,omitempty"`
+	LastBlockAt         *time.Time `json:"last_block_at,omitempty"`
+}
+
+// statusBucket is one usage bucket in statusDoc.
+type statusBucket struct {
+	Utilization float64 `json:"utilization"`
+	Remaining   float64 `json:"remaining"`
+	ResetsAt    string  `json:"resets_at,omitempty"`
+}
+
+func newStatusBucket(b *UsageBucket) *statusBucket {
+	if b == nil {
+		return nil
+	}
+	return &statusBucket{
+		Utilization: b.Utilization,
+		Remaining:   max(0, 100-b.Utilization),
+		ResetsAt:    b.ResetsAt,
+	}
+}
+
+// newStatusDoc builds the status for an update that produced usage or err.
+func newStatusDoc(usage *UsageResponse, err error) *statusDoc {
+	st := &statusDoc{UpdatedAt: time.Now().UTC()}
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	if usage == nil {
+		return st
+	}
+	st.OK = true
+	st.LastUpdate = &st.UpdatedAt
+	st.Session = newStatusBucket(&usage.FiveHour)
+	st.Weekly = newStatusBucket(&usage.SevenDay)
+	st.Opus = newStatusBucket(usage.SevenDayOpus)
+	st.Sonnet = newStatusBucket(usage.SevenDaySonnet)
+	st.ExtraUsage = usage.ExtraUsage
+	return st
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+)
+
+// writeStatusFile writes st to cfg.StatusFile, if set, through a temporary
+// file so that a reader never sees half of it. A failure is logged and
+// shown under Diagnostics.
+func writeStatusFile(cfg *Config, st *statusDoc) {
+	if cfg == nil || cfg.StatusFile == "" {
+		return
+	}
+	if err := writeJSONFile(cfg.StatusFile, st); err != nil {
+		uiLog.Warnf("Writing status_file: %v", err)
+		health.report("status_file", false, err.Error())
+		return
+	}
+	health.report("status_file", true, "written")
+}
+
+// writeJSONFile replaces path with v as indented JSON.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
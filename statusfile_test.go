@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteStatusFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	cfg := &Config{StatusFile: path}
+	m := &metricsStore{}
+	read := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	m.record(&UsageResponse{
+		FiveHour:       UsageBucket{Utilization: 42, ResetsAt: "2026-05-04T14:00:00Z"},
+		SevenDay:       UsageBucket{Utilization: 17},
+		SevenDaySonnet: &UsageBucket{Utilization: 3},
+	}, nil, read)
+	m.record(nil, errors.New("HTTP 503"), read.Add(5*time.Minute))
+	writeStatusFile(cfg, m.status(read.Add(5*time.Minute)))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	// A failed update keeps the previous numbers, dated by last_update
+	if got["ok"] != false || got["error"] != "HTTP 503" || got["last_update"] != "2026-05-04T12:00:00Z" || got["updated_at"] != "2026-05-04T12:05:00Z" {
+		t.Errorf("status: %s", data)
+	}
+	session, _ := got["session"].(map[string]any)
+	if session["utilization"] != 42.0 || session["resets_at"] != "2026-05-04T14:00:00Z" {
+		t.Errorf("session: %v", session)
+	}
+	if sonnet, _ := got["sonnet"].(map[string]any); sonnet["utilization"] != 3.0 {
+		t.Errorf("sonnet: %v", got["sonnet"])
+	}
+	if _, ok := got["opus"]; ok {
+		t.Errorf("opus present: %s", data)
+	}
+	if _, ok := got["cloudflare"]; ok {
+		t.Errorf("cloudflare present with nothing known: %s", data)
+	}
+
+	m.record(&UsageResponse{FiveHour: UsageBucket{Utilization: 50}}, nil, read.Add(10*time.Minute))
+	writeStatusFile(cfg, m.status(read.Add(10*time.Minute)))
+	data, _ = os.ReadFile(path)
+	got = nil
+	json.Unmarshal(data, &got)
+	if got["ok"] != true || got["error"] != nil || got["last_update"] != "2026-05-04T12:10:00Z" {
+		t.Errorf("after recovering: %s", data)
+	}
+
+	// A clearance imported 9 hours before, and a block 2 days before
+	m.setCloudflare(cloudflareInfo{
+		clearanceCreated: read.Add(-9 * time.Hour),
+		clearanceExpires: read.Add(15 * time.Hour),
+		lastBlock:        read.Add(-48 * time.Hour),
+	})
+	writeStatusFile(cfg, m.status(read))
+	data, _ = os.ReadFile(path)
+	got = nil
+	json.Unmarshal(data, &got)
+	cf, _ := got["cloudflare"].(map[string]any)
+	if cf["clearance_age_seconds"] != 32400.0 || cf["clearance_expires_at"] != "2026-05-05T03:00:00Z" || cf["last_block_at"] != "2026-05-02T12:00:00Z" {
+		t.Errorf("cloudflare: %s", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
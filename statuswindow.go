@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// The mini status window shows the usage rows of the menu in a small
+// window that stays open, for a glance without opening the menu. It
+// follows every update while open. Windows has a native window
+// (statuswindow_windows.go); elsewhere, with no GUI toolkit to draw one,
+// it is a small page in the browser that reloads itself.
+
+// statusWindow is the text the window shows, kept by the UI goroutine.
+var statusWindow struct {
+	sync.Mutex
+	text  string
+	shown bool // opened at least once this run
+}
+
+// statusWindowText renders st for the window: the usage rows and extra
+// usage, one per line.
+func statusWindowText(st uiState) string {
+	var lines []string
+	for _, row := range []string{st.session, st.weekly, st.opus, st.sonnet, st.extra} {
+		if row != "" {
+			lines = append(lines, row)
+		}
+	}
+	if len(lines) == 0 {
+		return appName + ": no data yet"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// showStatusWindow opens the window with the usage on screen, or brings it
+// to the front.
+func showStatusWindow() {
+	st := ui.state()
+	statusWindow.Lock()
+	statusWindow.text = statusWindowText(st)
+	statusWindow.shown = true
+	text := statusWindow.text
+	statusWindow.Unlock()
+	if err := openStatusWindow(text); err != nil {
+		uiLog.Warnf("Failed to open the status window: %v", err)
+	}
+}
+
+// updateStatusWindow makes an opened window show st; called on the UI
+// goroutine after each change.
+func updateStatusWindow(st uiState) {
+	text := statusWindowText(st)
+	statusWindow.Lock()
+	changed := statusWindow.shown && text != statusWindow.text
+	statusWindow.text = text
+	statusWindow.Unlock()
+	if changed {
+		setStatusWindowText(text)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// statusPageRefresh is how often, in seconds, the status page reloads to
+// pick up updates.
+const statusPageRefresh = 30
+
+// statusPagePath is the page standing in for the status window.
+func statusPagePath() string { return filepath.Join(paths.stateDir, "status.html") }
+
+// openStatusWindow writes the status page and opens it in the browser.
+func openStatusWindow(text string) error {
+	if err := writeStatusPage(text); err != nil {
+		return err
+	}
+	openURL((&url.URL{Scheme: "file", Path: filepath.ToSlash(statusPagePath())}).String())
+	return nil
+}
+
+// setStatusWindowText rewrites the page; it shows on its next reload.
+func setStatusWindowText(text string) {
+	if err := writeStatusPage(text); err != nil {
+		uiLog.Warnf("Failed to update the status page: %v", err)
+	}
+}
+
+func writeStatusPage(text string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta http-equiv="refresh" content="%d">
+<title>%s</title>
+<style>body{font:14px system-ui,sans-serif;margin:1em}p{margin:.3em 0}</style>
+</head><body>
+`, statusPageRefresh, html.EscapeString(appName))
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(line))
+	}
+	b.WriteString("</body></html>\n")
+	return os.WriteFile(statusPagePath(), []byte(b.String()), 0644)
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestStatusWindowText(t *testing.T) {
+	if got, want := statusWindowText(uiState{}), appName+": no data yet"; got != want {
+		t.Errorf("empty state = %q, want %q", got, want)
+	}
+	st := uiState{
+		tooltip: "not shown",
+		session: "Session (5h): 42% — resets in 2h 13m",
+		weekly:  "Weekly: 10% — resets in 3d 2h",
+		opus:    "Opus: n/a",
+		extra:   "Extra usage: $12.34 / $50.00 (24%)",
+	}
+	want := "Session (5h): 42% — resets in 2h 13m\nWeekly: 10% — resets in 3d 2h\nOpus: n/a\nExtra usage: $12.34 / $50.00 (24%)"
+	if got := statusWindowText(st); got != want {
+		t.Errorf("statusWindowText = %q, want %q", got, want)
+	}
+}
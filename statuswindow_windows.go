@@ -0,0 +1,217 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procRegisterClassExW = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW  = user32.NewProc("CreateWindowExW")
+	procDefWindowProcW   = user32.NewProc("DefWindowProcW")
+	procGetMessageW      = user32.NewProc("GetMessageW")
+	procTranslateMessage = user32.NewProc("TranslateMessage")
+	procDispatchMessageW = user32.NewProc("DispatchMessageW")
+	procShowWindow       = user32.NewProc("ShowWindow")
+	procSetForegroundWnd = user32.NewProc("SetForegroundWindow")
+	procSetWindowTextW   = user32.NewProc("SetWindowTextW")
+	procSendMessageW     = user32.NewProc("SendMessageW")
+	procMoveWindow       = user32.NewProc("MoveWindow")
+	procLoadCursorW      = user32.NewProc("LoadCursorW")
+	procGetClientRect    = user32.NewProc("GetClientRect")
+	procGetStockObject   = syscall.NewLazyDLL("gdi32.dll").NewProc("GetStockObject")
+	procGetModuleHandleW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetModuleHandleW")
+)
+
+const (
+	statusWindowClass = "ClaudeMonitorStatus"
+
+	wsCaption      = 0x00C00000
+	wsSysMenu      = 0x00080000
+	wsChild        = 0x40000000
+	wsVisible      = 0x10000000
+	wsExTopmost    = 0x00000008
+	wsExToolWindow = 0x00000080
+	ssNoPrefix     = 0x00000080
+
+	wmSize    = 0x0005
+	wmClose   = 0x0010
+	wmSetFont = 0x0030
+
+	swHide       = 0
+	swShowNormal = 1
+
+	colorWindow    = 5
+	idcArrow       = 32512
+	defaultGUIFont = 17
+	spiGetWorkArea = 0x0030
+
+	statusWindowWidth  = 360
+	statusWindowHeight = 190
+	// statusWindowMargin is the gap between the window and the edges of
+	// the work area, and around the text.
+	statusWindowMargin = 12
+)
+
+type wndClassEx struct {
+	cbSize        uint32
+	style         uint32
+	lpfnWndProc   uintptr
+	cbClsExtra    int32
+	cbWndExtra    int32
+	hInstance     uintptr
+	hIcon         uintptr
+	hCursor       uintptr
+	hbrBackground uintptr
+	lpszMenuName  *uint16
+	lpszClassName *uint16
+	hIconSm       uintptr
+}
+
+type winMsg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      struct{ x, y int32 }
+	private uint32
+}
+
+type winRect struct{ left, top, right, bottom int32 }
+
+// statusWin holds the window once created; it lives until the app exits
+// and closing it only hides it.
+var statusWin struct {
+	once         sync.Once
+	hwnd, static uintptr
+	err          error
+}
+
+var statusWndProcC = syscall.NewCallback(statusWndProc)
+
+// openStatusWindow shows the window with text, creating it on first use,
+// in the bottom-right corner of the work area near the tray.
+func openStatusWindow(text string) error {
+	statusWin.once.Do(func() {
+		ready := make(chan struct{})
+		go runStatusWindow(ready)
+		<-ready
+	})
+	if statusWin.err != nil {
+		return statusWin.err
+	}
+	setStatusWindowText(text)
+	procShowWindow.Call(statusWin.hwnd, swShowNormal)
+	procSetForegroundWnd.Call(statusWin.hwnd)
+	return nil
+}
+
+// setStatusWindowText replaces the text of the window, shown or hidden.
+func setStatusWindowText(text string) {
+	if statusWin.static == 0 {
+		return
+	}
+	// The static control wants CRLF line breaks
+	p, err := syscall.UTF16PtrFromString(strings.ReplaceAll(text, "\n", "\r\n"))
+	if err != nil {
+		return
+	}
+	procSetWindowTextW.Call(statusWin.static, uintptr(unsafe.Pointer(p)))
+}
+
+// runStatusWindow creates the window and runs its message loop, on a
+// thread of its own as Windows requires. ready is closed once hwnd or err
+// is set.
+func runStatusWindow(ready chan<- struct{}) {
+	runtime.LockOSThread()
+	hwnd, static, err := createStatusWindow()
+	statusWin.hwnd, statusWin.static, statusWin.err = hwnd, static, err
+	close(ready)
+	if err != nil {
+		return
+	}
+	var msg winMsg
+	for {
+		r, _, _ := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		if int32(r) <= 0 {
+			return
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&msg)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
+func createStatusWindow() (hwnd, static uintptr, err error) {
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	cursor, _, _ := procLoadCursorW.Call(0, idcArrow)
+	class, _ := syscall.UTF16PtrFromString(statusWindowClass)
+	wc := wndClassEx{
+		lpfnWndProc:   statusWndProcC,
+		hInstance:     instance,
+		hCursor:       cursor,
+		hbrBackground: colorWindow + 1,
+		lpszClassName: class,
+	}
+	wc.cbSize = uint32(unsafe.Sizeof(wc))
+	if r, _, e := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); r == 0 {
+		return 0, 0, fmt.Errorf("RegisterClassEx: %w", e)
+	}
+
+	x, y := int32(-0x80000000), int32(-0x80000000) // CW_USEDEFAULT
+	var work winRect
+	if r, _, _ := procSystemParametersInfoW.Call(spiGetWorkArea, 0, uintptr(unsafe.Pointer(&work)), 0); r != 0 {
+		x = work.right - statusWindowWidth - statusWindowMargin
+		y = work.bottom - statusWindowHeight - statusWindowMargin
+	}
+	title, _ := syscall.UTF16PtrFromString(appName)
+	hwnd, _, e := procCreateWindowExW.Call(wsExTopmost|wsExToolWindow,
+		uintptr(unsafe.Pointer(class)), uintptr(unsafe.Pointer(title)),
+		wsCaption|wsSysMenu, uintptr(x), uintptr(y), statusWindowWidth, statusWindowHeight,
+		0, 0, instance, 0)
+	if hwnd == 0 {
+		return 0, 0, fmt.Errorf("CreateWindowEx: %w", e)
+	}
+	staticClass, _ := syscall.UTF16PtrFromString("STATIC")
+	static, _, e = procCreateWindowExW.Call(0,
+		uintptr(unsafe.Pointer(staticClass)), 0,
+		wsChild|wsVisible|ssNoPrefix, statusWindowMargin, statusWindowMargin, 0, 0,
+		hwnd, 0, instance, 0)
+	if static == 0 {
+		return 0, 0, fmt.Errorf("CreateWindowEx STATIC: %w", e)
+	}
+	font, _, _ := procGetStockObject.Call(defaultGUIFont)
+	procSendMessageW.Call(static, wmSetFont, font, 1)
+	var client winRect
+	procGetClientRect.Call(hwnd, uintptr(unsafe.Pointer(&client)))
+	fitStatusText(static, client.right, client.bottom)
+	return hwnd, static, nil
+}
+
+// fitStatusText sizes the text to a client area of w×h.
+func fitStatusText(static uintptr, w, h int32) {
+	procMoveWindow.Call(static, statusWindowMargin, statusWindowMargin,
+		uintptr(max(w-2*statusWindowMargin, 0)), uintptr(max(h-2*statusWindowMargin, 0)), 1)
+}
+
+// statusWndProc hides the window instead of destroying it when closed, and
+// keeps the text filling the client area.
+func statusWndProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	switch msg {
+	case wmClose:
+		procShowWindow.Call(hwnd, swHide)
+		return 0
+	case wmSize:
+		if statusWin.static != 0 {
+			fitStatusText(statusWin.static, int32(lParam&0xffff), int32(lParam>>16&0xffff))
+		}
+	}
+	r, _, _ := procDefWindowProcW.Call(hwnd, msg, wParam, lParam)
+	return r
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// telegramAPI is the Bot API base URL, swapped out in tests.
+	telegramAPI = "https://api.telegram.org"
+	// telegramTimeout bounds a sendMessage request.
+	telegramTimeout = 10 * time.Second
+)
+
+// telegramInterval is how often one bucket may message, so a value
+// flapping around a threshold can't flood the chat.
+const telegramInterval = time.Hour
+
+// telegramSink sends events as messages from the bot telegram_bot_token
+// to telegram_chat_id.
+type telegramSink struct {
+	token, chatID string
+	cfg           *Config // for status_format
+}
+
+// telegram returns the sink cfg sets up, and false without both keys.
+func (c *Config) telegram() (telegramSink, bool) {
+	if c == nil {
+		return telegramSink{}, false
+	}
+	s := telegramSink{token: strings.TrimSpace(c.TelegramBotToken), chatID: strings.TrimSpace(c.TelegramChatID), cfg: c}
+	return s, s.token != "" && s.chatID != ""
+}
+
+func (telegramSink) name() string { return "Telegram" }
+
+// wants takes threshold crossings, session resets and auth errors.
+func (telegramSink) wants(ev alertEvent) bool {
+	switch ev.Event {
+	case eventThresholdCrossed, eventAuthError:
+		return true
+	case eventLimitReset:
+		return ev.Bucket == "session"
+	}
+	return false
+}
+
+// deliver messages ev with the "Copy status" summary under it, unless its
+// bucket messaged within telegramInterval.
+func (s telegramSink) deliver(ev alertEvent) error {
+	key := ev.Bucket
+	if key == "" {
+		key = ev.Event
+	}
+	if !telegramLimit.allow(key, ev.Timestamp) {
+		uiLog.Infof("Telegram: %s for %s skipped, one message per %s", ev.Event, key, shortDuration(telegramInterval))
+		return nil
+	}
+	summary, _ := lastStatus.text(s.cfg, timeNow()) // a broken status_format leaves the headline
+	return s.send(telegramText(ev, summary))
+}
+
+// telegramText is the message for ev: a headline, then summary.
+func telegramText(ev alertEvent, summary string) string {
+	var head string
+	switch ev.Event {
+	case eventThresholdCrossed:
+		head = fmt.Sprintf("⚠ %s usage passed %g%%", bucketTitle(ev.Bucket), ev.Threshold)
+	case eventLimitReset:
+		head = fmt.Sprintf("✓ %s limit reset", bucketTitle(ev.Bucket))
+	case eventAuthError:
+		head = "✗ claude.ai rejected the session key (" + ev.Error + "): import the cookies again"
+	case eventRecovered:
+		head = "✓ Updates work again"
+	default:
+		head = ev.Event
+	}
+	if summary == "" {
+		return head
+	}
+	return head + "\n" + summary
+}
+
+// bucketTitle is "Session" for "session".
+func bucketTitle(bucket string) string {
+	if bucket == "" {
+		return ""
+	}
+	return strings.ToUpper(bucket[:1]) + bucket[1:]
+}
+
+// send calls sendMessage with text.
+func (s telegramSink) send(text string) error {
+	body, err := json.Marshal(map[string]string{"chat_id": s.chatID, "text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: telegramTimeout}).Post(telegramAPI+"/bot"+s.token+"/sendMessage", "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The error repeats the URL, and the token in it
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &reply) != nil || !reply.OK {
+		if reply.Description != "" {
+			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, reply.Description)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendTelegramTest sends a message to check telegram_bot_token and
+// telegram_chat_id, for "Send test message".
+func sendTelegramTest() error {
+	cfg, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	s, ok := cfg.telegram()
+	if !ok {
+		return errors.New("set telegram_bot_token and telegram_chat_id first")
+	}
+	summary, _ := lastStatus.text(cfg, timeNow())
+	return s.send("Test message from " + appName + "\n" + summary)
+}
+
+// rateLimit lets each key through once per interval.
+type rateLimit struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+var telegramLimit = rateLimit{interval: telegramInterval}
+
+// allow reports whether key may go at now, and if so counts it.
+func (r *rateLimit) allow(key string, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.last[key]; ok && now.Sub(last) < r.interval {
+		return false
+	}
+	if r.last == nil {
+		r.last = make(map[string]time.Time)
+	}
+	r.last[key] = now
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramDeliver(t *testing.T) {
+	var sent []map[string]string
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		var m map[string]string
+		json.NewDecoder(r.Body).Decode(&m)
+		sent = append(sent, m)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	savedAPI := telegramAPI
+	t.Cleanup(func() {
+		telegramAPI = savedAPI
+		telegramLimit = rateLimit{interval: telegramInterval}
+		lastStatus.set(nil, nil, nil, time.Time{})
+	})
+	telegramAPI = srv.URL
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.Local)
+	lastStatus.set(&UsageResponse{FiveHour: UsageBucket{Utilization: 82}, SevenDay: UsageBucket{Utilization: 40}}, nil, nil, now)
+	s, ok := (&Config{TelegramBotToken: "123:abc", TelegramChatID: "42"}).telegram()
+	if !ok {
+		t.Fatal("telegram not set up with both keys")
+	}
+
+	crossing := bucketEvent(eventThresholdCrossed, "session", &UsageBucket{Utilization: 82}, now)
+	crossing.Threshold = 80
+	if err := s.deliver(crossing); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/bot123:abc/sendMessage" {
+		t.Errorf("path %s", path)
+	}
+	if len(sent) != 1 || sent[0]["chat_id"] != "42" {
+		t.Fatalf("sent %v", sent)
+	}
+	if text := sent[0]["text"]; !strings.HasPrefix(text, "⚠ Session usage passed 80%\nClaude usage: session 82%") {
+		t.Errorf("text %q", text)
+	}
+
+	// The same bucket again within the hour is dropped, another bucket isn't
+	crossing.Timestamp = now.Add(30 * time.Minute)
+	s.deliver(crossing)
+	weekly := bucketEvent(eventThresholdCrossed, "weekly", &UsageBucket{Utilization: 91}, now.Add(30*time.Minute))
+	s.deliver(weekly)
+	crossing.Timestamp = now.Add(61 * time.Minute)
+	s.deliver(crossing)
+	if len(sent) != 3 {
+		t.Errorf("%d messages, want 3", len(sent))
+	}
+}
+
+func TestTelegramWants(t *testing.T) {
+	var s telegramSink
+	for _, tc := range []struct {
+		ev   alertEvent
+		want bool
+	}{
+		{alertEvent{Event: eventThresholdCrossed, Bucket: "weekly"}, true},
+		{alertEvent{Event: eventLimitReset, Bucket: "session"}, true},
+		{alertEvent{Event: eventLimitReset, Bucket: "weekly"}, false},
+		{alertEvent{Event: eventAuthError}, true},
+		{alertEvent{Event: eventRecovered}, false},
+	} {
+		if got := s.wants(tc.ev); got != tc.want {
+			t.Errorf("%+v: %v", tc.ev, got)
+		}
+	}
+	if _, ok := (&Config{TelegramBotToken: "123:abc"}).telegram(); ok {
+		t.Error("set up without a chat id")
+	}
+}
@@ -0,0 +1,144 @@
+#!/usr/bin/env python3
+"""Writes the SQLite fixtures the tests read. Run from this directory
+after changing it; the databases are committed so the tests don't need
+Python."""
+
+import os
+import shutil
+import sqlite3
+import tempfile
+
+# moz_cookies as current Firefox creates it
+MOZ_COOKIES = """CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT NOT NULL DEFAULT '', name TEXT, value TEXT, host TEXT, path TEXT, expiry INTEGER, lastAccessed INTEGER, creationTime INTEGER, isSecure INTEGER, isHttpOnly INTEGER, inBrowserElement INTEGER DEFAULT 0, sameSite INTEGER DEFAULT 0, rawSameSite INTEGER DEFAULT 0, schemeMap INTEGER DEFAULT 0, isPartitionedAttributeSet INTEGER DEFAULT 0, CONSTRAINT moz_uniqueid UNIQUE (name, host, path, originAttributes))"""
+
+FAR = 4102444800  # 2100-01-01, so the fixtures don't expire
+USED = 1760000000 * 1000000  # lastAccessed and creationTime, microseconds
+
+
+def cookie_db(path, rows):
+    if os.path.exists(path):
+        os.remove(path)
+    db = sqlite3.connect(path)
+    db.execute(MOZ_COOKIES)
+    db.executemany(
+        "INSERT INTO moz_cookies (originAttributes, name, value, host, path, expiry, lastAccessed, creationTime, isSecure, isHttpOnly) VALUES (?, ?, ?, ?, '/', ?, ?, ?, 1, 1)",
+        [(oa, name, value, host, expiry, used, used) for oa, name, value, host, expiry, used in rows])
+    db.commit()
+    db.close()
+
+
+def basic():
+    """A profile logged in to claude.ai, with cookies of other hosts,
+    including lookalikes that a substring match on "claude.ai" would take."""
+    os.makedirs("firefox/basic", exist_ok=True)
+    cookie_db("firefox/basic/cookies.sqlite", [
+        ("", "sessionKey", "sk-ant-sid01-default", "claude.ai", FAR, USED),
+        ("", "lastActiveOrg", "org-default", "claude.ai", FAR, USED),
+        ("", "cf_clearance", "cf-default", ".claude.ai", FAR, USED),
+        ("", "sessionKey", "sk-ant-sid01-other", "example.com", FAR, USED + 1),
+        ("", "sessionKey", "sk-ant-sid01-evil", "notclaude.ai.evil.com", FAR, USED + 2),
+        ("", "cf_clearance", "cf-evil", ".claude.ai.evil.com", FAR, USED + 2),
+        ("", "lastActiveOrg", "org-evil", "fakeclaude.ai", FAR, USED + 2),
+    ])
+
+
+def containers():
+    """Logged in inside container 3 and, earlier, container 5; the default
+    context has a stale sessionKey from before logging out."""
+    os.makedirs("firefox/containers", exist_ok=True)
+    cookie_db("firefox/containers/cookies.sqlite", [
+        ("", "sessionKey", "sk-ant-sid01-stale", "claude.ai", FAR, USED - 3),
+        ("", "cf_clearance", "cf-default", ".claude.ai", FAR, USED - 3),
+        ("^userContextId=3", "sessionKey", "sk-ant-sid01-work", "claude.ai", FAR, USED),
+        ("^userContextId=3", "lastActiveOrg", "org-work", "claude.ai", FAR, USED),
+        ("^userContextId=3", "cf_clearance", "cf-work", ".claude.ai", FAR, USED),
+        ("^userContextId=5", "sessionKey", "sk-ant-sid01-personal", "claude.ai", FAR, USED - 1),
+        ("^userContextId=5", "lastActiveOrg", "org-personal", "claude.ai", FAR, USED - 1),
+    ])
+
+
+def overflow():
+    """A cf_clearance too long for one page, stored in overflow pages."""
+    os.makedirs("firefox/overflow", exist_ok=True)
+    cookie_db("firefox/overflow/cookies.sqlite", [
+        ("", "sessionKey", "sk-ant-sid01-overflow", "claude.ai", FAR, USED),
+        ("", "lastActiveOrg", "org-overflow", "claude.ai", FAR, USED),
+        ("", "cf_clearance", "cf-" + "0123456789abcdef" * 600 + "-end", ".claude.ai", FAR, USED),
+    ])
+
+
+def wal():
+    """A profile whose newest cf_clearance and sessionKey exist only in
+    cookies.sqlite-wal, as when Firefox is running."""
+    os.makedirs("firefox/wal", exist_ok=True)
+    with tempfile.TemporaryDirectory() as tmp:
+        path = os.path.join(tmp, "cookies.sqlite")
+        cookie_db(path, [
+            ("", "sessionKey", "sk-ant-sid01-stale", "claude.ai", FAR, USED),
+            ("", "lastActiveOrg", "org-wal", "claude.ai", FAR, USED),
+            ("", "cf_clearance", "cf-stale", ".claude.ai", FAR, USED),
+        ])
+        db = sqlite3.connect(path)
+        db.execute("PRAGMA journal_mode=WAL")
+        db.execute("PRAGMA wal_autocheckpoint=0")
+        db.execute("UPDATE moz_cookies SET value = 'cf-fresh', lastAccessed = ? WHERE name = 'cf_clearance'", (USED + 1,))
+        db.commit()
+        db.execute("UPDATE moz_cookies SET value = 'sk-ant-sid01-fresh', lastAccessed = ? WHERE name = 'sessionKey'", (USED + 1,))
+        db.commit()
+        # Copy while the connection is open: closing it checkpoints the WAL.
+        for name in ("cookies.sqlite", "cookies.sqlite-wal"):
+            shutil.copy(os.path.join(tmp, name), os.path.join("firefox/wal", name))
+        db.close()
+
+
+def large():
+    """A long-used profile: thousands of cookies of other sites around the
+    claude.ai ones, so the table and its index span many pages."""
+    os.makedirs("firefox/large", exist_ok=True)
+    rows = [("", "c%d" % i, "v%08d" % i, "site%d.example" % (i % 997), FAR, USED) for i in range(5000)]
+    rows[2500:2500] = [
+        ("", "sessionKey", "sk-ant-sid01-large", "claude.ai", FAR, USED),
+        ("", "lastActiveOrg", "org-large", "claude.ai", FAR, USED),
+        ("", "cf_clearance", "cf-large", ".claude.ai", FAR, USED),
+        ("", "sessionKey", "sk-ant-sid01-elsewhere", "example.com", FAR, USED),
+    ]
+    cookie_db("firefox/large/cookies.sqlite", rows)
+
+
+CHROME_COOKIES = """CREATE TABLE cookies(creation_utc INTEGER NOT NULL, host_key TEXT NOT NULL, top_frame_site_key TEXT NOT NULL, name TEXT NOT NULL, value TEXT NOT NULL, encrypted_value BLOB NOT NULL, path TEXT NOT NULL, expires_utc INTEGER NOT NULL, is_secure INTEGER NOT NULL, is_httponly INTEGER NOT NULL, last_access_utc INTEGER NOT NULL, has_expires INTEGER NOT NULL, is_persistent INTEGER NOT NULL, priority INTEGER NOT NULL, samesite INTEGER NOT NULL, source_scheme INTEGER NOT NULL, source_port INTEGER NOT NULL, last_update_utc INTEGER NOT NULL, source_type INTEGER NOT NULL, has_cross_site_ancestor INTEGER NOT NULL, UNIQUE (host_key, top_frame_site_key, name, path, source_scheme, source_port))"""
+CHROME_EPOCH = 11644473600 * 1000000  # microseconds from 1601-01-01 to 1970-01-01
+# "sk-ant-sid01-desktop" encrypted as v10 with the Linux built-in key
+# ("peanuts"), as Chromium writes it without a keyring
+DESKTOP_SESSION_V10 = bytes.fromhex("7631302cd7b39594d5ed6a0a157091b9453efa05bb21c908f3320e985c6a17f485f60b")
+
+
+def claude_desktop():
+    """The Claude desktop app's cookie store under an XDG config home: no
+    profile subdirectory, one encrypted and two plain values."""
+    os.makedirs("claude-desktop/Claude/Network", exist_ok=True)
+    path = "claude-desktop/Claude/Network/Cookies"
+    if os.path.exists(path):
+        os.remove(path)
+    db = sqlite3.connect(path)
+    db.execute(CHROME_COOKIES)
+    used = USED + CHROME_EPOCH
+    expires = FAR * 1000000 + CHROME_EPOCH
+    db.executemany(
+        "INSERT INTO cookies VALUES (?, ?, '', ?, ?, ?, '/', ?, 1, 1, ?, 1, 1, 1, 0, 2, 443, ?, 0, 0)",
+        [(used, host, name, value, enc, expires, used, used) for host, name, value, enc in [
+            ("claude.ai", "sessionKey", "", DESKTOP_SESSION_V10),
+            ("claude.ai", "lastActiveOrg", "org-desktop", b""),
+            (".claude.ai", "cf_clearance", "cf-desktop", b""),
+        ]])
+    db.commit()
+    db.close()
+
+
+if __name__ == "__main__":
+    os.chdir(os.path.dirname(os.path.abspath(__file__)))
+    basic()
+    containers()
+    overflow()
+    wal()
+    large()
+    claude_desktop()
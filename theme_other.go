@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// darkMenuBar reports whether the menu bar is dark. macOS says so in
+// AppleInterfaceStyle; Linux panels have no common setting and are
+// usually dark.
+func darkMenuBar() bool {
+	if runtime.GOOS != "darwin" {
+		return true
+	}
+	out, err := exec.Command("defaults", "read", "-g", "AppleInterfaceStyle").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "Dark"
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procRegGetValueW = advapi32.NewProc("RegGetValueW")
+
+const (
+	hkeyCurrentUser = 0x80000001
+	rrfRtRegDword   = 0x00000010
+)
+
+// darkMenuBar reports whether the taskbar is dark: SystemUsesLightTheme is
+// 0, or missing as before Windows 10 1903, whose taskbar was always dark.
+func darkMenuBar() bool {
+	key, _ := syscall.UTF16PtrFromString(`Software\Microsoft\Windows\CurrentVersion\Themes\Personalize`)
+	name, _ := syscall.UTF16PtrFromString("SystemUsesLightTheme")
+	var light uint32
+	size := uint32(unsafe.Sizeof(light))
+	r, _, _ := procRegGetValueW.Call(hkeyCurrentUser, uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(name)),
+		rrfRtRegDword, 0, uintptr(unsafe.Pointer(&light)), uintptr(unsafe.Pointer(&size)))
+	return r != 0 || light == 0
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// netSettings is the subset of Config that shapes the HTTP transport.
+// A change in any field causes a fresh client to be built.
+type netSettings struct {
+	proxyURL           string
+	caCertFile         string
+	insecureSkipVerify bool
+	forceIPv4          bool
+}
+
+func netSettingsOf(cfg *Config) netSettings {
+	return netSettings{
+		proxyURL:           cfg.ProxyURL,
+		caCertFile:         cfg.CACertFile,
+		insecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		forceIPv4:          cfg.ForceIPv4,
+	}
+}
+
+// ErrNetConfig indicates that the network settings in config.json can't be
+// applied (bad proxy URL, unreadable CA certificate), as opposed to an API error.
+type ErrNetConfig struct {
+	Msg string
+}
+
+func (e *ErrNetConfig) Error() string { return e.Msg }
+
+var (
+	clientMu       sync.Mutex
+	clientSettings netSettings
+	httpClient     *http.Client
+)
+
+// httpClientFor returns the HTTP client for cfg's network settings.
+// The client (and its connection pool) is reused until those settings change.
+func httpClientFor(cfg *Config) (*http.Client, error) {
+	ns := netSettingsOf(cfg)
+
+	clientMu.Lock()
+	defer clientMu.Unlock()
+
+	if httpClient != nil && ns == clientSettings {
+		return httpClient, nil
+	}
+
+	transport, err := newTransport(ns)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		httpClient.CloseIdleConnections()
+	}
+	httpClient = &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: transport,
+	}
+	clientSettings = ns
+	return httpClient, nil
+}
+
+func newTransport(ns netSettings) (*http.Transport, error) {
+	proxy := http.ProxyFromEnvironment
+	if ns.proxyURL != "" {
+		u, err := parseProxyURL(ns.proxyURL)
+		if err != nil {
+			return nil, &ErrNetConfig{Msg: err.Error()}
+		}
+		proxy = http.ProxyURL(u)
+		apiLog.Infof("Using proxy: %s", u.Redacted())
+	}
+
+	tlsConfig, err := newTLSConfig(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialContextFor(ns.forceIPv4, net.DefaultResolver),
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        1,
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     90 * time.Second,
+	}, nil
+}
+
+// dialFallbackDelay is how long a dual-stack dial waits on IPv6 before
+// racing an IPv4 connection. Go's default is 300ms; it is set explicitly
+// because a half-broken IPv6 route must not eat the whole request timeout.
+const dialFallbackDelay = 200 * time.Millisecond
+
+// ipResolver is the part of *net.Resolver the IPv4-only dialer needs,
+// so that tests can substitute a fake.
+type ipResolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialContextFor returns the transport's dial function: Happy Eyeballs with
+// a short fallback delay, or A-record-only resolution when forceIPv4 is set.
+func dialContextFor(forceIPv4 bool, r ipResolver) dialFunc {
+	d := &net.Dialer{
+		Timeout:       10 * time.Second,
+		KeepAlive:     30 * time.Second,
+		FallbackDelay: dialFallbackDelay,
+	}
+	if !forceIPv4 {
+		return d.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialIPv4(ctx, d.DialContext, r, addr)
+	}
+}
+
+// dialIPv4 resolves the host of addr to A records only and tries each
+// address in turn. IP literals are dialed as given.
+func dialIPv4(ctx context.Context, dial dialFunc, r ipResolver, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return dial(ctx, "tcp4", addr)
+	}
+	ips, err := r.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no IPv4 address", Name: host, IsNotFound: true}
+	}
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := dial(ctx, "tcp4", net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// newTLSConfig returns nil (Go defaults) unless a custom CA certificate or
+// disabled verification is configured, e.g. behind a TLS-intercepting proxy.
+func newTLSConfig(ns netSettings) (*tls.Config, error) {
+	if ns.caCertFile == "" && !ns.insecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if ns.caCertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(ns.caCertFile)
+		if err != nil {
+			return nil, &ErrNetConfig{Msg: fmt.Sprintf("ca_cert_file: %v", err)}
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, &ErrNetConfig{Msg: fmt.Sprintf("ca_cert_file: no PEM certificates in %s", ns.caCertFile)}
+		}
+		tlsConfig.RootCAs = pool
+		apiLog.Infof("Using extra CA certificates from %s", ns.caCertFile)
+	}
+	if ns.insecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+		apiLog.Warnf("WARNING: TLS certificate verification is disabled (tls_insecure_skip_verify)")
+	}
+	return tlsConfig, nil
+}
+
+// parseProxyURL validates a proxy_url value. Supported schemes are http,
+// https and socks5 (socks5h is accepted as an alias resolving DNS remotely).
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("proxy_url is not a valid URL (expected e.g. http://host:3128)")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	case "":
+		return nil, fmt.Errorf("proxy_url must include a scheme (http://, https:// or socks5://)")
+	default:
+		return nil, fmt.Errorf("proxy_url: unsupported scheme %q (use http, https or socks5)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy_url: missing host")
+	}
+	if p := u.Port(); p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("proxy_url: invalid port %q", p)
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Presets of the tray_title config setting; any other value is a format
+// of its own.
+var trayTitlePresets = map[string]string{
+	"compact": "{s}·{w}",   // "42·12"
+	"labeled": "S{s} W{w}", // "S42 W12"
+}
+
+// maxTrayTitle caps the menu bar title, which shares the bar with every
+// other app's items.
+const maxTrayTitle = 16
+
+// trayTitleFormat returns the format the menu bar title is rendered
+// from, or "" when there is none: tray_title is unset, or the platform's
+// tray has no title next to the icon. A nil config yields "".
+func (c *Config) trayTitleFormat() string {
+	if c == nil || runtime.GOOS != "darwin" {
+		return ""
+	}
+	f := strings.TrimSpace(c.TrayTitle)
+	if p, ok := trayTitlePresets[strings.ToLower(f)]; ok {
+		return p
+	}
+	return f
+}
+
+// renderTrayTitle fills format's placeholders {s}, {w}, {o} and {n} with
+// the session, weekly, Opus and Sonnet numbers, "-" for a per-model bucket
+// that isn't there. The numbers are remaining percentages, or used ones
+// with used set, as on the icon.
+func renderTrayTitle(format string, usage *UsageResponse, opus, sonnet *UsageBucket, used bool) string {
+	if format == "" {
+		return ""
+	}
+	num := func(b *UsageBucket) string {
+		if b == nil {
+			return "-"
+		}
+		return fmt.Sprint(iconNumber(100-int(b.Utilization), used))
+	}
+	r := strings.NewReplacer("{s}", num(&usage.FiveHour), "{w}", num(&usage.SevenDay), "{o}", num(opus), "{n}", num(sonnet))
+	return truncate(r.Replace(format), maxTrayTitle)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRenderTrayTitle(t *testing.T) {
+	usage := &UsageResponse{FiveHour: UsageBucket{Utilization: 58}, SevenDay: UsageBucket{Utilization: 88}}
+	opus := &UsageBucket{Utilization: 30}
+	for _, tc := range []struct {
+		format string
+		used   bool
+		want   string
+	}{
+		{"", false, ""},
+		{trayTitlePresets["compact"], false, "42·12"},
+		{trayTitlePresets["labeled"], false, "S42 W12"},
+		{trayTitlePresets["labeled"], true, "S58 W88"},
+		{"{s}/{w}/{o}/{n}", false, "42/12/70/-"},
+		{"Session {s}% Weekly {w}%", false, "Session 42% Wee…"},
+	} {
+		if got := renderTrayTitle(tc.format, usage, opus, nil, tc.used); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestTrayTitleFormat(t *testing.T) {
+	cfg := &Config{TrayTitle: " Compact "}
+	want := ""
+	if runtime.GOOS == "darwin" {
+		want = "{s}·{w}"
+	}
+	if got := cfg.trayTitleFormat(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := (*Config)(nil).trayTitleFormat(); got != "" {
+		t.Errorf("nil config: %q", got)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"time"
+)
+
+const (
+	// trendWindow is how far back a bucket's trend is measured.
+	trendWindow = 30 * time.Minute
+	// trendMinSpan is the shortest stretch of readings a trend is taken
+	// from.
+	trendMinSpan = 5 * time.Minute
+	// trendReadings is how many readings per bucket are kept: a window's
+	// worth at the fastest adaptive polling.
+	trendReadings = 16
+	// trendMenuPoints is the change, in points over the readings, that
+	// the menu shows as rising or falling rather than steady.
+	trendMenuPoints = 1
+	// trendIconPoints is the change that puts an arrow on the icon.
+	trendIconPoints = 10
+	// trendResetPct is the utilization at or below which a fall counts as
+	// the limit resetting rather than usage falling.
+	trendResetPct = 5
+)
+
+// utilizationReading is one bucket's utilization at a point in time.
+type utilizationReading struct {
+	at          time.Time
+	utilization float64
+}
+
+// trendBuffer is a ring buffer of a bucket's latest readings.
+type trendBuffer struct {
+	readings [trendReadings]utilizationReading
+	next, n  int
+}
+
+func (b *trendBuffer) add(r utilizationReading) {
+	b.readings[b.next] = r
+	b.next = (b.next + 1) % trendReadings
+	b.n = min(b.n+1, trendReadings)
+}
+
+// list returns the readings, oldest first.
+func (b *trendBuffer) list() []utilizationReading {
+	out := make([]utilizationReading, 0, b.n)
+	for i := b.n; i > 0; i-- {
+		out = append(out, b.readings[(b.next-i+trendReadings)%trendReadings])
+	}
+	return out
+}
+
+// bucketTrend is how much a bucket's utilization changed lately, in
+// points; known is false without enough readings to tell.
+type bucketTrend struct {
+	delta float64
+	known bool
+}
+
+// measureTrend takes the trend from readings (oldest first) over the
+// trendWindow before the latest one. A fall to trendResetPct or below is
+// the limit resetting: only the readings from then on count, so a reset
+// doesn't show as usage falling.
+func measureTrend(readings []utilizationReading) bucketTrend {
+	if len(readings) == 0 {
+		return bucketTrend{}
+	}
+	last := readings[len(readings)-1]
+	first := last
+	for i := len(readings) - 2; i >= 0; i-- {
+		r := readings[i]
+		if last.at.Sub(r.at) > trendWindow {
+			break
+		}
+		if first.utilization <= trendResetPct && r.utilization > first.utilization {
+			break
+		}
+		first = r
+	}
+	if last.at.Sub(first.at) < trendMinSpan {
+		return bucketTrend{}
+	}
+	return bucketTrend{delta: last.utilization - first.utilization, known: true}
+}
+
+// glyph is the trend mark after a row's percentage: ▲ rising, ▼ falling
+// or ► steady, in words when accessible; "" when unknown.
+func (t bucketTrend) glyph(accessible bool) string {
+	if !t.known {
+		return ""
+	}
+	glyphs := [...]string{"►", "▲", "▼"}
+	words := [...]string{"steady", "rising", "falling"}
+	i := 0
+	switch {
+	case t.delta >= trendMenuPoints:
+		i = 1
+	case t.delta <= -trendMenuPoints:
+		i = 2
+	}
+	if accessible {
+		return words[i]
+	}
+	return glyphs[i]
+}
+
+// arrow is the icon arrow for t: none unless it changed by at least
+// trendIconPoints.
+func (t bucketTrend) arrow() iconArrow {
+	switch {
+	case !t.known:
+		return arrowNone
+	case t.delta >= trendIconPoints:
+		return arrowUp
+	case t.delta <= -trendIconPoints:
+		return arrowDown
+	}
+	return arrowNone
+}
+
+// usageTrends keeps the latest readings of the primary account's buckets,
+// for the trend marks in the menu and on the icon.
+type usageTrends struct {
+	mu                            sync.Mutex
+	session, weekly, opus, sonnet trendBuffer
+}
+
+var recentTrends usageTrends
+
+// bucketTrends are the trends of session, weekly, opus and sonnet, in the
+// order of uiState.lines.
+type bucketTrends [4]bucketTrend
+
+// observe adds a reading taken at now and returns the trends. opus and
+// sonnet are the per-model buckets as shown, after bucketPresence: one
+// that was removed starts over when it comes back, and one missing from
+// usage while its removal isn't confirmed adds no reading, rather than
+// repeat the last one shown.
+func (t *usageTrends) observe(usage *UsageResponse, opus, sonnet *UsageBucket, now time.Time) bucketTrends {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out bucketTrends
+	for i, row := range []struct {
+		buf *trendBuffer
+		b   *UsageBucket
+		raw *UsageBucket
+	}{
+		{&t.session, &usage.FiveHour, &usage.FiveHour},
+		{&t.weekly, &usage.SevenDay, &usage.SevenDay},
+		{&t.opus, opus, usage.SevenDayOpus},
+		{&t.sonnet, sonnet, usage.SevenDaySonnet},
+	} {
+		if row.b == nil {
+			*row.buf = trendBuffer{}
+			continue
+		}
+		if row.raw != nil {
+			row.buf.add(utilizationReading{at: now, utilization: row.b.Utilization})
+		}
+		out[i] = measureTrend(row.buf.list())
+	}
+	return out
+}
+
+// withArrows adds the arrows of the buckets key shows to a usage icon:
+// session and weekly on the split icon, the letter's bucket on a compact
+// one.
+func (ts bucketTrends) withArrows(key iconKey) iconKey {
+	if key.letter == "" {
+		key.arrowA, key.arrowB = ts[0].arrow(), ts[1].arrow()
+		return key
+	}
+	for i, letter := range []string{"S", "W", "O", "N"} {
+		if key.letter == letter {
+			key.arrowA = ts[i].arrow()
+		}
+	}
+	return key
+}
+
+// iconArrow is a trend arrow drawn in a corner of the icon.
+type iconArrow int8
+
+const (
+	arrowNone iconArrow = iota
+	arrowUp
+	arrowDown
+)
+
+// drawArrow draws a small triangle pointing up or down with its top-left
+// corner at (x, y): three rows of 1, 3 and 5 font pixels at scale.
+func drawArrow(img *image.RGBA, a iconArrow, x, y, scale int, c color.RGBA) {
+	if a == arrowNone {
+		return
+	}
+	for row := 0; row < 3; row++ {
+		w := row
+		if a == arrowDown {
+			w = 2 - row
+		}
+		r := image.Rect(x+(2-w)*scale, y+row*scale, x+(3+w)*scale, y+(row+1)*scale)
+		fillOver(img, r, c)
+	}
+}
+
+// drawArrows puts a usage icon's arrows in its top corners: the split
+// icon's session arrow top left and weekly arrow top right, a compact
+// icon's arrow top right, clear of its letter. Icons under 20 pixels have
+// no room for them.
+func drawArrows(img *image.RGBA, k iconKey) {
+	size := img.Rect.Dx()
+	if size < 20 || (k.arrowA == arrowNone && k.arrowB == arrowNone) {
+		return
+	}
+	scale := fontScale
+	if size < smallIcon {
+		scale = 1
+	}
+	c := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	if k.palette != paletteColor {
+		c = k.palette.ink()
+	}
+	right := size - 5*scale - 2
+	if k.letter != "" {
+		drawArrow(img, k.arrowA, right, 2, scale, c)
+		return
+	}
+	drawArrow(img, k.arrowA, 2, 2, scale, c)
+	drawArrow(img, k.arrowB, right, 2, scale, c)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestMeasureTrend(t *testing.T) {
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	seq := func(step time.Duration, pcts ...float64) []utilizationReading {
+		var out []utilizationReading
+		for i, p := range pcts {
+			out = append(out, utilizationReading{at: now.Add(-time.Duration(len(pcts)-1-i) * step), utilization: p})
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name     string
+		readings []utilizationReading
+		want     bucketTrend
+	}{
+		{"rising", seq(5*time.Minute, 45, 48, 52, 60), bucketTrend{delta: 15, known: true}},
+		{"steady", seq(5*time.Minute, 40, 40, 40.5), bucketTrend{delta: 0.5, known: true}},
+		{"falling", seq(5*time.Minute, 30, 25, 20), bucketTrend{delta: -10, known: true}},
+		// Only the last 30 minutes count
+		{"window", seq(10*time.Minute, 10, 20, 30, 40, 50), bucketTrend{delta: 30, known: true}},
+		// A drop to about zero is the reset, not usage falling: only the
+		// readings since count
+		{"reset", seq(5*time.Minute, 70, 85, 2, 6, 12), bucketTrend{delta: 10, known: true}},
+		{"just reset", seq(5*time.Minute, 85, 90, 0), bucketTrend{}},
+		{"one reading", seq(5*time.Minute, 50), bucketTrend{}},
+		{"too short", seq(time.Minute, 50, 60), bucketTrend{}},
+		{"none", nil, bucketTrend{}},
+	} {
+		if got := measureTrend(tc.readings); got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTrendBuffer(t *testing.T) {
+	var b trendBuffer
+	start := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < trendReadings+3; i++ {
+		b.add(utilizationReading{at: start.Add(time.Duration(i) * time.Minute), utilization: float64(i)})
+	}
+	got := b.list()
+	if len(got) != trendReadings {
+		t.Fatalf("%d readings, want %d", len(got), trendReadings)
+	}
+	for i, r := range got {
+		if want := float64(i + 3); r.utilization != want {
+			t.Errorf("reading %d is %v, want %v", i, r.utilization, want)
+		}
+	}
+}
+
+func TestTrendBucketPresence(t *testing.T) {
+	var trends usageTrends
+	presence := bucketPresence{name: "Sonnet"}
+	start := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	poll := 0
+	observe := func(pct float64) bucketTrend {
+		u := &UsageResponse{}
+		if pct >= 0 {
+			u.SevenDaySonnet = &UsageBucket{Utilization: pct}
+		}
+		sonnet, _ := presence.observe(u.SevenDaySonnet)
+		poll++
+		return trends.observe(u, nil, sonnet, start.Add(time.Duration(poll)*5*time.Minute))[3]
+	}
+	for _, pct := range []float64{10, 12, 14} {
+		observe(pct)
+	}
+	// Missing while the removal isn't confirmed: the last value is shown,
+	// but not taken for a reading
+	observe(-1)
+	observe(-1)
+	if n := trends.sonnet.n; n != 3 {
+		t.Errorf("%d readings after the gap, want 3", n)
+	}
+	// Confirmed: the series ends, and starts over once it is back, at 32
+	if tr := observe(-1); tr.known || trends.sonnet.n != 0 {
+		t.Errorf("trend after the removal: %+v, %d readings", tr, trends.sonnet.n)
+	}
+	for _, pct := range []float64{30, 31, 32, 33} {
+		observe(pct)
+	}
+	if tr := observe(34); !tr.known || tr.delta != 2 {
+		t.Errorf("trend after coming back: %+v", tr)
+	}
+}
+
+func TestTrendGlyph(t *testing.T) {
+	for _, tc := range []struct {
+		trend      bucketTrend
+		glyph      string
+		accessible string
+		arrow      iconArrow
+	}{
+		{bucketTrend{}, "", "", arrowNone},
+		{bucketTrend{delta: 0.5, known: true}, "►", "steady", arrowNone},
+		{bucketTrend{delta: 3, known: true}, "▲", "rising", arrowNone},
+		{bucketTrend{delta: 15, known: true}, "▲", "rising", arrowUp},
+		{bucketTrend{delta: -12, known: true}, "▼", "falling", arrowDown},
+	} {
+		if got := tc.trend.glyph(false); got != tc.glyph {
+			t.Errorf("%+v: glyph %q, want %q", tc.trend, got, tc.glyph)
+		}
+		if got := tc.trend.glyph(true); got != tc.accessible {
+			t.Errorf("%+v: words %q, want %q", tc.trend, got, tc.accessible)
+		}
+		if got := tc.trend.arrow(); got != tc.arrow {
+			t.Errorf("%+v: arrow %v, want %v", tc.trend, got, tc.arrow)
+		}
+	}
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+	l := newBucketLine("Session (5h)", &UsageBucket{Utilization: 60, ResetsAt: now.Add(2 * time.Hour).Format(time.RFC3339)}, bucketLineOpts{})
+	l.trend = bucketTrend{delta: 15, known: true}
+	if got, want := l.render(), "Session (5h): 60% ▲ — resets in 2h 0m"; got != want {
+		t.Errorf("row = %q, want %q", got, want)
+	}
+}
+
+func TestTrendArrows(t *testing.T) {
+	ts := bucketTrends{{delta: 15, known: true}, {delta: -12, known: true}, {}, {delta: 20, known: true}}
+	if got := ts.withArrows(iconKey{a: 40, b: 60, c: 60}); got.arrowA != arrowUp || got.arrowB != arrowDown {
+		t.Errorf("split icon arrows: %v %v", got.arrowA, got.arrowB)
+	}
+	if got := ts.withArrows(iconKey{letter: "N", a: 40}); got.arrowA != arrowUp || got.arrowB != arrowNone {
+		t.Errorf("compact Sonnet icon arrows: %v %v", got.arrowA, got.arrowB)
+	}
+
+	// The session arrow sits in the top-left corner, its tip one font
+	// pixel wide; the icon without arrows has the background there
+	plain := iconKey{a: 40, b: 60, c: 60}
+	arrows := plain
+	arrows.arrowA = arrowUp
+	tip := arrows.render(iconSize).RGBAAt(2+2*fontScale, 2)
+	if white := (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}); tip != white {
+		t.Errorf("arrow tip is %v, want white", tip)
+	}
+	if got := plain.render(iconSize).RGBAAt(2+2*fontScale, 2); got != levelColor(40) {
+		t.Errorf("icon without arrows has %v in the corner", got)
+	}
+	if n := diffPixels(arrows.render(16), plain.render(16)); n > 0 {
+		t.Errorf("16 px icon got arrows: %d pixels differ", n)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getlantern/systray"
+)
+
+// uiState is a complete snapshot of the usage display: icon, tooltip and
+// the rows that carry data. doUpdate computes one per refresh and publishes
+// it; only the UI goroutine (uiUpdater.run) turns snapshots into systray
+// calls, so overlapping updates can't mix an icon from one fetch with menu
+// text from another.
+type uiState struct {
+	icon         []byte
+	iconTemplate bool // icon is a macOS template image
+	tooltip      string
+	title        string // next to the icon in the macOS menu bar; see tray_title
+
+	// lastUsage is the usage icon last shown and whether it was a
+	// template, for an API error to show it dimmed; nil before any usage
+	// is known.
+	lastUsage         *iconKey
+	lastUsageTemplate bool
+	// critical is set while icon is the usage icon and a bucket is in the
+	// critical band, with critical_blink on; see withAlert.
+	critical bool
+
+	session, weekly, opus, sonnet string
+	extra                         string // empty hides the row
+	spending                      spendingView
+	history                       historyView
+	pace                          paceView
+	accounts                      []accountView // fewer than two hides them
+	orgWarning                    string        // empty hides the row
+	sessionExpiry                 string        // empty hides the row
+	cloudflare                    string        // Diagnostics ▸ Cloudflare
+	lastError                     string        // empty hides the row
+	// cloudflareInfo is what cloudflare goes by, for the metrics and the
+	// status document.
+	cloudflareInfo cloudflareInfo
+	// bucketChanges are the presence changes the reading behind the rows
+	// confirmed, for the history.
+	bucketChanges []bucketChange
+
+	// lines render session, weekly, opus and sonnet again on refresh, so
+	// their reset countdowns stay right between updates. A nil line leaves
+	// its row as it is, e.g. an error in the session row.
+	lines [4]*bucketLine
+
+	// progress is set while an update runs: refreshingText, or the retry
+	// it is waiting for. Empty restores the Refresh item.
+	progress string
+}
+
+const refreshingText = "Refreshing…"
+
+// setUsageIcon shows the usage icon key and remembers it as the last one.
+func (st *uiState) setUsageIcon(key iconKey, template bool) {
+	st.icon, st.iconTemplate = key.icon(), template
+	st.lastUsage, st.lastUsageTemplate = &key, template
+	st.critical = false
+}
+
+// setStatusIcon shows the icon for status, an API error on top of the last
+// usage icon, and clears the menu bar title: its numbers may be wrong now.
+func (st *uiState) setStatusIcon(status iconStatus) {
+	st.icon, st.title = makeStatusIcon(status, st.lastUsage), ""
+	st.iconTemplate = status == statusAPIError && st.lastUsage != nil && st.lastUsageTemplate
+	st.critical = false
+}
+
+// showRetryProgress puts "Refreshing… (attempt 2/4, retrying in 30s)" in
+// the tooltip while fetchUsage waits to retry.
+func showRetryProgress(st *uiState, p retryProgress, accessible bool) {
+	retry := shortDuration(p.RetryIn)
+	if accessible {
+		retry = plural(int(p.RetryIn.Seconds()), "second")
+	}
+	st.progress = fmt.Sprintf("%s (attempt %d/%d, retrying in %s)", refreshingText, p.Attempt, p.Total, retry)
+	st.tooltip = appName + ": " + st.progress
+}
+
+// publishProgress returns a fetchUsage progress callback that shows each
+// retry on top of the state on screen, as part of update gen.
+func publishProgress(u *uiUpdater, gen uint64) func(retryProgress) {
+	return func(p retryProgress) {
+		st := u.state()
+		showRetryProgress(&st, p, accessibleText.Load())
+		u.publish(gen, st)
+	}
+}
+
+// updateView is what doUpdate shows its results in besides the snapshots
+// it publishes: the tray's usageMenu, or headlessView without a tray.
+type updateView interface {
+	// apply shows a snapshot along with the one shown before; called on
+	// the UI goroutine only.
+	apply(prev, st uiState)
+	// setOrgs lists the session's organizations in the picker, with
+	// current checked.
+	setOrgs(orgs []Organization, current string)
+	// accountViews renders the per-account submenus. It tracks bucket
+	// presence per account, so it must be called once per update.
+	accountViews(accounts []*Config, results []accountResult) []accountView
+}
+
+func (m *usageMenu) setOrgs(orgs []Organization, current string) {
+	m.orgs.set(orgs, current)
+}
+
+func (m *usageMenu) accountViews(accounts []*Config, results []accountResult) []accountView {
+	return m.accounts.views(accounts, results)
+}
+
+type uiSnapshot struct {
+	gen   uint64
+	state uiState
+}
+
+// uiUpdater owns the usage display. Snapshots carry a generation number
+// taken when their update started; one older than the snapshot already on
+// screen is dropped.
+type uiUpdater struct {
+	mu      sync.Mutex
+	current uiState
+	applied uint64
+
+	gen       atomic.Uint64
+	snapshots chan uiSnapshot
+	ticks     chan struct{} // see refresh
+	blinks    chan struct{} // see blinkTicker
+
+	callMu sync.Mutex
+	calls  []func()      // see do
+	wake   chan struct{} // a call is queued
+
+	quit     chan struct{} // closed by stop
+	stopped  chan struct{} // closed when run has returned
+	stopOnce sync.Once
+}
+
+var ui = newUIUpdater()
+
+func newUIUpdater() *uiUpdater {
+	return &uiUpdater{
+		snapshots: make(chan uiSnapshot, 16),
+		ticks:     make(chan struct{}, 1),
+		blinks:    make(chan struct{}, 1),
+		wake:      make(chan struct{}, 1),
+		quit:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// nextGeneration tags a snapshot that is about to be computed.
+func (u *uiUpdater) nextGeneration() uint64 {
+	return u.gen.Add(1)
+}
+
+// state returns a copy of the state on screen, as the base for the next
+// snapshot: a failed update only replaces some of the rows.
+func (u *uiUpdater) state() uiState {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	st := u.current
+	st.accounts = append([]accountView(nil), u.current.accounts...)
+	return st
+}
+
+// publish queues st for display.
+func (u *uiUpdater) publish(gen uint64, st uiState) {
+	select {
+	case u.snapshots <- uiSnapshot{gen: gen, state: st}:
+	case <-u.quit: // shutting down; the tray may already be gone
+	}
+}
+
+// do runs fn on the UI goroutine, in the order called: the menu's own
+// changes outside the usage display, such as a mark on the item clicked
+// or a checkmark moved, go through here so no systray call races apply.
+// It never blocks; calls queued before run starts wait for it, and those
+// after stop are dropped.
+func (u *uiUpdater) do(fn func()) {
+	u.callMu.Lock()
+	u.calls = append(u.calls, fn)
+	u.callMu.Unlock()
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// doAfter runs fn on the UI goroutine after d, e.g. to put back a title
+// that showed the result of a click. Stopping the timer cancels it.
+func (u *uiUpdater) doAfter(d time.Duration, fn func()) *time.Timer {
+	return time.AfterFunc(d, func() { u.do(fn) })
+}
+
+// showTitle sets item's title through do, for code outside apply.
+func showTitle(item *systray.MenuItem, title string) {
+	ui.do(func() { item.SetTitle(title) })
+}
+
+// checkOnly checks items[i] and unchecks the others, through do.
+func checkOnly(items []*systray.MenuItem, i int) {
+	ui.do(func() {
+		for j, item := range items {
+			if j == i {
+				item.Check()
+			} else {
+				item.Uncheck()
+			}
+		}
+	})
+}
+
+// runCalls runs the calls queued by do.
+func (u *uiUpdater) runCalls() {
+	u.callMu.Lock()
+	calls := u.calls
+	u.calls = nil
+	u.callMu.Unlock()
+	for _, fn := range calls {
+		fn()
+	}
+}
+
+// fillDiskLines reads what the Cloudflare diagnostics line and the
+// sessionKey expiry warning show from disk; once per update, since the
+// snapshots published in between carry them over.
+func (st *uiState) fillDiskLines() {
+	st.cloudflare, st.cloudflareInfo = cloudflareLine()
+	st.sessionExpiry = sessionExpiryLine()
+}
+
+// renderLines renders the bucket rows that have a line again.
+func (st *uiState) renderLines() {
+	rows := [...]*string{&st.session, &st.weekly, &st.opus, &st.sonnet}
+	for i, l := range st.lines {
+		if l != nil {
+			*rows[i] = l.render()
+		}
+	}
+}
+
+// refresh shows the state on screen again, with the rows that render the
+// time since or until something brought up to date. It never blocks; a
+// refresh already pending covers this one.
+func (u *uiUpdater) refresh() {
+	select {
+	case u.ticks <- struct{}{}:
+	default:
+	}
+}
+
+// stop makes run return and waits until it has, so no systray call is in
+// progress afterwards. Snapshots published later are discarded.
+func (u *uiUpdater) stop() {
+	u.stopOnce.Do(func() { close(u.quit) })
+	<-u.stopped
+}
+
+// run passes snapshots to apply as they arrive, along with the one shown
+// before, and the one on screen again on refresh, and runs the calls queued
+// by do. In the tray apply is usageMenu.apply, the only code that changes
+// the usage icon, tooltip and rows. While a critical alert is on, the icon
+// is swapped as withAlert says on the way, blinking on blinkTicker's ticks,
+// so blinking and updates take turns on this goroutine.
+func (u *uiUpdater) run(apply func(prev, st uiState)) {
+	defer close(u.stopped)
+	blinkDone := make(chan struct{})
+	go u.blinkTicker(blinkDone)
+	defer func() { <-blinkDone }()
+	// Deferred last so it runs first: the ticker only stops on quit
+	defer recoverCrash("UI", nil)
+
+	var shown uiState
+	blinkOn := false
+	show := func(st uiState) {
+		st = st.withAlert(criticalAlert.blinking(time.Now()), blinkOn)
+		apply(shown, st)
+		shown = st
+	}
+	for {
+		var snap uiSnapshot
+		select {
+		case <-u.quit:
+			return
+		case <-u.ticks:
+			u.mu.Lock()
+			u.current.renderLines()
+			st := u.current
+			u.mu.Unlock()
+			show(st)
+			continue
+		case <-u.blinks:
+			blinkOn = !blinkOn
+			show(u.state())
+			continue
+		case <-u.wake:
+			u.runCalls()
+			continue
+		case snap = <-u.snapshots:
+		}
+
+		u.mu.Lock()
+		if snap.gen < u.applied {
+			u.mu.Unlock()
+			uiLog.Infof("Dropping UI snapshot %d, %d is already shown", snap.gen, u.applied)
+			continue
+		}
+		u.applied, u.current = snap.gen, snap.state
+		u.mu.Unlock()
+
+		show(snap.state)
+	}
+}
+
+// apply makes the menu show st. Empty row texts leave the row as it is.
+func (m *usageMenu) apply(prev, st uiState) {
+	if st.icon != nil && (!bytes.Equal(prev.icon, st.icon) || prev.iconTemplate != st.iconTemplate) {
+		if st.iconTemplate {
+			systray.SetTemplateIcon(st.icon, st.icon)
+		} else {
+			systray.SetIcon(st.icon)
+		}
+	}
+	if st.tooltip != "" && st.tooltip != prev.tooltip {
+		systray.SetTooltip(st.tooltip)
+	}
+	if st.title != prev.title {
+		systray.SetTitle(st.title)
+	}
+
+	for _, row := range []struct {
+		item *systray.MenuItem
+		text string
+	}{
+		{m.session, st.session},
+		{m.weekly, st.weekly},
+		{m.opus, st.opus},
+		{m.sonnet, st.sonnet},
+		{m.cloudflare, st.cloudflare},
+	} {
+		if row.text != "" {
+			setTitle(row.item, row.text)
+		}
+	}
+
+	if st.progress == "" && prev.progress != "" {
+		m.refresh.SetTitle("Refresh now")
+	} else if st.progress != "" && prev.progress == "" {
+		m.refresh.SetTitle(refreshingText)
+	}
+
+	showRow(m.sessionPace, st.pace.session)
+	showRow(m.weeklyPace, st.pace.weekly)
+	showRow(m.extra, st.extra)
+	showRow(m.orgWarning, st.orgWarning)
+	showRow(m.sessionExpiry, st.sessionExpiry)
+	updated, next := timeRows(time.Now(), accessibleText.Load())
+	showRow(m.updated, updated)
+	showRow(m.nextCheck, next)
+	showRow(m.lastError, st.lastError)
+	m.spending.apply(st.spending)
+	m.history.apply(st.history)
+	m.accounts.apply(st.accounts)
+	updateStatusWindow(st)
+}
+
+// showRow sets item's title and shows it, or hides it when text is empty.
+func showRow(item *systray.MenuItem, text string) {
+	if text == "" {
+		setShown(item, false)
+		return
+	}
+	setTitle(item, text)
+	setShown(item, true)
+}
+
+// menuItemState is what setTitle and setShown last did to a menu item.
+type menuItemState struct {
+	title         string
+	titled        bool
+	shown, showed bool // showed: shown is known
+}
+
+// appliedMenu lets apply leave alone the items that wouldn't change: each
+// SetTitle, Show or Hide makes systray rebuild the native menu item, which
+// flickers on Windows. Only the UI goroutine uses it, and only for items
+// that apply alone changes.
+var appliedMenu = map[*systray.MenuItem]*menuItemState{}
+
+func menuState(item *systray.MenuItem) *menuItemState {
+	ms := appliedMenu[item]
+	if ms == nil {
+		ms = &menuItemState{}
+		appliedMenu[item] = ms
+	}
+	return ms
+}
+
+// setTitle sets item's title unless it already has it.
+func setTitle(item *systray.MenuItem, title string) {
+	ms := menuState(item)
+	if ms.titled && ms.title == title {
+		return
+	}
+	item.SetTitle(title)
+	ms.title, ms.titled = title, true
+}
+
+// setShown shows or hides item unless it already is.
+func setShown(item *systray.MenuItem, shown bool) {
+	ms := menuState(item)
+	if ms.showed && ms.shown == shown {
+		return
+	}
+	if shown {
+		item.Show()
+	} else {
+		item.Hide()
+	}
+	ms.shown, ms.showed = shown, true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUIDo(t *testing.T) {
+	u := newUIUpdater()
+	ran := make(chan int, 8)
+	// Queued before run starts, as health.attach does while the menu is
+	// built
+	u.do(func() { ran <- 1 })
+	u.do(func() { ran <- 2 })
+
+	go u.run(func(prev, st uiState) {})
+	u.do(func() { ran <- 3 })
+	u.doAfter(10*time.Millisecond, func() { ran <- 4 })
+
+	var got []int
+	for len(got) < 4 {
+		select {
+		case n := <-ran:
+			got = append(got, n)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("ran %v", got)
+		}
+	}
+	if !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("ran %v, want in order", got)
+	}
+
+	u.stop()
+	u.do(func() { ran <- 5 })
+	time.Sleep(20 * time.Millisecond)
+	if len(ran) != 0 {
+		t.Error("a call ran after stop")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// usageAlertState is the reading the usage notifications were last checked
+// against, so that only upward crossings notify: a reading that stays
+// above a threshold, or falls after a reset and crosses it again later, is
+// compared with the one before. Resets are told apart by the reset times.
+type usageAlertState struct {
+	Session float64 `json:"session"`
+	Weekly  float64 `json:"weekly"`
+
+	SessionResetsAt string `json:"session_resets_at,omitempty"`
+	WeeklyResetsAt  string `json:"weekly_resets_at,omitempty"`
+}
+
+const (
+	// resetNotifyFloor is the utilization a bucket must have reached for
+	// its reset to be worth a notification.
+	resetNotifyFloor = 50
+	// resetMinDrop is how far utilization must fall, in points, to count
+	// as a reset rather than the API's numbers wobbling.
+	resetMinDrop = 25
+)
+
+// bucketReset reports whether a bucket went from prev (resetting at
+// prevResets) to cur (resetting at curResets) by a reset of its window:
+// utilization fell sharply from at least resetNotifyFloor, and the reset
+// time moved forward or the old one has passed. An idle bucket may have
+// no reset time at all.
+func bucketReset(prev float64, prevResets string, cur float64, curResets string) bool {
+	if prev < resetNotifyFloor || prev-cur < resetMinDrop {
+		return false
+	}
+	left, ok := resetDuration(prevResets)
+	if !ok {
+		return false
+	}
+	if left <= 0 {
+		return true
+	}
+	next, ok := resetDuration(curResets)
+	return ok && next > left
+}
+
+// crossedThreshold returns the highest of thresholds that utilization went
+// up to or past since prev, and whether there was one.
+func crossedThreshold(thresholds []float64, prev, cur float64) (float64, bool) {
+	sorted := append([]float64(nil), thresholds...)
+	sort.Float64s(sorted)
+	var crossed float64
+	ok := false
+	for _, t := range sorted {
+		if t > 0 && prev < t && cur >= t {
+			crossed, ok = t, true
+		}
+	}
+	return crossed, ok
+}
+
+// notifySessionReset reports whether a session window reset notifies. A
+// nil config yields the default (on).
+func (c *Config) notifySessionReset() bool {
+	return c == nil || c.NotifySessionReset == nil || *c.NotifySessionReset
+}
+
+// notifyWeeklyReset reports whether a weekly window reset notifies. A nil
+// config yields the default (on).
+func (c *Config) notifyWeeklyReset() bool {
+	return c == nil || c.NotifyWeeklyReset == nil || *c.NotifyWeeklyReset
+}
+
+// checkUsageAlerts compares usage with the previous reading and returns a
+// notification for each of the session and weekly buckets that crossed
+// one of its notify_session_at / notify_weekly_at thresholds or whose
+// limit reset, each with its event for the integrations. A reset with its
+// notification turned off still raises the event.
+func checkUsageAlerts(cfg *Config, usage *UsageResponse) []notification {
+	if cfg == nil || usage == nil {
+		return nil
+	}
+	if len(cfg.NotifySessionAt) == 0 && len(cfg.NotifyWeeklyAt) == 0 && !cfg.notifySessionReset() && !cfg.notifyWeeklyReset() && len(eventSinks(cfg)) == 0 {
+		return nil
+	}
+	now := timeNow()
+	var fired []notification
+	err := updateState(statePath(), func(st *appState) {
+		var prev usageAlertState
+		if st.UsageAlerts != nil {
+			prev = *st.UsageAlerts
+		}
+		crossing := func(bucket string, b *UsageBucket, t float64) *alertEvent {
+			ev := bucketEvent(eventThresholdCrossed, bucket, b, now)
+			ev.Threshold = t
+			return &ev
+		}
+		reset := func(bucket string, b *UsageBucket) *alertEvent {
+			ev := bucketEvent(eventLimitReset, bucket, b, now)
+			return &ev
+		}
+		if t, ok := crossedThreshold(cfg.NotifySessionAt, prev.Session, usage.FiveHour.Utilization); ok {
+			fired = append(fired, notification{
+				title: fmt.Sprintf("Session usage at %d%%", int(usage.FiveHour.Utilization)),
+				body:  fmt.Sprintf("Passed %g%% of the 5-hour limit; it resets %s", t, formatReset(resetTime(usage.FiveHour.ResetsAt))),
+				event: crossing("session", &usage.FiveHour, t),
+			})
+		}
+		if t, ok := crossedThreshold(cfg.NotifyWeeklyAt, prev.Weekly, usage.SevenDay.Utilization); ok {
+			fired = append(fired, notification{
+				title: fmt.Sprintf("Weekly usage at %d%%", int(usage.SevenDay.Utilization)),
+				body:  fmt.Sprintf("Passed %g%% of the weekly limit; it resets %s", t, formatReset(resetTime(usage.SevenDay.ResetsAt))),
+				event: crossing("weekly", &usage.SevenDay, t),
+			})
+		}
+		if bucketReset(prev.Session, prev.SessionResetsAt, usage.FiveHour.Utilization, usage.FiveHour.ResetsAt) {
+			n := notification{event: reset("session", &usage.FiveHour)}
+			if cfg.notifySessionReset() {
+				n.title = "Session limit reset"
+				n.body = fmt.Sprintf("You're good to go: session usage is back to %d%%", int(usage.FiveHour.Utilization))
+			}
+			fired = append(fired, n)
+		}
+		if bucketReset(prev.Weekly, prev.WeeklyResetsAt, usage.SevenDay.Utilization, usage.SevenDay.ResetsAt) {
+			n := notification{event: reset("weekly", &usage.SevenDay)}
+			if cfg.notifyWeeklyReset() {
+				n.title = "Weekly limit reset"
+				n.body = fmt.Sprintf("You're good to go: weekly usage is back to %d%%", int(usage.SevenDay.Utilization))
+			}
+			fired = append(fired, n)
+		}
+		st.UsageAlerts = &usageAlertState{
+			Session:         usage.FiveHour.Utilization,
+			Weekly:          usage.SevenDay.Utilization,
+			SessionResetsAt: usage.FiveHour.ResetsAt,
+			WeeklyResetsAt:  usage.SevenDay.ResetsAt,
+		}
+	})
+	if err != nil {
+		uiLog.Warnf("Failed to save usage alerts: %v", err)
+		return nil
+	}
+	return fired
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrossedThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		prev, cur float64
+		want      float64
+		ok        bool
+	}{
+		{70, 85, 80, true},
+		{70, 96, 95, true}, // past both: only the highest
+		{85, 90, 0, false}, // still above 80, below 95
+		{95, 99, 0, false},
+		{96, 10, 0, false}, // reset
+		{10, 80, 80, true},
+	} {
+		got, ok := crossedThreshold([]float64{95, 80}, tt.prev, tt.cur)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%g -> %g: got %g, %v", tt.prev, tt.cur, got, ok)
+		}
+	}
+}
+
+func TestCheckUsageAlerts(t *testing.T) {
+	saved := paths
+	t.Cleanup(func() { paths = saved })
+	paths = appPaths{configDir: t.TempDir(), stateDir: t.TempDir()}
+
+	cfg := &Config{NotifySessionAt: []float64{80, 95}, NotifyWeeklyAt: []float64{90}}
+	reading := func(session, weekly float64) *UsageResponse {
+		return &UsageResponse{FiveHour: UsageBucket{Utilization: session}, SevenDay: UsageBucket{Utilization: weekly}}
+	}
+	var titles []string
+	for _, u := range []*UsageResponse{reading(50, 50), reading(82, 60), reading(84, 60), reading(97, 91), reading(5, 92), reading(81, 93)} {
+		for _, n := range checkUsageAlerts(cfg, u) {
+			titles = append(titles, n.title)
+		}
+	}
+	want := "Session usage at 82%, Session usage at 97%, Weekly usage at 91%, Session usage at 81%"
+	if got := strings.Join(titles, ", "); got != want {
+		t.Errorf("notifications: %s\nwant: %s", got, want)
+	}
+
+	off := false
+	quiet := &Config{NotifySessionReset: &off, NotifyWeeklyReset: &off}
+	if notes := checkUsageAlerts(quiet, reading(99, 99)); notes != nil {
+		t.Errorf("with all notifications off: %v", notes)
+	}
+
+	// A reset: the old window's end has passed and usage is back down
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	before := reading(90, 95)
+	before.FiveHour.ResetsAt, before.SevenDay.ResetsAt = past, past
+	checkUsageAlerts(&Config{}, before)
+	after := reading(3, 4)
+	titles = nil
+	var events []string
+	for _, n := range checkUsageAlerts(&Config{NotifyWeeklyReset: &off}, after) {
+		if n.title != "" {
+			titles = append(titles, n.title)
+		}
+		if n.event != nil {
+			events = append(events, n.event.Bucket+" "+n.event.Event)
+		}
+	}
+	if got := strings.Join(titles, ", "); got != "Session limit reset" {
+		t.Errorf("after a reset: %s", got)
+	}
+	// The weekly notification is off, its event isn't
+	if got, want := strings.Join(events, ", "), "session limit_reset, weekly limit_reset"; got != want {
+		t.Errorf("reset events: %s, want %s", got, want)
+	}
+}
+
+func TestBucketReset(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	saved := timeNow
+	t.Cleanup(func() { timeNow = saved })
+	timeNow = func() time.Time { return now }
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+
+	for _, tt := range []struct {
+		name       string
+		prev       float64
+		prevResets string
+		cur        float64
+		curResets  string
+		want       bool
+	}{
+		{"window reset", 90, at(10 * time.Minute), 2, at(5 * time.Hour), true},
+		{"idle after the old reset", 70, at(-time.Hour), 0, "", true},
+		{"below the floor", 40, at(10 * time.Minute), 0, at(5 * time.Hour), false},
+		{"small dip", 90, at(10 * time.Minute), 80, at(5 * time.Hour), false},
+		{"same window", 90, at(10 * time.Minute), 10, at(10 * time.Minute), false},
+		{"no reset time", 90, "", 0, "", false},
+	} {
+		if got := bucketReset(tt.prev, tt.prevResets, tt.cur, tt.curResets); got != tt.want {
+			t.Errorf("%s: bucketReset = %v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookTimeout bounds each webhook request; webhookRetryDelay is the
+// pause before the one retry.
+var (
+	webhookTimeout    = 10 * time.Second
+	webhookRetryDelay = 5 * time.Second
+)
+
+// Webhook is an entry of the webhooks config array: a URL events are
+// POSTed to as JSON.
+type Webhook struct {
+	URL string `json:"url"`
+	// Events limits the webhook to these events; empty takes them all.
+	Events []string `json:"events,omitempty"`
+	// Headers are added to each request, e.g. an Authorization header.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// validateWebhooks checks the webhooks array of config.json.
+func validateWebhooks(hooks []Webhook) error {
+	for i, w := range hooks {
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhooks[%d]: url must be an absolute http:// or https:// URL, got %q", i, redactURL(w.URL))
+		}
+		for _, e := range w.Events {
+			if !validAlertEvent(e) {
+				return fmt.Errorf("webhooks[%d]: unknown event %q", i, e)
+			}
+		}
+	}
+	return nil
+}
+
+func (w Webhook) name() string { return "Webhook " + redactURL(w.URL) }
+
+func (w Webhook) wants(ev alertEvent) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == ev.Event {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver POSTs ev, retrying once after webhookRetryDelay.
+func (w Webhook) deliver(ev alertEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if err = w.post(body); err == nil {
+		return nil
+	}
+	time.Sleep(webhookRetryDelay)
+	return w.post(body)
+}
+
+func (w Webhook) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claude-monitor")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Timeout: webhookTimeout}).Do(req)
+	if err != nil {
+		// The error repeats the URL, query and all
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookPayload(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		var m map[string]any
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Errorf("payload isn't JSON: %s", body)
+		}
+		got <- m
+	}))
+	defer srv.Close()
+
+	now := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+	ev := bucketEvent(eventThresholdCrossed, "session", &UsageBucket{Utilization: 82, ResetsAt: "2026-05-04T14:00:00Z"}, now)
+	ev.Threshold = 80
+	hook := Webhook{URL: srv.URL + "/hook?token=secret", Headers: map[string]string{"Authorization": "Bearer x"}}
+	if err := hook.deliver(ev); err != nil {
+		t.Fatal(err)
+	}
+	m := <-got
+	want := map[string]any{
+		"event":       "threshold_crossed",
+		"bucket":      "session",
+		"utilization": 82.0,
+		"threshold":   80.0,
+		"resets_at":   "2026-05-04T14:00:00Z",
+		"timestamp":   "2026-05-04T12:00:00Z",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s = %v, want %v", k, m[k], v)
+		}
+	}
+	if len(m) != len(want) {
+		t.Errorf("payload has extra fields: %v", m)
+	}
+	if auth != "Bearer x" {
+		t.Errorf("Authorization header %q", auth)
+	}
+	if name := hook.name(); strings.Contains(name, "secret") {
+		t.Errorf("name shows the query: %s", name)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	saved := webhookRetryDelay
+	t.Cleanup(func() { webhookRetryDelay = saved })
+	webhookRetryDelay = 0
+
+	calls, failures := 0, 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	if err := (Webhook{URL: srv.URL}).deliver(alertEvent{Event: eventRecovered}); err != nil || calls != 2 {
+		t.Errorf("retry: %v after %d calls", err, calls)
+	}
+
+	calls, failures = 0, 2
+	if err := (Webhook{URL: srv.URL}).deliver(alertEvent{Event: eventRecovered}); err == nil || calls != 2 {
+		t.Errorf("no error after two failures: %v, %d calls", err, calls)
+	}
+}
+
+func TestDispatchEventsDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	delivered := make(chan string, 2)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev alertEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		delivered <- ev.Event
+	}))
+	defer fast.Close()
+
+	cfg := &Config{Webhooks: []Webhook{
+		{URL: slow.URL},
+		{URL: fast.URL, Events: []string{eventAuthError}},
+	}}
+	start := time.Now()
+	dispatchEvents(cfg, []alertEvent{{Event: eventRecovered}, {Event: eventAuthError}})
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("dispatchEvents took %v", d)
+	}
+	// The slow webhook doesn't hold up the other, which takes only its event
+	select {
+	case ev := <-delivered:
+		if ev != eventAuthError {
+			t.Errorf("filtered webhook got %s", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the fast webhook waited for the slow one")
+	}
+}
+
+func TestOutcomeEvents(t *testing.T) {
+	now := time.Now()
+	unauthorized := &ErrHTTP{StatusCode: 401, Msg: "HTTP 401"}
+	for _, tc := range []struct {
+		prevKind string
+		err      error
+		want     string
+	}{
+		{"", nil, ""},
+		{"HTTP 401", nil, eventRecovered},
+		{"", unauthorized, "update_failed auth_error"},
+		{"HTTP 401", unauthorized, ""}, // still failing: once per streak
+		{"network", unauthorized, eventAuthError},
+		{"", &ErrCloudflare{Msg: "HTTP 403"}, eventUpdateFailed},
+		{"Cloudflare 403", errors.New("EOF"), ""},
+	} {
+		var names []string
+		for _, ev := range outcomeEvents(tc.prevKind, tc.err, now) {
+			names = append(names, ev.Event)
+		}
+		if got := strings.Join(names, " "); got != tc.want {
+			t.Errorf("%q then %v: %q, want %q", tc.prevKind, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	for _, tc := range []struct {
+		hooks []Webhook
+		ok    bool
+	}{
+		{[]Webhook{{URL: "https://example.com/hook"}}, true},
+		{[]Webhook{{URL: "http://10.0.0.5:8123/api/webhook/x", Events: []string{"threshold_crossed", "recovered"}}}, true},
+		{[]Webhook{{URL: "example.com/hook"}}, false},
+		{[]Webhook{{URL: "https://example.com", Events: []string{"limit_hit"}}}, false},
+	} {
+		if err := validateWebhooks(tc.hooks); (err == nil) != tc.ok {
+			t.Errorf("%+v: %v", tc.hooks, err)
+		}
+	}
+	if got := redactURL("https://user:pw@example.com/hook?token=s#x"); got != "https://example.com/hook" {
+		t.Errorf("redacted: %s", got)
+	}
+}